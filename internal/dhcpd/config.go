@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
@@ -28,6 +29,14 @@ type ServerConfig struct {
 	// when LocalDomainName is "lan".
 	LocalDomainName string `yaml:"local_domain_name"`
 
+	// DNSRegistrar, if not nil, is used to register the hostnames of the
+	// leases within LocalDomainName when the leases are granted, and to
+	// deregister them when the leases are removed.
+	//
+	// NOTE: AdGuard Home itself doesn't set it, since its DNS server resolves
+	// the hostnames of the leases using [Interface.SetOnLeaseChanged].
+	DNSRegistrar DNSRegistrar `yaml:"-"`
+
 	Conf4 V4ServerConf `yaml:"dhcpv4"`
 	Conf6 V6ServerConf `yaml:"dhcpv6"`
 
@@ -68,6 +77,10 @@ type DHCPServer interface {
 	// Stop - stop server
 	Stop() (err error)
 	getLeasesRef() []*Lease
+
+	// utilization returns the utilization of the address pool at now or nil,
+	// if the server isn't configured.
+	utilization(now time.Time) (u *Utilization)
 }
 
 // V4ServerConf - server configuration
@@ -102,6 +115,20 @@ type V4ServerConf struct {
 	//     DEC_CODE ip IP_ADDR
 	Options []string `yaml:"options" json:"-"`
 
+	// VendorOptions are the sets of custom options sent only to the clients
+	// with a matching vendor class identifier, option 60.  Those are merged
+	// over Options in the order of declaration.
+	VendorOptions []*VendorOptionSet `yaml:"vendor_options" json:"-"`
+
+	// DomainSearch are the domains sent in the Domain Search Option, option
+	// 119, after the local domain name.  The option may be overridden by
+	// Options.
+	DomainSearch []string `yaml:"domain_search" json:"-"`
+
+	// localDomainName is the local domain name, which is the first domain of
+	// the Domain Search Option.
+	localDomainName string
+
 	ipRange *ipRange
 
 	leaseTime  time.Duration // the time during which a dynamic lease is considered valid
@@ -120,6 +147,47 @@ type V4ServerConf struct {
 	notify func(uint32)
 }
 
+// VendorOptionSet is a set of custom DHCPv4 options for the clients with a
+// particular vendor class identifier.
+type VendorOptionSet struct {
+	// Match is the pattern matched against the vendor class identifier of the
+	// client.  The pattern ending with "*" matches any identifier starting
+	// with the rest of it, otherwise the identifier should be equal to it.
+	Match string `yaml:"match"`
+
+	// Options are the custom options in the same format as
+	// [V4ServerConf.Options].
+	Options []string `yaml:"options"`
+}
+
+// validate returns an error if set is not a valid vendor option set.
+func (set *VendorOptionSet) validate() (err error) {
+	if set == nil {
+		return errors.Error("nil vendor option set")
+	}
+
+	defer func() { err = errors.Annotate(err, "vendor options for %q: %w", set.Match) }()
+
+	pat := strings.TrimSuffix(set.Match, "*")
+	if pat == "" {
+		return errors.Error("empty match pattern")
+	} else if strings.Contains(pat, "*") {
+		return errors.Error("wildcard is only allowed at the end of match pattern")
+	}
+
+	return nil
+}
+
+// matches returns true if the vendor class identifier vendor matches the
+// pattern of set.
+func (set *VendorOptionSet) matches(vendor string) (ok bool) {
+	if strings.HasSuffix(set.Match, "*") {
+		return strings.HasPrefix(vendor, set.Match[:len(set.Match)-1])
+	}
+
+	return vendor == set.Match
+}
+
 // errNilConfig is an error returned by validation method if the config is nil.
 const errNilConfig errors.Error = "nil config"
 
@@ -206,6 +274,13 @@ func (c *V4ServerConf) Validate() (err error) {
 		)
 	}
 
+	for i, set := range c.VendorOptions {
+		err = set.validate()
+		if err != nil {
+			return fmt.Errorf("at index %d: %w", i, err)
+		}
+	}
+
 	return nil
 }
 
@@ -159,6 +159,16 @@ type Interface interface {
 	SetOnLeaseChanged(onLeaseChanged OnLeaseChangedT)
 	FindMACbyIP(ip netip.Addr) (mac net.HardwareAddr)
 
+	// FindLeasesByHostname returns the active and static leases with
+	// hostnames matching pattern, which is either a glob or a substring.  The
+	// matching is case-insensitive.
+	FindLeasesByHostname(pattern string) (leases []*Lease, err error)
+
+	// Utilization returns the utilization of the address pools of the DHCPv4
+	// and DHCPv6 servers.  v4 or v6 is nil if the corresponding server isn't
+	// configured.
+	Utilization() (v4, v6 *Utilization)
+
 	WriteDiskConfig(c *ServerConfig)
 }
 
@@ -166,13 +176,15 @@ type Interface interface {
 //
 // TODO(e.burkov):  Move to aghtest when the API stabilized.
 type MockInterface struct {
-	OnStart             func() (err error)
-	OnStop              func() (err error)
-	OnEnabled           func() (ok bool)
-	OnLeases            func(flags GetLeasesFlags) (leases []*Lease)
-	OnSetOnLeaseChanged func(f OnLeaseChangedT)
-	OnFindMACbyIP       func(ip netip.Addr) (mac net.HardwareAddr)
-	OnWriteDiskConfig   func(c *ServerConfig)
+	OnStart                func() (err error)
+	OnStop                 func() (err error)
+	OnEnabled              func() (ok bool)
+	OnLeases               func(flags GetLeasesFlags) (leases []*Lease)
+	OnSetOnLeaseChanged    func(f OnLeaseChangedT)
+	OnFindMACbyIP          func(ip netip.Addr) (mac net.HardwareAddr)
+	OnFindLeasesByHostname func(pattern string) (leases []*Lease, err error)
+	OnUtilization          func() (v4, v6 *Utilization)
+	OnWriteDiskConfig      func(c *ServerConfig)
 }
 
 var _ Interface = (*MockInterface)(nil)
@@ -197,6 +209,14 @@ func (s *MockInterface) FindMACbyIP(ip netip.Addr) (mac net.HardwareAddr) {
 	return s.OnFindMACbyIP(ip)
 }
 
+// FindLeasesByHostname implements the [Interface] for *MockInterface.
+func (s *MockInterface) FindLeasesByHostname(pattern string) (leases []*Lease, err error) {
+	return s.OnFindLeasesByHostname(pattern)
+}
+
+// Utilization implements the [Interface] for *MockInterface.
+func (s *MockInterface) Utilization() (v4, v6 *Utilization) { return s.OnUtilization() }
+
 // WriteDiskConfig implements the Interface for *MockInterface.
 func (s *MockInterface) WriteDiskConfig(c *ServerConfig) { s.OnWriteDiskConfig(c) }
 
@@ -211,6 +231,10 @@ type server struct {
 
 	// Called when the leases DB is modified
 	onLeaseChanged []OnLeaseChangedT
+
+	// dnsRegistry registers the hostnames of the leases in DNS.  It's nil if
+	// the registration is disabled.
+	dnsRegistry *dnsRegistry
 }
 
 // type check
@@ -234,6 +258,10 @@ func Create(conf *ServerConfig) (s *server, err error) {
 		},
 	}
 
+	if conf.DNSRegistrar != nil {
+		s.dnsRegistry = newDNSRegistry(conf.DNSRegistrar, conf.LocalDomainName)
+	}
+
 	// TODO(e.burkov):  Don't register handlers, see TODO on
 	// [aghhttp.RegisterFunc].
 	s.registerHandlers()
@@ -274,6 +302,7 @@ func (s *server) setServers(conf *ServerConfig) (v4Enabled, v6Enabled bool, err
 	v4conf := conf.Conf4
 	v4conf.InterfaceName = s.conf.InterfaceName
 	v4conf.notify = s.onNotify
+	v4conf.localDomainName = s.conf.LocalDomainName
 	v4conf.Enabled = s.conf.Enabled && v4conf.RangeStart.IsValid()
 
 	s.srv4, err = v4Create(&v4conf)
@@ -320,11 +349,19 @@ func (s *server) resetLeases() (err error) {
 		}
 	}
 
+	s.dnsRegistry.sync(nil)
+
 	return s.dbStore()
 }
 
 // server calls this function after DB is updated
 func (s *server) onNotify(flags uint32) {
+	if flags == LeaseChangedRemovedAll {
+		s.dnsRegistry.sync(nil)
+	} else {
+		s.dnsRegistry.sync(s.Leases(LeasesAll))
+	}
+
 	if flags == LeaseChangedDBStore {
 		err := s.dbStore()
 		if err != nil {
@@ -408,3 +445,42 @@ func (s *server) FindMACbyIP(ip netip.Addr) (mac net.HardwareAddr) {
 func (s *server) AddStaticLease(l *Lease) error {
 	return s.srv4.AddStaticLease(l)
 }
+
+// MakeLeaseStatic converts the active dynamic lease for ip into a static lease
+// with the same IP address, MAC address, and hostname, and stores it in the
+// database.  It returns an error if there is no active dynamic lease for ip or
+// if the static lease can't be added, e.g. when ip is outside of the subnet or
+// conflicts with another static lease.  It's safe for concurrent use.
+func (s *server) MakeLeaseStatic(ip netip.Addr) (l *Lease, err error) {
+	ip = ip.Unmap()
+
+	srv := s.srv6
+	if ip.Is4() {
+		srv = s.srv4
+	}
+
+	for _, dl := range srv.GetLeases(LeasesDynamic) {
+		if dl.IP == ip {
+			l = dl
+
+			break
+		}
+	}
+
+	if l == nil {
+		return nil, fmt.Errorf("no active dynamic lease for ip %s", ip)
+	}
+
+	l.IsStatic = true
+	l.Expiry = time.Time{}
+
+	// AddStaticLease removes the dynamic lease, validates the new one, and
+	// notifies about the change, which stores the database.
+	err = srv.AddStaticLease(l)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	return l, nil
+}
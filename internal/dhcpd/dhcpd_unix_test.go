@@ -136,3 +136,234 @@ func cloneUDPAddr(a *net.UDPAddr) (clone *net.UDPAddr) {
 		Zone: a.Zone,
 	}
 }
+
+func TestServer_FindLeasesByHostname(t *testing.T) {
+	s, err := Create(&ServerConfig{
+		Enabled:        true,
+		Conf4:          *defaultV4ServerConf(),
+		DataDir:        t.TempDir(),
+		ConfigModified: func() {},
+	})
+	require.NoError(t, err)
+
+	for i, host := range []string{"kitchen-display", "living-room-tv", "kitchen-speaker"} {
+		err = s.AddStaticLease(&Lease{
+			Hostname: host,
+			HWAddr:   net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, byte(i)},
+			IP:       netip.AddrFrom4([4]byte{192, 168, 10, byte(150 + i)}),
+		})
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name       string
+		pattern    string
+		wantErrMsg string
+		want       []string
+	}{{
+		name:       "substring",
+		pattern:    "KITCHEN",
+		wantErrMsg: "",
+		want:       []string{"kitchen-display", "kitchen-speaker"},
+	}, {
+		name:       "glob",
+		pattern:    "*-tv",
+		wantErrMsg: "",
+		want:       []string{"living-room-tv"},
+	}, {
+		name:       "glob_exact",
+		pattern:    "kitchen-?isplay",
+		wantErrMsg: "",
+		want:       []string{"kitchen-display"},
+	}, {
+		name:       "no_match",
+		pattern:    "garage",
+		wantErrMsg: "",
+		want:       []string{},
+	}, {
+		name:       "empty",
+		pattern:    "",
+		wantErrMsg: "empty hostname pattern",
+		want:       nil,
+	}, {
+		name:       "bad_glob",
+		pattern:    "kitchen-[",
+		wantErrMsg: `bad hostname pattern "kitchen-[": syntax error in pattern`,
+		want:       nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			leases, fErr := s.FindLeasesByHostname(tc.pattern)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, fErr)
+			if tc.want == nil {
+				assert.Nil(t, leases)
+
+				return
+			}
+
+			hosts := make([]string, 0, len(leases))
+			for _, l := range leases {
+				hosts = append(hosts, l.Hostname)
+			}
+
+			assert.Equal(t, tc.want, hosts)
+		})
+	}
+}
+
+func TestServer_MakeLeaseStatic(t *testing.T) {
+	dataDir := t.TempDir()
+	conf := &ServerConfig{
+		Enabled:        true,
+		Conf4:          *defaultV4ServerConf(),
+		DataDir:        dataDir,
+		ConfigModified: func() {},
+	}
+
+	s, err := Create(conf)
+	require.NoError(t, err)
+
+	dynIP := netip.MustParseAddr("192.168.10.150")
+	dynLease := &Lease{
+		Expiry:   time.Now().Add(time.Hour),
+		Hostname: "kitchen-display",
+		HWAddr:   net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA},
+		IP:       dynIP,
+	}
+
+	srv4, ok := s.srv4.(*v4Server)
+	require.True(t, ok)
+
+	err = srv4.addLease(dynLease)
+	require.NoError(t, err)
+
+	t.Run("unknown", func(t *testing.T) {
+		_, mErr := s.MakeLeaseStatic(netip.MustParseAddr("192.168.10.151"))
+		testutil.AssertErrorMsg(t, "no active dynamic lease for ip 192.168.10.151", mErr)
+	})
+
+	l, err := s.MakeLeaseStatic(dynIP)
+	require.NoError(t, err)
+
+	assert.True(t, l.IsStatic)
+	assert.Equal(t, dynLease.Hostname, l.Hostname)
+	assert.Equal(t, dynLease.HWAddr, l.HWAddr)
+	assert.Equal(t, dynIP, l.IP)
+
+	assert.Empty(t, s.Leases(LeasesDynamic))
+
+	t.Run("already_static", func(t *testing.T) {
+		_, mErr := s.MakeLeaseStatic(dynIP)
+		testutil.AssertErrorMsg(t, "no active dynamic lease for ip 192.168.10.150", mErr)
+	})
+
+	t.Run("persisted", func(t *testing.T) {
+		loaded, cErr := Create(conf)
+		require.NoError(t, cErr)
+
+		static := loaded.Leases(LeasesStatic)
+		require.Len(t, static, 1)
+
+		assert.Equal(t, dynIP, static[0].IP)
+		assert.Equal(t, dynLease.Hostname, static[0].Hostname)
+	})
+}
+
+// testDNSRegistrar is a [DNSRegistrar] for tests.
+type testDNSRegistrar struct {
+	onRegister   func(fqdn string, ip netip.Addr)
+	onDeregister func(fqdn string, ip netip.Addr)
+}
+
+// type check
+var _ DNSRegistrar = (*testDNSRegistrar)(nil)
+
+// Register implements the [DNSRegistrar] interface for *testDNSRegistrar.
+func (r *testDNSRegistrar) Register(fqdn string, ip netip.Addr) { r.onRegister(fqdn, ip) }
+
+// Deregister implements the [DNSRegistrar] interface for *testDNSRegistrar.
+func (r *testDNSRegistrar) Deregister(fqdn string, ip netip.Addr) { r.onDeregister(fqdn, ip) }
+
+func TestServer_DNSRegistrar(t *testing.T) {
+	type record struct {
+		fqdn string
+		ip   netip.Addr
+	}
+
+	var registered, deregistered []record
+	reg := &testDNSRegistrar{
+		onRegister: func(fqdn string, ip netip.Addr) {
+			registered = append(registered, record{fqdn: fqdn, ip: ip})
+		},
+		onDeregister: func(fqdn string, ip netip.Addr) {
+			deregistered = append(deregistered, record{fqdn: fqdn, ip: ip})
+		},
+	}
+
+	newServer := func(t *testing.T, r DNSRegistrar) (s *server) {
+		t.Helper()
+
+		registered, deregistered = nil, nil
+
+		s, err := Create(&ServerConfig{
+			Enabled:         true,
+			Conf4:           *defaultV4ServerConf(),
+			DataDir:         t.TempDir(),
+			ConfigModified:  func() {},
+			LocalDomainName: "lan",
+			DNSRegistrar:    r,
+		})
+		require.NoError(t, err)
+
+		return s
+	}
+
+	ip := netip.MustParseAddr("192.168.10.150")
+	lease := &Lease{
+		Hostname: "printer",
+		HWAddr:   net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA},
+		IP:       ip,
+	}
+	want := []record{{fqdn: "printer.lan", ip: ip}}
+
+	t.Run("lifecycle", func(t *testing.T) {
+		s := newServer(t, reg)
+
+		err := s.srv4.AddStaticLease(lease.Clone())
+		require.NoError(t, err)
+
+		assert.Equal(t, want, registered)
+		assert.Empty(t, deregistered)
+
+		err = s.srv4.RemoveStaticLease(lease.Clone())
+		require.NoError(t, err)
+
+		assert.Equal(t, want, registered)
+		assert.Equal(t, want, deregistered)
+	})
+
+	t.Run("removed_all", func(t *testing.T) {
+		s := newServer(t, reg)
+
+		err := s.srv4.AddStaticLease(lease.Clone())
+		require.NoError(t, err)
+
+		s.onNotify(LeaseChangedRemovedAll)
+		assert.Equal(t, want, deregistered)
+
+		err = s.resetLeases()
+		require.NoError(t, err)
+
+		assert.Len(t, deregistered, 1)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := newServer(t, nil)
+
+		err := s.srv4.AddStaticLease(lease.Clone())
+		require.NoError(t, err)
+
+		assert.Empty(t, registered)
+	})
+}
@@ -0,0 +1,89 @@
+package dhcpd
+
+import (
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// DNSRegistrar registers the hostnames of DHCP clients in DNS so that they can
+// be resolved.
+type DNSRegistrar interface {
+	// Register makes fqdn resolvable into ip with A or AAAA queries and ip
+	// resolvable into fqdn with PTR queries.
+	Register(fqdn string, ip netip.Addr)
+
+	// Deregister removes the records previously added by Register.
+	Deregister(fqdn string, ip netip.Addr)
+}
+
+// dnsRegistry keeps track of the leases registered in DNS.  It is safe for
+// concurrent use.
+type dnsRegistry struct {
+	// mu protects registered.  It's held while calling the registrar, so that
+	// the calls are made in the order of the lease changes.
+	mu *sync.Mutex
+
+	// registrar is the actual registrar of the DNS records.
+	registrar DNSRegistrar
+
+	// registered maps the IP addresses of the registered leases to their
+	// fully-qualified domain names.
+	registered map[netip.Addr]string
+
+	// domain is the local domain name appended to the hostnames.
+	domain string
+}
+
+// newDNSRegistry returns a new DNS registry using r.  r must not be nil.
+func newDNSRegistry(r DNSRegistrar, domain string) (reg *dnsRegistry) {
+	return &dnsRegistry{
+		mu:         &sync.Mutex{},
+		registrar:  r,
+		registered: map[netip.Addr]string{},
+		domain:     domain,
+	}
+}
+
+// fqdn returns the fully-qualified domain name for hostname.
+func (reg *dnsRegistry) fqdn(hostname string) (fqdn string) {
+	fqdn = strings.ToLower(hostname)
+	if reg.domain != "" {
+		fqdn += "." + reg.domain
+	}
+
+	return fqdn
+}
+
+// sync registers the records for leases, which aren't registered yet, and
+// deregisters the ones, leases for which are gone or have changed their
+// hostnames.  reg may be nil.
+func (reg *dnsRegistry) sync(leases []*Lease) {
+	if reg == nil {
+		return
+	}
+
+	want := make(map[netip.Addr]string, len(leases))
+	for _, l := range leases {
+		if l.Hostname != "" {
+			want[l.IP] = reg.fqdn(l.Hostname)
+		}
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for ip, fqdn := range reg.registered {
+		if want[ip] != fqdn {
+			reg.registrar.Deregister(fqdn, ip)
+			delete(reg.registered, ip)
+		}
+	}
+
+	for ip, fqdn := range want {
+		if reg.registered[ip] != fqdn {
+			reg.registrar.Register(fqdn, ip)
+			reg.registered[ip] = fqdn
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package dhcpd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"golang.org/x/exp/slices"
+)
+
+// hostnameMatcher matches the hostnames of the leases against a pattern.  The
+// matching is case-insensitive.
+type hostnameMatcher struct {
+	// pattern is the lowercased pattern.
+	pattern string
+
+	// isGlob is true if pattern contains any of the glob metacharacters.
+	isGlob bool
+}
+
+// newHostnameMatcher returns a new properly initialized *hostnameMatcher.
+// pattern is a glob, as accepted by [path.Match], if it contains any of the
+// glob metacharacters, and a substring otherwise.  pattern must not be empty.
+func newHostnameMatcher(pattern string) (m *hostnameMatcher, err error) {
+	if pattern == "" {
+		return nil, errors.Error("empty hostname pattern")
+	}
+
+	m = &hostnameMatcher{
+		pattern: strings.ToLower(pattern),
+		isGlob:  strings.ContainsAny(pattern, `*?[\`),
+	}
+
+	if m.isGlob {
+		// Check the pattern once, since path.Match only reports an error
+		// when it reaches the malformed part of the pattern.
+		_, err = path.Match(m.pattern, "")
+		if err != nil {
+			return nil, fmt.Errorf("bad hostname pattern %q: %w", pattern, err)
+		}
+	}
+
+	return m, nil
+}
+
+// match returns true if hostname matches the pattern of m.
+func (m *hostnameMatcher) match(hostname string) (ok bool) {
+	if hostname == "" {
+		return false
+	}
+
+	hostname = strings.ToLower(hostname)
+	if !m.isGlob {
+		return strings.Contains(hostname, m.pattern)
+	}
+
+	// The pattern has already been checked in newHostnameMatcher.
+	ok, _ = path.Match(m.pattern, hostname)
+
+	return ok
+}
+
+// FindLeasesByHostname returns the active and static leases with hostnames
+// matching pattern, sorted by hostname.  pattern is a glob, e.g.
+// "kitchen-*", if it contains any of the glob metacharacters, and a substring
+// otherwise.  The matching is case-insensitive.  It's safe for concurrent use.
+//
+// NOTE: It's a linear scan over all the leases, since neither globs nor
+// substrings can be looked up in an index by hostname, and there are usually
+// few leases.
+func (s *server) FindLeasesByHostname(pattern string) (leases []*Lease, err error) {
+	m, err := newHostnameMatcher(pattern)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	// The slice shouldn't be nil, since the front-end requires a non-nil
+	// value in the response.
+	leases = []*Lease{}
+	for _, l := range s.Leases(LeasesAll) {
+		if m.match(l.Hostname) {
+			leases = append(leases, l)
+		}
+	}
+
+	slices.SortStableFunc(leases, func(a, b *Lease) (less bool) {
+		return a.Hostname < b.Hostname
+	})
+
+	return leases, nil
+}
@@ -149,6 +149,47 @@ func (s *server) handleDHCPStatus(w http.ResponseWriter, r *http.Request) {
 	_ = aghhttp.WriteJSONResponse(w, r, status)
 }
 
+// dhcpUtilizationResponse is the response for the GET /control/dhcp/utilization
+// HTTP API.
+type dhcpUtilizationResponse struct {
+	// V4 is the utilization of the DHCPv4 address pool.  It's nil if the
+	// DHCPv4 server isn't configured.
+	V4 *Utilization `json:"v4"`
+
+	// V6 is the utilization of the DHCPv6 address pool.  It's nil if the
+	// DHCPv6 server isn't configured.
+	V6 *Utilization `json:"v6"`
+
+	IfaceName string `json:"interface_name"`
+}
+
+// handleDHCPUtilization is the handler for the GET /control/dhcp/utilization
+// HTTP API.
+func (s *server) handleDHCPUtilization(w http.ResponseWriter, r *http.Request) {
+	resp := &dhcpUtilizationResponse{
+		IfaceName: s.conf.InterfaceName,
+	}
+
+	resp.V4, resp.V6 = s.Utilization()
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
+
+// handleDHCPFindLeases is the handler for the GET /control/dhcp/find_leases
+// HTTP API.  The hostname query parameter is either a glob, e.g.
+// "kitchen-*", or a substring.
+func (s *server) handleDHCPFindLeases(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("hostname")
+	leases, err := s.FindLeasesByHostname(pattern)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "finding leases: %s", err)
+
+		return
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, leases)
+}
+
 func (s *server) enableDHCP(ifaceName string) (code int, err error) {
 	var hasStaticIP bool
 	hasStaticIP, err = aghnet.IfaceHasStaticIP(ifaceName)
@@ -220,15 +261,20 @@ func (s *server) handleDHCPSetConfigV4(
 
 	// Set the default values for the fields not configurable via web API.
 	c4 := &V4ServerConf{
-		notify:      s.onNotify,
-		ICMPTimeout: s.conf.Conf4.ICMPTimeout,
-		Options:     s.conf.Conf4.Options,
+		notify:        s.onNotify,
+		ICMPTimeout:   s.conf.Conf4.ICMPTimeout,
+		Options:       s.conf.Conf4.Options,
+		VendorOptions: s.conf.Conf4.VendorOptions,
+		DomainSearch:  s.conf.Conf4.DomainSearch,
 	}
 
 	s.srv4.WriteDiskConfig4(c4)
 	v4Conf.notify = c4.notify
 	v4Conf.ICMPTimeout = c4.ICMPTimeout
 	v4Conf.Options = c4.Options
+	v4Conf.VendorOptions = c4.VendorOptions
+	v4Conf.DomainSearch = c4.DomainSearch
+	v4Conf.localDomainName = s.conf.LocalDomainName
 
 	srv4, err := v4Create(v4Conf)
 
@@ -351,6 +397,184 @@ func (s *server) setConfFromJSON(conf *dhcpServerConfigJSON, srv4, srv6 DHCPServ
 	}
 }
 
+// dhcpInterfaceJSON is the request for the POST /control/dhcp/interface/enable
+// and POST /control/dhcp/interface/disable HTTP APIs.
+type dhcpInterfaceJSON struct {
+	InterfaceName string `json:"interface_name"`
+}
+
+// decodeDHCPInterface decodes the request for the interface enabling and
+// disabling HTTP APIs.  If ok is false, the error response has already been
+// written.
+func decodeDHCPInterface(w http.ResponseWriter, r *http.Request) (ifaceName string, ok bool) {
+	req := &dhcpInterfaceJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to parse json: %s", err)
+
+		return "", false
+	}
+
+	if req.InterfaceName == "" {
+		aghhttp.Error(r, w, http.StatusBadRequest, "interface_name: empty value")
+
+		return "", false
+	}
+
+	return req.InterfaceName, true
+}
+
+// serversForIface returns DHCPv4 and DHCPv6 servers created from the current
+// configuration of s for the network interface with the given name.  err is
+// not nil if enabled is true and the configuration isn't valid for that
+// interface.
+func (s *server) serversForIface(
+	ifaceName string,
+	enabled bool,
+) (srv4, srv6 DHCPServer, err error) {
+	v4Conf := &V4ServerConf{
+		notify:        s.onNotify,
+		ICMPTimeout:   s.conf.Conf4.ICMPTimeout,
+		Options:       s.conf.Conf4.Options,
+		VendorOptions: s.conf.Conf4.VendorOptions,
+		DomainSearch:  s.conf.Conf4.DomainSearch,
+	}
+	s.srv4.WriteDiskConfig4(v4Conf)
+	v4Conf.InterfaceName = ifaceName
+	v4Conf.notify = s.onNotify
+	v4Conf.localDomainName = s.conf.LocalDomainName
+	v4Conf.Enabled = enabled && v4Conf.RangeStart.IsValid()
+
+	srv4, err = v4Create(v4Conf)
+	if err != nil && v4Conf.Enabled {
+		return nil, nil, fmt.Errorf("bad dhcpv4 configuration: %s", err)
+	}
+
+	v6Conf := V6ServerConf{
+		RASLAACOnly:  s.conf.Conf6.RASLAACOnly,
+		RAAllowSLAAC: s.conf.Conf6.RAAllowSLAAC,
+	}
+	s.srv6.WriteDiskConfig6(&v6Conf)
+	v6Conf.InterfaceName = ifaceName
+	v6Conf.notify = s.onNotify
+	v6Conf.Enabled = enabled && len(v6Conf.RangeStart) != 0
+
+	srv6, err = v6Create(v6Conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad dhcpv6 configuration: %s", err)
+	}
+
+	if enabled && !v4Conf.Enabled && !v6Conf.Enabled {
+		return nil, nil, fmt.Errorf("dhcpv4 or dhcpv6 configuration must be complete")
+	}
+
+	return srv4, srv6, nil
+}
+
+// handleDHCPInterfaceEnable is the handler for the POST
+// /control/dhcp/interface/enable HTTP API.
+//
+// Since only a single interface is supported, enabling DHCP on another one is
+// rejected until it's disabled on the current one.
+func (s *server) handleDHCPInterfaceEnable(w http.ResponseWriter, r *http.Request) {
+	ifaceName, ok := decodeDHCPInterface(w, r)
+	if !ok {
+		return
+	}
+
+	if s.conf.Enabled {
+		if s.conf.InterfaceName == ifaceName {
+			return
+		}
+
+		aghhttp.Error(
+			r,
+			w,
+			http.StatusConflict,
+			"dhcp is already enabled on interface %q",
+			s.conf.InterfaceName,
+		)
+
+		return
+	}
+
+	_, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "interface %q: %s", ifaceName, err)
+
+		return
+	}
+
+	srv4, srv6, err := s.serversForIface(ifaceName, true)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "interface %q: %s", ifaceName, err)
+
+		return
+	}
+
+	err = s.Stop()
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusInternalServerError, "stopping dhcp: %s", err)
+
+		return
+	}
+
+	s.srv4, s.srv6 = srv4, srv6
+	s.conf.InterfaceName = ifaceName
+	s.conf.Enabled = true
+	s.conf.ConfigModified()
+
+	err = s.dbLoad()
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusInternalServerError, "loading leases db: %s", err)
+
+		return
+	}
+
+	code, err := s.enableDHCP(ifaceName)
+	if err != nil {
+		aghhttp.Error(r, w, code, "enabling dhcp: %s", err)
+	}
+}
+
+// handleDHCPInterfaceDisable is the handler for the POST
+// /control/dhcp/interface/disable HTTP API.
+func (s *server) handleDHCPInterfaceDisable(w http.ResponseWriter, r *http.Request) {
+	ifaceName, ok := decodeDHCPInterface(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.conf.Enabled || s.conf.InterfaceName != ifaceName {
+		aghhttp.Error(r, w, http.StatusBadRequest, "dhcp isn't enabled on interface %q", ifaceName)
+
+		return
+	}
+
+	srv4, srv6, err := s.serversForIface(ifaceName, false)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusInternalServerError, "interface %q: %s", ifaceName, err)
+
+		return
+	}
+
+	err = s.Stop()
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusInternalServerError, "stopping dhcp: %s", err)
+
+		return
+	}
+
+	s.srv4, s.srv6 = srv4, srv6
+	s.conf.Enabled = false
+	s.conf.ConfigModified()
+
+	err = s.dbLoad()
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusInternalServerError, "loading leases db: %s", err)
+	}
+}
+
 type netInterfaceJSON struct {
 	Name         string       `json:"name"`
 	HardwareAddr string       `json:"hardware_address"`
@@ -608,6 +832,41 @@ func (s *server) handleDHCPAddStaticLease(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// makeStaticLeaseReq is the request for the POST
+// /control/dhcp/make_static_lease HTTP API.
+type makeStaticLeaseReq struct {
+	// IP is the address of the active dynamic lease to make static.
+	IP netip.Addr `json:"ip"`
+}
+
+// handleDHCPMakeStaticLease is the handler for the POST
+// /control/dhcp/make_static_lease HTTP API.  It responds with the added static
+// lease.
+func (s *server) handleDHCPMakeStaticLease(w http.ResponseWriter, r *http.Request) {
+	req := &makeStaticLeaseReq{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "json.Decode: %s", err)
+
+		return
+	}
+
+	if !req.IP.IsValid() {
+		aghhttp.Error(r, w, http.StatusBadRequest, "invalid IP")
+
+		return
+	}
+
+	l, err := s.MakeLeaseStatic(req.IP)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+		return
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, leasesToStatic([]*Lease{l})[0])
+}
+
 func (s *server) handleDHCPRemoveStaticLease(w http.ResponseWriter, r *http.Request) {
 	l := &leaseStatic{}
 	err := json.NewDecoder(r.Body).Decode(l)
@@ -672,9 +931,10 @@ func (s *server) handleReset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	v4conf := &V4ServerConf{
-		LeaseDuration: DefaultDHCPLeaseTTL,
-		ICMPTimeout:   DefaultDHCPTimeoutICMP,
-		notify:        s.onNotify,
+		LeaseDuration:   DefaultDHCPLeaseTTL,
+		ICMPTimeout:     DefaultDHCPTimeoutICMP,
+		localDomainName: s.conf.LocalDomainName,
+		notify:          s.onNotify,
 	}
 	s.srv4, _ = v4Create(v4conf)
 
@@ -704,9 +964,14 @@ func (s *server) registerHandlers() {
 
 	s.conf.HTTPRegister(http.MethodGet, "/control/dhcp/status", s.handleDHCPStatus)
 	s.conf.HTTPRegister(http.MethodGet, "/control/dhcp/interfaces", s.handleDHCPInterfaces)
+	s.conf.HTTPRegister(http.MethodGet, "/control/dhcp/utilization", s.handleDHCPUtilization)
+	s.conf.HTTPRegister(http.MethodGet, "/control/dhcp/find_leases", s.handleDHCPFindLeases)
 	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/set_config", s.handleDHCPSetConfig)
+	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/interface/enable", s.handleDHCPInterfaceEnable)
+	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/interface/disable", s.handleDHCPInterfaceDisable)
 	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/find_active_dhcp", s.handleDHCPFindActiveServer)
 	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/add_static_lease", s.handleDHCPAddStaticLease)
+	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/make_static_lease", s.handleDHCPMakeStaticLease)
 	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/remove_static_lease", s.handleDHCPRemoveStaticLease)
 	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/reset", s.handleReset)
 	s.conf.HTTPRegister(http.MethodPost, "/control/dhcp/reset_leases", s.handleResetLeases)
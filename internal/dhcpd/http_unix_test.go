@@ -10,6 +10,7 @@ import (
 	"net/netip"
 	"testing"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -157,3 +158,137 @@ func TestServer_handleDHCPStatus(t *testing.T) {
 	})
 	require.True(t, ok)
 }
+
+func TestServer_handleDHCPSetConfig_vendorOptions(t *testing.T) {
+	conf4 := defaultV4ServerConf()
+	conf4.VendorOptions = []*VendorOptionSet{{
+		Match:   "PXEClient*",
+		Options: []string{"66 text tftp.example"},
+	}}
+
+	s, err := Create(&ServerConfig{
+		Enabled:        true,
+		Conf4:          *conf4,
+		DataDir:        t.TempDir(),
+		ConfigModified: func() {},
+	})
+	require.NoError(t, err)
+
+	b := &bytes.Buffer{}
+	err = json.NewEncoder(b).Encode(&dhcpServerConfigJSON{
+		V4: &v4ServerConfJSON{
+			GatewayIP:     DefaultGatewayIP,
+			SubnetMask:    DefaultSubnetMask,
+			RangeStart:    DefaultRangeStart,
+			RangeEnd:      DefaultRangeEnd,
+			LeaseDuration: 3600,
+		},
+		Enabled: aghalg.NBFalse,
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/control/dhcp/set_config", b)
+	w := httptest.NewRecorder()
+
+	s.handleDHCPSetConfig(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	got := &V4ServerConf{}
+	s.srv4.WriteDiskConfig4(got)
+
+	assert.Equal(t, conf4.VendorOptions, got.VendorOptions)
+	assert.Equal(t, uint32(3600), got.LeaseDuration)
+}
+
+func TestServer_handleDHCPInterface(t *testing.T) {
+	const (
+		ifaceName = "test-iface"
+		otherName = "other-iface"
+	)
+
+	confModified := 0
+	s, err := Create(&ServerConfig{
+		Enabled:        true,
+		InterfaceName:  ifaceName,
+		Conf4:          *defaultV4ServerConf(),
+		DataDir:        t.TempDir(),
+		ConfigModified: func() { confModified++ },
+	})
+	require.NoError(t, err)
+
+	// newReq is a helper that returns a request with the given interface name.
+	newReq := func(t *testing.T, name string) (r *http.Request) {
+		b := &bytes.Buffer{}
+		err = json.NewEncoder(b).Encode(&dhcpInterfaceJSON{InterfaceName: name})
+		require.NoError(t, err)
+
+		r, err = http.NewRequest(http.MethodPost, "", b)
+		require.NoError(t, err)
+
+		return r
+	}
+
+	testCases := []struct {
+		handler     http.HandlerFunc
+		name        string
+		ifaceName   string
+		wantCode    int
+		wantEnabled bool
+	}{{
+		handler:     s.handleDHCPInterfaceEnable,
+		name:        "enable_empty",
+		ifaceName:   "",
+		wantCode:    http.StatusBadRequest,
+		wantEnabled: true,
+	}, {
+		handler:     s.handleDHCPInterfaceEnable,
+		name:        "enable_same",
+		ifaceName:   ifaceName,
+		wantCode:    http.StatusOK,
+		wantEnabled: true,
+	}, {
+		handler:     s.handleDHCPInterfaceEnable,
+		name:        "enable_other",
+		ifaceName:   otherName,
+		wantCode:    http.StatusConflict,
+		wantEnabled: true,
+	}, {
+		handler:     s.handleDHCPInterfaceDisable,
+		name:        "disable_other",
+		ifaceName:   otherName,
+		wantCode:    http.StatusBadRequest,
+		wantEnabled: true,
+	}, {
+		handler:     s.handleDHCPInterfaceDisable,
+		name:        "disable",
+		ifaceName:   ifaceName,
+		wantCode:    http.StatusOK,
+		wantEnabled: false,
+	}, {
+		handler:     s.handleDHCPInterfaceDisable,
+		name:        "disable_disabled",
+		ifaceName:   ifaceName,
+		wantCode:    http.StatusBadRequest,
+		wantEnabled: false,
+	}, {
+		handler:     s.handleDHCPInterfaceEnable,
+		name:        "enable_missing",
+		ifaceName:   otherName,
+		wantCode:    http.StatusBadRequest,
+		wantEnabled: false,
+	}}
+
+	for _, tc := range testCases {
+		ok := t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.handler(w, newReq(t, tc.ifaceName))
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantEnabled, s.Enabled())
+		})
+		require.True(t, ok)
+	}
+
+	assert.Equal(t, 1, confModified)
+	assert.Equal(t, ifaceName, s.conf.InterfaceName)
+}
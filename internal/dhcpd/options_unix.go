@@ -13,23 +13,26 @@ import (
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/stringutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/rfc1035label"
 )
 
 // The aliases for DHCP option types available for explicit declaration.
 //
 // TODO(e.burkov):  Add an option for classless routes.
 const (
-	typDel  = "del"
-	typBool = "bool"
-	typDur  = "dur"
-	typHex  = "hex"
-	typIP   = "ip"
-	typIPs  = "ips"
-	typText = "text"
-	typU8   = "u8"
-	typU16  = "u16"
+	typDel     = "del"
+	typBool    = "bool"
+	typDomains = "domains"
+	typDur     = "dur"
+	typHex     = "hex"
+	typIP      = "ip"
+	typIPs     = "ips"
+	typText    = "text"
+	typU8      = "u8"
+	typU16     = "u16"
 )
 
 // parseDHCPOptionHex parses a DHCP option as a hex-encoded string.
@@ -75,6 +78,20 @@ func parseDHCPOptionIPs(s string) (val dhcpv4.OptionValue, err error) {
 	return ips, nil
 }
 
+// parseDHCPOptionDomains parses a DHCP option as a comma-separated list of
+// domain names encoded as described in RFC 3397.
+func parseDHCPOptionDomains(s string) (val dhcpv4.OptionValue, err error) {
+	domains := strings.Split(s, ",")
+	for i, d := range domains {
+		err = netutil.ValidateDomainName(d)
+		if err != nil {
+			return nil, fmt.Errorf("parsing domain at index %d: %w", i, err)
+		}
+	}
+
+	return &rfc1035label.Labels{Labels: domains}, nil
+}
+
 // parseDHCPOptionDur parses a DHCP option as a duration in a human-readable
 // form.
 func parseDHCPOptionDur(s string) (val dhcpv4.OptionValue, err error) {
@@ -130,6 +147,8 @@ func parseDHCPOptionVal(typ, valStr string) (val dhcpv4.OptionValue, err error)
 		val, err = parseDHCPOptionBool(valStr)
 	case typDel:
 		val = dhcpv4.OptionGeneric{Data: nil}
+	case typDomains:
+		val, err = parseDHCPOptionDomains(valStr)
 	case typDur:
 		val, err = parseDHCPOptionDur(valStr)
 	case typHex:
@@ -163,6 +182,7 @@ func parseDHCPOptionVal(typ, valStr string) (val dhcpv4.OptionValue, err error)
 //   - 7  text http://192.168.1.1/wpad.dat
 //   - 8  u8   255
 //   - 9  u16  65535
+//   - 10 domains lan,example.com
 func parseDHCPOption(s string) (code dhcpv4.OptionCode, val dhcpv4.OptionValue, err error) {
 	defer func() { err = errors.Annotate(err, "invalid option string %q: %w", s) }()
 
@@ -194,6 +214,48 @@ func parseDHCPOption(s string) (code dhcpv4.OptionCode, val dhcpv4.OptionValue,
 	return dhcpv4.GenericOptionCode(code64), val, nil
 }
 
+// vendorOptions are the options parsed from a vendor option set.
+type vendorOptions struct {
+	// set is the vendor option set the options are parsed from.
+	set *VendorOptionSet
+
+	// opts are the parsed options.
+	opts dhcpv4.Options
+}
+
+// parseVendorOptions parses the options of each vendor option set.  Options
+// with bad option strings are skipped.
+func parseVendorOptions(sets []*VendorOptionSet) (vos []*vendorOptions) {
+	for _, set := range sets {
+		vo := &vendorOptions{
+			set:  set,
+			opts: dhcpv4.Options{},
+		}
+
+		for i, o := range set.Options {
+			code, val, err := parseDHCPOption(o)
+			if err != nil {
+				log.Error(
+					"dhcpv4: vendor options for %q: bad option string at index %d: %s",
+					set.Match,
+					i,
+					err,
+				)
+
+				continue
+			}
+
+			vo.opts.Update(dhcpv4.Option{Code: code, Value: val})
+		}
+
+		log.Debug("dhcpv4: vendor options for %q:\n%s", set.Match, vo.opts.Summary(nil))
+
+		vos = append(vos, vo)
+	}
+
+	return vos
+}
+
 // prepareOptions builds the set of DHCP options according to host requirements
 // document and values from conf.
 func (s *v4Server) prepareOptions() {
@@ -374,6 +436,14 @@ func (s *v4Server) prepareOptions() {
 		dhcpv4.OptSubnetMask(s.conf.SubnetMask.AsSlice()),
 	)
 
+	// Set the Domain Search Option so that the clients could resolve the
+	// unqualified hostnames within the local domain.
+	//
+	// See https://datatracker.ietf.org/doc/html/rfc3397.
+	if domains := s.domainSearchList(); len(domains) > 0 {
+		s.implicitOpts.Update(dhcpv4.OptDomainSearch(&rfc1035label.Labels{Labels: domains}))
+	}
+
 	// Set values for explicitly configured options.
 	s.explicitOpts = dhcpv4.Options{}
 	for i, o := range s.conf.Options {
@@ -395,4 +465,35 @@ func (s *v4Server) prepareOptions() {
 	if len(s.explicitOpts) == 0 {
 		s.explicitOpts = nil
 	}
+
+	s.vendorOpts = parseVendorOptions(s.conf.VendorOptions)
+}
+
+// domainSearchList returns the domain search list consisting of the local
+// domain name followed by the configured additional domains.  Invalid and
+// duplicate domains are skipped.
+func (s *v4Server) domainSearchList() (domains []string) {
+	set := stringutil.NewSet()
+	for i, d := range append([]string{s.conf.localDomainName}, s.conf.DomainSearch...) {
+		if d == "" {
+			continue
+		}
+
+		d = strings.ToLower(d)
+		if set.Has(d) {
+			continue
+		}
+
+		err := netutil.ValidateDomainName(d)
+		if err != nil {
+			log.Error("dhcpv4: bad domain search entry at index %d: %s", i, err)
+
+			continue
+		}
+
+		set.Add(d)
+		domains = append(domains, d)
+	}
+
+	return domains
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/rfc1035label"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -87,6 +88,12 @@ func TestParseOpt(t *testing.T) {
 		wantCode:   dhcpv4.GenericOptionCode(dhcpv4.OptionMaximumDatagramAssemblySize),
 		wantVal:    dhcpv4.Uint16(1234),
 		wantErrMsg: "",
+	}, {
+		name:       "domains_success",
+		in:         "119 domains lan,example.com",
+		wantCode:   dhcpv4.GenericOptionCode(dhcpv4.OptionDNSDomainSearchList),
+		wantVal:    &rfc1035label.Labels{Labels: []string{"lan", "example.com"}},
+		wantErrMsg: "",
 	}, {
 		name:       "bad_parts",
 		in:         "6 ip",
@@ -160,6 +167,13 @@ func TestParseOpt(t *testing.T) {
 		wantVal:  nil,
 		wantErrMsg: "invalid option string \"23 u16 65536\": decoding u16: " +
 			"strconv.ParseUint: parsing \"65536\": value out of range",
+	}, {
+		name:     "domains_error",
+		in:       "119 domains lan,",
+		wantCode: nil,
+		wantVal:  nil,
+		wantErrMsg: "invalid option string \"119 domains lan,\": parsing domain at " +
+			"index 1: bad domain name \"\": domain name is empty",
 	}}
 
 	for _, tc := range testCases {
@@ -266,3 +280,71 @@ func TestPrepareOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestV4Server_prepareOptions_domainSearch(t *testing.T) {
+	testCases := []struct {
+		name         string
+		wantImplicit *rfc1035label.Labels
+		wantExplicit dhcpv4.OptionValue
+		localDomain  string
+		domains      []string
+		opts         []string
+	}{{
+		name:         "none",
+		wantImplicit: nil,
+		wantExplicit: nil,
+		localDomain:  "",
+		domains:      nil,
+		opts:         nil,
+	}, {
+		name:         "local_domain",
+		wantImplicit: &rfc1035label.Labels{Labels: []string{"lan"}},
+		wantExplicit: nil,
+		localDomain:  "lan",
+		domains:      nil,
+		opts:         nil,
+	}, {
+		name: "extras",
+		wantImplicit: &rfc1035label.Labels{
+			Labels: []string{"lan", "example.com", "example.org"},
+		},
+		wantExplicit: nil,
+		localDomain:  "lan",
+		domains:      []string{"example.com", "LAN", "bad domain", "example.org"},
+		opts:         nil,
+	}, {
+		name:         "overridden",
+		wantImplicit: nil,
+		wantExplicit: &rfc1035label.Labels{Labels: []string{"home.arpa"}},
+		localDomain:  "lan",
+		domains:      []string{"example.com"},
+		opts:         []string{"119 domains home.arpa"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &v4Server{
+				conf: &V4ServerConf{
+					Options:         tc.opts,
+					DomainSearch:    tc.domains,
+					localDomainName: tc.localDomain,
+				},
+			}
+
+			s.prepareOptions()
+
+			code := dhcpv4.OptionDNSDomainSearchList
+			if tc.wantImplicit == nil {
+				assert.NotContains(t, s.implicitOpts, code.Code())
+			} else {
+				assert.Equal(t, tc.wantImplicit.ToBytes(), s.implicitOpts.Get(code))
+			}
+
+			if tc.wantExplicit == nil {
+				assert.Nil(t, s.explicitOpts.Get(code))
+			} else {
+				assert.Equal(t, tc.wantExplicit.ToBytes(), s.explicitOpts.Get(code))
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package dhcpd
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Utilization is the utilization of the address pool of a DHCP server.
+type Utilization struct {
+	// RangeStart is the first address of the pool.
+	RangeStart netip.Addr `json:"range_start"`
+
+	// RangeEnd is the last address of the pool.
+	RangeEnd netip.Addr `json:"range_end"`
+
+	// Total is the number of addresses in the pool.
+	Total uint64 `json:"total"`
+
+	// Active is the number of addresses in the pool held by the unexpired
+	// dynamic leases.
+	Active uint64 `json:"active"`
+
+	// Static is the number of addresses in the pool reserved by the static
+	// leases.
+	Static uint64 `json:"static"`
+
+	// Free is the number of addresses in the pool available for the new
+	// leases.  The addresses declined by the clients aren't free until the
+	// corresponding blocklisted leases expire.
+	Free uint64 `json:"free"`
+}
+
+// Utilization implements the [Interface] for *server.
+func (s *server) Utilization() (v4, v6 *Utilization) {
+	now := time.Now()
+
+	if s.srv4 != nil {
+		v4 = s.srv4.utilization(now)
+	}
+
+	if s.srv6 != nil {
+		v6 = s.srv6.utilization(now)
+	}
+
+	return v4, v6
+}
@@ -7,6 +7,7 @@ package dhcpd
 import (
 	"net"
 	"net/netip"
+	"time"
 )
 
 type winServer struct{}
@@ -24,6 +25,7 @@ func (winServer) WriteDiskConfig4(_ *V4ServerConf)                {}
 func (winServer) WriteDiskConfig6(_ *V6ServerConf)                {}
 func (winServer) Start() (err error)                              { return nil }
 func (winServer) Stop() (err error)                               { return nil }
+func (winServer) utilization(_ time.Time) (u *Utilization)        { return nil }
 
 func v4Create(_ *V4ServerConf) (s DHCPServer, err error) { return winServer{}, nil }
 func v6Create(_ V6ServerConf) (s DHCPServer, err error)  { return winServer{}, nil }
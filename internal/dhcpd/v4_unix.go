@@ -39,6 +39,11 @@ type v4Server struct {
 	// have intersections with [implicitOpts].
 	explicitOpts dhcpv4.Options
 
+	// vendorOpts are the options parsed from the vendor option sets of the
+	// configuration.  Those are merged over [explicitOpts] for the clients with
+	// the matching vendor class identifier.
+	vendorOpts []*vendorOptions
+
 	// leasesLock protects leases, leaseHosts, and leasedOffsets.
 	leasesLock sync.Mutex
 
@@ -197,6 +202,46 @@ func (s *v4Server) GetLeases(flags GetLeasesFlags) (leases []*Lease) {
 	return leases
 }
 
+// utilization implements the [DHCPServer] interface for *v4Server.
+func (s *v4Server) utilization(now time.Time) (u *Utilization) {
+	r := s.conf.ipRange
+	if r == nil {
+		return nil
+	}
+
+	u = &Utilization{
+		RangeStart: s.conf.RangeStart,
+		RangeEnd:   s.conf.RangeEnd,
+		Total:      r.len(),
+	}
+
+	var blocked uint64
+
+	s.leasesLock.Lock()
+	defer s.leasesLock.Unlock()
+
+	for _, l := range s.leases {
+		if !r.contains(l.IP.AsSlice()) {
+			continue
+		}
+
+		switch {
+		case l.IsStatic:
+			u.Static++
+		case !l.Expiry.After(now):
+			// The address of an expired lease may be reused.
+		case s.isBlocklisted(l):
+			blocked++
+		default:
+			u.Active++
+		}
+	}
+
+	u.Free = u.Total - u.Active - u.Static - blocked
+
+	return u
+}
+
 // FindMACbyIP implements the [Interface] for *v4Server.
 func (s *v4Server) FindMACbyIP(ip netip.Addr) (mac net.HardwareAddr) {
 	now := time.Now()
@@ -287,17 +332,18 @@ func (s *v4Server) rmDynamicLease(lease *Lease) (err error) {
 // non-unique hostname.
 const ErrDupHostname = errors.Error("hostname is not unique")
 
-// addLease adds a dynamic or static lease.
+// addLease adds a dynamic or static lease.  Static leases are validated
+// against the subnet and may be outside of the range of dynamic leases.
 func (s *v4Server) addLease(l *Lease) (err error) {
 	r := s.conf.ipRange
 	leaseIP := net.IP(l.IP.AsSlice())
 	offset, inOffset := r.offset(leaseIP)
 
 	if l.IsStatic {
-		// TODO(a.garipov, d.seregin): Subnet can be nil when dhcp server is
-		// disabled.
-		if sn := s.conf.subnet; !sn.Contains(l.IP) {
-			return fmt.Errorf("subnet %s does not contain the ip %q", sn, l.IP)
+		err = s.validateStaticIP(l.IP)
+		if err != nil {
+			// Don't wrap the error, because it's informative enough as is.
+			return err
 		}
 	} else if !inOffset {
 		return fmt.Errorf("lease %s (%s) out of range, not adding", l.IP, l.HWAddr)
@@ -312,7 +358,29 @@ func (s *v4Server) addLease(l *Lease) (err error) {
 	}
 
 	s.leases = append(s.leases, l)
-	s.leasedOffsets.set(offset, true)
+	if inOffset {
+		s.leasedOffsets.set(offset, true)
+	}
+
+	return nil
+}
+
+// validateStaticIP returns an error if ip can't be used for a static lease,
+// that is if it's outside of the subnet or is its network or broadcast
+// address.
+func (s *v4Server) validateStaticIP(ip netip.Addr) (err error) {
+	// TODO(a.garipov, d.seregin): Subnet can be nil when dhcp server is
+	// disabled.
+	sn := s.conf.subnet
+	if !sn.Contains(ip) {
+		return fmt.Errorf("subnet %s does not contain the ip %q", sn, ip)
+	}
+
+	if ip == sn.Masked().Addr() {
+		return fmt.Errorf("ip %q is the network address of subnet %s", ip, sn)
+	} else if ip == s.conf.broadcastIP {
+		return fmt.Errorf("ip %q is the broadcast address of subnet %s", ip, sn)
+	}
 
 	return nil
 }
@@ -879,9 +947,11 @@ func (s *v4Server) handleRequest(req, resp *dhcpv4.DHCPv4) (lease *Lease, needsR
 	return lease, needsReply
 }
 
-// handleDecline is the handler for the DHCP Decline request.
+// handleDecline is the handler for the DHCP Decline request.  The declined IP
+// address is considered used by another device, so it's blocklisted for the
+// lease duration and the client is given another one.
 func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
-	s.conf.notify(LeaseChangedDBStore)
+	defer s.conf.notify(LeaseChangedDBStore)
 
 	s.leasesLock.Lock()
 	defer s.leasesLock.Unlock()
@@ -904,6 +974,8 @@ func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
 		return fmt.Errorf("removing old lease for %s: %w", mac, err)
 	}
 
+	s.blocklistIP(oldLease.IP)
+
 	newLease, err := s.allocateLease(mac)
 	if err != nil {
 		return fmt.Errorf("allocating new lease for %s: %w", mac, err)
@@ -916,13 +988,8 @@ func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
 		return nil
 	}
 
-	newLease.Hostname = oldLease.Hostname
-	newLease.Expiry = time.Now().Add(s.conf.leaseTime)
-
-	err = s.addLease(newLease)
-	if err != nil {
-		return fmt.Errorf("adding new lease for %s: %w", mac, err)
-	}
+	// The new lease is already added by allocateLease, so only update it.
+	s.commitLease(newLease, oldLease.Hostname)
 
 	log.Info("dhcpv4: changed IP from %s to %s for %s", reqIP, newLease.IP, mac)
 
@@ -932,6 +999,24 @@ func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
 	return nil
 }
 
+// blocklistIP marks ip as unavailable for the lease duration, so that it isn't
+// offered to any client until then.  s.leasesLock is expected to be locked.
+func (s *v4Server) blocklistIP(ip netip.Addr) {
+	l := &Lease{
+		IP: ip,
+	}
+	s.blocklistLease(l)
+
+	err := s.addLease(l)
+	if err != nil {
+		log.Info("dhcpv4: blocklisting declined ip %s: %s", ip, err)
+
+		return
+	}
+
+	log.Info("dhcpv4: declined ip %s is blocklisted until %s", ip, l.Expiry)
+}
+
 // findLeaseForIP returns a lease for provided ip and mac.
 func (s *v4Server) findLeaseForIP(ip net.IP, mac net.HardwareAddr) (l *Lease) {
 	netIP, ok := netip.AddrFromSlice(ip)
@@ -974,7 +1059,7 @@ func (s *v4Server) handleRelease(req, resp *dhcpv4.DHCPv4) (err error) {
 	}
 
 	for _, l := range s.leases {
-		if !bytes.Equal(l.HWAddr, mac) || l.IP != netIP {
+		if l.IsStatic || !bytes.Equal(l.HWAddr, mac) || l.IP != netIP {
 			continue
 		}
 
@@ -1126,7 +1211,24 @@ func (s *v4Server) updateOptions(req, resp *dhcpv4.DHCPv4) {
 	// If the server has been explicitly configured with a default value for the
 	// parameter or the parameter has a non-default value on the client's
 	// subnet, the server MUST include that value in an appropriate option.
-	for code, val := range s.explicitOpts {
+	setExplicitOptions(resp, s.explicitOpts)
+
+	vendor := req.ClassIdentifier()
+	if vendor == "" {
+		return
+	}
+
+	for _, vo := range s.vendorOpts {
+		if vo.set.matches(vendor) {
+			setExplicitOptions(resp, vo.opts)
+		}
+	}
+}
+
+// setExplicitOptions sets the explicitly configured options to resp.  The
+// options with nil values are removed from resp.
+func setExplicitOptions(resp *dhcpv4.DHCPv4, opts dhcpv4.Options) {
+	for code, val := range opts {
 		if val != nil {
 			resp.Options[code] = val
 		} else {
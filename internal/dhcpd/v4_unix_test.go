@@ -219,6 +219,44 @@ func TestV4Server_AddRemove_static(t *testing.T) {
 		},
 		name:       "success",
 		wantErrMsg: "",
+	}, {
+		lease: &Lease{
+			Hostname: "outside-range.local",
+			HWAddr:   net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA},
+			IP:       netip.MustParseAddr("192.168.10.10"),
+		},
+		name:       "outside_range",
+		wantErrMsg: "",
+	}, {
+		lease: &Lease{
+			Hostname: "other-subnet.local",
+			HWAddr:   net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA},
+			IP:       netip.MustParseAddr("192.168.11.10"),
+		},
+		name: "other_subnet",
+		wantErrMsg: "dhcpv4: adding static lease: adding static lease for " +
+			"192.168.11.10 (aa:aa:aa:aa:aa:aa): " +
+			`subnet 192.168.10.1/24 does not contain the ip "192.168.11.10"`,
+	}, {
+		lease: &Lease{
+			Hostname: "network.local",
+			HWAddr:   net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA},
+			IP:       netip.MustParseAddr("192.168.10.0"),
+		},
+		name: "network",
+		wantErrMsg: "dhcpv4: adding static lease: adding static lease for " +
+			"192.168.10.0 (aa:aa:aa:aa:aa:aa): " +
+			`ip "192.168.10.0" is the network address of subnet 192.168.10.1/24`,
+	}, {
+		lease: &Lease{
+			Hostname: "broadcast.local",
+			HWAddr:   net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA},
+			IP:       netip.MustParseAddr("192.168.10.255"),
+		},
+		name: "broadcast",
+		wantErrMsg: "dhcpv4: adding static lease: adding static lease for " +
+			"192.168.10.255 (aa:aa:aa:aa:aa:aa): " +
+			`ip "192.168.10.255" is the broadcast address of subnet 192.168.10.1/24`,
 	}, {
 		lease: &Lease{
 			Hostname: "probably-router.local",
@@ -503,6 +541,120 @@ func TestV4Server_updateOptions(t *testing.T) {
 	}
 }
 
+func TestV4Server_updateOptions_vendor(t *testing.T) {
+	const (
+		pxeServer  = "tftp.example"
+		pxeFile    = "pxelinux.0"
+		voipServer = "voip.example"
+	)
+
+	conf := defaultV4ServerConf()
+	conf.VendorOptions = []*VendorOptionSet{{
+		Match: "PXEClient*",
+		Options: []string{
+			fmt.Sprintf("%d text %s", dhcpv4.OptionTFTPServerName, pxeServer),
+			fmt.Sprintf("%d text %s", dhcpv4.OptionBootfileName, pxeFile),
+		},
+	}, {
+		Match: "VoIP-Phone",
+		Options: []string{
+			fmt.Sprintf("%d text %s", dhcpv4.OptionTFTPServerName, voipServer),
+		},
+	}}
+
+	s, err := v4Create(conf)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		vendor     string
+		wantServer string
+		wantFile   string
+	}{{
+		name:       "pxe",
+		vendor:     "PXEClient:Arch:00000:UNDI:002001",
+		wantServer: pxeServer,
+		wantFile:   pxeFile,
+	}, {
+		name:       "voip",
+		vendor:     "VoIP-Phone",
+		wantServer: voipServer,
+		wantFile:   "",
+	}, {
+		name:       "voip_prefix",
+		vendor:     "VoIP-Phone-2",
+		wantServer: "",
+		wantFile:   "",
+	}, {
+		name:       "none",
+		vendor:     "",
+		wantServer: "",
+		wantFile:   "",
+	}}
+
+	for _, tc := range testCases {
+		var mods []dhcpv4.Modifier
+		if tc.vendor != "" {
+			mods = append(mods, dhcpv4.WithOption(dhcpv4.OptClassIdentifier(tc.vendor)))
+		}
+
+		req, err := dhcpv4.New(mods...)
+		require.NoError(t, err)
+
+		resp, err := dhcpv4.NewReplyFromRequest(req)
+		require.NoError(t, err)
+
+		t.Run(tc.name, func(t *testing.T) {
+			s.updateOptions(req, resp)
+
+			assert.Equal(t, tc.wantServer, resp.TFTPServerName())
+			assert.Equal(t, tc.wantFile, resp.BootFileNameOption())
+		})
+	}
+}
+
+func TestV4ServerConf_Validate_vendorOptions(t *testing.T) {
+	testCases := []struct {
+		name       string
+		match      string
+		wantErrMsg string
+	}{{
+		name:       "exact",
+		match:      "VoIP-Phone",
+		wantErrMsg: "",
+	}, {
+		name:       "prefix",
+		match:      "PXEClient*",
+		wantErrMsg: "",
+	}, {
+		name:  "empty",
+		match: "",
+		wantErrMsg: `dhcpv4: at index 0: vendor options for "": ` +
+			`empty match pattern`,
+	}, {
+		name:  "only_wildcard",
+		match: "*",
+		wantErrMsg: `dhcpv4: at index 0: vendor options for "*": ` +
+			`empty match pattern`,
+	}, {
+		name:  "inner_wildcard",
+		match: "PXE*Client",
+		wantErrMsg: `dhcpv4: at index 0: vendor options for "PXE*Client": ` +
+			`wildcard is only allowed at the end of match pattern`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := defaultV4ServerConf()
+			conf.VendorOptions = []*VendorOptionSet{{
+				Match: tc.match,
+			}}
+
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, conf.Validate())
+		})
+	}
+}
+
 func TestV4StaticLease_Get(t *testing.T) {
 	sIface := defaultSrv(t)
 
@@ -863,6 +1015,22 @@ func TestV4Server_handleDecline(t *testing.T) {
 	}
 
 	require.Equal(t, wantResp, resp)
+
+	require.Len(t, s4.leases, 2)
+
+	declined, newLease := s4.leases[0], s4.leases[1]
+	assert.Equal(t, dynamicIP, declined.IP)
+	assert.True(t, s4.isBlocklisted(declined))
+	assert.True(t, declined.Expiry.After(time.Now()))
+
+	assert.Equal(t, s4.conf.RangeStart, newLease.IP)
+	assert.Equal(t, dynamicMAC, newLease.HWAddr)
+	assert.Equal(t, dynamicName, newLease.Hostname)
+
+	offset, ok := s4.conf.ipRange.offset(net.IP(dynamicIP.AsSlice()))
+	require.True(t, ok)
+
+	assert.True(t, s4.leasedOffsets.isSet(offset))
 }
 
 func TestV4Server_handleRelease(t *testing.T) {
@@ -904,4 +1072,112 @@ func TestV4Server_handleRelease(t *testing.T) {
 	}
 
 	require.Equal(t, wantResp, resp)
+
+	assert.Empty(t, s4.leases)
+	assert.False(t, s4.leaseHosts.Has(dynamicName))
+
+	t.Run("static", func(t *testing.T) {
+		static := &Lease{
+			Hostname: anotherName,
+			HWAddr:   dynamicMAC,
+			IP:       dynamicIP,
+			IsStatic: true,
+		}
+		s4.leases = []*Lease{static}
+
+		resp = &dhcpv4.DHCPv4{}
+		err = s4.handleRelease(req, resp)
+		require.NoError(t, err)
+
+		assert.Equal(t, []*Lease{static}, s4.leases)
+	})
+}
+
+func TestV4Server_utilization(t *testing.T) {
+	now := time.Now()
+
+	s := defaultSrv(t)
+
+	s4, ok := s.(*v4Server)
+	require.True(t, ok)
+
+	s4.leases = []*Lease{{
+		// Static lease within the range.
+		HWAddr:   net.HardwareAddr{0x01, 0x01, 0x01, 0x01, 0x01, 0x01},
+		IP:       netip.MustParseAddr("192.168.10.100"),
+		IsStatic: true,
+	}, {
+		// Static lease out of the range.
+		HWAddr:   net.HardwareAddr{0x02, 0x02, 0x02, 0x02, 0x02, 0x02},
+		IP:       netip.MustParseAddr("192.168.10.10"),
+		IsStatic: true,
+	}, {
+		// Active dynamic lease.
+		Expiry: now.Add(time.Hour),
+		HWAddr: net.HardwareAddr{0x03, 0x03, 0x03, 0x03, 0x03, 0x03},
+		IP:     netip.MustParseAddr("192.168.10.101"),
+	}, {
+		// Expired dynamic lease.
+		Expiry: now.Add(-time.Hour),
+		HWAddr: net.HardwareAddr{0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+		IP:     netip.MustParseAddr("192.168.10.102"),
+	}, {
+		// Blocklisted lease.
+		Expiry: now.Add(time.Hour),
+		HWAddr: make(net.HardwareAddr, defaultHwAddrLen),
+		IP:     netip.MustParseAddr("192.168.10.103"),
+	}}
+
+	u := s4.utilization(now)
+	require.NotNil(t, u)
+
+	assert.Equal(t, &Utilization{
+		RangeStart: DefaultRangeStart,
+		RangeEnd:   DefaultRangeEnd,
+		Total:      101,
+		Active:     1,
+		Static:     1,
+		Free:       98,
+	}, u)
+}
+
+func TestV4Server_static_outsideRange(t *testing.T) {
+	s := testutil.RequireTypeAssert[*v4Server](t, defaultSrv(t))
+
+	mac := net.HardwareAddr{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}
+	staticIP := netip.MustParseAddr("192.168.10.10")
+
+	err := s.AddStaticLease(&Lease{
+		Hostname: "static.local",
+		HWAddr:   mac,
+		IP:       staticIP,
+	})
+	require.NoError(t, err)
+
+	t.Run("dynamic_range", func(t *testing.T) {
+		assert.Equal(t, net.IP(DefaultRangeStart.AsSlice()), s.nextIP())
+	})
+
+	t.Run("discover", func(t *testing.T) {
+		req, err := dhcpv4.NewDiscovery(mac)
+		require.NoError(t, err)
+
+		resp, err := dhcpv4.NewReplyFromRequest(req)
+		require.NoError(t, err)
+
+		l, err := s.handleDiscover(req, resp)
+		require.NoError(t, err)
+		require.NotNil(t, l)
+
+		assert.True(t, l.IsStatic)
+		assert.Equal(t, staticIP, l.IP)
+	})
+
+	t.Run("utilization", func(t *testing.T) {
+		u := s.utilization(time.Now())
+		require.NotNil(t, u)
+
+		assert.Zero(t, u.Static)
+		assert.Equal(t, u.Total, u.Free)
+	})
 }
@@ -46,6 +46,42 @@ func (s *v6Server) WriteDiskConfig6(c *V6ServerConf) {
 	*c = s.conf
 }
 
+// utilization implements the [DHCPServer] interface for *v6Server.
+func (s *v6Server) utilization(now time.Time) (u *Utilization) {
+	start, ok := netip.AddrFromSlice(s.conf.ipStart)
+	if !ok || !s.conf.Enabled {
+		return nil
+	}
+
+	end := start.As16()
+	end[15] = 0xff
+
+	u = &Utilization{
+		RangeStart: start,
+		RangeEnd:   netip.AddrFrom16(end),
+		Total:      uint64(0xff-s.conf.ipStart[15]) + 1,
+	}
+
+	s.leasesLock.Lock()
+	defer s.leasesLock.Unlock()
+
+	for _, l := range s.leases {
+		if !ip6InRange(s.conf.ipStart, l.IP.AsSlice()) {
+			continue
+		}
+
+		if l.IsStatic {
+			u.Static++
+		} else if l.Expiry.After(now) {
+			u.Active++
+		}
+	}
+
+	u.Free = u.Total - u.Active - u.Static
+
+	return u
+}
+
 // Return TRUE if IP address is within range [start..0xff]
 func ip6InRange(start, ip net.IP) bool {
 	if len(start) != 16 {
@@ -379,3 +379,43 @@ func TestV6_FindMACbyIP(t *testing.T) {
 		})
 	}
 }
+
+func TestV6Server_utilization(t *testing.T) {
+	now := time.Now()
+
+	sIface, err := v6Create(V6ServerConf{
+		Enabled:    true,
+		RangeStart: net.ParseIP("2001::1"),
+		notify:     notify6,
+	})
+	require.NoError(t, err)
+
+	s, ok := sIface.(*v6Server)
+	require.True(t, ok)
+
+	s.leases = []*Lease{{
+		HWAddr:   net.HardwareAddr{0x01, 0x01, 0x01, 0x01, 0x01, 0x01},
+		IP:       netip.MustParseAddr("2001::1"),
+		IsStatic: true,
+	}, {
+		Expiry: now.Add(time.Hour),
+		HWAddr: net.HardwareAddr{0x02, 0x02, 0x02, 0x02, 0x02, 0x02},
+		IP:     netip.MustParseAddr("2001::2"),
+	}, {
+		Expiry: now.Add(-time.Hour),
+		HWAddr: net.HardwareAddr{0x03, 0x03, 0x03, 0x03, 0x03, 0x03},
+		IP:     netip.MustParseAddr("2001::3"),
+	}}
+
+	u := s.utilization(now)
+	require.NotNil(t, u)
+
+	assert.Equal(t, &Utilization{
+		RangeStart: netip.MustParseAddr("2001::1"),
+		RangeEnd:   netip.MustParseAddr("2001::ff"),
+		Total:      255,
+		Active:     1,
+		Static:     1,
+		Free:       253,
+	}, u)
+}
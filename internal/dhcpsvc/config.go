@@ -17,6 +17,11 @@ type Config struct {
 	// clients' hostnames.
 	LocalDomainName string
 
+	// ISCLeasesFile is the optional path to the leases file of ISC dhcpd, e.g.
+	// "/var/lib/dhcp/dhcpd.leases", to import the leases from on the first
+	// start of the service, see [ImportISCLeases].
+	ISCLeasesFile string
+
 	// ICMPTimeout is the timeout for checking another DHCP server's presence.
 	ICMPTimeout time.Duration
 
@@ -34,6 +34,13 @@ type Lease struct {
 	IsStatic bool
 }
 
+// Interface is a DHCP service.
+//
+// The methods changing the leases accept a context, which bounds the time
+// spent on persisting the changes and checking the addresses for conflicts,
+// e.g. using ICMP, and allows to cancel those on shutdown.
+//
+// The other methods don't accept a context, since they don't do any I/O.
 type Interface interface {
 	agh.ServiceWithConfig[*Config]
 
@@ -59,20 +66,28 @@ type Interface interface {
 	// Leases returns all the DHCP leases.
 	Leases() (leases []*Lease)
 
+	// FindLeasesByHostname returns the DHCP leases with hostnames matching
+	// pattern.  pattern is a glob, e.g. "kitchen-*", if it contains any of the
+	// glob metacharacters, and a substring otherwise.  The matching is
+	// case-insensitive.  It returns an error if pattern is empty or malformed.
+	FindLeasesByHostname(pattern string) (leases []*Lease, err error)
+
 	// AddLease adds a new DHCP lease.  It returns an error if the lease is
-	// invalid or already exists.
-	AddLease(l *Lease) (err error)
+	// invalid or already exists, or if ctx is done before it's added.
+	AddLease(ctx context.Context, l *Lease) (err error)
 
 	// EditLease changes an existing DHCP lease.  It returns an error if there
-	// is no lease equal to old or if new is invalid or already exists.
-	EditLease(old, new *Lease) (err error)
+	// is no lease equal to old, if new is invalid or already exists, or if ctx
+	// is done before it's changed.
+	EditLease(ctx context.Context, old, new *Lease) (err error)
 
 	// RemoveLease removes an existing DHCP lease.  It returns an error if there
-	// is no lease equal to l.
-	RemoveLease(l *Lease) (err error)
+	// is no lease equal to l or if ctx is done before it's removed.
+	RemoveLease(ctx context.Context, l *Lease) (err error)
 
-	// Reset removes all the DHCP leases.
-	Reset() (err error)
+	// Reset removes all the DHCP leases.  It returns an error if ctx is done
+	// before those are removed.
+	Reset(ctx context.Context) (err error)
 }
 
 // Empty is an [Interface] implementation that does nothing.
@@ -107,14 +122,17 @@ func (Empty) IPByHost(_ string) (ip netip.Addr) { return netip.Addr{} }
 // Leases implements the [Interface] interface for Empty.
 func (Empty) Leases() (leases []*Lease) { return nil }
 
+// FindLeasesByHostname implements the [Interface] interface for Empty.
+func (Empty) FindLeasesByHostname(_ string) (leases []*Lease, err error) { return nil, nil }
+
 // AddLease implements the [Interface] interface for Empty.
-func (Empty) AddLease(_ *Lease) (err error) { return nil }
+func (Empty) AddLease(_ context.Context, _ *Lease) (err error) { return nil }
 
 // EditLease implements the [Interface] interface for Empty.
-func (Empty) EditLease(_, _ *Lease) (err error) { return nil }
+func (Empty) EditLease(_ context.Context, _, _ *Lease) (err error) { return nil }
 
 // RemoveLease implements the [Interface] interface for Empty.
-func (Empty) RemoveLease(_ *Lease) (err error) { return nil }
+func (Empty) RemoveLease(_ context.Context, _ *Lease) (err error) { return nil }
 
 // Reset implements the [Interface] interface for Empty.
-func (Empty) Reset() (err error) { return nil }
+func (Empty) Reset(_ context.Context) (err error) { return nil }
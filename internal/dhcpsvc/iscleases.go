@@ -0,0 +1,435 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"golang.org/x/exp/slices"
+)
+
+// ImportISCLeases reads the leases file of ISC dhcpd at conf.ISCLeasesFile and
+// returns the active leases and the host reservations from it, which fit the
+// IPv4 configurations of the interfaces in conf.  It's intended to seed the
+// lease table of the service on its first start, so that the clients keep their
+// addresses after the migration.  The leases, which don't fit, have expired by
+// now, or conflict with the already accepted ones are skipped.  leases are
+// sorted by IP address.
+func ImportISCLeases(conf *Config, now time.Time) (leases []*Lease, err error) {
+	if conf.ISCLeasesFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(conf.ISCLeasesFile)
+	if err != nil {
+		return nil, fmt.Errorf("dhcpsvc: reading isc leases: %w", err)
+	}
+
+	parsed, err := parseISCLeases(data)
+	if err != nil {
+		return nil, fmt.Errorf("dhcpsvc: parsing isc leases %q: %w", conf.ISCLeasesFile, err)
+	}
+
+	// Accept the reservations first, since they take precedence over the
+	// dynamic leases for the same addresses.
+	slices.SortStableFunc(parsed, func(a, b *Lease) (less bool) {
+		return a.IsStatic && !b.IsStatic
+	})
+
+	ips := map[netip.Addr]struct{}{}
+	macs := map[string]struct{}{}
+	for _, l := range parsed {
+		err = validateISCLease(conf, l, now)
+		if err == nil {
+			if _, ok := ips[l.IP]; ok {
+				err = errors.Error("address is already leased")
+			} else if _, ok = macs[string(l.HWAddr)]; ok {
+				err = errors.Error("hardware address already has a lease")
+			}
+		}
+
+		if err != nil {
+			log.Debug("dhcpsvc: skipping isc lease for %s (%s): %s", l.IP, l.HWAddr, err)
+
+			continue
+		}
+
+		ips[l.IP] = struct{}{}
+		macs[string(l.HWAddr)] = struct{}{}
+		leases = append(leases, l)
+	}
+
+	slices.SortFunc(leases, func(a, b *Lease) (less bool) {
+		return a.IP.Less(b.IP)
+	})
+
+	log.Info(
+		"dhcpsvc: imported %d isc leases from %q, skipped %d",
+		len(leases),
+		conf.ISCLeasesFile,
+		len(parsed)-len(leases),
+	)
+
+	return leases, nil
+}
+
+// validateISCLease returns an error if l doesn't fit any of the enabled IPv4
+// configurations of the interfaces in conf or if l has expired by now.  The
+// dynamic leases must be within the range of addresses, and the reservations
+// must be within the subnet.
+func validateISCLease(conf *Config, l *Lease, now time.Time) (err error) {
+	if len(l.HWAddr) == 0 {
+		return errors.Error("no hardware address")
+	} else if !l.IsStatic && !l.Expiry.IsZero() && !l.Expiry.After(now) {
+		return fmt.Errorf("expired at %s", l.Expiry.Format(time.RFC3339))
+	}
+
+	for _, iface := range conf.Interfaces {
+		c := iface.IPv4
+		if c == nil || !c.Enabled {
+			continue
+		}
+
+		if l.IsStatic && c.subnetContains(l.IP) {
+			return nil
+		} else if !l.IsStatic && c.rangeContains(l.IP) {
+			return nil
+		}
+	}
+
+	if l.IsStatic {
+		return errors.Error("reserved address is outside of the configured subnets")
+	}
+
+	return errors.Error("address is outside of the configured ranges")
+}
+
+// rangeContains returns true if ip is within the range of addresses of c.
+func (c *IPv4Config) rangeContains(ip netip.Addr) (ok bool) {
+	return c.RangeStart.Compare(ip) <= 0 && ip.Compare(c.RangeEnd) <= 0
+}
+
+// subnetContains returns true if ip is within the subnet of c and isn't the
+// address of the gateway, the network, or the broadcast.
+func (c *IPv4Config) subnetContains(ip netip.Addr) (ok bool) {
+	if !ip.Is4() || !c.GatewayIP.Is4() || !c.SubnetMask.Is4() || ip == c.GatewayIP {
+		return false
+	}
+
+	mask := c.SubnetMask.As4()
+	ones, bits := net.IPMask(mask[:]).Size()
+	if bits == 0 {
+		// The mask isn't canonical.
+		return false
+	}
+
+	subnet := netip.PrefixFrom(c.GatewayIP, ones).Masked()
+	if !subnet.Contains(ip) {
+		return false
+	}
+
+	network := subnet.Addr().As4()
+	var broadcast [4]byte
+	for i := range mask {
+		broadcast[i] = network[i] | ^mask[i]
+	}
+
+	return ip != subnet.Addr() && ip != netip.AddrFrom4(broadcast)
+}
+
+// parseISCLeases parses the contents of the leases file of ISC dhcpd.  The
+// later declarations of leases for the same address supersede the earlier
+// ones, as the file is appended on each change.  Only the active leases and
+// the host reservations are returned.  The zero expiry means that the lease
+// never expires.  The IPv6 leases and the unknown declarations are ignored.
+func parseISCLeases(data []byte) (leases []*Lease, err error) {
+	p := &iscParser{data: data}
+
+	dynamic := map[netip.Addr]*Lease{}
+	static := map[string]*Lease{}
+	for !p.done() {
+		var head []string
+		var body [][]string
+		head, body, err = p.declaration()
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return nil, err
+		}
+
+		if len(head) != 2 || body == nil {
+			continue
+		}
+
+		switch head[0] {
+		case "lease":
+			err = parseISCLease(dynamic, head[1], body)
+		case "host":
+			err = parseISCHost(static, head[1], body)
+		default:
+			// Ignore the IPv6 leases, the server DUID, and so on.
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", head[0], head[1], err)
+		}
+	}
+
+	for _, l := range dynamic {
+		leases = append(leases, l)
+	}
+
+	for _, l := range static {
+		leases = append(leases, l)
+	}
+
+	slices.SortFunc(leases, func(a, b *Lease) (less bool) {
+		return a.IP.Less(b.IP)
+	})
+
+	return leases, nil
+}
+
+// parseISCLease parses the lease declaration for the address ipStr with the
+// statements of body and stores it in leases, if it's active.
+func parseISCLease(leases map[netip.Addr]*Lease, ipStr string, body [][]string) (err error) {
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	l := &Lease{IP: ip}
+	active := true
+	for _, st := range body {
+		switch {
+		case len(st) == 3 && st[0] == "binding" && st[1] == "state":
+			active = st[2] == "active"
+		case len(st) >= 2 && st[0] == "ends":
+			l.Expiry, err = parseISCTime(st[1:])
+		case len(st) == 3 && st[0] == "hardware":
+			l.HWAddr, err = net.ParseMAC(st[2])
+		case len(st) == 2 && st[0] == "client-hostname":
+			l.Hostname = iscUnquote(st[1])
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", st[0], err)
+		}
+	}
+
+	if active {
+		leases[ip] = l
+	} else {
+		delete(leases, ip)
+	}
+
+	return nil
+}
+
+// parseISCHost parses the host declaration named name with the statements of
+// body and stores it in hosts, unless it's deleted.
+func parseISCHost(hosts map[string]*Lease, name string, body [][]string) (err error) {
+	l := &Lease{
+		Hostname: iscUnquote(name),
+		IsStatic: true,
+	}
+
+	for _, st := range body {
+		switch {
+		case len(st) == 1 && st[0] == "deleted":
+			delete(hosts, name)
+
+			return nil
+		case len(st) == 2 && st[0] == "fixed-address":
+			l.IP, err = netip.ParseAddr(st[1])
+		case len(st) == 3 && st[0] == "hardware":
+			l.HWAddr, err = net.ParseMAC(st[2])
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", st[0], err)
+		}
+	}
+
+	if !l.IP.IsValid() {
+		return errors.Error("no fixed address")
+	}
+
+	hosts[name] = l
+
+	return nil
+}
+
+// parseISCTime parses the time of a lease statement, which is either "never",
+// "epoch <seconds>", or "<weekday> <yyyy/mm/dd> <hh:mm:ss>" in UTC.  "never"
+// is returned as the zero time.
+func parseISCTime(fields []string) (t time.Time, err error) {
+	switch {
+	case len(fields) == 1 && fields[0] == "never":
+		return time.Time{}, nil
+	case len(fields) == 2 && fields[0] == "epoch":
+		var sec int64
+		sec, err = strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return time.Time{}, err
+		}
+
+		return time.Unix(sec, 0).UTC(), nil
+	case len(fields) == 3:
+		// Don't wrap the error since it's informative enough as is.
+		return time.Parse("2006/01/02 15:04:05", fields[1]+" "+fields[2])
+	default:
+		return time.Time{}, fmt.Errorf("bad time %q", strings.Join(fields, " "))
+	}
+}
+
+// iscUnquote returns s without the surrounding double quotes and with the
+// escape sequences decoded, if it's quoted.
+func iscUnquote(s string) (unquoted string) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	unquoted, err := strconv.Unquote(s)
+	if err != nil {
+		return s[1 : len(s)-1]
+	}
+
+	return unquoted
+}
+
+// iscParser is a parser of the declarations of the ISC dhcpd leases file.
+type iscParser struct {
+	// data is the yet unparsed part of the file.
+	data []byte
+}
+
+// done returns true if there are no more tokens.
+func (p *iscParser) done() (ok bool) {
+	p.skipSpace()
+
+	return len(p.data) == 0
+}
+
+// skipSpace skips the whitespace and the comments.
+func (p *iscParser) skipSpace() {
+	for len(p.data) > 0 {
+		switch c := p.data[0]; {
+		case c == '#':
+			i := bytes.IndexByte(p.data, '\n')
+			if i < 0 {
+				i = len(p.data) - 1
+			}
+
+			p.data = p.data[i+1:]
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.data = p.data[1:]
+		default:
+			return
+		}
+	}
+}
+
+// token returns the next token, which is either one of the punctuation
+// characters "{", "}", and ";", a quoted string with the quotes, or a
+// sequence of any other characters.  tok is empty if there are no more tokens.
+func (p *iscParser) token() (tok string, err error) {
+	if p.done() {
+		return "", nil
+	}
+
+	switch p.data[0] {
+	case '{', '}', ';':
+		tok, p.data = string(p.data[:1]), p.data[1:]
+
+		return tok, nil
+	case '"':
+		i := 1
+		for ; i < len(p.data) && p.data[i] != '"'; i++ {
+			if p.data[i] == '\\' {
+				i++
+			}
+		}
+
+		if i >= len(p.data) {
+			return "", errors.Error("unterminated string")
+		}
+
+		tok, p.data = string(p.data[:i+1]), p.data[i+1:]
+
+		return tok, nil
+	default:
+		i := bytes.IndexAny(p.data, " \t\r\n{};#\"")
+		if i < 0 {
+			i = len(p.data)
+		}
+
+		tok, p.data = string(p.data[:i]), p.data[i:]
+
+		return tok, nil
+	}
+}
+
+// statement returns the tokens of the next statement and the token, which has
+// terminated it, either ";" or "{".
+func (p *iscParser) statement() (toks []string, term string, err error) {
+	for {
+		var tok string
+		tok, err = p.token()
+		switch {
+		case err != nil:
+			// Don't wrap the error since it's informative enough as is.
+			return nil, "", err
+		case tok == "":
+			return nil, "", errors.Error("unexpected end of file")
+		case tok == ";", tok == "{", tok == "}":
+			return toks, tok, nil
+		default:
+			toks = append(toks, tok)
+		}
+	}
+}
+
+// declaration returns the head of the next top-level declaration and the
+// statements of its body, if it has one.  The nested declarations are skipped.
+func (p *iscParser) declaration() (head []string, body [][]string, err error) {
+	head, term, err := p.statement()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, nil, err
+	} else if term == "}" {
+		return nil, nil, errors.Error("unexpected }")
+	} else if term == ";" {
+		return head, nil, nil
+	}
+
+	body = [][]string{}
+	for depth := 1; depth > 0; {
+		var st []string
+		st, term, err = p.statement()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", strings.Join(head, " "), err)
+		}
+
+		switch term {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		default:
+			if depth == 1 {
+				body = append(body, st)
+			}
+		}
+	}
+
+	return head, body, nil
+}
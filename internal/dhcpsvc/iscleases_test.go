@@ -0,0 +1,159 @@
+package dhcpsvc_test
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testISCLeases is the contents of a leases file of ISC dhcpd.
+const testISCLeases = `# The format of this file is documented in the dhcpd.leases(5) manual page.
+# This lease file was written by isc-dhcp-4.4.1
+
+authoring-byte-order little-endian;
+server-duid "\000\001\000\001";
+
+lease 192.168.1.100 {
+  starts 4 2023/06/01 10:00:00;
+  ends 4 2023/06/01 12:00:00;
+  binding state active;
+  next binding state free;
+  hardware ethernet aa:aa:aa:aa:aa:01;
+  uid "\001\252\252\252\252\252\001";
+  client-hostname "laptop";
+}
+lease 192.168.1.100 {
+  starts 4 2023/06/01 12:00:00;
+  ends epoch 1685642400; # Thu Jun 01 18:00:00 2023
+  binding state active;
+  hardware ethernet aa:aa:aa:aa:aa:01;
+  client-hostname "laptop";
+}
+lease 192.168.1.101 {
+  ends 4 2023/06/01 18:00:00;
+  binding state free;
+  hardware ethernet aa:aa:aa:aa:aa:02;
+}
+lease 192.168.1.102 {
+  ends 4 2023/06/01 11:00:00;
+  binding state active;
+  hardware ethernet aa:aa:aa:aa:aa:03;
+}
+lease 10.0.0.5 {
+  ends never;
+  binding state active;
+  hardware ethernet aa:aa:aa:aa:aa:04;
+}
+lease 192.168.1.103 {
+  ends never;
+  binding state active;
+  hardware ethernet aa:aa:aa:aa:aa:05;
+}
+lease 192.168.1.104 {
+  ends never;
+  binding state active;
+  hardware ethernet aa:aa:aa:aa:aa:06;
+}
+host printer {
+  dynamic;
+  hardware ethernet aa:aa:aa:aa:aa:06;
+  fixed-address 192.168.1.10;
+}
+host old {
+  dynamic;
+  hardware ethernet aa:aa:aa:aa:aa:07;
+  fixed-address 192.168.1.11;
+}
+host old {
+  dynamic;
+  deleted;
+}
+ia-na "\001\000\000\000" {
+  cltt 4 2023/06/01 10:00:00;
+  iaaddr 2001:db8::1 {
+    binding state active;
+  }
+}
+`
+
+func TestImportISCLeases(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := &dhcpsvc.Config{
+		Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+			"eth0": {
+				IPv4: &dhcpsvc.IPv4Config{
+					GatewayIP:  netip.MustParseAddr("192.168.1.1"),
+					SubnetMask: netip.MustParseAddr("255.255.255.0"),
+					RangeStart: netip.MustParseAddr("192.168.1.100"),
+					RangeEnd:   netip.MustParseAddr("192.168.1.200"),
+					Enabled:    true,
+				},
+			},
+		},
+		ISCLeasesFile: filepath.Join(dir, "dhcpd.leases"),
+	}
+
+	err := os.WriteFile(conf.ISCLeasesFile, []byte(testISCLeases), 0o644)
+	require.NoError(t, err)
+
+	now := time.Date(2023, time.June, 1, 15, 0, 0, 0, time.UTC)
+	leases, err := dhcpsvc.ImportISCLeases(conf, now)
+	require.NoError(t, err)
+
+	mustParseMAC := func(s string) (mac net.HardwareAddr) {
+		mac, err = net.ParseMAC(s)
+		require.NoError(t, err)
+
+		return mac
+	}
+
+	want := []*dhcpsvc.Lease{{
+		IP:       netip.MustParseAddr("192.168.1.10"),
+		Hostname: "printer",
+		HWAddr:   mustParseMAC("aa:aa:aa:aa:aa:06"),
+		IsStatic: true,
+	}, {
+		IP:       netip.MustParseAddr("192.168.1.100"),
+		Expiry:   time.Date(2023, time.June, 1, 18, 0, 0, 0, time.UTC),
+		Hostname: "laptop",
+		HWAddr:   mustParseMAC("aa:aa:aa:aa:aa:01"),
+	}, {
+		IP:     netip.MustParseAddr("192.168.1.103"),
+		HWAddr: mustParseMAC("aa:aa:aa:aa:aa:05"),
+	}}
+
+	assert.Equal(t, want, leases)
+
+	t.Run("no_file", func(t *testing.T) {
+		leases, err = dhcpsvc.ImportISCLeases(&dhcpsvc.Config{}, now)
+		require.NoError(t, err)
+
+		assert.Empty(t, leases)
+	})
+
+	t.Run("bad_lease", func(t *testing.T) {
+		badConf := &dhcpsvc.Config{
+			ISCLeasesFile: filepath.Join(dir, "bad.leases"),
+		}
+
+		err = os.WriteFile(badConf.ISCLeasesFile, []byte("lease 1.2.3.4 {\n"), 0o644)
+		require.NoError(t, err)
+
+		_, err = dhcpsvc.ImportISCLeases(badConf, now)
+		testutil.AssertErrorMsg(
+			t,
+			`dhcpsvc: parsing isc leases "`+badConf.ISCLeasesFile+`": `+
+				`lease 1.2.3.4: unexpected end of file`,
+			err,
+		)
+	})
+}
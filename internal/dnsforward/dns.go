@@ -106,8 +106,10 @@ func (s *Server) handleDNSRequest(_ *proxy.Proxy, pctx *proxy.DNSContext) error
 	// (*proxy.Proxy).handleDNSRequest method performs it before calling the
 	// appropriate handler.
 	mods := []modProcessFunc{
+		s.processRatelimit,
 		s.processRecursion,
 		s.processInitial,
+		s.processClientRatelimit,
 		s.processDDRQuery,
 		s.processDetermineLocal,
 		s.processDHCPHosts,
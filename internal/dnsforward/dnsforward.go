@@ -109,6 +109,12 @@ type Server struct {
 	// See https://github.com/adguardTeam/adGuardHome/issues/3185#issuecomment-851048135.
 	recDetector *recursionDetector
 
+	// ratelimiter limits the plain UDP requests from the clients.  The rate
+	// limiting is performed here instead of the proxy, since the limit may be
+	// overridden for a particular client, including the clients that should
+	// be limited less strictly than the global limit.
+	ratelimiter ratelimiter
+
 	// dns64Pref is the NAT64 prefix used for DNS64 response mapping.  The major
 	// part of DNS64 happens inside the [proxy] package, but there still are
 	// some places where response mapping is needed (e.g. DHCP).
@@ -560,3 +560,86 @@ func TestServer_handleTestUpstreaDNS(t *testing.T) {
 		assert.True(t, strings.HasSuffix(sleepyRes, "i/o timeout"))
 	})
 }
+
+func TestServer_handleSetProtection(t *testing.T) {
+	const protectionURL = "/control/protection"
+
+	confModCh := make(chan struct{}, 1)
+	s := createTestServer(t, &filtering.Config{}, ServerConfig{
+		UDPListenAddrs: []*net.UDPAddr{},
+		TCPListenAddrs: []*net.TCPAddr{},
+		FilteringConfig: FilteringConfig{
+			ProtectionEnabled: true,
+			EDNSClientSubnet:  &EDNSClientSubnet{Enabled: false},
+		},
+		ConfigModified: func() {
+			select {
+			case confModCh <- struct{}{}:
+			default:
+			}
+		},
+	}, nil)
+
+	t.Run("enabled_with_duration", func(t *testing.T) {
+		r := httptest.NewRequest(
+			http.MethodPost,
+			protectionURL,
+			strings.NewReader(`{"enabled":true,"duration":1000}`),
+		)
+		w := httptest.NewRecorder()
+
+		s.handleSetProtection(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		enabled, until := s.UpdatedProtectionStatus()
+		assert.True(t, enabled)
+		assert.Nil(t, until)
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		r := httptest.NewRequest(
+			http.MethodPost,
+			protectionURL,
+			strings.NewReader(`{"enabled":false,"duration":60000}`),
+		)
+		w := httptest.NewRecorder()
+
+		s.handleSetProtection(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		enabled, until := s.UpdatedProtectionStatus()
+		assert.False(t, enabled)
+		require.NotNil(t, until)
+
+		assert.WithinDuration(t, time.Now().Add(time.Minute), *until, 5*time.Second)
+	})
+
+	t.Run("auto_resume", func(t *testing.T) {
+		past := time.Now().Add(-time.Second)
+		func() {
+			s.serverLock.Lock()
+			defer s.serverLock.Unlock()
+
+			s.conf.ProtectionEnabled = false
+			s.conf.ProtectionDisabledUntil = &past
+		}()
+
+		// Drain the notification from the previous subtest, if any.
+		select {
+		case <-confModCh:
+		default:
+		}
+
+		enabled, until := s.UpdatedProtectionStatus()
+		assert.True(t, enabled)
+		assert.Nil(t, until)
+
+		testutil.RequireReceive(t, confModCh, time.Second)
+
+		s.serverLock.RLock()
+		defer s.serverLock.RUnlock()
+
+		assert.True(t, s.conf.ProtectionEnabled)
+		assert.Nil(t, s.conf.ProtectionDisabledUntil)
+	})
+}
@@ -0,0 +1,206 @@
+package dnsforward
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"golang.org/x/exp/slices"
+)
+
+// ratelimitPruneIvl is the interval, after which the token buckets of the
+// clients, which haven't sent any requests since, are removed.
+const ratelimitPruneIvl = 1 * time.Minute
+
+// ratelimiter limits the rates of the requests from the clients using a token
+// bucket for each client address.  The limit of a client is learned from its
+// filtering settings, so that the requests exceeding the limit are dropped
+// before the settings are looked up.  The zero ratelimiter is ready for use.
+type ratelimiter struct {
+	// mu protects buckets and lastPrune.
+	mu sync.Mutex
+
+	// buckets are the token buckets of the client addresses.
+	buckets map[netip.Addr]*tokenBucket
+
+	// lastPrune is the time of the last removal of the idle buckets.
+	lastPrune time.Time
+}
+
+// tokenBucket is the token bucket of a single client.  It's refilled with limit
+// tokens per second up to limit tokens, and each request takes a token.
+type tokenBucket struct {
+	// last is the time of the last refill of the bucket.
+	last time.Time
+
+	// tokens is the number of requests the client may send right now.
+	tokens float64
+
+	// limit is the maximum number of requests per second from the client.
+	// Zero means that the client isn't limited.
+	limit uint32
+}
+
+// refill adds the tokens accumulated since the last refill.
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	b.last = now
+
+	capacity := float64(b.limit)
+	b.tokens += elapsed.Seconds() * capacity
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+}
+
+// bucket returns the token bucket of ip, creating it with defLimit if there is
+// none.  r.mu is expected to be locked.
+func (r *ratelimiter) bucket(ip netip.Addr, defLimit uint32, now time.Time) (b *tokenBucket) {
+	if r.buckets == nil {
+		r.buckets = map[netip.Addr]*tokenBucket{}
+		r.lastPrune = now
+	}
+
+	if now.Sub(r.lastPrune) >= ratelimitPruneIvl {
+		for bip, bb := range r.buckets {
+			if now.Sub(bb.last) >= ratelimitPruneIvl {
+				delete(r.buckets, bip)
+			}
+		}
+
+		r.lastPrune = now
+	}
+
+	b, ok := r.buckets[ip]
+	if !ok {
+		b = &tokenBucket{
+			last:   now,
+			tokens: float64(defLimit),
+			limit:  defLimit,
+		}
+		r.buckets[ip] = b
+	}
+
+	return b
+}
+
+// isLimited takes a token from the bucket of ip at now and returns true if
+// there are no tokens left.  defLimit is used as the limit of the clients,
+// which limits haven't been learned yet.
+func (r *ratelimiter) isLimited(ip netip.Addr, defLimit uint32, now time.Time) (ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.bucket(ip, defLimit, now)
+	if b.limit == 0 {
+		// Keep the bucket from being pruned while the client is active.
+		b.last = now
+
+		return false
+	}
+
+	b.refill(now)
+	if b.tokens < 1 {
+		return true
+	}
+
+	b.tokens--
+
+	return false
+}
+
+// setLimit sets the limit of the client with ip as learned from its filtering
+// settings.
+func (r *ratelimiter) setLimit(ip netip.Addr, limit uint32, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.bucket(ip, limit, now)
+	if b.limit == limit {
+		return
+	}
+
+	capacity := float64(limit)
+	if b.limit == 0 {
+		// The client hasn't been limited before, so start with a full bucket.
+		b.tokens = capacity
+		b.last = now
+	} else {
+		b.refill(now)
+	}
+
+	b.limit = limit
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+}
+
+// clientRatelimit returns the rate limit for the client with the filtering
+// settings setts.  Zero means that the client isn't limited.
+func (s *Server) clientRatelimit(setts *filtering.Settings) (limit uint32) {
+	switch {
+	case setts == nil || setts.Ratelimit == 0:
+		return s.conf.Ratelimit
+	case setts.Ratelimit < 0:
+		return 0
+	default:
+		return uint32(setts.Ratelimit)
+	}
+}
+
+// ratelimitAddr returns the address of the client to limit the rate of the
+// request from.  ok is false if the request shouldn't be limited, since only
+// the plain UDP requests from the clients outside of the rate limit allowlist
+// are limited.
+func (s *Server) ratelimitAddr(pctx *proxy.DNSContext) (ip netip.Addr, ok bool) {
+	if pctx.Proto != proxy.ProtoUDP {
+		return netip.Addr{}, false
+	}
+
+	ip = netutil.NetAddrToAddrPort(pctx.Addr).Addr()
+
+	return ip, !slices.Contains(s.conf.RatelimitWhitelist, ip.String())
+}
+
+// processRatelimit drops the plain UDP requests from the clients exceeding
+// their rate limit.  It must be called before the filtering settings of the
+// client are looked up, so that the dropped requests are as cheap as possible.
+func (s *Server) processRatelimit(dctx *dnsContext) (rc resultCode) {
+	log.Debug("dnsforward: started processing ratelimit")
+	defer log.Debug("dnsforward: finished processing ratelimit")
+
+	ip, ok := s.ratelimitAddr(dctx.proxyCtx)
+	if !ok {
+		return resultCodeSuccess
+	}
+
+	if s.ratelimiter.isLimited(ip, s.conf.Ratelimit, dctx.startTime) {
+		log.Debug("dnsforward: ratelimited %s", ip)
+
+		// Don't respond to the limited clients just like the proxy does.
+		return resultCodeFinish
+	}
+
+	return resultCodeSuccess
+}
+
+// processClientRatelimit records the rate limit of the client, which is either
+// the client-specific one or the global one, for the next requests from it to
+// be limited by [Server.processRatelimit].  It must be called after the
+// filtering settings of the client are looked up.
+func (s *Server) processClientRatelimit(dctx *dnsContext) (rc resultCode) {
+	ip, ok := s.ratelimitAddr(dctx.proxyCtx)
+	if ok {
+		s.ratelimiter.setLimit(ip, s.clientRatelimit(dctx.setts), dctx.startTime)
+	}
+
+	return resultCodeSuccess
+}
@@ -0,0 +1,126 @@
+package dnsforward
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_clientRatelimit(t *testing.T) {
+	const globalLimit = 20
+
+	s := &Server{
+		conf: ServerConfig{
+			FilteringConfig: FilteringConfig{
+				Ratelimit: globalLimit,
+			},
+		},
+	}
+
+	testCases := []struct {
+		setts *filtering.Settings
+		name  string
+		want  uint32
+	}{{
+		setts: nil,
+		name:  "no_settings",
+		want:  globalLimit,
+	}, {
+		setts: &filtering.Settings{Ratelimit: 0},
+		name:  "global",
+		want:  globalLimit,
+	}, {
+		setts: &filtering.Settings{Ratelimit: 100},
+		name:  "override",
+		want:  100,
+	}, {
+		setts: &filtering.Settings{Ratelimit: -1},
+		name:  "unlimited",
+		want:  0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, s.clientRatelimit(tc.setts))
+		})
+	}
+}
+
+func TestRatelimiter_isLimited(t *testing.T) {
+	const limit = 2
+
+	r := &ratelimiter{}
+	ip := netip.MustParseAddr("1.2.3.4")
+	otherIP := netip.MustParseAddr("5.6.7.8")
+	now := time.Unix(1000, 0)
+
+	assert.False(t, r.isLimited(ip, limit, now))
+	assert.False(t, r.isLimited(ip, limit, now))
+	assert.True(t, r.isLimited(ip, limit, now))
+
+	assert.False(t, r.isLimited(otherIP, limit, now))
+
+	// Only a half of the limit is refilled within a half of a second, so the
+	// burst at the boundary of a second doesn't exceed the limit.
+	now = now.Add(time.Second / 2)
+	assert.False(t, r.isLimited(ip, limit, now))
+	assert.True(t, r.isLimited(ip, limit, now))
+
+	now = now.Add(time.Second)
+	assert.False(t, r.isLimited(ip, limit, now))
+	assert.False(t, r.isLimited(ip, limit, now))
+	assert.True(t, r.isLimited(ip, limit, now))
+}
+
+func TestRatelimiter_setLimit(t *testing.T) {
+	const globalLimit = 1
+
+	ip := netip.MustParseAddr("1.2.3.4")
+	now := time.Unix(1000, 0)
+
+	t.Run("higher", func(t *testing.T) {
+		r := &ratelimiter{}
+		r.setLimit(ip, 3, now)
+
+		for i := 0; i < 3; i++ {
+			assert.False(t, r.isLimited(ip, globalLimit, now))
+		}
+
+		assert.True(t, r.isLimited(ip, globalLimit, now))
+	})
+
+	t.Run("lower", func(t *testing.T) {
+		r := &ratelimiter{}
+		assert.False(t, r.isLimited(ip, 10, now))
+
+		r.setLimit(ip, 1, now)
+		assert.False(t, r.isLimited(ip, 10, now))
+		assert.True(t, r.isLimited(ip, 10, now))
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		r := &ratelimiter{}
+		assert.False(t, r.isLimited(ip, globalLimit, now))
+
+		r.setLimit(ip, 0, now)
+		for i := 0; i < 10; i++ {
+			assert.False(t, r.isLimited(ip, globalLimit, now))
+		}
+
+		r.setLimit(ip, globalLimit, now)
+		assert.False(t, r.isLimited(ip, globalLimit, now))
+		assert.True(t, r.isLimited(ip, globalLimit, now))
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		r := &ratelimiter{}
+		r.setLimit(ip, 0, now)
+
+		later := now.Add(ratelimitPruneIvl)
+		assert.False(t, r.isLimited(netip.MustParseAddr("5.6.7.8"), globalLimit, later))
+		assert.NotContains(t, r.buckets, ip)
+	})
+}
@@ -42,7 +42,7 @@ func (s *Server) processQueryLogsAndStats(dctx *dnsContext) (rc resultCode) {
 	s.serverLock.RLock()
 	defer s.serverLock.RUnlock()
 
-	if s.shouldLog(host, qt, cl, ids) {
+	if s.shouldLog(host, qt, cl, ids, dctx.result) {
 		s.logQuery(dctx, pctx, elapsed, ip)
 	} else {
 		log.Debug(
@@ -70,15 +70,31 @@ func (s *Server) processQueryLogsAndStats(dctx *dnsContext) (rc resultCode) {
 }
 
 // shouldLog returns true if the query with the given data should be logged in
-// the query log.  s.serverLock is expected to be locked.
-func (s *Server) shouldLog(host string, qt, cl uint16, ids []string) (ok bool) {
+// the query log.  res may be nil.  s.serverLock is expected to be locked.
+func (s *Server) shouldLog(
+	host string,
+	qt uint16,
+	cl uint16,
+	ids []string,
+	res *filtering.Result,
+) (ok bool) {
 	if qt == dns.TypeANY && s.conf.RefuseAny {
 		return false
 	}
 
+	if s.queryLog == nil {
+		return false
+	}
+
 	// TODO(s.chzhen):  Use dnsforward.dnsContext when it will start containing
 	// persistent client.
-	return s.queryLog != nil && s.queryLog.ShouldLog(host, qt, cl, ids)
+	if s.queryLog.ShouldLog(host, qt, cl, ids) {
+		return true
+	}
+
+	return res != nil &&
+		res.Reason == filtering.FilteredBlockedService &&
+		s.queryLog.ShouldLogBlockedService(ids)
 }
 
 // shouldCountStat returns true if the query with the given data should be
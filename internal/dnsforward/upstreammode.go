@@ -0,0 +1,265 @@
+package dnsforward
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/dnsproxy/fastip"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+)
+
+// ClientUpstreamMode is the mode of using the custom upstreams of a client.
+type ClientUpstreamMode string
+
+// Client upstream modes.
+const (
+	// ClientUpstreamModeDefault means that the global upstream mode is used.
+	ClientUpstreamModeDefault ClientUpstreamMode = ""
+
+	// ClientUpstreamModeLoadBalance means that a single upstream is queried,
+	// which is chosen randomly with the faster upstreams being chosen more
+	// often.  The other upstreams are queried in the same manner if it fails.
+	ClientUpstreamModeLoadBalance ClientUpstreamMode = "load_balance"
+
+	// ClientUpstreamModeParallel means that all the upstreams are queried
+	// simultaneously and the first response is used.
+	ClientUpstreamModeParallel ClientUpstreamMode = "parallel"
+
+	// ClientUpstreamModeFastest means that all the upstreams are queried
+	// simultaneously and the response with the fastest IP address is used.
+	ClientUpstreamModeFastest ClientUpstreamMode = "fastest"
+)
+
+// Validate returns an error if m isn't a valid client upstream mode.
+func (m ClientUpstreamMode) Validate() (err error) {
+	switch m {
+	case
+		ClientUpstreamModeDefault,
+		ClientUpstreamModeLoadBalance,
+		ClientUpstreamModeParallel,
+		ClientUpstreamModeFastest:
+		return nil
+	default:
+		return fmt.Errorf(
+			"upstream_mode: bad value %q, want %q, %q, or %q",
+			m,
+			ClientUpstreamModeLoadBalance,
+			ClientUpstreamModeParallel,
+			ClientUpstreamModeFastest,
+		)
+	}
+}
+
+// WrapClientUpstreams makes the upstreams of conf resolve according to mode by
+// replacing each list of them with a single upstream, which queries the list
+// accordingly.  fastestTimeout is the timeout for dialing the IP addresses in
+// the [ClientUpstreamModeFastest] mode.  conf isn't changed if mode is
+// [ClientUpstreamModeDefault].
+//
+// NOTE: The proxy still applies the global upstream mode to the resulting
+// single upstream, which only matters for the fastest_addr mode, since the IP
+// addresses from the responses are then pinged again.
+func WrapClientUpstreams(
+	conf *proxy.UpstreamConfig,
+	mode ClientUpstreamMode,
+	fastestTimeout time.Duration,
+) {
+	if mode == ClientUpstreamModeDefault {
+		return
+	}
+
+	var fastest *fastip.FastestAddr
+	if mode == ClientUpstreamModeFastest {
+		fastest = fastip.NewFastestAddr()
+		fastest.PingWaitTimeout = fastestTimeout
+	}
+
+	wrap := func(ups []upstream.Upstream) (wrapped []upstream.Upstream) {
+		if len(ups) == 0 {
+			// Keep the empty lists, since they mean that the domain is
+			// excluded from the reserved upstreams.
+			return ups
+		}
+
+		return []upstream.Upstream{newModeUpstream(ups, fastest, mode)}
+	}
+
+	conf.Upstreams = wrap(conf.Upstreams)
+	for _, m := range []map[string][]upstream.Upstream{
+		conf.DomainReservedUpstreams,
+		conf.SpecifiedDomainUpstreams,
+	} {
+		for domain, ups := range m {
+			m[domain] = wrap(ups)
+		}
+	}
+}
+
+// modeUpstream is an upstream, which queries several upstreams according to
+// the client upstream mode.
+type modeUpstream struct {
+	// fastest is used to choose the fastest IP address in the
+	// [ClientUpstreamModeFastest] mode.  It's nil in the other modes.
+	fastest *fastip.FastestAddr
+
+	// rttMu protects rtts.
+	rttMu *sync.Mutex
+
+	// upstreams are the actual upstreams.  It must not be empty.
+	upstreams []upstream.Upstream
+
+	// rtts are the average round-trip times of upstreams with the same
+	// indexes.  They're only used in the [ClientUpstreamModeLoadBalance] mode.
+	rtts []time.Duration
+
+	// mode is the upstream mode.  It must not be [ClientUpstreamModeDefault].
+	mode ClientUpstreamMode
+}
+
+// newModeUpstream returns a new properly initialized *modeUpstream.  ups must
+// not be empty, fastest must not be nil if mode is [ClientUpstreamModeFastest].
+func newModeUpstream(
+	ups []upstream.Upstream,
+	fastest *fastip.FastestAddr,
+	mode ClientUpstreamMode,
+) (u *modeUpstream) {
+	return &modeUpstream{
+		fastest:   fastest,
+		rttMu:     &sync.Mutex{},
+		upstreams: ups,
+		rtts:      make([]time.Duration, len(ups)),
+		mode:      mode,
+	}
+}
+
+// type check
+var _ upstream.Upstream = (*modeUpstream)(nil)
+
+// Exchange implements the [upstream.Upstream] interface for *modeUpstream.
+func (u *modeUpstream) Exchange(req *dns.Msg) (resp *dns.Msg, err error) {
+	switch u.mode {
+	case ClientUpstreamModeParallel:
+		resp, _, err = upstream.ExchangeParallel(u.upstreams, req)
+	case ClientUpstreamModeFastest:
+		if qt := req.Question[0].Qtype; qt == dns.TypeA || qt == dns.TypeAAAA {
+			resp, _, err = u.fastest.ExchangeFastest(req, u.upstreams)
+		} else {
+			resp, _, err = upstream.ExchangeParallel(u.upstreams, req)
+		}
+	default:
+		resp, err = u.exchangeLoadBalance(req)
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return resp, err
+}
+
+// failedUpstreamRTT is the round-trip time recorded for an upstream, which has
+// failed to respond, so that it's chosen less often.
+const failedUpstreamRTT = 10 * time.Second
+
+// exchangeLoadBalance queries the upstreams in the order chosen by
+// [modeUpstream.order] until one of them responds and updates their round-trip
+// times.
+func (u *modeUpstream) exchangeLoadBalance(req *dns.Msg) (resp *dns.Msg, err error) {
+	var errs []error
+	for _, i := range u.order() {
+		start := time.Now()
+		resp, err = u.upstreams[i].Exchange(req)
+		if err == nil {
+			u.updateRTT(i, time.Since(start))
+
+			return resp, nil
+		}
+
+		u.updateRTT(i, failedUpstreamRTT)
+		errs = append(errs, err)
+	}
+
+	return nil, errors.List("all upstreams failed to exchange request", errs...)
+}
+
+// order returns the indexes of the upstreams in the random order, in which the
+// probability of an upstream to come before the remaining ones is inversely
+// proportional to its average round-trip time.
+func (u *modeUpstream) order() (idxs []int) {
+	u.rttMu.Lock()
+	defer u.rttMu.Unlock()
+
+	l := len(u.upstreams)
+	idxs = make([]int, l)
+	weights := make([]float64, l)
+
+	var sum float64
+	for i, rtt := range u.rtts {
+		idxs[i] = i
+
+		// Add a millisecond to give the upstreams without any round-trip
+		// time yet a large, but finite, weight.
+		weights[i] = 1 / (rtt + time.Millisecond).Seconds()
+		sum += weights[i]
+	}
+
+	for i := 0; i < l-1; i++ {
+		r := rand.Float64() * sum
+		j := i
+		for ; j < l-1; j++ {
+			r -= weights[j]
+			if r < 0 {
+				break
+			}
+		}
+
+		sum -= weights[j]
+		idxs[i], idxs[j] = idxs[j], idxs[i]
+		weights[i], weights[j] = weights[j], weights[i]
+	}
+
+	return idxs
+}
+
+// updateRTT updates the average round-trip time of the upstream with index i
+// with rtt.
+func (u *modeUpstream) updateRTT(i int, rtt time.Duration) {
+	u.rttMu.Lock()
+	defer u.rttMu.Unlock()
+
+	if u.rtts[i] == 0 {
+		u.rtts[i] = rtt
+	} else {
+		u.rtts[i] = (u.rtts[i] + rtt) / 2
+	}
+}
+
+// Address implements the [upstream.Upstream] interface for *modeUpstream.
+func (u *modeUpstream) Address() (addr string) {
+	addrs := make([]string, 0, len(u.upstreams))
+	for _, ups := range u.upstreams {
+		addrs = append(addrs, ups.Address())
+	}
+
+	return strings.Join(addrs, ", ")
+}
+
+// Close implements the [upstream.Upstream] interface for *modeUpstream.
+func (u *modeUpstream) Close() (err error) {
+	var errs []error
+	for _, ups := range u.upstreams {
+		err = ups.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.List("closing upstreams", errs...)
+	}
+
+	return nil
+}
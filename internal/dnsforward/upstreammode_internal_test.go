@@ -0,0 +1,160 @@
+package dnsforward
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientUpstreamMode_Validate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		mode       ClientUpstreamMode
+		wantErrMsg string
+	}{{
+		name:       "default",
+		mode:       ClientUpstreamModeDefault,
+		wantErrMsg: "",
+	}, {
+		name:       "load_balance",
+		mode:       ClientUpstreamModeLoadBalance,
+		wantErrMsg: "",
+	}, {
+		name:       "parallel",
+		mode:       ClientUpstreamModeParallel,
+		wantErrMsg: "",
+	}, {
+		name:       "fastest",
+		mode:       ClientUpstreamModeFastest,
+		wantErrMsg: "",
+	}, {
+		name: "bad",
+		mode: "fastest_addr",
+		wantErrMsg: `upstream_mode: bad value "fastest_addr", ` +
+			`want "load_balance", "parallel", or "fastest"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, tc.mode.Validate())
+		})
+	}
+}
+
+func TestWrapClientUpstreams(t *testing.T) {
+	const testErr errors.Error = "test error"
+
+	failing := aghtest.NewUpstreamMock(func(_ *dns.Msg) (resp *dns.Msg, err error) {
+		return nil, testErr
+	})
+
+	working := aghtest.NewUpstreamMock(func(req *dns.Msg) (resp *dns.Msg, err error) {
+		return (&dns.Msg{}).SetReply(req), nil
+	})
+
+	req := (&dns.Msg{}).SetQuestion("www.example.com.", dns.TypeTXT)
+
+	testCases := []struct {
+		name string
+		mode ClientUpstreamMode
+	}{{
+		name: "load_balance",
+		mode: ClientUpstreamModeLoadBalance,
+	}, {
+		name: "parallel",
+		mode: ClientUpstreamModeParallel,
+	}, {
+		name: "fastest",
+		mode: ClientUpstreamModeFastest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &proxy.UpstreamConfig{
+				Upstreams: []upstream.Upstream{failing, working},
+				DomainReservedUpstreams: map[string][]upstream.Upstream{
+					"example.org.": {failing, working},
+					"example.net.": nil,
+				},
+			}
+
+			WrapClientUpstreams(conf, tc.mode, time.Second)
+			require.Len(t, conf.Upstreams, 1)
+
+			u := testutil.RequireTypeAssert[*modeUpstream](t, conf.Upstreams[0])
+			assert.Equal(t, tc.mode, u.mode)
+
+			resp, err := u.Exchange(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, req.Id, resp.Id)
+
+			assert.Len(t, conf.DomainReservedUpstreams["example.org."], 1)
+			assert.Empty(t, conf.DomainReservedUpstreams["example.net."])
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ups := []upstream.Upstream{failing, working}
+		conf := &proxy.UpstreamConfig{
+			Upstreams: ups,
+		}
+
+		WrapClientUpstreams(conf, ClientUpstreamModeDefault, time.Second)
+		assert.Equal(t, ups, conf.Upstreams)
+	})
+
+	t.Run("all_failed", func(t *testing.T) {
+		conf := &proxy.UpstreamConfig{
+			Upstreams: []upstream.Upstream{failing, failing},
+		}
+
+		WrapClientUpstreams(conf, ClientUpstreamModeLoadBalance, time.Second)
+		require.Len(t, conf.Upstreams, 1)
+
+		_, err := conf.Upstreams[0].Exchange(req)
+		assert.ErrorIs(t, err, testErr)
+	})
+
+	t.Run("load_balance_distribution", func(t *testing.T) {
+		const n = 100
+
+		var firstNum, secondNum int
+		first := aghtest.NewUpstreamMock(func(req *dns.Msg) (resp *dns.Msg, err error) {
+			firstNum++
+
+			return (&dns.Msg{}).SetReply(req), nil
+		})
+		second := aghtest.NewUpstreamMock(func(req *dns.Msg) (resp *dns.Msg, err error) {
+			secondNum++
+
+			return (&dns.Msg{}).SetReply(req), nil
+		})
+
+		conf := &proxy.UpstreamConfig{
+			Upstreams: []upstream.Upstream{first, second},
+		}
+
+		WrapClientUpstreams(conf, ClientUpstreamModeLoadBalance, time.Second)
+		require.Len(t, conf.Upstreams, 1)
+
+		for i := 0; i < n; i++ {
+			_, err := conf.Upstreams[0].Exchange(req)
+			require.NoError(t, err)
+		}
+
+		// Both upstreams are equally fast, so each of them must be chosen, as
+		// opposed to always querying the first one.
+		assert.Equal(t, n, firstNum+secondNum)
+		assert.Positive(t, firstNum)
+		assert.Positive(t, secondNum)
+	})
+}
@@ -49,11 +49,13 @@ func initBlockedServices() {
 
 // BlockedServices is the configuration of blocked services.
 type BlockedServices struct {
-	// Schedule is blocked services schedule for every day of the week.
-	Schedule *schedule.Weekly `yaml:"schedule"`
+	// Schedule is blocked services schedule for every day of the week.  The
+	// services aren't blocked within its ranges, which include their
+	// beginnings but not their ends, see [schedule.Weekly].
+	Schedule *schedule.Weekly `json:"schedule" yaml:"schedule"`
 
 	// IDs is the names of blocked services.
-	IDs []string `yaml:"ids"`
+	IDs []string `json:"ids" yaml:"ids"`
 }
 
 // Clone returns a deep copy of blocked services.
@@ -81,19 +83,49 @@ func (s *BlockedServices) Validate() (err error) {
 	return nil
 }
 
-// ApplyBlockedServices - set blocked services settings for this DNS request
+// ApplyBlockedServices sets the global blocked services settings for this DNS
+// request unless the current time is within their schedule.  The schedule
+// isn't checked on each call, see [scheduleFlag].
 func (d *DNSFilter) ApplyBlockedServices(setts *Settings) {
 	d.confLock.RLock()
 	defer d.confLock.RUnlock()
 
 	setts.ServicesRules = []ServiceEntry{}
 
-	bsvc := d.BlockedServices
+	if d.bsvcPaused.isSet() {
+		return
+	}
+
+	d.ApplyBlockedServicesList(setts, d.BlockedServices.IDs)
+}
 
-	// TODO(s.chzhen):  Use startTime from [dnsforward.dnsContext].
-	if !bsvc.Schedule.Contains(time.Now()) {
-		d.ApplyBlockedServicesList(setts, bsvc.IDs)
+// BlockedServicesPaused returns true if the global blocked services are
+// currently paused by their schedule along with the moment it changes, which is
+// zero if it never changes.  It is safe for concurrent use.
+func (d *DNSFilter) BlockedServicesPaused() (paused bool, next time.Time) {
+	if d.bsvcPaused == nil {
+		return false, time.Time{}
 	}
+
+	return d.bsvcPaused.state()
+}
+
+// ApplyScheduledBlockedServices appends the filtering rules of bsvc to the
+// settings unless the current time is within the schedule of bsvc.  applied is
+// true if the rules were appended.
+//
+// TODO(s.chzhen):  Use startTime from [dnsforward.dnsContext].
+func (d *DNSFilter) ApplyScheduledBlockedServices(
+	setts *Settings,
+	bsvc *BlockedServices,
+) (applied bool) {
+	if bsvc.Schedule.Contains(d.now()) {
+		return false
+	}
+
+	d.ApplyBlockedServicesList(setts, bsvc.IDs)
+
+	return true
 }
 
 // ApplyBlockedServicesList appends filtering rules to the settings.
@@ -125,6 +157,84 @@ func (d *DNSFilter) handleBlockedServicesAll(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// serviceRuleJSON is the JSON representation of a parsed blocked service rule.
+type serviceRuleJSON struct {
+	// Text is the original text of the rule.
+	Text string `json:"text"`
+
+	// Shortcut is the longest substring of the rule pattern without special
+	// characters, which is used to preliminary match the hostnames.
+	Shortcut string `json:"shortcut"`
+
+	// PermittedDomains are the domains from the $domain modifier.
+	PermittedDomains []string `json:"permitted_domains"`
+
+	// Whitelist is true if the rule is an exception rule.
+	Whitelist bool `json:"whitelist"`
+
+	// Important is true if the rule has the $important modifier.
+	Important bool `json:"important"`
+
+	// Badfilter is true if the rule has the $badfilter modifier.
+	Badfilter bool `json:"badfilter"`
+
+	// HostLevel is true if the rule can be used for the hosts-level blocking.
+	HostLevel bool `json:"host_level"`
+
+	// Regex is true if the rule pattern is a regular expression.
+	Regex bool `json:"regex"`
+}
+
+// serviceRulesJSON is the JSON representation of the parsed rules of a blocked
+// service.
+type serviceRulesJSON struct {
+	// ID is the ID of the service.
+	ID string `json:"id"`
+
+	// Rules are the parsed rules of the service.
+	Rules []*serviceRuleJSON `json:"rules"`
+}
+
+// newServiceRuleJSON returns the JSON representation of the parsed rule r.
+func newServiceRuleJSON(r *rules.NetworkRule) (rj *serviceRuleJSON) {
+	return &serviceRuleJSON{
+		Text:             r.Text(),
+		Shortcut:         r.Shortcut,
+		PermittedDomains: r.GetPermittedDomains(),
+		Whitelist:        r.Whitelist,
+		Important:        r.IsOptionEnabled(rules.OptionImportant),
+		Badfilter:        r.IsOptionEnabled(rules.OptionBadfilter),
+		HostLevel:        r.IsHostLevelNetworkRule(),
+		Regex:            r.IsRegexRule(),
+	}
+}
+
+// handleBlockedServicesRules is the handler for the GET
+// /control/blocked_services/rules HTTP API.  It responds with the parsed rules
+// of every blocked service for diagnostic purposes.
+func (d *DNSFilter) handleBlockedServicesRules(w http.ResponseWriter, r *http.Request) {
+	svcs := make([]*serviceRulesJSON, 0, len(serviceIDs))
+	for _, id := range serviceIDs {
+		netRules := serviceRules[id]
+		sr := &serviceRulesJSON{
+			ID:    id,
+			Rules: make([]*serviceRuleJSON, 0, len(netRules)),
+		}
+
+		for _, nr := range netRules {
+			sr.Rules = append(sr.Rules, newServiceRuleJSON(nr))
+		}
+
+		svcs = append(svcs, sr)
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, struct {
+		Services []*serviceRulesJSON `json:"services"`
+	}{
+		Services: svcs,
+	})
+}
+
 func (d *DNSFilter) handleBlockedServicesList(w http.ResponseWriter, r *http.Request) {
 	d.confLock.RLock()
 	list := d.Config.BlockedServices.IDs
@@ -133,7 +243,68 @@ func (d *DNSFilter) handleBlockedServicesList(w http.ResponseWriter, r *http.Req
 	_ = aghhttp.WriteJSONResponse(w, r, list)
 }
 
+// blockedServicesSetMode is the mode of the blocked services set operation.
+type blockedServicesSetMode string
+
+// Valid blockedServicesSetMode values.
+const (
+	// blockedServicesSetModeReplace replaces the whole list of blocked
+	// services.  It's the default mode.
+	blockedServicesSetModeReplace blockedServicesSetMode = "replace"
+
+	// blockedServicesSetModeAdd adds the services to the list, skipping the
+	// ones already in it.
+	blockedServicesSetModeAdd blockedServicesSetMode = "add"
+
+	// blockedServicesSetModeRemove removes the services from the list.
+	blockedServicesSetModeRemove blockedServicesSetMode = "remove"
+)
+
+// apply returns the result of applying the set operation with ids to the list
+// of blocked services prev.  prev is not modified.
+func (m blockedServicesSetMode) apply(prev, ids []string) (res []string) {
+	switch m {
+	case blockedServicesSetModeAdd:
+		res = slices.Clone(prev)
+		for _, id := range ids {
+			if !slices.Contains(res, id) {
+				res = append(res, id)
+			}
+		}
+	case blockedServicesSetModeRemove:
+		res = make([]string, 0, len(prev))
+		for _, id := range prev {
+			if !slices.Contains(ids, id) {
+				res = append(res, id)
+			}
+		}
+	default:
+		res = ids
+	}
+
+	return res
+}
+
+// handleBlockedServicesSet is the handler for the POST
+// /control/blocked_services/set HTTP API.  The optional mode query parameter
+// defines if the list from the request replaces the current one, which is the
+// default, or is added to or removed from it.
 func (d *DNSFilter) handleBlockedServicesSet(w http.ResponseWriter, r *http.Request) {
+	mode := blockedServicesSetMode(r.URL.Query().Get("mode"))
+	switch mode {
+	case "":
+		mode = blockedServicesSetModeReplace
+	case
+		blockedServicesSetModeReplace,
+		blockedServicesSetModeAdd,
+		blockedServicesSetModeRemove:
+		// Go on.
+	default:
+		aghhttp.Error(r, w, http.StatusBadRequest, "unsupported mode %q", mode)
+
+		return
+	}
+
 	list := []string{}
 	err := json.NewDecoder(r.Body).Decode(&list)
 	if err != nil {
@@ -142,11 +313,76 @@ func (d *DNSFilter) handleBlockedServicesSet(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	err = (&BlockedServices{IDs: list}).Validate()
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "validating: %s", err)
+
+		return
+	}
+
 	d.confLock.Lock()
+	list = mode.apply(d.Config.BlockedServices.IDs, list)
 	d.Config.BlockedServices.IDs = list
 	d.confLock.Unlock()
 
-	log.Debug("Updated blocked services list: %d", len(list))
+	log.Debug("Updated blocked services list: %d (mode %s)", len(list), mode)
 
-	d.Config.ConfigModified()
+	if d.Config.BlockedServicesModified != nil {
+		d.Config.BlockedServicesModified()
+	} else {
+		d.Config.ConfigModified()
+	}
+}
+
+// handleBlockedServicesExport is the handler for the GET
+// /control/blocked_services/export HTTP API.  It responds with the whole
+// configuration of the global blocked services, which can later be passed to
+// the import HTTP API, e.g. of another instance.
+func (d *DNSFilter) handleBlockedServicesExport(w http.ResponseWriter, r *http.Request) {
+	d.confLock.RLock()
+	bsvc := d.Config.BlockedServices.Clone()
+	d.confLock.RUnlock()
+
+	_ = aghhttp.WriteJSONResponse(w, r, bsvc)
+}
+
+// handleBlockedServicesImport is the handler for the POST
+// /control/blocked_services/import HTTP API.  It replaces the whole
+// configuration of the global blocked services, but only if it's valid.
+func (d *DNSFilter) handleBlockedServicesImport(w http.ResponseWriter, r *http.Request) {
+	bsvc := &BlockedServices{}
+	err := json.NewDecoder(r.Body).Decode(bsvc)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "json.Decode: %s", err)
+
+		return
+	}
+
+	if bsvc.Schedule == nil {
+		bsvc.Schedule = schedule.EmptyWeekly()
+	}
+
+	if bsvc.IDs == nil {
+		bsvc.IDs = []string{}
+	}
+
+	err = bsvc.Validate()
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "validating: %s", err)
+
+		return
+	}
+
+	d.confLock.Lock()
+	d.Config.BlockedServices = bsvc
+	d.bsvcPaused.reset(bsvc.Schedule)
+	d.confLock.Unlock()
+
+	log.Debug("filtering: imported blocked services: %d", len(bsvc.IDs))
+
+	if d.Config.BlockedServicesModified != nil {
+		d.Config.BlockedServicesModified()
+	} else {
+		d.Config.ConfigModified()
+	}
 }
@@ -0,0 +1,165 @@
+package filtering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDNSFilter_ApplyBlockedServices(t *testing.T) {
+	InitModule()
+
+	const svcID = "youtube"
+
+	// Pause the blocking on Mondays from 01:00 to 02:00 UTC and on Tuesdays
+	// from 22:00 UTC to the end of the day.
+	const schedYAML = `
+time_zone: UTC
+mon:
+  start: 1h
+  end: 2h
+tue:
+  start: 22h
+  end: 24h
+`
+
+	sched := schedule.EmptyWeekly()
+	err := yaml.Unmarshal([]byte(schedYAML), sched)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		now         time.Time
+		name        string
+		wantApplied bool
+	}{{
+		now:         time.Date(2023, time.June, 5, 1, 30, 0, 0, time.UTC),
+		name:        "paused",
+		wantApplied: false,
+	}, {
+		now:         time.Date(2023, time.June, 5, 3, 0, 0, 0, time.UTC),
+		name:        "after_pause",
+		wantApplied: true,
+	}, {
+		now:         time.Date(2023, time.June, 6, 1, 30, 0, 0, time.UTC),
+		name:        "another_day",
+		wantApplied: true,
+	}, {
+		now:         time.Date(2023, time.June, 6, 23, 59, 59, 0, time.UTC),
+		name:        "paused_last_second",
+		wantApplied: false,
+	}, {
+		now:         time.Date(2023, time.June, 7, 0, 0, 0, 0, time.UTC),
+		name:        "after_midnight",
+		wantApplied: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, setts := newForTest(t, &Config{
+				BlockedServices: &BlockedServices{
+					Schedule: sched,
+					IDs:      []string{svcID},
+				},
+				Now: func() (now time.Time) { return tc.now },
+			}, nil)
+			t.Cleanup(d.Close)
+
+			d.ApplyBlockedServices(setts)
+
+			if !tc.wantApplied {
+				assert.Empty(t, setts.ServicesRules)
+
+				return
+			}
+
+			require.Len(t, setts.ServicesRules, 1)
+
+			assert.Equal(t, svcID, setts.ServicesRules[0].Name)
+		})
+	}
+}
+
+func TestScheduleFlag(t *testing.T) {
+	// Pause the blocking on Mondays from 01:00 to 02:00 UTC.
+	sched := schedule.EmptyWeekly()
+	err := yaml.Unmarshal([]byte("time_zone: UTC\nmon:\n  start: 1h\n  end: 2h\n"), sched)
+	require.NoError(t, err)
+
+	// monday is 2023-06-05, a Monday.
+	monday := time.Date(2023, time.June, 5, 0, 0, 0, 0, time.UTC)
+	start, end := monday.Add(time.Hour), monday.Add(2*time.Hour)
+
+	now := monday
+	f := newScheduleFlag(sched, func() (n time.Time) { return now })
+
+	assertState := func(t *testing.T, wantSet bool, wantNext time.Time) {
+		t.Helper()
+
+		ok, next := f.state()
+		assert.Equal(t, wantSet, ok)
+		assert.Equal(t, wantNext, next)
+		assert.Equal(t, wantSet, f.isSet())
+	}
+
+	assertState(t, false, start)
+
+	now = start.Add(-time.Nanosecond)
+	assertState(t, false, start)
+
+	now = start
+	assertState(t, true, end)
+
+	now = end.Add(-time.Nanosecond)
+	assertState(t, true, end)
+
+	now = end
+	assertState(t, false, start.AddDate(0, 0, 7))
+
+	t.Run("clock_backward", func(t *testing.T) {
+		now = start
+		f.reset(sched)
+		assertState(t, true, end)
+
+		// Set the clock back across the start of the range.
+		now = start.Add(-time.Minute)
+		assertState(t, false, start)
+
+		// Set the clock back within the period the result is valid for.
+		now = start.Add(-time.Hour)
+		assertState(t, false, start)
+	})
+
+	t.Run("clock_forward", func(t *testing.T) {
+		now = monday
+		f.reset(sched)
+		assertState(t, false, start)
+
+		// Set the clock forward across the whole range.
+		now = end.Add(time.Minute)
+		assertState(t, false, start.AddDate(0, 0, 7))
+
+		// Set the clock forward into the next range.
+		now = start.AddDate(0, 0, 7)
+		assertState(t, true, end.AddDate(0, 0, 7))
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		now = start
+		f.reset(schedule.EmptyWeekly())
+		assertState(t, false, time.Time{})
+
+		f.reset(sched)
+		assert.True(t, f.isSet())
+
+		f.reset(nil)
+		assertState(t, false, time.Time{})
+
+		// The result never changes, so setting the clock back is fine.
+		now = monday
+		assertState(t, false, time.Time{})
+	})
+}
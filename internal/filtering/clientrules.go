@@ -0,0 +1,98 @@
+package filtering
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/urlfilter/rules"
+)
+
+// NewClientDomainRules returns the rules blocking the domains from blocked and
+// allowing the domains from allowed, including their subdomains.  The result is
+// intended to be used as [Settings.ClientRules].
+func NewClientDomainRules(blocked, allowed []string) (rs []*rules.NetworkRule, err error) {
+	rs = make([]*rules.NetworkRule, 0, len(blocked)+len(allowed))
+
+	rs, err = appendDomainRules(rs, blocked, "")
+	if err != nil {
+		return nil, fmt.Errorf("blocked_domains: %w", err)
+	}
+
+	rs, err = appendDomainRules(rs, allowed, "@@")
+	if err != nil {
+		return nil, fmt.Errorf("allowed_domains: %w", err)
+	}
+
+	return rs, nil
+}
+
+// appendDomainRules appends the rules for domains with prefix to rs.
+func appendDomainRules(
+	rs []*rules.NetworkRule,
+	domains []string,
+	prefix string,
+) (res []*rules.NetworkRule, err error) {
+	for i, domain := range domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		err = netutil.ValidateDomainName(domain)
+		if err != nil {
+			return nil, fmt.Errorf("at index %d: %w", i, err)
+		}
+
+		var r *rules.NetworkRule
+		r, err = rules.NewNetworkRule(prefix+"||"+domain+"^", CustomListID)
+		if err != nil {
+			return nil, fmt.Errorf("at index %d: %w", i, err)
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs, nil
+}
+
+// matchClientRules checks the host against the client-specific rules from
+// setts.  The allowing rules take precedence over the blocking ones, as well as
+// over all other filtering.  err is always nil.
+func matchClientRules(host string, _ uint16, setts *Settings) (res Result, err error) {
+	if !setts.ProtectionEnabled || len(setts.ClientRules) == 0 {
+		return Result{}, nil
+	}
+
+	req := rules.NewRequestForHostname(host)
+
+	var blocking *rules.NetworkRule
+	for _, r := range setts.ClientRules {
+		if !r.Match(req) {
+			continue
+		}
+
+		if r.Whitelist {
+			return newClientRuleResult(r, NotFilteredAllowList, false), nil
+		} else if blocking == nil {
+			blocking = r
+		}
+	}
+
+	if blocking == nil {
+		return Result{}, nil
+	}
+
+	log.Debug("client rules: matched rule: %s  host: %s", blocking.Text(), host)
+
+	return newClientRuleResult(blocking, FilteredBlockList, true), nil
+}
+
+// newClientRuleResult returns the result of matching the client rule r.
+func newClientRuleResult(r *rules.NetworkRule, reason Reason, isFiltered bool) (res Result) {
+	return Result{
+		Rules: []*ResultRule{{
+			FilterListID: int64(r.GetFilterListID()),
+			Text:         r.Text(),
+		}},
+		Reason:     reason,
+		IsFiltered: isFiltered,
+	}
+}
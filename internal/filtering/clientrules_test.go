@@ -0,0 +1,137 @@
+package filtering
+
+import (
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientDomainRules(t *testing.T) {
+	testCases := []struct {
+		name       string
+		blocked    []string
+		allowed    []string
+		wantRules  []string
+		wantErrMsg string
+	}{{
+		name:       "empty",
+		blocked:    nil,
+		allowed:    nil,
+		wantRules:  []string{},
+		wantErrMsg: "",
+	}, {
+		name:       "valid",
+		blocked:    []string{"Example.ORG."},
+		allowed:    []string{"sub.example.org"},
+		wantRules:  []string{"||example.org^", "@@||sub.example.org^"},
+		wantErrMsg: "",
+	}, {
+		name:      "bad_blocked",
+		blocked:   []string{"example.org", "bad domain"},
+		allowed:   nil,
+		wantRules: nil,
+		wantErrMsg: `blocked_domains: at index 1: bad domain name "bad domain": ` +
+			`bad top-level domain name label "bad domain": ` +
+			`bad top-level domain name label rune ' '`,
+	}, {
+		name:       "bad_allowed",
+		blocked:    nil,
+		allowed:    []string{""},
+		wantRules:  nil,
+		wantErrMsg: `allowed_domains: at index 0: bad domain name "": domain name is empty`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs, err := NewClientDomainRules(tc.blocked, tc.allowed)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+			if tc.wantErrMsg != "" {
+				return
+			}
+
+			texts := make([]string, 0, len(rs))
+			for _, r := range rs {
+				texts = append(texts, r.Text())
+			}
+
+			assert.Equal(t, tc.wantRules, texts)
+		})
+	}
+}
+
+func TestDNSFilter_CheckHost_clientRules(t *testing.T) {
+	const (
+		globallyBlocked = "blocked.example"
+		clientBlocked   = "client-blocked.example"
+		other           = "other.example"
+	)
+
+	filters := []Filter{{
+		ID:   0,
+		Data: []byte("||" + globallyBlocked + "^\n"),
+	}}
+
+	d, setts := newForTest(t, &Config{}, filters)
+	t.Cleanup(d.Close)
+
+	clientRules, err := NewClientDomainRules(
+		[]string{clientBlocked},
+		[]string{globallyBlocked},
+	)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		host        string
+		clientRules bool
+		wantReason  Reason
+	}{{
+		name:        "global_block",
+		host:        globallyBlocked,
+		clientRules: false,
+		wantReason:  FilteredBlockList,
+	}, {
+		name:        "client_allow_override",
+		host:        globallyBlocked,
+		clientRules: true,
+		wantReason:  NotFilteredAllowList,
+	}, {
+		name:        "client_allow_override_subdomain",
+		host:        "www." + globallyBlocked,
+		clientRules: true,
+		wantReason:  NotFilteredAllowList,
+	}, {
+		name:        "client_block",
+		host:        clientBlocked,
+		clientRules: true,
+		wantReason:  FilteredBlockList,
+	}, {
+		name:        "client_block_other_client",
+		host:        clientBlocked,
+		clientRules: false,
+		wantReason:  NotFilteredNotFound,
+	}, {
+		name:        "not_matched",
+		host:        other,
+		clientRules: true,
+		wantReason:  NotFilteredNotFound,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := *setts
+			if tc.clientRules {
+				s.ClientRules = clientRules
+			}
+
+			res, cErr := d.CheckHost(tc.host, dns.TypeA, &s)
+			require.NoError(t, cErr)
+
+			assert.Equal(t, tc.wantReason, res.Reason)
+			assert.Equal(t, tc.wantReason == FilteredBlockList, res.IsFiltered)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package filtering
+
+import "strings"
+
+// EffectiveRules are the rules applied to the requests of a client, see
+// [DNSFilter.EffectiveRules].
+type EffectiveRules struct {
+	// Lists are the enabled blocklists and allowlists.  Their rules aren't
+	// included, since there are usually too many of them.
+	Lists []*EffectiveList `json:"lists"`
+
+	// UserRules are the global custom filtering rules without the comments and
+	// the empty lines.
+	UserRules []string `json:"user_rules"`
+
+	// ClientRules are the client-specific rules, see [Settings.ClientRules].
+	ClientRules []string `json:"client_rules"`
+
+	// BlockedServices are the blocked services active now.
+	BlockedServices []*EffectiveService `json:"blocked_services"`
+
+	// Rewrites are the legacy DNS rewrites.
+	Rewrites []*EffectiveRewrite `json:"rewrites"`
+}
+
+// EffectiveList is an enabled filter list in [EffectiveRules].
+type EffectiveList struct {
+	URL        string `json:"url"`
+	Name       string `json:"name"`
+	ID         int64  `json:"id"`
+	RulesCount uint32 `json:"rules_count"`
+	Allowlist  bool   `json:"allowlist"`
+}
+
+// EffectiveService is a blocked service in [EffectiveRules].
+type EffectiveService struct {
+	ID    string   `json:"id"`
+	Rules []string `json:"rules"`
+}
+
+// EffectiveRewrite is a legacy DNS rewrite in [EffectiveRules].
+type EffectiveRewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+}
+
+// EffectiveRules returns the rules applied to the requests filtered with setts.
+// The filter lists, the user rules, and the rewrites are only included if the
+// filtering is enabled, and the client rules and the blocked services are only
+// included if the protection is enabled, same as when checking the hosts.  All
+// the fields of r are non-nil.
+func (d *DNSFilter) EffectiveRules(setts *Settings) (r *EffectiveRules) {
+	r = &EffectiveRules{
+		Lists:           []*EffectiveList{},
+		UserRules:       []string{},
+		ClientRules:     []string{},
+		BlockedServices: []*EffectiveService{},
+		Rewrites:        []*EffectiveRewrite{},
+	}
+
+	if setts.FilteringEnabled {
+		d.appendEffectiveLists(r)
+		d.appendEffectiveRewrites(r)
+	}
+
+	if !setts.ProtectionEnabled {
+		return r
+	}
+
+	for _, cr := range setts.ClientRules {
+		r.ClientRules = append(r.ClientRules, cr.Text())
+	}
+
+	for _, svc := range setts.ServicesRules {
+		es := &EffectiveService{
+			ID:    svc.Name,
+			Rules: make([]string, 0, len(svc.Rules)),
+		}
+
+		for _, sr := range svc.Rules {
+			es.Rules = append(es.Rules, sr.Text())
+		}
+
+		r.BlockedServices = append(r.BlockedServices, es)
+	}
+
+	return r
+}
+
+// appendEffectiveLists appends the enabled filter lists and the user rules to
+// r.
+func (d *DNSFilter) appendEffectiveLists(r *EffectiveRules) {
+	d.filtersMu.RLock()
+	defer d.filtersMu.RUnlock()
+
+	for _, f := range d.Filters {
+		if f.Enabled {
+			r.Lists = append(r.Lists, newEffectiveList(f, false))
+		}
+	}
+
+	for _, f := range d.WhitelistFilters {
+		if f.Enabled {
+			r.Lists = append(r.Lists, newEffectiveList(f, true))
+		}
+	}
+
+	for _, line := range d.UserRules {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '!' || line[0] == '#' {
+			continue
+		}
+
+		r.UserRules = append(r.UserRules, line)
+	}
+}
+
+// newEffectiveList returns the description of f for [EffectiveRules].
+func newEffectiveList(f FilterYAML, allow bool) (l *EffectiveList) {
+	return &EffectiveList{
+		URL:        f.URL,
+		Name:       f.Name,
+		ID:         f.ID,
+		RulesCount: uint32(f.RulesCount),
+		Allowlist:  allow,
+	}
+}
+
+// appendEffectiveRewrites appends the legacy DNS rewrites to r.
+func (d *DNSFilter) appendEffectiveRewrites(r *EffectiveRules) {
+	d.confLock.RLock()
+	defer d.confLock.RUnlock()
+
+	for _, rw := range d.Rewrites {
+		r.Rewrites = append(r.Rewrites, &EffectiveRewrite{
+			Domain: rw.Domain,
+			Answer: rw.Answer,
+		})
+	}
+}
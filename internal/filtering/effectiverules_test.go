@@ -0,0 +1,114 @@
+package filtering
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSFilter_EffectiveRules(t *testing.T) {
+	d, setts := newForTest(t, &Config{
+		Filters: []FilterYAML{{
+			Enabled:    true,
+			URL:        "https://example.com/block.txt",
+			Name:       "block",
+			RulesCount: 10,
+			Filter:     Filter{ID: 1},
+		}, {
+			Enabled: false,
+			URL:     "https://example.com/disabled.txt",
+			Name:    "disabled",
+			Filter:  Filter{ID: 2},
+		}},
+		WhitelistFilters: []FilterYAML{{
+			Enabled:    true,
+			URL:        "https://example.com/allow.txt",
+			Name:       "allow",
+			RulesCount: 1,
+			Filter:     Filter{ID: 3},
+		}},
+		UserRules: []string{
+			"! Comment",
+			"",
+			"||global.example^",
+			"  # Another comment",
+		},
+		Rewrites: []*LegacyRewrite{{
+			Domain: "rewritten.example",
+			Answer: "1.2.3.4",
+		}},
+	}, nil)
+	t.Cleanup(d.Close)
+
+	clientRules, err := NewClientDomainRules([]string{"client.example"}, nil)
+	require.NoError(t, err)
+
+	setts.ClientRules = clientRules
+	d.ApplyBlockedServicesList(setts, []string{"facebook"})
+
+	require.Len(t, setts.ServicesRules, 1)
+
+	svcRules := make([]string, 0, len(setts.ServicesRules[0].Rules))
+	for _, r := range setts.ServicesRules[0].Rules {
+		svcRules = append(svcRules, r.Text())
+	}
+
+	wantLists := []*EffectiveList{{
+		URL:        "https://example.com/block.txt",
+		Name:       "block",
+		ID:         1,
+		RulesCount: 10,
+		Allowlist:  false,
+	}, {
+		URL:        "https://example.com/allow.txt",
+		Name:       "allow",
+		ID:         3,
+		RulesCount: 1,
+		Allowlist:  true,
+	}}
+	wantRewrites := []*EffectiveRewrite{{
+		Domain: "rewritten.example",
+		Answer: "1.2.3.4",
+	}}
+
+	t.Run("all", func(t *testing.T) {
+		r := d.EffectiveRules(setts)
+
+		assert.Equal(t, wantLists, r.Lists)
+		assert.Equal(t, []string{"||global.example^"}, r.UserRules)
+		assert.Equal(t, []string{"||client.example^"}, r.ClientRules)
+		assert.Equal(t, []*EffectiveService{{
+			ID:    "facebook",
+			Rules: svcRules,
+		}}, r.BlockedServices)
+		assert.Equal(t, wantRewrites, r.Rewrites)
+	})
+
+	t.Run("no_filtering", func(t *testing.T) {
+		noFiltering := *setts
+		noFiltering.FilteringEnabled = false
+
+		r := d.EffectiveRules(&noFiltering)
+
+		assert.Empty(t, r.Lists)
+		assert.Empty(t, r.UserRules)
+		assert.Empty(t, r.Rewrites)
+		assert.Equal(t, []string{"||client.example^"}, r.ClientRules)
+		assert.Len(t, r.BlockedServices, 1)
+	})
+
+	t.Run("no_protection", func(t *testing.T) {
+		noProtection := *setts
+		noProtection.ProtectionEnabled = false
+
+		r := d.EffectiveRules(&noProtection)
+
+		assert.Equal(t, wantLists, r.Lists)
+		assert.Equal(t, []string{"||global.example^"}, r.UserRules)
+		assert.NotNil(t, r.ClientRules)
+		assert.Empty(t, r.ClientRules)
+		assert.NotNil(t, r.BlockedServices)
+		assert.Empty(t, r.BlockedServices)
+	})
+}
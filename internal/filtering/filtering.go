@@ -15,9 +15,11 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/mathutil"
@@ -56,6 +58,10 @@ type Settings struct {
 
 	ServicesRules []ServiceEntry
 
+	// ClientRules are the client-specific rules created with
+	// [NewClientDomainRules].  They are checked before any other filtering.
+	ClientRules []*rules.NetworkRule
+
 	ProtectionEnabled   bool
 	FilteringEnabled    bool
 	SafeSearchEnabled   bool
@@ -64,6 +70,11 @@ type Settings struct {
 
 	// ClientSafeSearch is a client configured safe search.
 	ClientSafeSearch SafeSearch
+
+	// Ratelimit is the client-specific maximum number of requests per second.
+	// Zero means that the global rate limit is used, and a negative value
+	// means that the client isn't limited.
+	Ratelimit int
 }
 
 // Resolver is the interface for net.Resolver to simplify testing.
@@ -114,12 +125,21 @@ type Config struct {
 	// Called when the configuration is changed by HTTP request
 	ConfigModified func() `yaml:"-"`
 
+	// BlockedServicesModified is called each time the global blocked services
+	// are changed via the web UI.  If it's nil, ConfigModified is called
+	// instead.
+	BlockedServicesModified func() `yaml:"-"`
+
 	// Register an HTTP handler
 	HTTPRegister aghhttp.RegisterFunc `yaml:"-"`
 
 	// HTTPClient is the client to use for updating the remote filters.
 	HTTPClient *http.Client `yaml:"-"`
 
+	// Now returns the current time, which is used to check the schedules of
+	// blocked services.  If nil, [time.Now] is used.
+	Now func() (now time.Time) `yaml:"-"`
+
 	// DataDir is used to store filters' contents.
 	DataDir string `yaml:"-"`
 
@@ -203,6 +223,14 @@ type DNSFilter struct {
 	filterTitleRegexp *regexp.Regexp
 
 	hostCheckers []hostChecker
+
+	// now returns the current time.  It's never nil.
+	now func() (now time.Time)
+
+	// bsvcPaused is true when the current time is within the schedule of the
+	// global blocked services, so the services shouldn't be blocked.  It's
+	// never nil.
+	bsvcPaused *scheduleFlag
 }
 
 // Filter represents a filter list
@@ -957,7 +985,15 @@ func New(c *Config, blockFilters []Filter) (d *DNSFilter, err error) {
 
 	d.safeSearch = c.SafeSearch
 
+	d.now = c.Now
+	if d.now == nil {
+		d.now = time.Now
+	}
+
 	d.hostCheckers = []hostChecker{{
+		check: matchClientRules,
+		name:  "client rules",
+	}, {
 		check: d.matchSysHosts,
 		name:  "hosts container",
 	}, {
@@ -987,14 +1023,19 @@ func New(c *Config, blockFilters []Filter) (d *DNSFilter, err error) {
 		return nil, fmt.Errorf("rewrites: preparing: %s", err)
 	}
 
+	var bsvcSched *schedule.Weekly
 	if d.BlockedServices != nil {
 		err = d.BlockedServices.Validate()
 
 		if err != nil {
 			return nil, fmt.Errorf("filtering: %w", err)
 		}
+
+		bsvcSched = d.BlockedServices.Schedule
 	}
 
+	d.bsvcPaused = newScheduleFlag(bsvcSched, d.now)
+
 	if blockFilters != nil {
 		err = d.initFiltering(nil, blockFilters)
 		if err != nil {
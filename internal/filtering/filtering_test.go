@@ -693,3 +693,74 @@ func BenchmarkSafeBrowsingParallel(b *testing.B) {
 		}
 	})
 }
+
+// testSafeSearch is a [SafeSearch] implementation for tests, which filters all
+// hosts.
+type testSafeSearch struct{}
+
+// type check
+var _ SafeSearch = testSafeSearch{}
+
+// CheckHost implements the [SafeSearch] interface for testSafeSearch.
+func (testSafeSearch) CheckHost(_ string, _ uint16) (res Result, err error) {
+	return Result{
+		IsFiltered: true,
+		Reason:     FilteredSafeSearch,
+	}, nil
+}
+
+// Update implements the [SafeSearch] interface for testSafeSearch.
+func (testSafeSearch) Update(_ SafeSearchConfig) (err error) {
+	return nil
+}
+
+func TestDNSFilter_CheckHost_clientSafeSearch(t *testing.T) {
+	const host = "www.google.com"
+
+	testCases := []struct {
+		global      SafeSearch
+		client      SafeSearch
+		name        string
+		enabled     bool
+		wantBlocked bool
+	}{{
+		global:      nil,
+		client:      testSafeSearch{},
+		name:        "client_without_global",
+		enabled:     true,
+		wantBlocked: true,
+	}, {
+		global:      testSafeSearch{},
+		client:      nil,
+		name:        "global",
+		enabled:     true,
+		wantBlocked: true,
+	}, {
+		global:      testSafeSearch{},
+		client:      nil,
+		name:        "client_disabled",
+		enabled:     false,
+		wantBlocked: false,
+	}, {
+		global:      nil,
+		client:      nil,
+		name:        "none",
+		enabled:     true,
+		wantBlocked: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, setts := newForTest(t, &Config{SafeSearch: tc.global}, nil)
+			t.Cleanup(d.Close)
+
+			setts.SafeSearchEnabled = tc.enabled
+			setts.ClientSafeSearch = tc.client
+
+			res, err := d.CheckHost(host, dns.TypeA, setts)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantBlocked, res.IsFiltered)
+		})
+	}
+}
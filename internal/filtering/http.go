@@ -560,8 +560,11 @@ func (d *DNSFilter) RegisterFilteringHandlers() {
 
 	registerHTTP(http.MethodGet, "/control/blocked_services/services", d.handleBlockedServicesIDs)
 	registerHTTP(http.MethodGet, "/control/blocked_services/all", d.handleBlockedServicesAll)
+	registerHTTP(http.MethodGet, "/control/blocked_services/rules", d.handleBlockedServicesRules)
 	registerHTTP(http.MethodGet, "/control/blocked_services/list", d.handleBlockedServicesList)
 	registerHTTP(http.MethodPost, "/control/blocked_services/set", d.handleBlockedServicesSet)
+	registerHTTP(http.MethodGet, "/control/blocked_services/export", d.handleBlockedServicesExport)
+	registerHTTP(http.MethodPost, "/control/blocked_services/import", d.handleBlockedServicesImport)
 
 	registerHTTP(http.MethodGet, "/control/filtering/status", d.handleFilteringStatus)
 	registerHTTP(http.MethodPost, "/control/filtering/config", d.handleFilteringConfig)
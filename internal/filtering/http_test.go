@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -305,3 +307,257 @@ func TestDNSFilter_handleParentalStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSFilter_handleBlockedServicesRules(t *testing.T) {
+	const rulesURL = "/control/blocked_services/rules"
+
+	InitModule()
+
+	handlers := make(map[string]http.Handler)
+
+	d, err := New(&Config{
+		DataDir: t.TempDir(),
+		HTTPRegister: func(_, url string, handler http.HandlerFunc) {
+			handlers[url] = handler
+		},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(d.Close)
+
+	d.RegisterFilteringHandlers()
+	require.Contains(t, handlers, rulesURL)
+
+	r := httptest.NewRequest(http.MethodGet, rulesURL, nil)
+	w := httptest.NewRecorder()
+
+	handlers[rulesURL].ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	resp := struct {
+		Services []*serviceRulesJSON `json:"services"`
+	}{}
+
+	err = json.NewDecoder(w.Body).Decode(&resp)
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Services)
+	require.Len(t, resp.Services, len(serviceIDs))
+
+	for _, svc := range resp.Services {
+		wantRules := serviceRules[svc.ID]
+		require.Len(t, svc.Rules, len(wantRules))
+
+		for i, rule := range svc.Rules {
+			assert.Equal(t, wantRules[i].Text(), rule.Text)
+		}
+	}
+}
+
+func TestDNSFilter_handleBlockedServicesImport(t *testing.T) {
+	const (
+		testTimeout = time.Second
+
+		exportURL = "/control/blocked_services/export"
+		importURL = "/control/blocked_services/import"
+	)
+
+	InitModule()
+
+	confModCh := make(chan struct{})
+	handlers := make(map[string]http.Handler)
+
+	d, err := New(&Config{
+		BlockedServices: &BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+			IDs:      []string{},
+		},
+		BlockedServicesModified: func() {
+			testutil.RequireSend(testutil.PanicT{}, confModCh, struct{}{}, testTimeout)
+		},
+		DataDir: t.TempDir(),
+		HTTPRegister: func(_, url string, handler http.HandlerFunc) {
+			handlers[url] = handler
+		},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(d.Close)
+
+	d.RegisterFilteringHandlers()
+	require.Contains(t, handlers, exportURL)
+	require.Contains(t, handlers, importURL)
+
+	testCases := []struct {
+		name     string
+		body     string
+		wantIDs  []string
+		wantCode int
+	}{{
+		name: "success",
+		body: `{"ids":["youtube","tiktok"],"schedule":{"time_zone":"UTC",` +
+			`"mon":[{"start":0,"end":3600000}]}}`,
+		wantIDs:  []string{"youtube", "tiktok"},
+		wantCode: http.StatusOK,
+	}, {
+		name:     "unknown_id",
+		body:     `{"ids":["youtube","unknown_service"]}`,
+		wantIDs:  []string{"youtube", "tiktok"},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name: "bad_schedule",
+		body: `{"ids":["youtube"],"schedule":{"time_zone":"UTC",` +
+			`"mon":[{"start":3600000,"end":0}]}}`,
+		wantIDs:  []string{"youtube", "tiktok"},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "no_schedule",
+		body:     `{"ids":["youtube"]}`,
+		wantIDs:  []string{"youtube"},
+		wantCode: http.StatusOK,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, importURL, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			if tc.wantCode == http.StatusOK {
+				go handlers[importURL].ServeHTTP(w, r)
+
+				testutil.RequireReceive(t, confModCh, testTimeout)
+			} else {
+				handlers[importURL].ServeHTTP(w, r)
+				assert.Equal(t, tc.wantCode, w.Code)
+			}
+
+			r = httptest.NewRequest(http.MethodGet, exportURL, nil)
+			w = httptest.NewRecorder()
+
+			handlers[exportURL].ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			exported := &BlockedServices{}
+			err = json.NewDecoder(w.Body).Decode(exported)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantIDs, exported.IDs)
+			assert.NotNil(t, exported.Schedule)
+		})
+	}
+}
+
+func TestDNSFilter_handleBlockedServicesSet(t *testing.T) {
+	const (
+		testTimeout = time.Second
+
+		listURL = "/control/blocked_services/list"
+		setURL  = "/control/blocked_services/set"
+	)
+
+	InitModule()
+
+	confModCh := make(chan struct{})
+	handlers := make(map[string]http.Handler)
+
+	d, err := New(&Config{
+		BlockedServices: &BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+			IDs:      []string{},
+		},
+		BlockedServicesModified: func() {
+			testutil.RequireSend(testutil.PanicT{}, confModCh, struct{}{}, testTimeout)
+		},
+		DataDir: t.TempDir(),
+		HTTPRegister: func(_, url string, handler http.HandlerFunc) {
+			handlers[url] = handler
+		},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(d.Close)
+
+	d.RegisterFilteringHandlers()
+	require.Contains(t, handlers, listURL)
+	require.Contains(t, handlers, setURL)
+
+	testCases := []struct {
+		name     string
+		query    string
+		body     string
+		wantIDs  []string
+		wantCode int
+	}{{
+		name:     "default",
+		query:    "",
+		body:     `["youtube","tiktok"]`,
+		wantIDs:  []string{"youtube", "tiktok"},
+		wantCode: http.StatusOK,
+	}, {
+		name:     "add",
+		query:    "?mode=add",
+		body:     `["tiktok","twitch","twitch"]`,
+		wantIDs:  []string{"youtube", "tiktok", "twitch"},
+		wantCode: http.StatusOK,
+	}, {
+		name:     "add_unknown",
+		query:    "?mode=add",
+		body:     `["unknown_service"]`,
+		wantIDs:  []string{"youtube", "tiktok", "twitch"},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "remove",
+		query:    "?mode=remove",
+		body:     `["tiktok","facebook"]`,
+		wantIDs:  []string{"youtube", "twitch"},
+		wantCode: http.StatusOK,
+	}, {
+		name:     "bad_mode",
+		query:    "?mode=merge",
+		body:     `["tiktok"]`,
+		wantIDs:  []string{"youtube", "twitch"},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "replace",
+		query:    "?mode=replace",
+		body:     `["facebook"]`,
+		wantIDs:  []string{"facebook"},
+		wantCode: http.StatusOK,
+	}, {
+		name:     "replace_unknown",
+		query:    "",
+		body:     `["twitch","unknown_service"]`,
+		wantIDs:  []string{"facebook"},
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "remove_unknown",
+		query:    "?mode=remove",
+		body:     `["unknown_service"]`,
+		wantIDs:  []string{"facebook"},
+		wantCode: http.StatusBadRequest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, setURL+tc.query, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			if tc.wantCode == http.StatusOK {
+				go handlers[setURL].ServeHTTP(w, r)
+
+				testutil.RequireReceive(t, confModCh, testTimeout)
+			} else {
+				handlers[setURL].ServeHTTP(w, r)
+				assert.Equal(t, tc.wantCode, w.Code)
+			}
+
+			r = httptest.NewRequest(http.MethodGet, listURL, nil)
+			w = httptest.NewRecorder()
+
+			handlers[listURL].ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var ids []string
+			err = json.NewDecoder(w.Body).Decode(&ids)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantIDs, ids)
+		})
+	}
+}
@@ -34,7 +34,9 @@ type SafeSearchConfig struct {
 }
 
 // checkSafeSearch checks host with safe search engine.  Matches
-// [hostChecker.check].
+// [hostChecker.check].  The client's safe search engine, if any, is used
+// regardless of the global one, so that the safe search is enforced for the
+// client even when it's disabled globally.
 func (d *DNSFilter) checkSafeSearch(
 	host string,
 	qtype uint16,
@@ -46,14 +48,14 @@ func (d *DNSFilter) checkSafeSearch(
 		return Result{}, nil
 	}
 
-	if d.safeSearch == nil {
-		return Result{}, nil
-	}
-
 	clientSafeSearch := setts.ClientSafeSearch
 	if clientSafeSearch != nil {
 		return clientSafeSearch.CheckHost(host, qtype)
 	}
 
+	if d.safeSearch == nil {
+		return Result{}, nil
+	}
+
 	return d.safeSearch.CheckHost(host, qtype)
 }
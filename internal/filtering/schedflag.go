@@ -0,0 +1,127 @@
+package filtering
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// scheduleFlag caches the result of [schedule.Weekly.Contains] for the current
+// time, so that it isn't calculated for each request.  The result is
+// recalculated once the current time leaves the period it's valid for, which
+// also covers the wall clock being set backwards or forwards.
+type scheduleFlag struct {
+	// mu protects sched and serializes the recalculations.
+	mu *sync.Mutex
+
+	// sched is the schedule the flag is calculated for.  It may be nil, in
+	// which case the flag is always false.
+	sched *schedule.Weekly
+
+	// now returns the current time.  It must not be nil.
+	now func() (now time.Time)
+
+	// cached is the cached result.  It's never nil.
+	cached *atomic.Pointer[scheduleFlagState]
+}
+
+// scheduleFlagState is the result of [schedule.Weekly.Contains] along with the
+// period it's valid for.
+type scheduleFlagState struct {
+	// last is the time the result was calculated at.
+	last time.Time
+
+	// next is the moment the result changes at.  It's zero if it never
+	// changes.
+	next time.Time
+
+	// contains is the result.
+	contains bool
+}
+
+// isValid returns true if the result is still valid at now.
+func (s *scheduleFlagState) isValid(now time.Time) (ok bool) {
+	return s.next.IsZero() || (!now.Before(s.last) && now.Before(s.next))
+}
+
+// newScheduleFlag returns a new properly initialized *scheduleFlag for sched.
+// now must not be nil.  sched may be nil.
+func newScheduleFlag(sched *schedule.Weekly, now func() (now time.Time)) (f *scheduleFlag) {
+	f = &scheduleFlag{
+		mu:     &sync.Mutex{},
+		now:    now,
+		cached: &atomic.Pointer[scheduleFlagState]{},
+	}
+
+	f.reset(sched)
+
+	return f
+}
+
+// isSet returns true if the current time is within the schedule.  It is safe
+// for concurrent use.
+func (f *scheduleFlag) isSet() (ok bool) {
+	return f.current().contains
+}
+
+// state returns the cached result along with the moment it changes at, which
+// is zero if it never changes.  It is safe for concurrent use.
+func (f *scheduleFlag) state() (ok bool, next time.Time) {
+	s := f.current()
+
+	return s.contains, s.next
+}
+
+// current returns the cached result for the current time, recalculating it if
+// it's not valid anymore.
+func (f *scheduleFlag) current() (s *scheduleFlagState) {
+	now := f.now()
+	if s = f.cached.Load(); s.isValid(now) {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Check again, since the result could have been recalculated while
+	// waiting for the lock.
+	if s = f.cached.Load(); s.isValid(now) {
+		return s
+	}
+
+	return f.updateLocked(now)
+}
+
+// reset recalculates the flag for sched, which may be nil.  It must be called
+// each time the schedule changes.  It is safe for concurrent use.
+func (f *scheduleFlag) reset(sched *schedule.Weekly) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sched = sched
+	f.updateLocked(f.now())
+}
+
+// updateLocked recalculates the flag at now, caches, and returns the result.
+// f.mu is expected to be locked.
+func (f *scheduleFlag) updateLocked(now time.Time) (s *scheduleFlagState) {
+	s = &scheduleFlagState{
+		last: now,
+	}
+
+	if f.sched != nil {
+		s.contains = f.sched.Contains(now)
+		s.next, _ = f.sched.NextChange(now)
+	}
+
+	f.cached.Store(s)
+
+	if !s.next.IsZero() {
+		log.Debug("filtering: schedule flag is %t until %s", s.contains, s.next)
+	}
+
+	return s
+}
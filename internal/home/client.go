@@ -3,13 +3,19 @@ package home
 import (
 	"encoding"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/safesearch"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/golibs/stringutil"
+	"github.com/AdguardTeam/urlfilter/rules"
+	"golang.org/x/exp/slices"
 )
 
 // Client contains information about persistent clients.
@@ -26,12 +32,53 @@ type Client struct {
 	// BlockedServices is the configuration of blocked services of a client.
 	BlockedServices *filtering.BlockedServices
 
+	// ParentalSchedule, if not nil, is the schedule, within which the parental
+	// control is enabled for the client.  Outside of it, ParentalEnabled or
+	// the global setting is used.
+	ParentalSchedule *schedule.Weekly
+
+	// SafeBrowsingSchedule, if not nil, is the schedule, within which the safe
+	// browsing is enabled for the client.  Outside of it, SafeBrowsingEnabled
+	// or the global setting is used.
+	SafeBrowsingSchedule *schedule.Weekly
+
+	// domainRules are the rules compiled from BlockedDomains and
+	// AllowedDomains.
+	domainRules []*rules.NetworkRule
+
 	Name string
 
+	// Notes is the free-text note about the client.  It has no effect on
+	// filtering.
+	Notes string
+
+	// InheritFrom is the name of the persistent client, settings of which are
+	// used for the settings this client doesn't set itself.  Empty string
+	// means that the client doesn't inherit any settings.
+	InheritFrom string
+
 	IDs       []string
 	Tags      []string
 	Upstreams []string
 
+	// UpstreamsProfile is the name of the upstream profile, upstreams of which
+	// are used if Upstreams are empty.  Empty string means that the client
+	// doesn't use any profile.
+	UpstreamsProfile string
+
+	// UpstreamMode is the mode of using Upstreams.  The global upstream mode
+	// is used if it's empty.
+	UpstreamMode dnsforward.ClientUpstreamMode
+
+	// BlockedDomains are the domains, which are blocked for the client along
+	// with their subdomains.
+	BlockedDomains []string
+
+	// AllowedDomains are the domains, which are never filtered for the client
+	// along with their subdomains.  They take precedence over BlockedDomains
+	// and the global filtering.
+	AllowedDomains []string
+
 	UseOwnSettings        bool
 	FilteringEnabled      bool
 	SafeBrowsingEnabled   bool
@@ -39,6 +86,28 @@ type Client struct {
 	UseOwnBlockedServices bool
 	IgnoreQueryLog        bool
 	IgnoreStatistics      bool
+
+	// LogBlockedServices, if true, makes the queries of this client matching
+	// the blocked services written to the query log even if IgnoreQueryLog is
+	// true.
+	LogBlockedServices bool
+
+	// QueryLogRetention is the time the query log entries of this client are
+	// kept for.  Zero means that the global query log retention is used.
+	QueryLogRetention time.Duration
+
+	// Ratelimit is the maximum number of requests per second from this client
+	// overriding the global one.  Zero means that the global rate limit is
+	// used, and a negative value means that the client isn't limited.
+	Ratelimit int
+
+	// CreatedAt is the time when the client has been added.  It's zero for
+	// the clients added before it has been introduced.
+	CreatedAt time.Time
+
+	// ModifiedAt is the time when the client has been last updated.  It's
+	// equal to CreatedAt if the client has never been updated.
+	ModifiedAt time.Time
 }
 
 // ShallowClone returns a deep copy of the client, except upstreamConfig,
@@ -47,13 +116,93 @@ func (c *Client) ShallowClone() (sh *Client) {
 	clone := *c
 
 	clone.BlockedServices = c.BlockedServices.Clone()
+	clone.ParentalSchedule = cloneSchedule(c.ParentalSchedule)
+	clone.SafeBrowsingSchedule = cloneSchedule(c.SafeBrowsingSchedule)
 	clone.IDs = stringutil.CloneSlice(c.IDs)
 	clone.Tags = stringutil.CloneSlice(c.Tags)
 	clone.Upstreams = stringutil.CloneSlice(c.Upstreams)
+	clone.BlockedDomains = stringutil.CloneSlice(c.BlockedDomains)
+	clone.AllowedDomains = stringutil.CloneSlice(c.AllowedDomains)
 
 	return &clone
 }
 
+// cloneSchedule returns a deep copy of w, which may be nil.
+func cloneSchedule(w *schedule.Weekly) (c *schedule.Weekly) {
+	if w == nil {
+		return nil
+	}
+
+	return w.Clone()
+}
+
+// applyProtectionSchedules enables the parental control and the safe browsing
+// in setts if now is within the corresponding schedules of c.  Otherwise, the
+// settings already in setts are left as is.
+func (c *Client) applyProtectionSchedules(setts *filtering.Settings, now time.Time) {
+	if c.ParentalSchedule != nil && c.ParentalSchedule.Contains(now) {
+		setts.ParentalEnabled = true
+	}
+
+	if c.SafeBrowsingSchedule != nil && c.SafeBrowsingSchedule.Contains(now) {
+		setts.SafeBrowsingEnabled = true
+	}
+}
+
+// setDomainRules compiles the rules for BlockedDomains and AllowedDomains of c.
+func (c *Client) setDomainRules() (err error) {
+	c.domainRules, err = filtering.NewClientDomainRules(c.BlockedDomains, c.AllowedDomains)
+
+	// Don't wrap the error since it's informative enough as is.
+	return err
+}
+
+// inherit sets the settings of c, which aren't set by c itself, from tmpl.
+// Both c and tmpl must not be nil.  c must be a clone, since it's modified.
+// The identifying fields, like IDs and tags, and the logging settings are never
+// inherited.
+func (c *Client) inherit(tmpl *Client) {
+	if !c.UseOwnSettings && tmpl.UseOwnSettings {
+		c.UseOwnSettings = true
+		c.FilteringEnabled = tmpl.FilteringEnabled
+		c.SafeBrowsingEnabled = tmpl.SafeBrowsingEnabled
+		c.ParentalEnabled = tmpl.ParentalEnabled
+		c.safeSearchConf = tmpl.safeSearchConf
+		c.SafeSearch = tmpl.SafeSearch
+	}
+
+	if !c.UseOwnBlockedServices && tmpl.UseOwnBlockedServices {
+		c.UseOwnBlockedServices = true
+		c.BlockedServices = tmpl.BlockedServices.Clone()
+	}
+
+	if c.ParentalSchedule == nil {
+		c.ParentalSchedule = cloneSchedule(tmpl.ParentalSchedule)
+	}
+
+	if c.SafeBrowsingSchedule == nil {
+		c.SafeBrowsingSchedule = cloneSchedule(tmpl.SafeBrowsingSchedule)
+	}
+
+	if !c.hasUpstreams() && c.UpstreamsProfile == "" {
+		c.Upstreams = stringutil.CloneSlice(tmpl.Upstreams)
+		c.UpstreamsProfile = tmpl.UpstreamsProfile
+		c.UpstreamMode = tmpl.UpstreamMode
+	}
+
+	if c.Ratelimit == 0 {
+		c.Ratelimit = tmpl.Ratelimit
+	}
+}
+
+// hasUpstreams returns true if c has any upstreams configured, not counting the
+// comments and empty lines.
+func (c *Client) hasUpstreams() (ok bool) {
+	return slices.IndexFunc(c.Upstreams, func(u string) (isUps bool) {
+		return !dnsforward.IsCommentOrEmpty(u)
+	}) != -1
+}
+
 // closeUpstreams closes the client-specific upstream config of c if any.
 func (c *Client) closeUpstreams() (err error) {
 	if c.upstreamConfig != nil {
@@ -83,18 +232,33 @@ func (c *Client) setSafeSearch(
 	return nil
 }
 
+// applySafeSearch overrides the safe search settings in setts with the ones of
+// c regardless of the global ones.  That is, the safe search is enforced if
+// it's enabled for c, even if it's disabled globally, and vice versa.  It
+// should only be used when the own settings of c are used, see
+// [Client.UseOwnSettings].
+func (c *Client) applySafeSearch(setts *filtering.Settings) {
+	setts.SafeSearchEnabled = c.safeSearchConf.Enabled
+	if setts.SafeSearchEnabled {
+		setts.ClientSafeSearch = c.SafeSearch
+	} else {
+		setts.ClientSafeSearch = nil
+	}
+}
+
 // clientSource represents the source from which the information about the
 // client has been obtained.
 type clientSource uint
 
-// Clients information sources.  The order determines the priority.
+// Clients information sources.  The default priority of the runtime sources is
+// set by [defaultClientSourceOrder].
 const (
 	ClientSourceNone clientSource = iota
 	ClientSourceWHOIS
 	ClientSourceARP
 	ClientSourceRDNS
-	ClientSourceDHCP
 	ClientSourceHostsFile
+	ClientSourceDHCP
 	ClientSourcePersistent
 )
 
@@ -119,6 +283,89 @@ func (cs clientSource) String() (s string) {
 	}
 }
 
+// parseRuntimeClientSource parses the source of a runtime client from its
+// human-readable name as returned by [clientSource.String].  The comparison is
+// case-insensitive.
+func parseRuntimeClientSource(s string) (cs clientSource, err error) {
+	for cs = ClientSourceWHOIS; cs < ClientSourcePersistent; cs++ {
+		if strings.EqualFold(s, cs.String()) {
+			return cs, nil
+		}
+	}
+
+	return ClientSourceNone, fmt.Errorf("unknown runtime client source %q", s)
+}
+
+// clientSourcePriority maps the sources of the runtime clients to their
+// priorities.  The sources with greater priorities take precedence when several
+// sources report the same IP address.  The persistent clients always have the
+// highest priority.
+type clientSourcePriority map[clientSource]int
+
+// defaultClientSourceOrder is the default order of the runtime client sources
+// from the highest priority to the lowest one.
+var defaultClientSourceOrder = []clientSource{
+	ClientSourceDHCP,
+	ClientSourceHostsFile,
+	ClientSourceRDNS,
+	ClientSourceARP,
+	ClientSourceWHOIS,
+}
+
+// newClientSourcePriority returns the priority of the runtime client sources
+// with the given names, as returned by [clientSource.String], ordered from the
+// highest priority to the lowest one.  The sources missing from names have
+// lower priorities than the listed ones and keep their default order.
+func newClientSourcePriority(names []string) (p clientSourcePriority, err error) {
+	order := make([]clientSource, 0, len(defaultClientSourceOrder))
+	for i, name := range names {
+		var src clientSource
+		src, err = parseRuntimeClientSource(name)
+		if err != nil {
+			return nil, fmt.Errorf("source at index %d: %w", i, err)
+		} else if slices.Contains(order, src) {
+			return nil, fmt.Errorf("source at index %d: duplicate source %q", i, name)
+		}
+
+		order = append(order, src)
+	}
+
+	for _, src := range defaultClientSourceOrder {
+		if !slices.Contains(order, src) {
+			order = append(order, src)
+		}
+	}
+
+	p = clientSourcePriority{
+		ClientSourcePersistent: len(order) + 1,
+	}
+
+	for i, src := range order {
+		p[src] = len(order) - i
+	}
+
+	return p, nil
+}
+
+// defaultClientSourcePriority is the priority of the runtime client sources
+// used unless configured otherwise.  It must not be modified.
+var defaultClientSourcePriority = func() (p clientSourcePriority) {
+	// The default order is always valid.
+	p, _ = newClientSourcePriority(nil)
+
+	return p
+}()
+
+// higher returns true if a has a higher priority than b.  If p is nil,
+// [defaultClientSourcePriority] is used.
+func (p clientSourcePriority) higher(a, b clientSource) (ok bool) {
+	if p == nil {
+		p = defaultClientSourcePriority
+	}
+
+	return p[a] > p[b]
+}
+
 // type check
 var _ encoding.TextMarshaler = clientSource(0)
 
@@ -136,7 +383,87 @@ type RuntimeClient struct {
 	// Host is the host name of a client.
 	Host string
 
+	// lastSeen is the time of the last DNS query from the client.  It's nil if
+	// there were no queries since the client has been added.  It's updated
+	// atomically, so that it doesn't require the clients container to be
+	// locked for writing on every DNS query.
+	lastSeen atomic.Pointer[time.Time]
+
 	// Source is the source from which the information about the client has
 	// been obtained.
 	Source clientSource
+
+	// hosts are the host names of the client reported by each source.  Host is
+	// the one reported by the source with the highest priority.
+	hosts map[clientSource]string
+}
+
+// LastSeen returns the time of the last DNS query from the client.  It's zero
+// if there were no queries since the client has been added.
+func (rc *RuntimeClient) LastSeen() (t time.Time) {
+	if p := rc.lastSeen.Load(); p != nil {
+		return *p
+	}
+
+	return time.Time{}
+}
+
+// setLastSeen sets the time of the last DNS query from the client.  It's safe
+// for concurrent use.
+func (rc *RuntimeClient) setLastSeen(t time.Time) {
+	rc.lastSeen.Store(&t)
+}
+
+// addHost records the host name reported by src.  Host and Source are only
+// updated if src doesn't have a lower priority than Source according to prio.
+// ok is true if they have been updated.
+func (rc *RuntimeClient) addHost(
+	host string,
+	src clientSource,
+	prio clientSourcePriority,
+) (ok bool) {
+	if rc.hosts == nil {
+		rc.hosts = map[clientSource]string{}
+	}
+
+	rc.hosts[src] = host
+	if prio.higher(rc.Source, src) {
+		return false
+	}
+
+	rc.Host, rc.Source = host, src
+
+	return true
+}
+
+// removeSource removes the host name reported by src.  If it's Host, the host
+// name reported by the source with the next highest priority according to prio
+// is used instead.  empty is true if there are no more sources of the client
+// left.
+func (rc *RuntimeClient) removeSource(src clientSource, prio clientSourcePriority) (empty bool) {
+	delete(rc.hosts, src)
+
+	if rc.Source == src {
+		rc.selectHost(prio)
+	}
+
+	return rc.Source == ClientSourceNone
+}
+
+// selectHost sets Host and Source to the host name reported by the source with
+// the highest priority according to prio.
+func (rc *RuntimeClient) selectHost(prio clientSourcePriority) {
+	rc.Host, rc.Source = "", ClientSourceNone
+	for s, h := range rc.hosts {
+		if prio.higher(s, rc.Source) {
+			rc.Host, rc.Source = h, s
+		}
+	}
+}
+
+// hasSource returns true if src has reported the client.
+func (rc *RuntimeClient) hasSource(src clientSource) (ok bool) {
+	_, ok = rc.hosts[src]
+
+	return ok || rc.Source == src
 }
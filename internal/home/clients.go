@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"path"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
@@ -15,12 +17,15 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/stringutil"
+	"github.com/AdguardTeam/golibs/timeutil"
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
@@ -60,6 +65,11 @@ type clientsContainer struct {
 	// dnsServer is used for checking clients IP status access list status
 	dnsServer *dnsforward.Server
 
+	// ptr resolves the hostnames of the runtime clients on demand for the GET
+	// /control/clients/find HTTP API.  It's nil if the DNS server isn't
+	// initialized.
+	ptr *ptrResolver
+
 	// etcHosts contains list of rewrite rules taken from the operating system's
 	// hosts database.
 	etcHosts *aghnet.HostsContainer
@@ -67,11 +77,41 @@ type clientsContainer struct {
 	// arpdb stores the neighbors retrieved from ARP.
 	arpdb aghnet.ARPDB
 
-	// lock protects all fields.
+	// arpMACs maps the IP addresses of the neighbors retrieved from ARP to
+	// their hardware addresses.  It's updated along with the runtime clients
+	// from ARP, so that the neighbors aren't searched on each request.
+	arpMACs map[netip.Addr]net.HardwareAddr
+
+	// srcPriority is the priority of the sources of the runtime clients.  If
+	// it's nil, [defaultClientSourcePriority] is used.
+	srcPriority clientSourcePriority
+
+	// runtimeDefaults is the client holding the settings applied to the
+	// clients, which aren't persistent.  It's never nil after
+	// [clientsContainer.setRuntimeDefaults] is called.
+	runtimeDefaults *Client
+
+	// approval is the state of the approval of the new runtime clients.  It's
+	// nil if the approval is disabled.
+	approval *clientsApproval
+
+	// groups maps the names of the client groups to the groups.
+	groups map[string]*ClientGroup
+
+	// upstreamProfiles maps the names of the upstream profiles to their
+	// upstreams.
+	upstreamProfiles map[string][]string
+
+	// subscribers are the channels of the handlers of the changes of the
+	// persistent clients, see [clientsContainer.subscribe].
+	subscribers []chan *clientEvent
+
+	// lock protects all fields.  The methods, which only read the fields, use
+	// the read lock, so that they don't block each other.
 	//
 	// TODO(a.garipov): Use a pointer and describe which fields are protected in
-	// more detail.  Use sync.RWMutex.
-	lock sync.Mutex
+	// more detail.
+	lock sync.RWMutex
 
 	// safeSearchCacheSize is the size of the safe search cache to use for
 	// persistent clients.
@@ -81,6 +121,19 @@ type clientsContainer struct {
 	// persistent clients.
 	safeSearchCacheTTL time.Duration
 
+	// findLimiter limits the requests to the GET /control/clients/find and
+	// GET /control/clients/whois HTTP APIs.
+	findLimiter requestRatelimiter
+
+	// findMaxIDs is the maximum number of the client identifiers in a single
+	// request to the GET /control/clients/find HTTP API.  Zero means no limit.
+	findMaxIDs uint
+
+	// findRatelimit is the maximum number of requests per second from a single
+	// remote address to the GET /control/clients/find and GET
+	// /control/clients/whois HTTP APIs.  Zero means no limit.
+	findRatelimit uint32
+
 	// testing is a flag that disables some features for internal tests.
 	//
 	// TODO(a.garipov): Awful.  Remove.
@@ -168,6 +221,123 @@ func (clients *clientsContainer) reloadARP() {
 	}
 }
 
+// runtimeDefaults is the configuration of the settings applied to the clients,
+// which aren't persistent, instead of the global ones.
+type runtimeDefaults struct {
+	SafeSearchConf filtering.SafeSearchConfig `yaml:"safe_search" json:"safe_search"`
+
+	// Enabled, if true, makes the settings below used for the clients, which
+	// aren't persistent.  Otherwise, the global settings are used.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	FilteringEnabled    bool `yaml:"filtering_enabled" json:"filtering_enabled"`
+	ParentalEnabled     bool `yaml:"parental_enabled" json:"parental_enabled"`
+	SafeBrowsingEnabled bool `yaml:"safebrowsing_enabled" json:"safebrowsing_enabled"`
+}
+
+// setRuntimeDefaults sets the settings applied to the clients, which aren't
+// persistent.  conf may be nil, in which case the global settings are used for
+// those.
+func (clients *clientsContainer) setRuntimeDefaults(conf *runtimeDefaults) (err error) {
+	c := &Client{
+		Name: "runtime defaults",
+	}
+
+	if conf != nil {
+		c.UseOwnSettings = conf.Enabled
+		c.FilteringEnabled = conf.FilteringEnabled
+		c.ParentalEnabled = conf.ParentalEnabled
+		c.SafeBrowsingEnabled = conf.SafeBrowsingEnabled
+		c.safeSearchConf = conf.SafeSearchConf
+
+		if conf.SafeSearchConf.Enabled {
+			c.safeSearchConf.CustomResolver = safeSearchResolver{}
+
+			err = c.setSafeSearch(
+				c.safeSearchConf,
+				clients.safeSearchCacheSize,
+				clients.safeSearchCacheTTL,
+			)
+			if err != nil {
+				return fmt.Errorf("runtime defaults: init safesearch: %w", err)
+			}
+		}
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	clients.runtimeDefaults = c
+
+	return nil
+}
+
+// setSourcePriority sets the priority of the runtime client sources from their
+// names ordered from the highest priority to the lowest one, see
+// [newClientSourcePriority], and updates the host names of the known runtime
+// clients accordingly.
+func (clients *clientsContainer) setSourcePriority(names []string) (err error) {
+	prio, err := newClientSourcePriority(names)
+	if err != nil {
+		return fmt.Errorf("runtime sources priority: %w", err)
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	clients.srcPriority = prio
+	for _, rc := range clients.ipToRC {
+		if len(rc.hosts) > 0 {
+			rc.selectHost(prio)
+		}
+	}
+
+	return nil
+}
+
+// runtimeDefaultsConf returns the configuration of the settings applied to the
+// clients, which aren't persistent.
+func (clients *clientsContainer) runtimeDefaultsConf() (conf *runtimeDefaults) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	c := clients.runtimeDefaults
+	if c == nil {
+		return &runtimeDefaults{}
+	}
+
+	ssConf := c.safeSearchConf
+	ssConf.CustomResolver = nil
+
+	return &runtimeDefaults{
+		SafeSearchConf:      ssConf,
+		Enabled:             c.UseOwnSettings,
+		FilteringEnabled:    c.FilteringEnabled,
+		ParentalEnabled:     c.ParentalEnabled,
+		SafeBrowsingEnabled: c.SafeBrowsingEnabled,
+	}
+}
+
+// applyRuntimeDefaults sets the filtering settings of setts to the ones for the
+// clients, which aren't persistent, if those are enabled.  ok is true if the
+// settings have been applied.
+func (clients *clientsContainer) applyRuntimeDefaults(setts *filtering.Settings) (ok bool) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	c := clients.runtimeDefaults
+	if c == nil || !c.UseOwnSettings {
+		return false
+	}
+
+	setts.FilteringEnabled = c.FilteringEnabled
+	c.applySafeSearch(setts)
+	setts.SafeBrowsingEnabled = c.SafeBrowsingEnabled
+	setts.ParentalEnabled = c.ParentalEnabled
+
+	return true
+}
+
 // clientObject is the YAML representation of a persistent client.
 type clientObject struct {
 	SafeSearchConf filtering.SafeSearchConfig `yaml:"safe_search"`
@@ -175,20 +345,60 @@ type clientObject struct {
 	// BlockedServices is the configuration of blocked services of a client.
 	BlockedServices *filtering.BlockedServices `yaml:"blocked_services"`
 
+	// ParentalSchedule is the schedule, within which the parental control is
+	// enabled for the client.
+	ParentalSchedule *schedule.Weekly `yaml:"parental_schedule,omitempty"`
+
+	// SafeBrowsingSchedule is the schedule, within which the safe browsing is
+	// enabled for the client.
+	SafeBrowsingSchedule *schedule.Weekly `yaml:"safebrowsing_schedule,omitempty"`
+
 	Name string `yaml:"name"`
 
+	// Notes is the free-text note about the client.
+	Notes string `yaml:"notes,omitempty"`
+
+	// InheritFrom is the name of the client to inherit the settings from.
+	InheritFrom string `yaml:"inherit_from,omitempty"`
+
 	IDs       []string `yaml:"ids"`
 	Tags      []string `yaml:"tags"`
 	Upstreams []string `yaml:"upstreams"`
 
+	// UpstreamsProfile is the name of the upstream profile to use if
+	// Upstreams are empty.
+	UpstreamsProfile string `yaml:"upstreams_profile,omitempty"`
+
+	// UpstreamMode is the mode of using Upstreams.
+	UpstreamMode dnsforward.ClientUpstreamMode `yaml:"upstream_mode,omitempty"`
+
+	// BlockedDomains are the domains blocked for the client.
+	BlockedDomains []string `yaml:"blocked_domains,omitempty"`
+
+	// AllowedDomains are the domains never filtered for the client.
+	AllowedDomains []string `yaml:"allowed_domains,omitempty"`
+
 	UseGlobalSettings        bool `yaml:"use_global_settings"`
 	FilteringEnabled         bool `yaml:"filtering_enabled"`
 	ParentalEnabled          bool `yaml:"parental_enabled"`
 	SafeBrowsingEnabled      bool `yaml:"safebrowsing_enabled"`
 	UseGlobalBlockedServices bool `yaml:"use_global_blocked_services"`
 
-	IgnoreQueryLog   bool `yaml:"ignore_querylog"`
-	IgnoreStatistics bool `yaml:"ignore_statistics"`
+	IgnoreQueryLog     bool `yaml:"ignore_querylog"`
+	IgnoreStatistics   bool `yaml:"ignore_statistics"`
+	LogBlockedServices bool `yaml:"log_blocked_services"`
+
+	// QueryLogRetention is the time the query log entries of the client are
+	// kept for.  Zero means that the global query log retention is used.
+	QueryLogRetention timeutil.Duration `yaml:"querylog_retention,omitempty"`
+
+	Ratelimit int `yaml:"ratelimit"`
+
+	// CreatedAt is the time when the client has been added.
+	CreatedAt time.Time `yaml:"created_at,omitempty"`
+
+	// ModifiedAt is the time when the client has been last updated.
+	ModifiedAt time.Time `yaml:"modified_at,omitempty"`
 }
 
 // addFromConfig initializes the clients container with objects from the
@@ -199,10 +409,20 @@ func (clients *clientsContainer) addFromConfig(
 ) (err error) {
 	for _, o := range objects {
 		cli := &Client{
-			Name: o.Name,
+			Name:        o.Name,
+			Notes:       o.Notes,
+			InheritFrom: o.InheritFrom,
+
+			IDs:              o.IDs,
+			Upstreams:        o.Upstreams,
+			UpstreamsProfile: o.UpstreamsProfile,
+			UpstreamMode:     o.UpstreamMode,
 
-			IDs:       o.IDs,
-			Upstreams: o.Upstreams,
+			BlockedDomains: o.BlockedDomains,
+			AllowedDomains: o.AllowedDomains,
+
+			ParentalSchedule:     cloneSchedule(o.ParentalSchedule),
+			SafeBrowsingSchedule: cloneSchedule(o.SafeBrowsingSchedule),
 
 			UseOwnSettings:        !o.UseGlobalSettings,
 			FilteringEnabled:      o.FilteringEnabled,
@@ -212,6 +432,12 @@ func (clients *clientsContainer) addFromConfig(
 			UseOwnBlockedServices: !o.UseGlobalBlockedServices,
 			IgnoreQueryLog:        o.IgnoreQueryLog,
 			IgnoreStatistics:      o.IgnoreStatistics,
+			LogBlockedServices:    o.LogBlockedServices,
+			QueryLogRetention:     o.QueryLogRetention.Duration,
+			Ratelimit:             o.Ratelimit,
+
+			CreatedAt:  o.CreatedAt,
+			ModifiedAt: o.ModifiedAt,
 		}
 
 		if o.SafeSearchConf.Enabled {
@@ -234,6 +460,18 @@ func (clients *clientsContainer) addFromConfig(
 			return fmt.Errorf("clients: init client blocked services %q: %w", cli.Name, err)
 		}
 
+		err = o.UpstreamMode.Validate()
+		if err != nil {
+			return fmt.Errorf("clients: init client %q: %w", cli.Name, err)
+		}
+
+		err = cli.setDomainRules()
+		if err != nil {
+			log.Error("clients: init client domains %q: %s", cli.Name, err)
+
+			continue
+		}
+
 		cli.BlockedServices = o.BlockedServices.Clone()
 
 		for _, t := range o.Tags {
@@ -258,20 +496,31 @@ func (clients *clientsContainer) addFromConfig(
 // forConfig returns all currently known persistent clients as objects for the
 // configuration file.
 func (clients *clientsContainer) forConfig() (objs []*clientObject) {
-	clients.lock.Lock()
-	defer clients.lock.Unlock()
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
 
 	objs = make([]*clientObject, 0, len(clients.list))
 	for _, cli := range clients.list {
 		o := &clientObject{
-			Name: cli.Name,
+			Name:        cli.Name,
+			Notes:       cli.Notes,
+			InheritFrom: cli.InheritFrom,
 
 			BlockedServices: cli.BlockedServices.Clone(),
 
+			ParentalSchedule:     cloneSchedule(cli.ParentalSchedule),
+			SafeBrowsingSchedule: cloneSchedule(cli.SafeBrowsingSchedule),
+
 			IDs:       stringutil.CloneSlice(cli.IDs),
 			Tags:      stringutil.CloneSlice(cli.Tags),
 			Upstreams: stringutil.CloneSlice(cli.Upstreams),
 
+			UpstreamsProfile: cli.UpstreamsProfile,
+			UpstreamMode:     cli.UpstreamMode,
+
+			BlockedDomains: stringutil.CloneSlice(cli.BlockedDomains),
+			AllowedDomains: stringutil.CloneSlice(cli.AllowedDomains),
+
 			UseGlobalSettings:        !cli.UseOwnSettings,
 			FilteringEnabled:         cli.FilteringEnabled,
 			ParentalEnabled:          cli.ParentalEnabled,
@@ -280,6 +529,12 @@ func (clients *clientsContainer) forConfig() (objs []*clientObject) {
 			UseGlobalBlockedServices: !cli.UseOwnBlockedServices,
 			IgnoreQueryLog:           cli.IgnoreQueryLog,
 			IgnoreStatistics:         cli.IgnoreStatistics,
+			LogBlockedServices:       cli.LogBlockedServices,
+			QueryLogRetention:        timeutil.Duration{Duration: cli.QueryLogRetention},
+			Ratelimit:                cli.Ratelimit,
+
+			CreatedAt:  cli.CreatedAt,
+			ModifiedAt: cli.ModifiedAt,
 		}
 
 		objs = append(objs, o)
@@ -320,12 +575,14 @@ func (clients *clientsContainer) onDHCPLeaseChanged(flags int) {
 	clients.lock.Lock()
 	defer clients.lock.Unlock()
 
-	clients.rmHostsBySrc(ClientSourceDHCP)
+	removed := clients.rmHostsBySrc(ClientSourceDHCP)
 
 	if flags == dhcpd.LeaseChangedRemovedAll {
 		return
 	}
 
+	defer clients.restoreLastSeen(removed)
+
 	leases := clients.dhcpServer.Leases(dhcpd.LeasesAll)
 	n := 0
 	for _, l := range leases {
@@ -346,8 +603,8 @@ func (clients *clientsContainer) onDHCPLeaseChanged(flags int) {
 // the source which updated it last.  It returns [ClientSourceNone] if the
 // client doesn't exist.
 func (clients *clientsContainer) clientSource(ip netip.Addr) (src clientSource) {
-	clients.lock.Lock()
-	defer clients.lock.Unlock()
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
 
 	_, ok := clients.findLocked(ip.String())
 	if ok {
@@ -362,6 +619,38 @@ func (clients *clientsContainer) clientSource(ip netip.Addr) (src clientSource)
 	return ClientSourceNone
 }
 
+// hasHigherSource returns true if the client with ip is known from a source
+// with a higher priority than src.
+func (clients *clientsContainer) hasHigherSource(ip netip.Addr, src clientSource) (ok bool) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	_, ok = clients.findLocked(ip.String())
+	if ok {
+		return clients.srcPriority.higher(ClientSourcePersistent, src)
+	}
+
+	rc, ok := clients.ipToRC[ip]
+
+	return ok && clients.srcPriority.higher(rc.Source, src)
+}
+
+// hasQueryLogRetention returns true if any persistent client has its own
+// retention of the query log entries.  It is a valid
+// [querylog.Config.HasClientRetention].
+func (clients *clientsContainer) hasQueryLogRetention() (ok bool) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	for _, c := range clients.list {
+		if c.QueryLogRetention != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 // findMultiple is a wrapper around Find to make it a valid client finder for
 // the query log.  c is never nil; if no information about the client is found,
 // it returns an artificial client record by only setting the blocking-related
@@ -402,8 +691,10 @@ func (clients *clientsContainer) clientOrArtificial(
 	client, ok := clients.Find(id)
 	if ok {
 		return &querylog.Client{
-			Name:           client.Name,
-			IgnoreQueryLog: client.IgnoreQueryLog,
+			Name:               client.Name,
+			IgnoreQueryLog:     client.IgnoreQueryLog,
+			LogBlockedServices: client.LogBlockedServices,
+			Retention:          client.QueryLogRetention,
 		}, false
 	}
 
@@ -423,8 +714,8 @@ func (clients *clientsContainer) clientOrArtificial(
 
 // Find returns a shallow copy of the client if there is one found.
 func (clients *clientsContainer) Find(id string) (c *Client, ok bool) {
-	clients.lock.Lock()
-	defer clients.lock.Unlock()
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
 
 	c, ok = clients.findLocked(id)
 	if !ok {
@@ -434,12 +725,109 @@ func (clients *clientsContainer) Find(id string) (c *Client, ok bool) {
 	return c.ShallowClone(), true
 }
 
+// findEffective is like [clientsContainer.Find] but it also resolves the
+// settings of the groups of the client, see [ClientGroup], and the settings
+// inherited by the client, see [Client.InheritFrom].  If the inheritance can't
+// be fully resolved, the settings resolved so far are used.
+func (clients *clientsContainer) findEffective(id string) (c *Client, ok bool) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	c, ok = clients.findLocked(id)
+	if !ok {
+		return nil, false
+	}
+
+	return clients.effectiveLocked(c), true
+}
+
+// effectiveLocked returns a shallow copy of c with the inherited settings
+// resolved, see [clientsContainer.findEffective].  The own settings of c take
+// precedence over the ones of its groups, which are applied in the order of
+// their names and take precedence over the settings inherited from other
+// clients.  clients.lock is expected to be locked.
+func (clients *clientsContainer) effectiveLocked(c *Client) (eff *Client) {
+	eff = c.ShallowClone()
+	for _, g := range clients.groupsOfLocked(c.Name) {
+		eff.inherit(g.settings)
+	}
+
+	tmpls, err := clients.templatesLocked(eff)
+	if err != nil {
+		log.Info("clients: resolving settings of %q: %s", eff.Name, err)
+	}
+
+	for _, tmpl := range tmpls {
+		eff.inherit(tmpl)
+	}
+
+	return eff
+}
+
+// templatesLocked returns the chain of clients c inherits the settings from,
+// starting from the closest one.  err is not nil if the chain refers to an
+// unknown client or contains a cycle, tmpls contains the clients resolved
+// before that.  clients.lock is expected to be locked.
+func (clients *clientsContainer) templatesLocked(c *Client) (tmpls []*Client, err error) {
+	seen := stringutil.NewSet(c.Name)
+	for name := c.InheritFrom; name != ""; {
+		if seen.Has(name) {
+			return tmpls, fmt.Errorf("inheritance cycle at client %q", name)
+		}
+
+		seen.Add(name)
+
+		tmpl, ok := clients.list[name]
+		if !ok {
+			return tmpls, fmt.Errorf("inherited client %q not found", name)
+		}
+
+		tmpls = append(tmpls, tmpl)
+		name = tmpl.InheritFrom
+	}
+
+	return tmpls, nil
+}
+
+// checkInheritFrom returns an error if the client named name, previously named
+// prevName, can't inherit the settings from the client named from.  prevName
+// is empty for new clients.
+func (clients *clientsContainer) checkInheritFrom(name, prevName, from string) (err error) {
+	if from == "" {
+		return nil
+	}
+
+	isSelf := func(n string) (ok bool) { return n == name || (prevName != "" && n == prevName) }
+	if isSelf(from) {
+		return errors.Error("inherit_from: client can't inherit from itself")
+	}
+
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	tmpl, ok := clients.list[from]
+	if !ok {
+		return fmt.Errorf("inherit_from: client %q not found", from)
+	}
+
+	seen := stringutil.NewSet()
+	for ; tmpl != nil && !seen.Has(tmpl.Name); tmpl = clients.list[tmpl.InheritFrom] {
+		if isSelf(tmpl.InheritFrom) {
+			return fmt.Errorf("inherit_from: inheritance cycle at client %q", tmpl.Name)
+		}
+
+		seen.Add(tmpl.Name)
+	}
+
+	return nil
+}
+
 // shouldCountClient is a wrapper around Find to make it a valid client
 // information finder for the statistics.  If no information about the client
 // is found, it returns true.
 func (clients *clientsContainer) shouldCountClient(ids []string) (y bool) {
-	clients.lock.Lock()
-	defer clients.lock.Unlock()
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
 
 	for _, id := range ids {
 		client, ok := clients.findLocked(id)
@@ -465,7 +853,21 @@ func (clients *clientsContainer) findUpstreams(
 		return nil, nil
 	}
 
-	upstreams := stringutil.FilterOut(c.Upstreams, dnsforward.IsCommentOrEmpty)
+	upstreams := clients.upstreamsLocked(c)
+	if len(upstreams) == 0 {
+		// Use the upstreams of the closest client in the inheritance chain
+		// having them to share the upstream configuration.
+		tmpls, _ := clients.templatesLocked(c)
+		for _, tmpl := range tmpls {
+			upstreams = clients.upstreamsLocked(tmpl)
+			if len(upstreams) > 0 {
+				c = tmpl
+
+				break
+			}
+		}
+	}
+
 	if len(upstreams) == 0 {
 		return nil, nil
 	}
@@ -488,6 +890,8 @@ func (clients *clientsContainer) findUpstreams(
 		return nil, err
 	}
 
+	dnsforward.WrapClientUpstreams(conf, c.UpstreamMode, config.DNS.FastestTimeout.Duration)
+
 	c.upstreamConfig = conf
 
 	return conf, nil
@@ -501,9 +905,19 @@ func (clients *clientsContainer) findLocked(id string) (c *Client, ok bool) {
 		return c, true
 	}
 
+	// The MACs are indexed in the canonical form, see
+	// [normalizeClientIdentifier].
+	if mac, err := net.ParseMAC(id); err == nil {
+		c, ok = clients.idIndex[mac.String()]
+
+		return c, ok
+	}
+
 	ip, err := netip.ParseAddr(id)
 	if err != nil {
-		return nil, false
+		// The exact ClientIDs are checked above, so that they take precedence
+		// over the globs.
+		return clients.findByGlobLocked(id)
 	}
 
 	for _, c = range clients.list {
@@ -520,6 +934,11 @@ func (clients *clientsContainer) findLocked(id string) (c *Client, ok bool) {
 		}
 	}
 
+	c, ok = clients.findByHostLocked(ip)
+	if ok {
+		return c, true
+	}
+
 	if clients.dhcpServer != nil {
 		return clients.findDHCP(ip)
 	}
@@ -527,6 +946,50 @@ func (clients *clientsContainer) findLocked(id string) (c *Client, ok bool) {
 	return nil, false
 }
 
+// findByGlobLocked searches for a client having a ClientID glob matching the
+// ClientID id, see [isClientIDGlob].  If several globs match, the longest one
+// is used, since it's likely the most specific one.  clients.lock is expected
+// to be locked.
+func (clients *clientsContainer) findByGlobLocked(id string) (c *Client, ok bool) {
+	best := ""
+	for glob, gc := range clients.idIndex {
+		if !isClientIDGlob(glob) ||
+			len(glob) < len(best) ||
+			len(glob) == len(best) && glob > best {
+			continue
+		}
+
+		// The globs are validated, so the error is always nil.
+		if matched, _ := path.Match(glob, id); matched {
+			c, best = gc, glob
+		}
+	}
+
+	return c, c != nil
+}
+
+// findByHostLocked searches for a client having one of the host names
+// currently reported for ip by the runtime sources, including DHCP, as an ID.
+// If several clients match, the one matched by the host name from the source
+// with the highest priority is returned.  clients.lock is expected to be
+// locked.
+func (clients *clientsContainer) findByHostLocked(ip netip.Addr) (c *Client, ok bool) {
+	rc, ok := clients.ipToRC[ip]
+	if !ok {
+		return nil, false
+	}
+
+	bestSrc := ClientSourceNone
+	for src, host := range rc.hosts {
+		found, has := clients.idIndex[strings.ToLower(host)]
+		if has && (c == nil || clients.srcPriority.higher(src, bestSrc)) {
+			c, bestSrc = found, src
+		}
+	}
+
+	return c, c != nil
+}
+
 // findDHCP searches for a client by its MAC, if the DHCP server is active and
 // there is such client.  clients.lock is expected to be locked.
 func (clients *clientsContainer) findDHCP(ip netip.Addr) (c *Client, ok bool) {
@@ -557,32 +1020,178 @@ func (clients *clientsContainer) findRuntimeClient(ip netip.Addr) (rc *RuntimeCl
 		return nil, false
 	}
 
-	clients.lock.Lock()
-	defer clients.lock.Unlock()
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
 
 	rc, ok = clients.ipToRC[ip]
 
 	return rc, ok
 }
 
-// check validates the client.
+// clientMatch is a client matching a search query.
+type clientMatch struct {
+	// name is the name of the client.
+	name string
+
+	// ids are the identifiers of the client.
+	ids []string
+
+	// rank is the match quality, the lower the better.
+	rank int
+
+	// persistent is true if the client is a persistent one.
+	persistent bool
+}
+
+// Match ranks of search results.
+const (
+	matchRankPrefix = iota
+	matchRankSubstring
+	matchRankNone
+)
+
+// matchRank returns the best rank of matching any of vals against the
+// lowercased query q.
+func matchRank(q string, vals ...string) (rank int) {
+	rank = matchRankNone
+	for _, v := range vals {
+		v = strings.ToLower(v)
+		if strings.HasPrefix(v, q) {
+			return matchRankPrefix
+		} else if strings.Contains(v, q) {
+			rank = matchRankSubstring
+		}
+	}
+
+	return rank
+}
+
+// search returns at most limit persistent and runtime clients, which names or
+// identifiers contain q case-insensitively.  The clients matching q by prefix
+// come before the ones matching it as a substring.
+func (clients *clientsContainer) search(q string, limit int) (matches []*clientMatch) {
+	q = strings.ToLower(q)
+
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	for _, c := range clients.list {
+		rank := matchRank(q, append([]string{c.Name}, c.IDs...)...)
+		if rank == matchRankNone {
+			continue
+		}
+
+		matches = append(matches, &clientMatch{
+			name:       c.Name,
+			ids:        stringutil.CloneSlice(c.IDs),
+			rank:       rank,
+			persistent: true,
+		})
+	}
+
+	for ip, rc := range clients.ipToRC {
+		ipStr := ip.String()
+		rank := matchRank(q, rc.Host, ipStr)
+		if rank == matchRankNone {
+			continue
+		}
+
+		matches = append(matches, &clientMatch{
+			name: rc.Host,
+			ids:  []string{ipStr},
+			rank: rank,
+		})
+	}
+
+	slices.SortFunc(matches, func(a, b *clientMatch) (less bool) {
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		} else if a.name != b.name {
+			return a.name < b.name
+		}
+
+		return a.ids[0] < b.ids[0]
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches
+}
+
+// check validates the client.  It returns the first error of checkFields, if
+// any.
 func (clients *clientsContainer) check(c *Client) (err error) {
-	switch {
-	case c == nil:
+	if c == nil {
 		return errors.Error("client is nil")
-	case c.Name == "":
-		return errors.Error("invalid name")
-	case len(c.IDs) == 0:
-		return errors.Error("id required")
-	default:
-		// Go on.
+	}
+
+	errs := clients.checkFields(c)
+	if len(errs) > 0 {
+		// Don't wrap the error since it's informative enough as is.
+		return errs[0].err
+	}
+
+	slices.Sort(c.Tags)
+
+	return nil
+}
+
+// maxClientNotesLen is the maximum length of the notes of a persistent client
+// in characters.
+const maxClientNotesLen = 1024
+
+// fieldError is a validation error of a field of a persistent client.
+type fieldError struct {
+	// err is the validation error.
+	err error
+
+	// field is the name of the field in the HTTP API.
+	field string
+}
+
+// type check
+var _ error = (*fieldError)(nil)
+
+// Error implements the [error] interface for *fieldError.
+func (e *fieldError) Error() (msg string) {
+	return e.err.Error()
+}
+
+// Unwrap implements the [errors.Wrapper] interface for *fieldError.
+func (e *fieldError) Unwrap() (unwrapped error) {
+	return e.err
+}
+
+// checkFields validates the fields of c, which must not be nil, and returns
+// the errors of all the invalid fields.  It also normalizes the identifiers of
+// c.  It doesn't check if the name and the identifiers are used by other
+// clients.
+func (clients *clientsContainer) checkFields(c *Client) (errs []*fieldError) {
+	addErr := func(field string, err error) {
+		errs = append(errs, &fieldError{err: err, field: field})
+	}
+
+	if c.Name == "" {
+		addErr("name", errors.Error("invalid name"))
+	}
+
+	if l := utf8.RuneCountInString(c.Notes); l > maxClientNotesLen {
+		err := fmt.Errorf("notes too long: got %d characters, max %d", l, maxClientNotesLen)
+		addErr("notes", err)
+	}
+
+	if len(c.IDs) == 0 {
+		addErr("ids", errors.Error("id required"))
 	}
 
 	for i, id := range c.IDs {
-		var norm string
-		norm, err = normalizeClientIdentifier(id)
+		norm, err := normalizeClientIdentifier(id)
 		if err != nil {
-			return fmt.Errorf("client at index %d: %w", i, err)
+			addErr("ids", fmt.Errorf("client at index %d: %w", i, err))
+
+			continue
 		}
 
 		c.IDs[i] = norm
@@ -590,18 +1199,54 @@ func (clients *clientsContainer) check(c *Client) (err error) {
 
 	for _, t := range c.Tags {
 		if !clients.allTags.Has(t) {
-			return fmt.Errorf("invalid tag: %q", t)
+			addErr("tags", fmt.Errorf("invalid tag: %q", t))
 		}
 	}
 
-	slices.Sort(c.Tags)
+	err := dnsforward.ValidateUpstreams(c.Upstreams)
+	if err != nil {
+		addErr("upstreams", fmt.Errorf("invalid upstream servers: %w", err))
+	}
+
+	if c.BlockedServices != nil {
+		err = c.BlockedServices.Validate()
+		if err != nil {
+			addErr("blocked_services", err)
+		}
+	}
 
-	err = dnsforward.ValidateUpstreams(c.Upstreams)
+	err = querylog.ValidateRetention(c.QueryLogRetention)
 	if err != nil {
-		return fmt.Errorf("invalid upstream servers: %w", err)
+		addErr("querylog_retention", err)
 	}
 
-	return nil
+	return errs
+}
+
+// checkUnique returns the errors about the name and the identifiers of c used
+// by the clients other than prev.  prev may be nil.
+func (clients *clientsContainer) checkUnique(c, prev *Client) (errs []*fieldError) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	if existing, ok := clients.list[c.Name]; ok && existing != prev {
+		errs = append(errs, &fieldError{
+			err:   errors.Error("client already exists"),
+			field: "name",
+		})
+	}
+
+	for _, id := range c.IDs {
+		existing, ok := clients.idIndex[id]
+		if ok && existing != prev {
+			errs = append(errs, &fieldError{
+				err:   fmt.Errorf("id %q is used by client with name %q", id, existing.Name),
+				field: "ids",
+			})
+		}
+	}
+
+	return errs
 }
 
 // normalizeClientIdentifier returns a normalized version of idStr.  If idStr
@@ -624,17 +1269,110 @@ func normalizeClientIdentifier(idStr string) (norm string, err error) {
 	var mac net.HardwareAddr
 	if mac, err = net.ParseMAC(idStr); err == nil {
 		return mac.String(), nil
+	} else if looksLikeMAC(idStr) {
+		// Don't let the malformed MACs pass as ClientIDs or host names.
+		return "", fmt.Errorf("bad client identifier: %w", err)
+	}
+
+	if isClientIDGlob(idStr) {
+		err = validateClientIDGlob(idStr)
+		if err != nil {
+			return "", fmt.Errorf("bad client identifier: %w", err)
+		}
+
+		return strings.ToLower(idStr), nil
 	}
 
 	if err = dnsforward.ValidateClientID(idStr); err == nil {
 		return strings.ToLower(idStr), nil
 	}
 
+	// Host names are matched against the ones reported for the client by the
+	// runtime sources, see [clientsContainer.findByHostLocked].
+	if err = netutil.ValidateHostname(idStr); err == nil {
+		return strings.ToLower(idStr), nil
+	}
+
 	return "", fmt.Errorf("bad client identifier %q", idStr)
 }
 
+// isClientIDGlob returns true if id is a ClientID glob, that is it contains the
+// wildcards "*" or "?", which match any sequence of characters and any single
+// character correspondingly.  For example, "kiosk-*" matches the ClientIDs
+// "kiosk-001" and "kiosk-002".
+func isClientIDGlob(id string) (ok bool) {
+	return strings.ContainsAny(id, "*?")
+}
+
+// validateClientIDGlob returns an error if glob isn't a valid ClientID with
+// some of its characters replaced by wildcards, see [isClientIDGlob].
+func validateClientIDGlob(glob string) (err error) {
+	// Check the rest of the glob as if the wildcards were a valid character.
+	label := strings.NewReplacer("*", "x", "?", "x").Replace(glob)
+
+	err = netutil.ValidateHostnameLabel(label)
+	if err != nil {
+		// Replace the domain name label wrapper with our own.
+		return fmt.Errorf("invalid clientid glob %q: %w", glob, errors.Unwrap(err))
+	}
+
+	return nil
+}
+
+// looksLikeMAC returns true if s consists of groups of hexadecimal digits
+// separated the same way as in the MAC formats supported by [net.ParseMAC],
+// regardless of the number of the groups.  Those are at least five groups of
+// two digits separated by colons or hyphens or at least three groups of four
+// digits separated by dots.
+func looksLikeMAC(s string) (ok bool) {
+	for _, f := range []struct {
+		sep       string
+		groupLen  int
+		minGroups int
+	}{{
+		sep:       ":",
+		groupLen:  2,
+		minGroups: 5,
+	}, {
+		sep:       "-",
+		groupLen:  2,
+		minGroups: 5,
+	}, {
+		sep:       ".",
+		groupLen:  4,
+		minGroups: 3,
+	}} {
+		groups := strings.Split(s, f.sep)
+		if len(groups) >= f.minGroups && allHexGroups(groups, f.groupLen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allHexGroups returns true if all groups consist of exactly groupLen
+// hexadecimal digits.
+func allHexGroups(groups []string, groupLen int) (ok bool) {
+	for _, g := range groups {
+		if len(g) != groupLen {
+			return false
+		}
+
+		for _, c := range g {
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 // Add adds a new client object.  ok is false if such client already exists or
-// if an error occurred.
+// if an error occurred.  The creation time of c is set to the current time
+// unless it's already set, for example when c is loaded from the configuration
+// file.
 func (clients *clientsContainer) Add(c *Client) (ok bool, err error) {
 	err = clients.check(c)
 	if err != nil {
@@ -659,7 +1397,16 @@ func (clients *clientsContainer) Add(c *Client) (ok bool, err error) {
 		}
 	}
 
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+
+	if c.ModifiedAt.IsZero() {
+		c.ModifiedAt = c.CreatedAt
+	}
+
 	clients.add(c)
+	clients.notify(clientAdded, c, nil)
 
 	log.Debug("clients: added %q: ID:%q [%d]", c.Name, c.IDs, len(clients.list))
 
@@ -693,10 +1440,48 @@ func (clients *clientsContainer) Del(name string) (ok bool) {
 	}
 
 	clients.del(c)
+	clients.removeMemberLocked(name)
+	clients.notify(clientDeleted, c, nil)
 
 	return true
 }
 
+// DelBulk removes all persistent clients having the tag and all runtime
+// clients obtained from src.  Empty tag and [ClientSourceNone] disable the
+// corresponding filter.  Note that the runtime clients may be added again with
+// the next update of their source.  persistent and runtime are the numbers of
+// removed persistent and runtime clients respectively.
+func (clients *clientsContainer) DelBulk(
+	tag string,
+	src clientSource,
+) (persistentNum, runtimeNum int) {
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if tag != "" {
+		for name, c := range clients.list {
+			if !slices.Contains(c.Tags, tag) {
+				continue
+			}
+
+			if err := c.closeUpstreams(); err != nil {
+				log.Error("client container: removing client %s: %s", name, err)
+			}
+
+			clients.del(c)
+			clients.removeMemberLocked(name)
+			clients.notify(clientDeleted, c, nil)
+			persistentNum++
+		}
+	}
+
+	if src != ClientSourceNone {
+		runtimeNum = len(clients.rmHostsBySrc(src))
+	}
+
+	return persistentNum, runtimeNum
+}
+
 // del removes c from the indexes. clients.lock is expected to be locked.
 func (clients *clientsContainer) del(c *Client) {
 	// update Name index
@@ -719,6 +1504,14 @@ func (clients *clientsContainer) Update(prev, c *Client) (err error) {
 	clients.lock.Lock()
 	defer clients.lock.Unlock()
 
+	// Don't wrap the error since it's informative enough as is.
+	return clients.updateLocked(prev, c)
+}
+
+// updateLocked replaces prev with c in the indexes and notifies the
+// subscribers.  c is expected to be checked already.  clients.lock is expected
+// to be locked.
+func (clients *clientsContainer) updateLocked(prev, c *Client) (err error) {
 	// Check the name index.
 	if prev.Name != c.Name {
 		_, ok := clients.list[c.Name]
@@ -737,8 +1530,22 @@ func (clients *clientsContainer) Update(prev, c *Client) (err error) {
 		}
 	}
 
+	c.CreatedAt = prev.CreatedAt
+	c.ModifiedAt = time.Now()
+
 	clients.del(prev)
 	clients.add(c)
+	clients.notify(clientUpdated, c, prev)
+
+	if prev.Name != c.Name {
+		for _, cli := range clients.list {
+			if cli.InheritFrom == prev.Name {
+				cli.InheritFrom = c.Name
+			}
+		}
+
+		clients.renameMemberLocked(prev.Name, c.Name)
+	}
 
 	return nil
 }
@@ -801,29 +1608,67 @@ func (clients *clientsContainer) addHostLocked(
 		}
 
 		clients.ipToRC[ip] = rc
-	} else if src < rc.Source {
-		return false
 	}
 
-	rc.Host = host
-	rc.Source = src
+	if !rc.addHost(host, src, clients.srcPriority) {
+		log.Debug("clients: retained %s -> %q from %s", ip, host, src)
+
+		return false
+	}
 
 	log.Debug("clients: added %s -> %q [%d]", ip, host, len(clients.ipToRC))
 
 	return true
 }
 
-// rmHostsBySrc removes all entries that match the specified source.
-func (clients *clientsContainer) rmHostsBySrc(src clientSource) {
-	n := 0
+// rmHostsBySrc removes the host names reported by the specified source.  The
+// entries reported by no other sources are removed completely.  removed are the
+// completely removed entries.
+func (clients *clientsContainer) rmHostsBySrc(
+	src clientSource,
+) (removed map[netip.Addr]*RuntimeClient) {
+	removed = map[netip.Addr]*RuntimeClient{}
 	for ip, rc := range clients.ipToRC {
-		if rc.Source == src {
+		if rc.hasSource(src) && rc.removeSource(src, clients.srcPriority) {
 			delete(clients.ipToRC, ip)
-			n++
+			removed[ip] = rc
 		}
 	}
 
-	log.Debug("clients: removed %d client aliases", n)
+	log.Debug("clients: removed %d client aliases", len(removed))
+
+	return removed
+}
+
+// restoreLastSeen sets the last seen time of the runtime clients, which have
+// been re-added after being removed by [clientsContainer.rmHostsBySrc], so that
+// it isn't lost on every update of their source.  clients.lock is expected to
+// be locked.
+func (clients *clientsContainer) restoreLastSeen(removed map[netip.Addr]*RuntimeClient) {
+	for ip, prev := range removed {
+		rc, ok := clients.ipToRC[ip]
+		if !ok {
+			continue
+		}
+
+		if prevSeen := prev.LastSeen(); rc.LastSeen().Before(prevSeen) {
+			rc.setLastSeen(prevSeen)
+		}
+	}
+}
+
+// updateLastSeen sets the last seen time of the runtime client with ip, if
+// there is one.
+func (clients *clientsContainer) updateLastSeen(ip netip.Addr, now time.Time) {
+	// Only lock for reading, since the last seen time is updated atomically
+	// and this is called on every DNS query.
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	rc, ok := clients.ipToRC[ip]
+	if ok {
+		rc.setLastSeen(now)
+	}
 }
 
 // addFromHostsFile fills the client-hostname pairing index from the system's
@@ -832,7 +1677,8 @@ func (clients *clientsContainer) addFromHostsFile(hosts aghnet.HostsRecords) {
 	clients.lock.Lock()
 	defer clients.lock.Unlock()
 
-	clients.rmHostsBySrc(ClientSourceHostsFile)
+	removed := clients.rmHostsBySrc(ClientSourceHostsFile)
+	defer clients.restoreLastSeen(removed)
 
 	n := 0
 	for ip, rec := range hosts {
@@ -864,10 +1710,15 @@ func (clients *clientsContainer) addFromSystemARP() {
 	clients.lock.Lock()
 	defer clients.lock.Unlock()
 
-	clients.rmHostsBySrc(ClientSourceARP)
+	removed := clients.rmHostsBySrc(ClientSourceARP)
+	defer clients.restoreLastSeen(removed)
+
+	clients.arpMACs = make(map[netip.Addr]net.HardwareAddr, len(ns))
 
 	added := 0
 	for _, n := range ns {
+		clients.arpMACs[n.IP] = n.MAC
+
 		if clients.addHostLocked(n.IP, n.Name, ClientSourceARP) {
 			added++
 		}
@@ -877,8 +1728,13 @@ func (clients *clientsContainer) addFromSystemARP() {
 }
 
 // close gracefully closes all the client-specific upstream configurations of
-// the persistent clients.
+// the persistent clients and stops the handlers of the changes.
 func (clients *clientsContainer) close() (err error) {
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	clients.unsubscribeAll()
+
 	persistent := maps.Values(clients.list)
 	slices.SortFunc(persistent, func(a, b *Client) (less bool) { return a.Name < b.Name })
 
@@ -7,11 +7,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/maps"
 )
 
 // newClientsContainer is a helper that creates a new clients container for
@@ -341,3 +345,1070 @@ func TestClientsCustomUpstream(t *testing.T) {
 	assert.Len(t, config.Upstreams, 1)
 	assert.Len(t, config.DomainReservedUpstreams, 1)
 }
+
+func TestClientsContainer_search(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	ok, err := clients.Add(&Client{
+		IDs:  []string{"1.1.1.1"},
+		Name: "kitchen-tv",
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = clients.Add(&Client{
+		IDs:  []string{"1.1.1.2"},
+		Name: "Living room TV",
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok = clients.AddHost(netip.MustParseAddr("2.2.2.2"), "tv-box", ClientSourceRDNS)
+	require.True(t, ok)
+
+	ok = clients.AddHost(netip.MustParseAddr("2.2.2.3"), "laptop", ClientSourceDHCP)
+	require.True(t, ok)
+
+	names := func(matches []*clientMatch) (res []string) {
+		for _, m := range matches {
+			res = append(res, m.name)
+		}
+
+		return res
+	}
+
+	testCases := []struct {
+		name  string
+		q     string
+		want  []string
+		limit int
+	}{{
+		name:  "prefix_before_substring",
+		q:     "TV",
+		want:  []string{"tv-box", "Living room TV", "kitchen-tv"},
+		limit: defaultSearchLimit,
+	}, {
+		name:  "limit",
+		q:     "tv",
+		want:  []string{"tv-box"},
+		limit: 1,
+	}, {
+		name:  "id",
+		q:     "2.2.2.3",
+		want:  []string{"laptop"},
+		limit: defaultSearchLimit,
+	}, {
+		name:  "none",
+		q:     "phone",
+		want:  nil,
+		limit: defaultSearchLimit,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, names(clients.search(tc.q, tc.limit)))
+		})
+	}
+}
+
+func TestClientsContainer_DelBulk(t *testing.T) {
+	const (
+		tagCamera = "device_camera"
+		tagLaptop = "device_laptop"
+	)
+
+	var (
+		arpIP   = netip.MustParseAddr("3.3.3.3")
+		rdnsIP  = netip.MustParseAddr("4.4.4.4")
+		hostsIP = netip.MustParseAddr("5.5.5.5")
+	)
+
+	newContainer := func(t *testing.T) (clients *clientsContainer) {
+		t.Helper()
+
+		clients = newClientsContainer(t)
+
+		for _, c := range []*Client{{
+			Name: "camera1",
+			IDs:  []string{"1.1.1.1"},
+			Tags: []string{tagCamera},
+		}, {
+			Name: "camera2",
+			IDs:  []string{"1.1.1.2"},
+			Tags: []string{tagCamera, tagLaptop},
+		}, {
+			Name: "laptop",
+			IDs:  []string{"2.2.2.2"},
+			Tags: []string{tagLaptop},
+		}} {
+			ok, err := clients.Add(c)
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+
+		require.True(t, clients.AddHost(arpIP, "arp-host", ClientSourceARP))
+		require.True(t, clients.AddHost(rdnsIP, "rdns-host", ClientSourceRDNS))
+		require.True(t, clients.AddHost(hostsIP, "hosts-host", ClientSourceHostsFile))
+
+		return clients
+	}
+
+	testCases := []struct {
+		name           string
+		tag            string
+		wantNames      []string
+		wantRuntimeIPs []netip.Addr
+		src            clientSource
+		wantPersistent int
+		wantRuntime    int
+	}{{
+		name:           "tag",
+		tag:            tagCamera,
+		wantNames:      []string{"laptop"},
+		wantRuntimeIPs: []netip.Addr{arpIP, rdnsIP, hostsIP},
+		src:            ClientSourceNone,
+		wantPersistent: 2,
+		wantRuntime:    0,
+	}, {
+		name:           "source",
+		tag:            "",
+		wantNames:      []string{"camera1", "camera2", "laptop"},
+		wantRuntimeIPs: []netip.Addr{arpIP, hostsIP},
+		src:            ClientSourceRDNS,
+		wantPersistent: 0,
+		wantRuntime:    1,
+	}, {
+		name:           "both",
+		tag:            tagLaptop,
+		wantNames:      []string{"camera1"},
+		wantRuntimeIPs: []netip.Addr{rdnsIP, hostsIP},
+		src:            ClientSourceARP,
+		wantPersistent: 2,
+		wantRuntime:    1,
+	}, {
+		name:           "no_match",
+		tag:            "user_child",
+		wantNames:      []string{"camera1", "camera2", "laptop"},
+		wantRuntimeIPs: []netip.Addr{arpIP, rdnsIP, hostsIP},
+		src:            ClientSourceDHCP,
+		wantPersistent: 0,
+		wantRuntime:    0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clients := newContainer(t)
+
+			persistent, rt := clients.DelBulk(tc.tag, tc.src)
+			assert.Equal(t, tc.wantPersistent, persistent)
+			assert.Equal(t, tc.wantRuntime, rt)
+
+			assert.ElementsMatch(t, tc.wantNames, maps.Keys(clients.list))
+			assert.ElementsMatch(t, tc.wantRuntimeIPs, maps.Keys(clients.ipToRC))
+
+			for _, c := range clients.list {
+				for _, id := range c.IDs {
+					assert.Same(t, c, clients.idIndex[id])
+				}
+			}
+
+			assert.Len(t, clients.idIndex, len(tc.wantNames))
+		})
+	}
+}
+
+func TestParseRuntimeClientSource(t *testing.T) {
+	testCases := []struct {
+		name       string
+		in         string
+		wantErrMsg string
+		want       clientSource
+	}{{
+		name:       "arp",
+		in:         "ARP",
+		wantErrMsg: "",
+		want:       ClientSourceARP,
+	}, {
+		name:       "case_insensitive",
+		in:         "rdns",
+		wantErrMsg: "",
+		want:       ClientSourceRDNS,
+	}, {
+		name:       "hosts",
+		in:         "etc/hosts",
+		wantErrMsg: "",
+		want:       ClientSourceHostsFile,
+	}, {
+		name:       "empty",
+		in:         "",
+		wantErrMsg: `unknown runtime client source ""`,
+		want:       ClientSourceNone,
+	}, {
+		name:       "persistent",
+		in:         "persistent",
+		wantErrMsg: `unknown runtime client source "persistent"`,
+		want:       ClientSourceNone,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := parseRuntimeClientSource(tc.in)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, src)
+		})
+	}
+}
+
+func TestClientsContainer_updateLastSeen(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	var (
+		ip        = netip.MustParseAddr("1.1.1.1")
+		unknownIP = netip.MustParseAddr("2.2.2.2")
+		now       = time.Date(2023, time.May, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	clients.addFromHostsFile(aghnet.HostsRecords{
+		ip: {Canonical: "host"},
+	})
+
+	rc, ok := clients.findRuntimeClient(ip)
+	require.True(t, ok)
+
+	assert.True(t, rc.LastSeen().IsZero())
+	assert.Nil(t, newRuntimeClientJSON(ip, rc, clients.srcPriority).LastSeen)
+
+	clients.updateLastSeen(ip, now)
+	clients.updateLastSeen(unknownIP, now)
+
+	_, ok = clients.findRuntimeClient(unknownIP)
+	assert.False(t, ok)
+
+	t.Run("observed", func(t *testing.T) {
+		rc, ok = clients.findRuntimeClient(ip)
+		require.True(t, ok)
+
+		assert.Equal(t, now, rc.LastSeen())
+
+		cj := newRuntimeClientJSON(ip, rc, clients.srcPriority)
+		require.NotNil(t, cj.LastSeen)
+
+		assert.Equal(t, now, *cj.LastSeen)
+	})
+
+	t.Run("resync", func(t *testing.T) {
+		clients.addFromHostsFile(aghnet.HostsRecords{
+			ip: {Canonical: "new-host"},
+		})
+
+		rc, ok = clients.findRuntimeClient(ip)
+		require.True(t, ok)
+
+		assert.Equal(t, "new-host", rc.Host)
+		assert.Equal(t, now, rc.LastSeen())
+	})
+}
+
+func TestClientsContainer_setRuntimeDefaults(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	assert.Equal(t, &runtimeDefaults{}, clients.runtimeDefaultsConf())
+
+	conf := &runtimeDefaults{
+		SafeSearchConf: filtering.SafeSearchConfig{
+			Enabled: true,
+			Google:  true,
+			YouTube: true,
+		},
+		Enabled:             true,
+		FilteringEnabled:    true,
+		ParentalEnabled:     true,
+		SafeBrowsingEnabled: false,
+	}
+
+	err := clients.setRuntimeDefaults(conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, conf, clients.runtimeDefaultsConf())
+
+	setts := &filtering.Settings{}
+	require.True(t, clients.applyRuntimeDefaults(setts))
+
+	assert.True(t, setts.FilteringEnabled)
+	assert.True(t, setts.SafeSearchEnabled)
+	assert.NotNil(t, setts.ClientSafeSearch)
+	assert.True(t, setts.ParentalEnabled)
+	assert.False(t, setts.SafeBrowsingEnabled)
+
+	err = clients.setRuntimeDefaults(nil)
+	require.NoError(t, err)
+
+	assert.False(t, clients.applyRuntimeDefaults(&filtering.Settings{}))
+}
+
+func TestClient_applySafeSearch(t *testing.T) {
+	clientConf := filtering.SafeSearchConfig{
+		Enabled: true,
+		Google:  true,
+	}
+
+	forced := &Client{
+		Name:           "kid",
+		safeSearchConf: clientConf,
+		UseOwnSettings: true,
+	}
+
+	err := forced.setSafeSearch(clientConf, 1000, time.Minute)
+	require.NoError(t, err)
+
+	exempt := &Client{
+		Name:           "parent",
+		UseOwnSettings: true,
+	}
+
+	t.Run("forced_global_off", func(t *testing.T) {
+		setts := &filtering.Settings{
+			SafeSearchEnabled: false,
+		}
+
+		forced.applySafeSearch(setts)
+
+		assert.True(t, setts.SafeSearchEnabled)
+		assert.Same(t, forced.SafeSearch, setts.ClientSafeSearch)
+	})
+
+	t.Run("exempt_global_on", func(t *testing.T) {
+		setts := &filtering.Settings{
+			ClientSafeSearch:  forced.SafeSearch,
+			SafeSearchEnabled: true,
+		}
+
+		exempt.applySafeSearch(setts)
+
+		assert.False(t, setts.SafeSearchEnabled)
+		assert.Nil(t, setts.ClientSafeSearch)
+	})
+}
+
+func TestClientsContainer_subscribe(t *testing.T) {
+	const testTimeout = time.Second
+
+	clients := newClientsContainer(t)
+
+	events := make(chan *clientEvent, 3)
+	clients.subscribe(func(e *clientEvent) { events <- e })
+
+	c := &Client{
+		IDs:  []string{"1.1.1.1"},
+		Name: "client1",
+	}
+
+	ok, err := clients.Add(c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	updated := &Client{
+		IDs:  []string{"1.1.1.1", "2.2.2.2"},
+		Name: "client1",
+	}
+
+	err = clients.Update(c, updated)
+	require.NoError(t, err)
+
+	ok = clients.Del(updated.Name)
+	require.True(t, ok)
+
+	want := []*clientEvent{{
+		client: c,
+		change: clientAdded,
+	}, {
+		client: updated,
+		prev:   c,
+		change: clientUpdated,
+	}, {
+		client: updated,
+		change: clientDeleted,
+	}}
+
+	for _, w := range want {
+		e, _ := testutil.RequireReceive(t, events, testTimeout)
+		assert.Equal(t, w, e)
+	}
+
+	err = clients.close()
+	require.NoError(t, err)
+
+	assert.Empty(t, clients.subscribers)
+}
+
+func TestClientsContainer_mergeRuntime(t *testing.T) {
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	t.Run("two_sources", func(t *testing.T) {
+		clients := newClientsContainer(t)
+
+		require.True(t, clients.AddHost(ip, "from-rdns", ClientSourceRDNS))
+		require.True(t, clients.AddHost(ip, "from-dhcp", ClientSourceDHCP))
+
+		require.Len(t, clients.ipToRC, 1)
+
+		rc := clients.ipToRC[ip]
+		require.NotNil(t, rc)
+
+		assert.Equal(t, "from-dhcp", rc.Host)
+		assert.Equal(t, ClientSourceDHCP, rc.Source)
+		assert.Equal(t, map[clientSource]string{
+			ClientSourceRDNS: "from-rdns",
+			ClientSourceDHCP: "from-dhcp",
+		}, rc.hosts)
+
+		removed := clients.rmHostsBySrc(ClientSourceDHCP)
+		assert.Empty(t, removed)
+
+		rc = clients.ipToRC[ip]
+		require.NotNil(t, rc)
+
+		assert.Equal(t, "from-rdns", rc.Host)
+		assert.Equal(t, ClientSourceRDNS, rc.Source)
+
+		removed = clients.rmHostsBySrc(ClientSourceRDNS)
+		assert.Len(t, removed, 1)
+		assert.Empty(t, clients.ipToRC)
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		clients := newClientsContainer(t)
+
+		require.True(t, clients.AddHost(ip, "from-dhcp", ClientSourceDHCP))
+		assert.False(t, clients.AddHost(ip, "from-hosts", ClientSourceHostsFile))
+		assert.False(t, clients.AddHost(ip, "from-rdns", ClientSourceRDNS))
+		assert.False(t, clients.AddHost(ip, "from-arp", ClientSourceARP))
+
+		rc := clients.ipToRC[ip]
+		require.NotNil(t, rc)
+
+		assert.Equal(t, "from-dhcp", rc.Host)
+		assert.Equal(t, ClientSourceDHCP, rc.Source)
+		assert.Len(t, rc.hosts, 4)
+
+		cj := newRuntimeClientJSON(ip, rc, clients.srcPriority)
+		assert.Equal(t, "from-dhcp", cj.Name)
+		assert.Equal(t, []*runtimeClientHostJSON{{
+			Name:   "from-dhcp",
+			Source: ClientSourceDHCP,
+		}, {
+			Name:   "from-hosts",
+			Source: ClientSourceHostsFile,
+		}, {
+			Name:   "from-rdns",
+			Source: ClientSourceRDNS,
+		}, {
+			Name:   "from-arp",
+			Source: ClientSourceARP,
+		}}, cj.Hosts)
+	})
+}
+
+func TestClientsContainer_setSourcePriority(t *testing.T) {
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	clients := newClientsContainer(t)
+
+	require.True(t, clients.AddHost(ip, "from-dhcp", ClientSourceDHCP))
+	require.False(t, clients.AddHost(ip, "from-rdns", ClientSourceRDNS))
+
+	err := clients.setSourcePriority([]string{"rdns"})
+	require.NoError(t, err)
+
+	rc := clients.ipToRC[ip]
+	require.NotNil(t, rc)
+
+	assert.Equal(t, "from-rdns", rc.Host)
+	assert.Equal(t, ClientSourceRDNS, rc.Source)
+	assert.True(t, clients.hasHigherSource(ip, ClientSourceDHCP))
+
+	cj := newRuntimeClientJSON(ip, rc, clients.srcPriority)
+	assert.Equal(t, []*runtimeClientHostJSON{{
+		Name:   "from-rdns",
+		Source: ClientSourceRDNS,
+	}, {
+		Name:   "from-dhcp",
+		Source: ClientSourceDHCP,
+	}}, cj.Hosts)
+
+	assert.False(t, clients.AddHost(ip, "from-hosts", ClientSourceHostsFile))
+	assert.Equal(t, "from-rdns", rc.Host)
+
+	clients.rmHostsBySrc(ClientSourceRDNS)
+	assert.Equal(t, "from-dhcp", rc.Host)
+	assert.Equal(t, ClientSourceDHCP, rc.Source)
+
+	err = clients.setSourcePriority(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "from-dhcp", rc.Host)
+	assert.False(t, clients.hasHigherSource(ip, ClientSourceDHCP))
+	assert.True(t, clients.hasHigherSource(ip, ClientSourceRDNS))
+}
+
+func TestNewClientSourcePriority(t *testing.T) {
+	testCases := []struct {
+		name       string
+		wantErrMsg string
+		names      []string
+		wantOrder  []clientSource
+	}{{
+		name:       "default",
+		wantErrMsg: "",
+		names:      nil,
+		wantOrder: []clientSource{
+			ClientSourcePersistent,
+			ClientSourceDHCP,
+			ClientSourceHostsFile,
+			ClientSourceRDNS,
+			ClientSourceARP,
+			ClientSourceWHOIS,
+			ClientSourceNone,
+		},
+	}, {
+		name:       "partial",
+		wantErrMsg: "",
+		names:      []string{"WHOIS", "etc/hosts"},
+		wantOrder: []clientSource{
+			ClientSourcePersistent,
+			ClientSourceWHOIS,
+			ClientSourceHostsFile,
+			ClientSourceDHCP,
+			ClientSourceRDNS,
+			ClientSourceARP,
+			ClientSourceNone,
+		},
+	}, {
+		name:       "unknown",
+		wantErrMsg: `source at index 1: unknown runtime client source "bad"`,
+		names:      []string{"dhcp", "bad"},
+		wantOrder:  nil,
+	}, {
+		name:       "duplicate",
+		wantErrMsg: `source at index 1: duplicate source "DHCP"`,
+		names:      []string{"dhcp", "DHCP"},
+		wantOrder:  nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prio, err := newClientSourcePriority(tc.names)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+			if tc.wantErrMsg != "" {
+				return
+			}
+
+			for i := 1; i < len(tc.wantOrder); i++ {
+				assert.Truef(
+					t,
+					prio.higher(tc.wantOrder[i-1], tc.wantOrder[i]),
+					"%s must be higher than %s",
+					tc.wantOrder[i-1],
+					tc.wantOrder[i],
+				)
+			}
+		})
+	}
+}
+
+func TestClientsContainer_findEffective(t *testing.T) {
+	filtering.InitModule()
+
+	clients := newClientsContainer(t)
+
+	testClients := []*Client{{
+		Name:             "base",
+		IDs:              []string{"1.1.1.1"},
+		Upstreams:        []string{"1.2.3.4"},
+		UseOwnSettings:   true,
+		FilteringEnabled: true,
+		ParentalEnabled:  true,
+		Ratelimit:        10,
+	}, {
+		Name:                  "kids",
+		InheritFrom:           "base",
+		IDs:                   []string{"1.1.1.2"},
+		UseOwnBlockedServices: true,
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+			IDs:      []string{"youtube"},
+		},
+	}, {
+		Name:        "tablet",
+		InheritFrom: "kids",
+		IDs:         []string{"1.1.1.3"},
+		Ratelimit:   5,
+	}, {
+		Name:        "cycle_a",
+		InheritFrom: "cycle_b",
+		IDs:         []string{"1.1.1.4"},
+	}, {
+		Name:           "cycle_b",
+		InheritFrom:    "cycle_a",
+		IDs:            []string{"1.1.1.5"},
+		UseOwnSettings: true,
+	}, {
+		Name:        "orphan",
+		InheritFrom: "missing",
+		IDs:         []string{"1.1.1.6"},
+	}}
+
+	for _, c := range testClients {
+		ok, err := clients.Add(c)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	t.Run("chain", func(t *testing.T) {
+		c, ok := clients.findEffective("1.1.1.3")
+		require.True(t, ok)
+
+		assert.True(t, c.UseOwnSettings)
+		assert.True(t, c.FilteringEnabled)
+		assert.True(t, c.ParentalEnabled)
+		assert.True(t, c.UseOwnBlockedServices)
+		assert.Equal(t, []string{"youtube"}, c.BlockedServices.IDs)
+		assert.Equal(t, []string{"1.2.3.4"}, c.Upstreams)
+		assert.Equal(t, 5, c.Ratelimit)
+		assert.Equal(t, "kids", c.InheritFrom)
+
+		conf, err := clients.findUpstreams("1.1.1.3")
+		require.NoError(t, err)
+		require.NotNil(t, conf)
+
+		baseConf, err := clients.findUpstreams("1.1.1.1")
+		require.NoError(t, err)
+
+		assert.Same(t, baseConf, conf)
+	})
+
+	t.Run("stored_unchanged", func(t *testing.T) {
+		c, ok := clients.Find("1.1.1.3")
+		require.True(t, ok)
+
+		assert.False(t, c.UseOwnSettings)
+		assert.Empty(t, c.Upstreams)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		c, ok := clients.findEffective("1.1.1.4")
+		require.True(t, ok)
+
+		assert.True(t, c.UseOwnSettings)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		c, ok := clients.findEffective("1.1.1.6")
+		require.True(t, ok)
+
+		assert.False(t, c.UseOwnSettings)
+	})
+}
+
+func TestClientsContainer_checkInheritFrom(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	for _, c := range []*Client{{
+		Name: "base",
+		IDs:  []string{"1.1.1.1"},
+	}, {
+		Name:        "kids",
+		InheritFrom: "base",
+		IDs:         []string{"1.1.1.2"},
+	}} {
+		ok, err := clients.Add(c)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	testCases := []struct {
+		name       string
+		client     string
+		prevName   string
+		from       string
+		wantErrMsg string
+	}{{
+		name:       "none",
+		client:     "new",
+		prevName:   "",
+		from:       "",
+		wantErrMsg: "",
+	}, {
+		name:       "valid",
+		client:     "new",
+		prevName:   "",
+		from:       "kids",
+		wantErrMsg: "",
+	}, {
+		name:       "not_found",
+		client:     "new",
+		prevName:   "",
+		from:       "missing",
+		wantErrMsg: `inherit_from: client "missing" not found`,
+	}, {
+		name:       "self",
+		client:     "base",
+		prevName:   "base",
+		from:       "base",
+		wantErrMsg: "inherit_from: client can't inherit from itself",
+	}, {
+		name:       "cycle",
+		client:     "base",
+		prevName:   "base",
+		from:       "kids",
+		wantErrMsg: `inherit_from: inheritance cycle at client "kids"`,
+	}, {
+		name:       "cycle_renamed",
+		client:     "base_new",
+		prevName:   "base",
+		from:       "kids",
+		wantErrMsg: `inherit_from: inheritance cycle at client "kids"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := clients.checkInheritFrom(tc.client, tc.prevName, tc.from)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
+
+func TestClientsContainer_findByHost(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	ok, err := clients.Add(&Client{
+		IDs:  []string{"Printer.lan"},
+		Name: "printer",
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = clients.Add(&Client{
+		IDs:  []string{"laptop"},
+		Name: "laptop",
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	var (
+		printerIP = netip.MustParseAddr("1.2.3.4")
+		laptopIP  = netip.MustParseAddr("1.2.3.5")
+		unknownIP = netip.MustParseAddr("1.2.3.6")
+	)
+
+	clients.AddHost(printerIP, "printer.lan", ClientSourceDHCP)
+	clients.AddHost(laptopIP, "laptop", ClientSourceARP)
+	clients.AddHost(laptopIP, "Printer.lan", ClientSourceRDNS)
+	clients.AddHost(unknownIP, "unknown", ClientSourceDHCP)
+
+	testCases := []struct {
+		name     string
+		id       string
+		wantName string
+		wantOK   bool
+	}{{
+		name:     "dhcp",
+		id:       printerIP.String(),
+		wantName: "printer",
+		wantOK:   true,
+	}, {
+		name:     "priority",
+		id:       laptopIP.String(),
+		wantName: "printer",
+		wantOK:   true,
+	}, {
+		name:     "unknown_host",
+		id:       unknownIP.String(),
+		wantName: "",
+		wantOK:   false,
+	}, {
+		name:     "hostname",
+		id:       "laptop",
+		wantName: "laptop",
+		wantOK:   true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, found := clients.Find(tc.id)
+			require.Equal(t, tc.wantOK, found)
+
+			if tc.wantOK {
+				assert.Equal(t, tc.wantName, c.Name)
+			}
+		})
+	}
+
+	t.Run("bad_hostname", func(t *testing.T) {
+		_, err = clients.Add(&Client{
+			IDs:  []string{"bad..host"},
+			Name: "bad",
+		})
+		testutil.AssertErrorMsg(t, `client at index 0: bad client identifier "bad..host"`, err)
+	})
+}
+
+func TestNormalizeClientIdentifier_mac(t *testing.T) {
+	const want = "aa:bb:cc:dd:ee:ff"
+
+	testCases := []struct {
+		name       string
+		in         string
+		want       string
+		wantErrMsg string
+	}{{
+		name:       "colons",
+		in:         "aa:bb:cc:dd:ee:ff",
+		want:       want,
+		wantErrMsg: "",
+	}, {
+		name:       "colons_upper",
+		in:         "AA:BB:CC:DD:EE:FF",
+		want:       want,
+		wantErrMsg: "",
+	}, {
+		name:       "hyphens",
+		in:         "aa-bb-cc-dd-ee-ff",
+		want:       want,
+		wantErrMsg: "",
+	}, {
+		name:       "dots",
+		in:         "aabb.ccdd.eeff",
+		want:       want,
+		wantErrMsg: "",
+	}, {
+		name: "bad_colons",
+		in:   "aa:bb:cc:dd:ee",
+		want: "",
+		wantErrMsg: "bad client identifier: " +
+			"address aa:bb:cc:dd:ee: invalid MAC address",
+	}, {
+		name: "bad_hyphens",
+		in:   "aa-bb-cc-dd-ee-ff-00",
+		want: "",
+		wantErrMsg: "bad client identifier: " +
+			"address aa-bb-cc-dd-ee-ff-00: invalid MAC address",
+	}, {
+		name: "bad_dots",
+		in:   "aabb.ccdd.eeff.0011.2233",
+		want: "",
+		wantErrMsg: "bad client identifier: " +
+			"address aabb.ccdd.eeff.0011.2233: invalid MAC address",
+	}, {
+		name:       "client_id",
+		in:         "aa-bb-cc",
+		want:       "aa-bb-cc",
+		wantErrMsg: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := normalizeClientIdentifier(tc.in)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClientsContainer_Find_mac(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	ok, err := clients.Add(&Client{
+		Name: "laptop",
+		IDs:  []string{"AA-BB-CC-DD-EE-FF"},
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	for _, id := range []string{
+		"aa:bb:cc:dd:ee:ff",
+		"AA:BB:CC:DD:EE:FF",
+		"aa-bb-cc-dd-ee-ff",
+		"aabb.ccdd.eeff",
+	} {
+		c, found := clients.Find(id)
+		require.Truef(t, found, "id %q", id)
+
+		assert.Equal(t, "laptop", c.Name)
+		assert.Equal(t, []string{"aa:bb:cc:dd:ee:ff"}, c.IDs)
+	}
+
+	_, err = clients.Add(&Client{
+		Name: "phone",
+		IDs:  []string{"aa-bb-cc-dd-ee"},
+	})
+	testutil.AssertErrorMsg(
+		t,
+		"client at index 0: bad client identifier: "+
+			"address aa-bb-cc-dd-ee: invalid MAC address",
+		err,
+	)
+}
+
+func TestClientsContainer_summary(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	assert.Equal(t, &clientsSummaryJSON{}, clients.summary())
+
+	ok, err := clients.Add(&Client{
+		Name: "client1",
+		IDs:  []string{"1.1.1.1"},
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok = clients.AddHost(netip.MustParseAddr("2.2.2.2"), "host2", ClientSourceHostsFile)
+	require.True(t, ok)
+
+	ok = clients.AddHost(netip.MustParseAddr("3.3.3.3"), "host3", ClientSourceARP)
+	require.True(t, ok)
+
+	assert.Equal(t, &clientsSummaryJSON{
+		Persistent: 1,
+		Runtime:    2,
+	}, clients.summary())
+}
+
+func TestClient_applyProtectionSchedules(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		parental         *schedule.Weekly
+		safeBrowsing     *schedule.Weekly
+		name             string
+		base             bool
+		wantParental     bool
+		wantSafeBrowsing bool
+	}{{
+		parental:         nil,
+		safeBrowsing:     nil,
+		name:             "no_schedules",
+		base:             false,
+		wantParental:     false,
+		wantSafeBrowsing: false,
+	}, {
+		parental:         nil,
+		safeBrowsing:     nil,
+		name:             "no_schedules_base",
+		base:             true,
+		wantParental:     true,
+		wantSafeBrowsing: true,
+	}, {
+		parental:         schedule.FullWeekly(),
+		safeBrowsing:     schedule.EmptyWeekly(),
+		name:             "within_parental",
+		base:             false,
+		wantParental:     true,
+		wantSafeBrowsing: false,
+	}, {
+		parental:         schedule.EmptyWeekly(),
+		safeBrowsing:     schedule.FullWeekly(),
+		name:             "within_safebrowsing",
+		base:             false,
+		wantParental:     false,
+		wantSafeBrowsing: true,
+	}, {
+		parental:         schedule.EmptyWeekly(),
+		safeBrowsing:     schedule.EmptyWeekly(),
+		name:             "outside_base",
+		base:             true,
+		wantParental:     true,
+		wantSafeBrowsing: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{
+				ParentalSchedule:     tc.parental,
+				SafeBrowsingSchedule: tc.safeBrowsing,
+			}
+
+			setts := &filtering.Settings{
+				ParentalEnabled:     tc.base,
+				SafeBrowsingEnabled: tc.base,
+			}
+
+			c.applyProtectionSchedules(setts, now)
+
+			assert.Equal(t, tc.wantParental, setts.ParentalEnabled)
+			assert.Equal(t, tc.wantSafeBrowsing, setts.SafeBrowsingEnabled)
+		})
+	}
+}
+
+func TestClientsContainer_Find_clientIDGlob(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	for _, c := range []*Client{{
+		Name: "kiosks",
+		IDs:  []string{"Kiosk-*"},
+	}, {
+		Name: "kiosk_lobby",
+		IDs:  []string{"kiosk-001"},
+	}, {
+		Name: "kiosks_old",
+		IDs:  []string{"kiosk-0??"},
+	}} {
+		ok, err := clients.Add(c)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	testCases := []struct {
+		name     string
+		id       string
+		wantName string
+		wantOK   bool
+	}{{
+		name:     "exact_over_glob",
+		id:       "kiosk-001",
+		wantName: "kiosk_lobby",
+		wantOK:   true,
+	}, {
+		name:     "longest_glob",
+		id:       "kiosk-002",
+		wantName: "kiosks_old",
+		wantOK:   true,
+	}, {
+		name:     "glob",
+		id:       "kiosk-1002",
+		wantName: "kiosks",
+		wantOK:   true,
+	}, {
+		name:     "no_match",
+		id:       "printer-001",
+		wantName: "",
+		wantOK:   false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, found := clients.Find(tc.id)
+			require.Equal(t, tc.wantOK, found)
+
+			if tc.wantOK {
+				assert.Equal(t, tc.wantName, c.Name)
+			}
+		})
+	}
+
+	t.Run("bad_glob", func(t *testing.T) {
+		_, err := clients.Add(&Client{
+			Name: "bad",
+			IDs:  []string{"kiosk_*"},
+		})
+		testutil.AssertErrorMsg(
+			t,
+			`client at index 0: bad client identifier: `+
+				`invalid clientid glob "kiosk_*": bad hostname label rune '_'`,
+			err,
+		)
+	})
+}
@@ -0,0 +1,413 @@
+package home
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/stringutil"
+	"github.com/AdguardTeam/urlfilter/rules"
+	"github.com/bluele/gcache"
+	"golang.org/x/exp/slices"
+)
+
+// Default values of the clients pending approval.
+const (
+	// maxPendingClients is the maximum number of the clients pending approval.
+	// The least recently seen ones are forgotten when it's exceeded.
+	maxPendingClients = 1000
+
+	// pendingClientTTL is the time, after which a client pending approval is
+	// forgotten.  It's recorded again with its next request.
+	pendingClientTTL = 24 * time.Hour
+)
+
+// clientsApprovalConfig is the configuration of the approval of the new
+// runtime clients.
+type clientsApprovalConfig struct {
+	// Profile is the name of the persistent client, the settings of which are
+	// applied to the clients pending approval.  If it's empty, all requests
+	// of such clients are blocked.
+	Profile string `yaml:"profile"`
+
+	// Approved are the IP and MAC addresses of the approved clients.
+	Approved []string `yaml:"approved"`
+
+	// Enabled, if true, makes the clients, which are neither persistent nor
+	// approved, pending approval.
+	Enabled bool `yaml:"enabled"`
+}
+
+// clientsApproval is the state of the approval of the new runtime clients.
+type clientsApproval struct {
+	// approved are the canonical IP and MAC addresses of the approved
+	// clients.
+	approved *stringutil.Set
+
+	// pending maps the IP addresses of the clients pending approval to their
+	// *pendingClient values.  It's safe for concurrent use, so the clients
+	// are recorded under the read lock.
+	pending gcache.Cache
+
+	// blockRules are the client rules blocking all requests of the clients
+	// pending approval when there is no profile.
+	blockRules []*rules.NetworkRule
+
+	// profile is the name of the persistent client, the settings of which are
+	// applied to the clients pending approval.
+	profile string
+}
+
+// pendingClient is a client pending approval.
+type pendingClient struct {
+	// firstSeen is the time of the first request from the client.
+	firstSeen time.Time
+
+	// mac is the hardware address of the client, if it's known.
+	mac net.HardwareAddr
+
+	// ip is the IP address of the client.
+	ip netip.Addr
+}
+
+// setApproval sets the approval of the new runtime clients.  conf may be nil,
+// in which case the approval is disabled.  The profile client, if any, should
+// already be added.
+func (clients *clientsContainer) setApproval(conf *clientsApprovalConfig) (err error) {
+	if conf == nil {
+		conf = &clientsApprovalConfig{}
+	}
+
+	approved := stringutil.NewSet()
+	for i, id := range conf.Approved {
+		var norm string
+		norm, err = normalizeApprovedID(id)
+		if err != nil {
+			return fmt.Errorf("clients approval: approved: at index %d: %w", i, err)
+		}
+
+		approved.Add(norm)
+	}
+
+	blockRule, err := rules.NewNetworkRule("||*^", filtering.CustomListID)
+	if err != nil {
+		// Should never happen, since the rule is valid.
+		return fmt.Errorf("clients approval: creating block rule: %w", err)
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if !conf.Enabled {
+		clients.approval = nil
+
+		return nil
+	}
+
+	if _, ok := clients.list[conf.Profile]; !ok && conf.Profile != "" {
+		// Don't return an error, since the profile client could have been
+		// deleted after the configuration has been written.
+		log.Info(
+			"clients approval: warning: no client named %q, blocking pending clients",
+			conf.Profile,
+		)
+	}
+
+	var pending gcache.Cache
+	if clients.approval != nil {
+		pending = clients.approval.pending
+	} else {
+		pending = gcache.New(maxPendingClients).LRU().Build()
+	}
+
+	clients.approval = &clientsApproval{
+		approved:   approved,
+		pending:    pending,
+		blockRules: []*rules.NetworkRule{blockRule},
+		profile:    conf.Profile,
+	}
+
+	// Some of the clients pending approval may have been approved in the
+	// configuration file.
+	clients.removeApprovedLocked()
+
+	return nil
+}
+
+// approvalConf returns the configuration of the approval of the new runtime
+// clients.  The disabled approval is returned as nil to keep it out of the
+// configuration file.
+func (clients *clientsContainer) approvalConf() (conf *clientsApprovalConfig) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	a := clients.approval
+	if a == nil {
+		return nil
+	}
+
+	approved := a.approved.Values()
+	slices.Sort(approved)
+
+	return &clientsApprovalConfig{
+		Profile:  a.profile,
+		Approved: approved,
+		Enabled:  true,
+	}
+}
+
+// normalizeApprovedID returns the canonical form of the IP or MAC address id.
+func normalizeApprovedID(id string) (norm string, err error) {
+	if ip, ipErr := netip.ParseAddr(id); ipErr == nil {
+		return ip.Unmap().String(), nil
+	}
+
+	mac, err := net.ParseMAC(id)
+	if err != nil {
+		return "", fmt.Errorf("bad client id %q: want ip or mac address", id)
+	}
+
+	return mac.String(), nil
+}
+
+// macByIPLocked returns the hardware address of the runtime client with ip
+// known from DHCP or ARP, if any.  clients.lock is expected to be locked.
+func (clients *clientsContainer) macByIPLocked(ip netip.Addr) (mac net.HardwareAddr) {
+	if clients.dhcpServer != nil {
+		mac = clients.dhcpServer.FindMACbyIP(ip)
+		if mac != nil {
+			return mac
+		}
+	}
+
+	return clients.arpMACs[ip]
+}
+
+// pendingProfile records the client with ip as pending approval if the
+// approval is enabled and neither its IP nor its MAC address have been
+// approved.  profile is the client, settings of which must be applied to the
+// pending client, or nil, if all its requests must be blocked by blockRules.
+// If record is false, the client is only checked and isn't recorded.  ip must
+// not be a persistent client.
+func (clients *clientsContainer) pendingProfile(
+	ip netip.Addr,
+	record bool,
+) (profile *Client, blockRules []*rules.NetworkRule, pending bool) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	a := clients.approval
+	if a == nil {
+		return nil, nil, false
+	}
+
+	// The clients pending approval are removed once they are approved, see
+	// [clientsContainer.removeApprovedLocked], so only check the new ones.
+	if !a.isPending(ip) {
+		if a.approved.Has(ip.String()) {
+			return nil, nil, false
+		}
+
+		mac := clients.macByIPLocked(ip)
+		if mac != nil && a.approved.Has(mac.String()) {
+			return nil, nil, false
+		}
+
+		if record {
+			a.record(ip, mac)
+		}
+	}
+
+	c, ok := clients.list[a.profile]
+	if !ok {
+		return nil, a.blockRules, true
+	}
+
+	return clients.effectiveLocked(c), nil, true
+}
+
+// isPending returns true if the client with ip has been recorded as pending
+// approval.
+func (a *clientsApproval) isPending(ip netip.Addr) (ok bool) {
+	_, err := a.pending.Get(ip)
+	if err != nil {
+		if !errors.Is(err, gcache.KeyNotFoundError) {
+			log.Debug("clients: retrieving pending client %s: %s", ip, err)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// record records the client with ip and mac as pending approval.  mac may be
+// nil.
+func (a *clientsApproval) record(ip netip.Addr, mac net.HardwareAddr) {
+	log.Info("clients: client %s is pending approval", ip)
+
+	err := a.pending.SetWithExpire(ip, &pendingClient{
+		firstSeen: time.Now(),
+		mac:       mac,
+		ip:        ip,
+	}, pendingClientTTL)
+	if err != nil {
+		log.Error("clients: recording pending client %s: %s", ip, err)
+	}
+}
+
+// pendingClients returns the clients pending approval, which haven't expired
+// yet, in no particular order.
+func (a *clientsApproval) pendingClients() (pcs []*pendingClient) {
+	for ip, val := range a.pending.GetALL(true) {
+		pc, ok := val.(*pendingClient)
+		if !ok {
+			log.Debug("clients: pending client %v: bad type %T", ip, val)
+
+			continue
+		}
+
+		pcs = append(pcs, pc)
+	}
+
+	return pcs
+}
+
+// removeApprovedLocked removes the clients, IP or MAC addresses of which have
+// been approved, from the pending ones.  The hardware addresses, which have
+// been unknown when the clients were recorded, are looked up again.
+// clients.lock is expected to be locked.
+func (clients *clientsContainer) removeApprovedLocked() {
+	a := clients.approval
+	for _, pc := range a.pendingClients() {
+		mac := pc.mac
+		if mac == nil {
+			mac = clients.macByIPLocked(pc.ip)
+		}
+
+		if a.approved.Has(pc.ip.String()) || mac != nil && a.approved.Has(mac.String()) {
+			a.pending.Remove(pc.ip)
+		}
+	}
+}
+
+// approve approves the client with the canonical IP or MAC address id and
+// removes the corresponding clients from the pending ones.  ok is false if the
+// approval is disabled.
+func (clients *clientsContainer) approve(id string) (ok bool) {
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	a := clients.approval
+	if a == nil {
+		return false
+	}
+
+	a.approved.Add(id)
+	clients.removeApprovedLocked()
+
+	return true
+}
+
+// pendingClientJSON is the JSON representation of a client pending approval.
+type pendingClientJSON struct {
+	FirstSeen time.Time  `json:"first_seen"`
+	IP        netip.Addr `json:"ip"`
+	MAC       string     `json:"mac,omitempty"`
+	Name      string     `json:"name,omitempty"`
+}
+
+// pendingClientsJSON is the response to the GET /control/clients/pending HTTP
+// API.
+type pendingClientsJSON struct {
+	Clients []*pendingClientJSON `json:"clients"`
+}
+
+// pendingJSON returns the clients pending approval ordered by the time they
+// have been first seen.
+func (clients *clientsContainer) pendingJSON() (resp *pendingClientsJSON) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	resp = &pendingClientsJSON{
+		Clients: []*pendingClientJSON{},
+	}
+
+	a := clients.approval
+	if a == nil {
+		return resp
+	}
+
+	pcs := a.pendingClients()
+	slices.SortFunc(pcs, func(a, b *pendingClient) (less bool) {
+		return a.firstSeen.Before(b.firstSeen)
+	})
+
+	for _, pc := range pcs {
+		pcj := &pendingClientJSON{
+			FirstSeen: pc.firstSeen,
+			IP:        pc.ip,
+		}
+
+		if pc.mac != nil {
+			pcj.MAC = pc.mac.String()
+		}
+
+		if rc, ok := clients.ipToRC[pc.ip]; ok {
+			pcj.Name = rc.Host
+		}
+
+		resp.Clients = append(resp.Clients, pcj)
+	}
+
+	return resp
+}
+
+// handleGetPendingClients is the handler for the GET /control/clients/pending
+// HTTP API.
+func (clients *clientsContainer) handleGetPendingClients(w http.ResponseWriter, r *http.Request) {
+	_ = aghhttp.WriteJSONResponse(w, r, clients.pendingJSON())
+}
+
+// approveClientJSON is the request to the POST /control/clients/approve HTTP
+// API.
+type approveClientJSON struct {
+	// ID is the IP or MAC address of the client.
+	ID string `json:"id"`
+}
+
+// handleApproveClient is the handler for the POST /control/clients/approve
+// HTTP API.
+func (clients *clientsContainer) handleApproveClient(w http.ResponseWriter, r *http.Request) {
+	req := &approveClientJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	id, err := normalizeApprovedID(req.ID)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+		return
+	}
+
+	if !clients.approve(id) {
+		aghhttp.Error(r, w, http.StatusBadRequest, "clients approval is disabled")
+
+		return
+	}
+
+	onConfigModified()
+
+	aghhttp.OK(w)
+}
@@ -0,0 +1,191 @@
+package home
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientsContainer_approval(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	var (
+		approvedIP = netip.MustParseAddr("1.1.1.1")
+		newIP      = netip.MustParseAddr("2.2.2.2")
+		otherIP    = netip.MustParseAddr("3.3.3.3")
+	)
+
+	err := clients.setApproval(&clientsApprovalConfig{
+		Approved: []string{"AA-AA-AA-AA-AA-AA", approvedIP.String()},
+		Enabled:  true,
+	})
+	require.NoError(t, err)
+
+	t.Run("conf", func(t *testing.T) {
+		conf := clients.approvalConf()
+		require.NotNil(t, conf)
+
+		assert.True(t, conf.Enabled)
+		assert.Equal(t, []string{"1.1.1.1", "aa:aa:aa:aa:aa:aa"}, conf.Approved)
+	})
+
+	t.Run("approved", func(t *testing.T) {
+		_, _, pending := clients.pendingProfile(approvedIP, true)
+		assert.False(t, pending)
+	})
+
+	t.Run("approved_mac", func(t *testing.T) {
+		arpIP := netip.MustParseAddr("4.4.4.4")
+		clients.arpMACs = map[netip.Addr]net.HardwareAddr{
+			arpIP: {0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA},
+		}
+
+		_, _, pending := clients.pendingProfile(arpIP, true)
+		assert.False(t, pending)
+	})
+
+	t.Run("not_recorded", func(t *testing.T) {
+		_, blockRules, pending := clients.pendingProfile(newIP, false)
+		require.True(t, pending)
+
+		assert.Len(t, blockRules, 1)
+		assert.Empty(t, clients.pendingJSON().Clients)
+	})
+
+	t.Run("quarantine_and_approve", func(t *testing.T) {
+		profile, blockRules, pending := clients.pendingProfile(newIP, true)
+		require.True(t, pending)
+
+		assert.Nil(t, profile)
+		assert.Len(t, blockRules, 1)
+
+		resp := clients.pendingJSON()
+		require.Len(t, resp.Clients, 1)
+
+		assert.Equal(t, newIP, resp.Clients[0].IP)
+
+		ok := clients.approve(newIP.String())
+		require.True(t, ok)
+
+		_, _, pending = clients.pendingProfile(newIP, true)
+		assert.False(t, pending)
+
+		assert.Empty(t, clients.pendingJSON().Clients)
+	})
+
+	t.Run("approve_mac_found_later", func(t *testing.T) {
+		ip := netip.MustParseAddr("5.5.5.5")
+		mac := net.HardwareAddr{0xBB, 0xBB, 0xBB, 0xBB, 0xBB, 0xBB}
+
+		_, _, pending := clients.pendingProfile(ip, true)
+		require.True(t, pending)
+
+		clients.arpMACs[ip] = mac
+
+		ok := clients.approve(mac.String())
+		require.True(t, ok)
+
+		_, _, pending = clients.pendingProfile(ip, true)
+		assert.False(t, pending)
+
+		assert.Empty(t, clients.pendingJSON().Clients)
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		ok, addErr := clients.Add(&Client{
+			Name:             "quarantine",
+			IDs:              []string{"192.0.2.1"},
+			UseOwnSettings:   true,
+			FilteringEnabled: true,
+		})
+		require.NoError(t, addErr)
+		require.True(t, ok)
+
+		err = clients.setApproval(&clientsApprovalConfig{
+			Profile: "quarantine",
+			Enabled: true,
+		})
+		require.NoError(t, err)
+
+		profile, blockRules, pending := clients.pendingProfile(otherIP, true)
+		require.True(t, pending)
+		require.NotNil(t, profile)
+
+		assert.Equal(t, "quarantine", profile.Name)
+		assert.Empty(t, blockRules)
+	})
+
+	t.Run("http_pending", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/control/clients/pending", nil)
+		w := httptest.NewRecorder()
+
+		clients.handleGetPendingClients(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &pendingClientsJSON{}
+		err = json.NewDecoder(w.Body).Decode(resp)
+		require.NoError(t, err)
+		require.Len(t, resp.Clients, 1)
+
+		assert.Equal(t, otherIP, resp.Clients[0].IP)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		err = clients.setApproval(nil)
+		require.NoError(t, err)
+
+		assert.Nil(t, clients.approvalConf())
+		assert.False(t, clients.approve(newIP.String()))
+
+		_, _, pending := clients.pendingProfile(otherIP, true)
+		assert.False(t, pending)
+
+		assert.Empty(t, clients.pendingJSON().Clients)
+	})
+
+	t.Run("bad_approved", func(t *testing.T) {
+		err = clients.setApproval(&clientsApprovalConfig{
+			Approved: []string{"bad"},
+			Enabled:  true,
+		})
+		testutil.AssertErrorMsg(
+			t,
+			`clients approval: approved: at index 0: bad client id "bad": `+
+				`want ip or mac address`,
+			err,
+		)
+	})
+}
+
+func TestClientsContainer_pendingProfile_limit(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	err := clients.setApproval(&clientsApprovalConfig{
+		Enabled: true,
+	})
+	require.NoError(t, err)
+
+	first := netip.MustParseAddr("10.0.0.0")
+	ip := first
+	for i := 0; i < maxPendingClients+1; i++ {
+		_, _, pending := clients.pendingProfile(ip, true)
+		require.True(t, pending)
+
+		ip = ip.Next()
+	}
+
+	pcs := clients.pendingJSON().Clients
+	require.Len(t, pcs, maxPendingClients)
+
+	// The least recently seen client must be forgotten.
+	for _, pc := range pcs {
+		assert.NotEqual(t, first, pc.IP)
+	}
+}
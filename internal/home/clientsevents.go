@@ -0,0 +1,109 @@
+package home
+
+import (
+	"fmt"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// clientChange is the type of a change of a persistent client.
+type clientChange uint8
+
+// clientChange values.
+const (
+	clientAdded clientChange = iota + 1
+	clientUpdated
+	clientDeleted
+)
+
+// type check
+var _ fmt.Stringer = clientChange(0)
+
+// String implements the [fmt.Stringer] interface for clientChange.
+func (c clientChange) String() (s string) {
+	switch c {
+	case clientAdded:
+		return "added"
+	case clientUpdated:
+		return "updated"
+	case clientDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("!bad_client_change_%d", c)
+	}
+}
+
+// clientEvent is the event of a change of a persistent client.
+type clientEvent struct {
+	// client is the added or updated client or the deleted one.  It must not
+	// be modified.
+	client *Client
+
+	// prev is the client before the update.  It's only set for
+	// [clientUpdated] and must not be modified.
+	prev *Client
+
+	// change is the type of the change.
+	change clientChange
+}
+
+// clientEventHandler is a callback for changes of persistent clients.
+type clientEventHandler func(e *clientEvent)
+
+// clientEventsBufSize is the number of events queued for each handler.  The
+// events are dropped if a handler doesn't keep up with them.
+const clientEventsBufSize = 64
+
+// subscribe registers h to be called for every change of the persistent
+// clients.  h is called in a separate goroutine in the order of the changes,
+// so it may use the container.  The changes themselves are never blocked by h.
+func (clients *clientsContainer) subscribe(h clientEventHandler) {
+	ch := make(chan *clientEvent, clientEventsBufSize)
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	clients.subscribers = append(clients.subscribers, ch)
+
+	go func() {
+		defer log.OnPanic("clients: event handler")
+
+		for e := range ch {
+			h(e)
+		}
+	}()
+}
+
+// notify sends the event about the change of c to all the subscribers without
+// blocking.  prev is only used for [clientUpdated].  clients.lock is expected
+// to be locked.
+func (clients *clientsContainer) notify(change clientChange, c, prev *Client) {
+	if len(clients.subscribers) == 0 {
+		return
+	}
+
+	e := &clientEvent{
+		client: c,
+		prev:   prev,
+		change: change,
+	}
+
+	for _, ch := range clients.subscribers {
+		select {
+		case ch <- e:
+			// Go on.
+		default:
+			log.Error("clients: event buffer is full, dropping event: client %q %s", c.Name, change)
+		}
+	}
+}
+
+// unsubscribeAll stops all the subscribers.  clients.lock is expected to be
+// locked.
+func (clients *clientsContainer) unsubscribeAll() {
+	for _, ch := range clients.subscribers {
+		close(ch)
+	}
+
+	clients.subscribers = nil
+}
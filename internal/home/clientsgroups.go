@@ -0,0 +1,450 @@
+package home
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/stringutil"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// ClientGroup is a named group of persistent clients sharing its settings.  The
+// members use the settings of the group, which they don't set themselves, see
+// [clientsContainer.effectiveLocked].
+type ClientGroup struct {
+	// settings is the client holding the settings of the group.  Only its
+	// settings inherited by the members are used, see [Client.inherit].
+	settings *Client
+
+	// Name is the unique name of the group.
+	Name string
+
+	// Members are the sorted names of the persistent clients in the group.
+	Members []string
+}
+
+// clientGroupObject is the representation of a client group in the
+// configuration file and the HTTP API.
+type clientGroupObject struct {
+	SafeSearchConf filtering.SafeSearchConfig `yaml:"safe_search" json:"safe_search"`
+
+	// BlockedServices are the blocked services of the group.  They are only
+	// used if UseOwnBlockedServices is true.
+	BlockedServices *filtering.BlockedServices `yaml:"blocked_services,omitempty" json:"blocked_services,omitempty"`
+
+	// ParentalSchedule is the schedule, within which the parental control is
+	// enabled for the members.
+	ParentalSchedule *schedule.Weekly `yaml:"parental_schedule,omitempty" json:"parental_schedule,omitempty"`
+
+	// SafeBrowsingSchedule is the schedule, within which the safe browsing is
+	// enabled for the members.
+	SafeBrowsingSchedule *schedule.Weekly `yaml:"safebrowsing_schedule,omitempty" json:"safebrowsing_schedule,omitempty"`
+
+	Name string `yaml:"name" json:"name"`
+
+	// Members are the names of the persistent clients in the group.
+	Members []string `yaml:"members" json:"members"`
+
+	// UseOwnSettings, if true, makes the filtering settings below used for the
+	// members, which don't set them themselves.
+	UseOwnSettings bool `yaml:"use_own_settings" json:"use_own_settings"`
+
+	FilteringEnabled    bool `yaml:"filtering_enabled" json:"filtering_enabled"`
+	ParentalEnabled     bool `yaml:"parental_enabled" json:"parental_enabled"`
+	SafeBrowsingEnabled bool `yaml:"safebrowsing_enabled" json:"safebrowsing_enabled"`
+
+	// UseOwnBlockedServices, if true, makes BlockedServices used for the
+	// members, which don't set them themselves.
+	UseOwnBlockedServices bool `yaml:"use_own_blocked_services" json:"use_own_blocked_services"`
+
+	// Ratelimit is the rate limit for the members, which don't set it
+	// themselves.  Zero means that it isn't set.
+	Ratelimit int `yaml:"ratelimit" json:"ratelimit"`
+}
+
+// newClientGroup returns a new client group described by o, which must not be
+// nil.  The members aren't checked.
+func (clients *clientsContainer) newClientGroup(o *clientGroupObject) (g *ClientGroup, err error) {
+	if o.Name == "" {
+		return nil, errors.Error("invalid name")
+	}
+
+	members := stringutil.NewSet()
+	for i, m := range o.Members {
+		if m == "" {
+			return nil, fmt.Errorf("members: at index %d: empty name", i)
+		} else if members.Has(m) {
+			return nil, fmt.Errorf("members: at index %d: duplicate client %q", i, m)
+		}
+
+		members.Add(m)
+	}
+
+	if o.BlockedServices != nil {
+		err = o.BlockedServices.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("blocked services: %w", err)
+		}
+	}
+
+	c := &Client{
+		Name:                  o.Name,
+		BlockedServices:       o.BlockedServices.Clone(),
+		ParentalSchedule:      cloneSchedule(o.ParentalSchedule),
+		SafeBrowsingSchedule:  cloneSchedule(o.SafeBrowsingSchedule),
+		UseOwnSettings:        o.UseOwnSettings,
+		FilteringEnabled:      o.FilteringEnabled,
+		ParentalEnabled:       o.ParentalEnabled,
+		SafeBrowsingEnabled:   o.SafeBrowsingEnabled,
+		UseOwnBlockedServices: o.UseOwnBlockedServices,
+		Ratelimit:             o.Ratelimit,
+		safeSearchConf:        o.SafeSearchConf,
+	}
+
+	if c.BlockedServices == nil {
+		c.BlockedServices = &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		}
+	}
+
+	if o.SafeSearchConf.Enabled {
+		c.safeSearchConf.CustomResolver = safeSearchResolver{}
+
+		err = c.setSafeSearch(
+			c.safeSearchConf,
+			clients.safeSearchCacheSize,
+			clients.safeSearchCacheTTL,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("init safesearch: %w", err)
+		}
+	}
+
+	memberNames := members.Values()
+	slices.Sort(memberNames)
+
+	return &ClientGroup{
+		settings: c,
+		Name:     o.Name,
+		Members:  memberNames,
+	}, nil
+}
+
+// toObject returns the representation of g in the configuration file and the
+// HTTP API.
+func (g *ClientGroup) toObject() (o *clientGroupObject) {
+	c := g.settings
+
+	o = &clientGroupObject{
+		SafeSearchConf:        c.safeSearchConf,
+		BlockedServices:       c.BlockedServices.Clone(),
+		ParentalSchedule:      cloneSchedule(c.ParentalSchedule),
+		SafeBrowsingSchedule:  cloneSchedule(c.SafeBrowsingSchedule),
+		Name:                  g.Name,
+		Members:               stringutil.CloneSlice(g.Members),
+		UseOwnSettings:        c.UseOwnSettings,
+		FilteringEnabled:      c.FilteringEnabled,
+		ParentalEnabled:       c.ParentalEnabled,
+		SafeBrowsingEnabled:   c.SafeBrowsingEnabled,
+		UseOwnBlockedServices: c.UseOwnBlockedServices,
+		Ratelimit:             c.Ratelimit,
+	}
+
+	// Don't write the resolver into the configuration file.
+	o.SafeSearchConf.CustomResolver = nil
+
+	return o
+}
+
+// setGroups sets the client groups from the configuration file.  The members
+// should already be added.  The unknown members are skipped, since the clients
+// could have been deleted after the configuration has been written.
+func (clients *clientsContainer) setGroups(objs []*clientGroupObject) (err error) {
+	groups := make(map[string]*ClientGroup, len(objs))
+	for i, o := range objs {
+		var g *ClientGroup
+		g, err = clients.newClientGroup(o)
+		if err != nil {
+			return fmt.Errorf("clients groups: at index %d: %w", i, err)
+		} else if _, ok := groups[g.Name]; ok {
+			return fmt.Errorf("clients groups: at index %d: duplicate group %q", i, g.Name)
+		}
+
+		groups[g.Name] = g
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	for _, g := range groups {
+		g.Members = stringutil.FilterOut(g.Members, func(m string) (unknown bool) {
+			_, ok := clients.list[m]
+			if !ok {
+				log.Info("clients groups: warning: group %q: no client named %q", g.Name, m)
+			}
+
+			return !ok
+		})
+	}
+
+	clients.groups = groups
+
+	return nil
+}
+
+// groupsConf returns the client groups sorted by name for the configuration
+// file.  objs is nil if there are no groups.
+func (clients *clientsContainer) groupsConf() (objs []*clientGroupObject) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	return clients.groupObjectsLocked()
+}
+
+// groupObjectsLocked returns the client groups sorted by name.  objs is nil if
+// there are no groups.  clients.lock is expected to be locked.
+func (clients *clientsContainer) groupObjectsLocked() (objs []*clientGroupObject) {
+	names := maps.Keys(clients.groups)
+	slices.Sort(names)
+
+	for _, name := range names {
+		objs = append(objs, clients.groups[name].toObject())
+	}
+
+	return objs
+}
+
+// addGroup adds a new client group described by o.  The members must be
+// existing persistent clients.
+func (clients *clientsContainer) addGroup(o *clientGroupObject) (err error) {
+	g, err := clients.newClientGroup(o)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if _, ok := clients.groups[g.Name]; ok {
+		return fmt.Errorf("group %q already exists", g.Name)
+	}
+
+	err = clients.checkMembersLocked(g)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	if clients.groups == nil {
+		clients.groups = map[string]*ClientGroup{}
+	}
+
+	clients.groups[g.Name] = g
+
+	return nil
+}
+
+// updateGroup replaces the client group named name with the one described by
+// o.  The members must be existing persistent clients.
+func (clients *clientsContainer) updateGroup(name string, o *clientGroupObject) (err error) {
+	g, err := clients.newClientGroup(o)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if _, ok := clients.groups[name]; !ok {
+		return fmt.Errorf("group %q not found", name)
+	}
+
+	if _, ok := clients.groups[g.Name]; ok && g.Name != name {
+		return fmt.Errorf("group %q already exists", g.Name)
+	}
+
+	err = clients.checkMembersLocked(g)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	delete(clients.groups, name)
+	clients.groups[g.Name] = g
+
+	return nil
+}
+
+// delGroup removes the client group named name.  ok is false if there is no
+// such group.
+func (clients *clientsContainer) delGroup(name string) (ok bool) {
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if _, ok = clients.groups[name]; ok {
+		delete(clients.groups, name)
+	}
+
+	return ok
+}
+
+// checkMembersLocked returns an error if any member of g isn't a persistent
+// client.  clients.lock is expected to be locked.
+func (clients *clientsContainer) checkMembersLocked(g *ClientGroup) (err error) {
+	for _, m := range g.Members {
+		if _, ok := clients.list[m]; !ok {
+			return fmt.Errorf("members: client %q not found", m)
+		}
+	}
+
+	return nil
+}
+
+// groupsOfLocked returns the groups, which the client named name is a member
+// of, sorted by name.  clients.lock is expected to be locked.
+func (clients *clientsContainer) groupsOfLocked(name string) (groups []*ClientGroup) {
+	for _, g := range clients.groups {
+		if slices.Contains(g.Members, name) {
+			groups = append(groups, g)
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b *ClientGroup) (less bool) { return a.Name < b.Name })
+
+	return groups
+}
+
+// renameMemberLocked replaces the member named prev with the one named name in
+// all groups.  clients.lock is expected to be locked.
+func (clients *clientsContainer) renameMemberLocked(prev, name string) {
+	for _, g := range clients.groups {
+		i := slices.Index(g.Members, prev)
+		if i == -1 {
+			continue
+		}
+
+		g.Members[i] = name
+		slices.Sort(g.Members)
+	}
+}
+
+// removeMemberLocked removes the member named name from all groups.
+// clients.lock is expected to be locked.
+func (clients *clientsContainer) removeMemberLocked(name string) {
+	for _, g := range clients.groups {
+		if i := slices.Index(g.Members, name); i != -1 {
+			g.Members = slices.Delete(g.Members, i, i+1)
+		}
+	}
+}
+
+// clientGroupsJSON is the response to the GET /control/clients/groups HTTP API.
+type clientGroupsJSON struct {
+	Groups []*clientGroupObject `json:"groups"`
+}
+
+// updateGroupJSON is the request to the POST /control/clients/groups/update
+// HTTP API.
+type updateGroupJSON struct {
+	Data *clientGroupObject `json:"data"`
+	Name string             `json:"name"`
+}
+
+// deleteGroupJSON is the request to the POST /control/clients/groups/delete
+// HTTP API.
+type deleteGroupJSON struct {
+	Name string `json:"name"`
+}
+
+// handleGetGroups is the handler for the GET /control/clients/groups HTTP API.
+func (clients *clientsContainer) handleGetGroups(w http.ResponseWriter, r *http.Request) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	resp := &clientGroupsJSON{
+		Groups: clients.groupObjectsLocked(),
+	}
+
+	if resp.Groups == nil {
+		resp.Groups = []*clientGroupObject{}
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
+
+// handleAddGroup is the handler for the POST /control/clients/groups/add HTTP
+// API.
+func (clients *clientsContainer) handleAddGroup(w http.ResponseWriter, r *http.Request) {
+	o := &clientGroupObject{}
+	err := json.NewDecoder(r.Body).Decode(o)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	err = clients.addGroup(o)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "adding group: %s", err)
+
+		return
+	}
+
+	onConfigModified()
+}
+
+// handleUpdateGroup is the handler for the POST /control/clients/groups/update
+// HTTP API.
+func (clients *clientsContainer) handleUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	req := &updateGroupJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	if req.Data == nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "data is required")
+
+		return
+	}
+
+	err = clients.updateGroup(req.Name, req.Data)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "updating group: %s", err)
+
+		return
+	}
+
+	onConfigModified()
+}
+
+// handleDelGroup is the handler for the POST /control/clients/groups/delete
+// HTTP API.
+func (clients *clientsContainer) handleDelGroup(w http.ResponseWriter, r *http.Request) {
+	req := &deleteGroupJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	if !clients.delGroup(req.Name) {
+		aghhttp.Error(r, w, http.StatusBadRequest, "group %q not found", req.Name)
+
+		return
+	}
+
+	onConfigModified()
+}
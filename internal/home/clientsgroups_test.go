@@ -0,0 +1,237 @@
+package home
+
+import (
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientsContainer_groups(t *testing.T) {
+	filtering.InitModule()
+
+	clients := newClientsContainer(t)
+
+	testClients := []*Client{{
+		Name:             "base",
+		IDs:              []string{"1.1.1.1"},
+		UseOwnSettings:   true,
+		FilteringEnabled: true,
+		Ratelimit:        10,
+	}, {
+		Name:        "tablet",
+		InheritFrom: "base",
+		IDs:         []string{"1.1.1.2"},
+	}, {
+		Name:      "phone",
+		IDs:       []string{"1.1.1.3"},
+		Ratelimit: 5,
+	}, {
+		Name: "laptop",
+		IDs:  []string{"1.1.1.4"},
+	}}
+
+	for _, c := range testClients {
+		c.BlockedServices = &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		}
+
+		ok, err := clients.Add(c)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	parentalSched := schedule.EmptyWeekly()
+	err := clients.addGroup(&clientGroupObject{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+			IDs:      []string{"youtube"},
+		},
+		ParentalSchedule:      parentalSched,
+		Name:                  "kids",
+		Members:               []string{"tablet", "phone"},
+		UseOwnSettings:        true,
+		ParentalEnabled:       true,
+		UseOwnBlockedServices: true,
+		Ratelimit:             20,
+	})
+	require.NoError(t, err)
+
+	err = clients.addGroup(&clientGroupObject{
+		Name:             "other",
+		Members:          []string{"phone"},
+		UseOwnSettings:   true,
+		FilteringEnabled: true,
+	})
+	require.NoError(t, err)
+
+	t.Run("membership", func(t *testing.T) {
+		conf := clients.groupsConf()
+		require.Len(t, conf, 2)
+
+		assert.Equal(t, "kids", conf[0].Name)
+		assert.Equal(t, []string{"phone", "tablet"}, conf[0].Members)
+		assert.Equal(t, "other", conf[1].Name)
+		assert.Equal(t, []string{"phone"}, conf[1].Members)
+	})
+
+	t.Run("group_over_inherited", func(t *testing.T) {
+		c, ok := clients.findEffective("1.1.1.2")
+		require.True(t, ok)
+
+		assert.True(t, c.UseOwnSettings)
+		assert.True(t, c.ParentalEnabled)
+		assert.False(t, c.FilteringEnabled)
+		assert.True(t, c.UseOwnBlockedServices)
+		assert.Equal(t, []string{"youtube"}, c.BlockedServices.IDs)
+		assert.NotNil(t, c.ParentalSchedule)
+		assert.Equal(t, 20, c.Ratelimit)
+	})
+
+	t.Run("client_over_group", func(t *testing.T) {
+		c, ok := clients.findEffective("1.1.1.3")
+		require.True(t, ok)
+
+		assert.Equal(t, 5, c.Ratelimit)
+
+		// The settings of the group named "kids" take precedence over the ones
+		// of the group named "other".
+		assert.True(t, c.ParentalEnabled)
+		assert.False(t, c.FilteringEnabled)
+	})
+
+	t.Run("not_member", func(t *testing.T) {
+		c, ok := clients.findEffective("1.1.1.4")
+		require.True(t, ok)
+
+		assert.False(t, c.UseOwnSettings)
+		assert.False(t, c.UseOwnBlockedServices)
+		assert.Zero(t, c.Ratelimit)
+	})
+
+	t.Run("rename_and_delete", func(t *testing.T) {
+		prev, ok := clients.Find("1.1.1.2")
+		require.True(t, ok)
+
+		c := prev.ShallowClone()
+		c.Name = "tablet_new"
+
+		err = clients.Update(prev, c)
+		require.NoError(t, err)
+
+		conf := clients.groupsConf()
+		require.Len(t, conf, 2)
+
+		assert.Equal(t, []string{"phone", "tablet_new"}, conf[0].Members)
+
+		ok = clients.Del("phone")
+		require.True(t, ok)
+
+		conf = clients.groupsConf()
+		require.Len(t, conf, 2)
+
+		assert.Equal(t, []string{"tablet_new"}, conf[0].Members)
+		assert.Empty(t, conf[1].Members)
+	})
+
+	t.Run("update_and_delete_group", func(t *testing.T) {
+		err = clients.updateGroup("other", &clientGroupObject{
+			Name:    "laptops",
+			Members: []string{"laptop"},
+		})
+		require.NoError(t, err)
+
+		conf := clients.groupsConf()
+		require.Len(t, conf, 2)
+
+		assert.Equal(t, "laptops", conf[1].Name)
+		assert.Equal(t, []string{"laptop"}, conf[1].Members)
+
+		ok := clients.delGroup("laptops")
+		require.True(t, ok)
+
+		assert.Len(t, clients.groupsConf(), 1)
+		assert.False(t, clients.delGroup("laptops"))
+	})
+
+	t.Run("config", func(t *testing.T) {
+		loaded := newClientsContainer(t)
+		err = loaded.addFromConfig(clients.forConfig(), &filtering.Config{})
+		require.NoError(t, err)
+
+		conf := clients.groupsConf()
+		conf[0].Members = append(conf[0].Members, "deleted")
+
+		err = loaded.setGroups(conf)
+		require.NoError(t, err)
+
+		assert.Equal(t, clients.groupsConf(), loaded.groupsConf())
+	})
+}
+
+func TestClientsContainer_addGroup_errors(t *testing.T) {
+	filtering.InitModule()
+
+	clients := newClientsContainer(t)
+
+	ok, err := clients.Add(&Client{
+		Name: "client",
+		IDs:  []string{"1.1.1.1"},
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	err = clients.addGroup(&clientGroupObject{
+		Name: "group",
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		group      *clientGroupObject
+		name       string
+		wantErrMsg string
+	}{{
+		group:      &clientGroupObject{},
+		name:       "no_name",
+		wantErrMsg: `invalid name`,
+	}, {
+		group: &clientGroupObject{
+			Name: "group",
+		},
+		name:       "duplicate_group",
+		wantErrMsg: `group "group" already exists`,
+	}, {
+		group: &clientGroupObject{
+			Name:    "new",
+			Members: []string{"unknown"},
+		},
+		name:       "unknown_member",
+		wantErrMsg: `members: client "unknown" not found`,
+	}, {
+		group: &clientGroupObject{
+			Name:    "new",
+			Members: []string{"client", "client"},
+		},
+		name:       "duplicate_member",
+		wantErrMsg: `members: at index 1: duplicate client "client"`,
+	}, {
+		group: &clientGroupObject{
+			BlockedServices: &filtering.BlockedServices{
+				IDs: []string{"unknown"},
+			},
+			Name: "new",
+		},
+		name:       "bad_blocked_services",
+		wantErrMsg: `blocked services: unknown blocked-service "unknown"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err = clients.addGroup(tc.group)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
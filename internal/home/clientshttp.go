@@ -1,15 +1,26 @@
 package home
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/netip"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/httphdr"
+	"github.com/AdguardTeam/golibs/netutil"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 // clientJSON is a common structure used by several handlers to deal with
@@ -29,16 +40,45 @@ type clientJSON struct {
 	// the allowlist.
 	DisallowedRule *string `json:"disallowed_rule,omitempty"`
 
+	// Source is the source of the name of a runtime client, which has the
+	// highest priority among the sources that know about the client.  It's
+	// only set in the responses about runtime clients.
+	Source string `json:"source,omitempty"`
+
 	// WHOIS is the filtered WHOIS data of a client.
 	WHOIS          *whois.Info                 `json:"whois_info,omitempty"`
 	SafeSearchConf *filtering.SafeSearchConfig `json:"safe_search"`
 
+	// ParentalSchedule is the schedule, within which the parental control is
+	// enabled for the client.  If it's nil, only ParentalEnabled is used.
+	ParentalSchedule *schedule.Weekly `json:"parental_schedule,omitempty"`
+
+	// SafeBrowsingSchedule is the schedule, within which the safe browsing is
+	// enabled for the client.  If it's nil, only SafeBrowsingEnabled is used.
+	SafeBrowsingSchedule *schedule.Weekly `json:"safebrowsing_schedule,omitempty"`
+
 	Name string `json:"name"`
 
+	// Notes is the free-text note about the client.
+	Notes string `json:"notes"`
+
+	// InheritFrom is the name of the client to inherit the settings from.
+	InheritFrom string `json:"inherit_from"`
+
 	BlockedServices []string `json:"blocked_services"`
 	IDs             []string `json:"ids"`
 	Tags            []string `json:"tags"`
 	Upstreams       []string `json:"upstreams"`
+	BlockedDomains  []string `json:"blocked_domains"`
+	AllowedDomains  []string `json:"allowed_domains"`
+
+	// UpstreamsProfile is the name of the upstream profile to use if
+	// Upstreams are empty.
+	UpstreamsProfile string `json:"upstreams_profile"`
+
+	// UpstreamMode is the mode of using Upstreams.  Empty string means that
+	// the global upstream mode is used.
+	UpstreamMode dnsforward.ClientUpstreamMode `json:"upstream_mode"`
 
 	FilteringEnabled    bool `json:"filtering_enabled"`
 	ParentalEnabled     bool `json:"parental_enabled"`
@@ -48,46 +88,237 @@ type clientJSON struct {
 	UseGlobalBlockedServices bool `json:"use_global_blocked_services"`
 	UseGlobalSettings        bool `json:"use_global_settings"`
 
-	IgnoreQueryLog   aghalg.NullBool `json:"ignore_querylog"`
-	IgnoreStatistics aghalg.NullBool `json:"ignore_statistics"`
+	IgnoreQueryLog     aghalg.NullBool `json:"ignore_querylog"`
+	IgnoreStatistics   aghalg.NullBool `json:"ignore_statistics"`
+	LogBlockedServices aghalg.NullBool `json:"log_blocked_services"`
+
+	// QueryLogRetention is the time the query log entries of the client are
+	// kept for, in milliseconds.  Zero means that the global query log
+	// retention is used.
+	QueryLogRetention float64 `json:"querylog_retention"`
+
+	// Ratelimit is the maximum number of requests per second from the client.
+	// Zero means that the global rate limit is used, and a negative value
+	// means that the client isn't limited.
+	Ratelimit int `json:"ratelimit"`
+
+	// CreatedAt is the time when the client has been added.  It's ignored in
+	// the requests.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+
+	// ModifiedAt is the time when the client has been last updated.  It's
+	// ignored in the requests.
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+
+	// structured, if true, means that the client is encoded in the structured
+	// shape, see [clientsShapeStructured].
+	structured bool
+}
+
+// type check
+var _ json.Marshaler = (*clientJSON)(nil)
+
+// MarshalJSON implements the [json.Marshaler] interface for *clientJSON.  In
+// the structured shape, the deprecated safesearch_enabled field is omitted and
+// safe_search is never null.
+func (cj *clientJSON) MarshalJSON() (b []byte, err error) {
+	// plainClientJSON prevents the infinite recursion of MarshalJSON.
+	type plainClientJSON clientJSON
+
+	if !cj.structured {
+		// Don't wrap the error since it's informative enough as is.
+		return json.Marshal((*plainClientJSON)(cj))
+	}
+
+	safeSearchConf := cj.SafeSearchConf
+	if safeSearchConf == nil {
+		safeSearchConf = &filtering.SafeSearchConfig{}
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return json.Marshal(&struct {
+		*plainClientJSON
+
+		// SafeSearchConf shadows the field of plainClientJSON.
+		SafeSearchConf *filtering.SafeSearchConfig `json:"safe_search"`
+
+		// SafeSearchEnabled shadows the deprecated field of plainClientJSON
+		// and is always nil, so it's omitted.
+		SafeSearchEnabled *bool `json:"safesearch_enabled,omitempty"`
+	}{
+		plainClientJSON: (*plainClientJSON)(cj),
+		SafeSearchConf:  safeSearchConf,
+	})
+}
+
+// Shapes of the client objects in the responses of the clients HTTP API.  See
+// [clientsShapeParam].
+const (
+	// clientsShapeLegacy is the default shape, which contains both the
+	// deprecated safesearch_enabled field and the structured safe_search one.
+	clientsShapeLegacy = ""
+
+	// clientsShapeStructured is the shape, which only contains the structured
+	// safe_search field.
+	clientsShapeStructured = "structured"
+)
+
+// clientsShapeParam is the query parameter of the clients HTTP API, which
+// selects the shape of the client objects in the responses.
+const clientsShapeParam = "shape"
+
+// structuredShape returns true if r requests the structured shape of the client
+// objects.  If the requested shape is invalid, it writes the error to w and
+// returns false in ok.
+func structuredShape(w http.ResponseWriter, r *http.Request) (structured, ok bool) {
+	switch shape := r.URL.Query().Get(clientsShapeParam); shape {
+	case clientsShapeLegacy:
+		return false, true
+	case clientsShapeStructured:
+		return true, true
+	default:
+		aghhttp.Error(
+			r,
+			w,
+			http.StatusBadRequest,
+			"%s: bad value %q, want %q",
+			clientsShapeParam,
+			shape,
+			clientsShapeStructured,
+		)
+
+		return false, false
+	}
 }
 
 type runtimeClientJSON struct {
 	WHOIS *whois.Info `json:"whois_info"`
 
+	// Hosts are the host names of the client reported by all its sources,
+	// ordered by the priority of the sources.  The first one is Name.
+	Hosts []*runtimeClientHostJSON `json:"hosts"`
+
+	// LastSeen is the time of the last DNS query from the client.  It's nil if
+	// there were no queries since the client has been added.
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+
 	IP     netip.Addr   `json:"ip"`
 	Name   string       `json:"name"`
 	Source clientSource `json:"source"`
 }
 
+// runtimeClientHostJSON is the JSON representation of a host name of a runtime
+// client reported by a source.
+type runtimeClientHostJSON struct {
+	Name   string       `json:"name"`
+	Source clientSource `json:"source"`
+}
+
+// newRuntimeClientJSON returns the JSON representation of the runtime client rc
+// with ip.  The host names are ordered according to prio.
+func newRuntimeClientJSON(
+	ip netip.Addr,
+	rc *RuntimeClient,
+	prio clientSourcePriority,
+) (cj runtimeClientJSON) {
+	cj = runtimeClientJSON{
+		WHOIS: rc.WHOIS,
+		Hosts: make([]*runtimeClientHostJSON, 0, len(rc.hosts)),
+
+		Name:   rc.Host,
+		Source: rc.Source,
+		IP:     ip,
+	}
+
+	for src, host := range rc.hosts {
+		cj.Hosts = append(cj.Hosts, &runtimeClientHostJSON{
+			Name:   host,
+			Source: src,
+		})
+	}
+
+	slices.SortFunc(cj.Hosts, func(a, b *runtimeClientHostJSON) (less bool) {
+		return prio.higher(a.Source, b.Source)
+	})
+
+	if lastSeen := rc.LastSeen(); !lastSeen.IsZero() {
+		cj.LastSeen = &lastSeen
+	}
+
+	return cj
+}
+
 type clientListJSON struct {
 	Clients        []*clientJSON       `json:"clients"`
 	RuntimeClients []runtimeClientJSON `json:"auto_clients"`
 	Tags           []string            `json:"supported_tags"`
 }
 
-// handleGetClients is the handler for GET /control/clients HTTP API.
+// Sorting orders of the persistent clients in the GET /control/clients HTTP
+// API.
+const (
+	// clientsSortNone means that the persistent clients aren't sorted.
+	clientsSortNone = ""
+
+	// clientsSortModified means that the most recently modified persistent
+	// clients come first.
+	clientsSortModified = "modified"
+)
+
+// handleGetClients is the handler for GET /control/clients HTTP API.  The
+// persistent clients are sorted according to the sort query parameter.  The
+// runtime clients are filtered according to the source query parameters, see
+// [parseSourcesQuery].
 func (clients *clientsContainer) handleGetClients(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	srcs, err := parseSourcesQuery(q["source"])
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "source: %s", err)
+
+		return
+	}
+
+	sortBy := q.Get("sort")
+	if sortBy != clientsSortNone && sortBy != clientsSortModified {
+		aghhttp.Error(
+			r,
+			w,
+			http.StatusBadRequest,
+			"sort: bad value %q, want %q",
+			sortBy,
+			clientsSortModified,
+		)
+
+		return
+	}
+
+	structured, ok := structuredShape(w, r)
+	if !ok {
+		return
+	}
+
 	data := clientListJSON{}
 
-	clients.lock.Lock()
-	defer clients.lock.Unlock()
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
 
-	for _, c := range clients.list {
+	persistent := maps.Values(clients.list)
+	if sortBy == clientsSortModified {
+		slices.SortFunc(persistent, clientModifiedLater)
+	}
+
+	for _, c := range persistent {
 		cj := clientToJSON(c)
+		cj.structured = structured
 		data.Clients = append(data.Clients, cj)
 	}
 
 	for ip, rc := range clients.ipToRC {
-		cj := runtimeClientJSON{
-			WHOIS: rc.WHOIS,
-
-			Name:   rc.Host,
-			Source: rc.Source,
-			IP:     ip,
+		if !hasAnySource(rc, srcs) {
+			continue
 		}
 
-		data.RuntimeClients = append(data.RuntimeClients, cj)
+		data.RuntimeClients = append(data.RuntimeClients, newRuntimeClientJSON(ip, rc, clients.srcPriority))
 	}
 
 	data.Tags = clientTags
@@ -95,6 +326,46 @@ func (clients *clientsContainer) handleGetClients(w http.ResponseWriter, r *http
 	_ = aghhttp.WriteJSONResponse(w, r, data)
 }
 
+// parseSourcesQuery parses the names of the runtime client sources from the
+// values of the source query parameter.  Each value may also contain several
+// comma-separated names.  srcs is empty if there are no values.
+func parseSourcesQuery(vals []string) (srcs []clientSource, err error) {
+	for _, v := range vals {
+		for _, name := range strings.Split(v, ",") {
+			var src clientSource
+			src, err = parseRuntimeClientSource(strings.TrimSpace(name))
+			if err != nil {
+				// Don't wrap the error since it's informative enough as is.
+				return nil, err
+			}
+
+			srcs = append(srcs, src)
+		}
+	}
+
+	return srcs, nil
+}
+
+// hasAnySource returns true if rc has been reported by any of srcs or if srcs
+// is empty.
+func hasAnySource(rc *RuntimeClient, srcs []clientSource) (ok bool) {
+	if len(srcs) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(srcs, rc.hasSource)
+}
+
+// clientModifiedLater returns true if a has been modified later than b.  The
+// clients modified at the same time are sorted by name.
+func clientModifiedLater(a, b *Client) (later bool) {
+	if !a.ModifiedAt.Equal(b.ModifiedAt) {
+		return a.ModifiedAt.After(b.ModifiedAt)
+	}
+
+	return a.Name < b.Name
+}
+
 // jsonToClient converts JSON object to Client object.
 func (clients *clientsContainer) jsonToClient(cj clientJSON, prev *Client) (c *Client, err error) {
 	var safeSearchConf filtering.SafeSearchConfig
@@ -118,25 +389,59 @@ func (clients *clientsContainer) jsonToClient(cj clientJSON, prev *Client) (c *C
 		}
 	}
 
+	weekly := schedule.EmptyWeekly()
+	prevName := ""
+	if prev != nil {
+		weekly = prev.BlockedServices.Schedule.Clone()
+		prevName = prev.Name
+	}
+
+	err = clients.checkInheritFrom(cj.Name, prevName, cj.InheritFrom)
+	if err != nil {
+		return nil, &fieldError{err: err, field: "inherit_from"}
+	}
+
+	err = clients.checkUpstreamsProfile(cj.UpstreamsProfile)
+	if err != nil {
+		return nil, &fieldError{err: err, field: "upstreams_profile"}
+	}
+
+	err = cj.UpstreamMode.Validate()
+	if err != nil {
+		return nil, &fieldError{err: err, field: "upstream_mode"}
+	}
+
 	c = &Client{
 		safeSearchConf: safeSearchConf,
 
-		Name: cj.Name,
+		Name:        cj.Name,
+		Notes:       cj.Notes,
+		InheritFrom: cj.InheritFrom,
 
 		BlockedServices: &filtering.BlockedServices{
-			Schedule: prev.BlockedServices.Schedule.Clone(),
+			Schedule: weekly,
 			IDs:      cj.BlockedServices,
 		},
 
-		IDs:       cj.IDs,
-		Tags:      cj.Tags,
-		Upstreams: cj.Upstreams,
+		IDs:              cj.IDs,
+		Tags:             cj.Tags,
+		Upstreams:        cj.Upstreams,
+		UpstreamsProfile: cj.UpstreamsProfile,
+		UpstreamMode:     cj.UpstreamMode,
+
+		BlockedDomains: cj.BlockedDomains,
+		AllowedDomains: cj.AllowedDomains,
+
+		ParentalSchedule:     cj.ParentalSchedule,
+		SafeBrowsingSchedule: cj.SafeBrowsingSchedule,
 
 		UseOwnSettings:        !cj.UseGlobalSettings,
 		FilteringEnabled:      cj.FilteringEnabled,
 		ParentalEnabled:       cj.ParentalEnabled,
 		SafeBrowsingEnabled:   cj.SafeBrowsingEnabled,
 		UseOwnBlockedServices: !cj.UseGlobalBlockedServices,
+		QueryLogRetention:     time.Duration(cj.QueryLogRetention) * time.Millisecond,
+		Ratelimit:             cj.Ratelimit,
 	}
 
 	if cj.IgnoreQueryLog != aghalg.NBNull {
@@ -151,6 +456,25 @@ func (clients *clientsContainer) jsonToClient(cj clientJSON, prev *Client) (c *C
 		c.IgnoreStatistics = prev.IgnoreStatistics
 	}
 
+	if cj.LogBlockedServices != aghalg.NBNull {
+		c.LogBlockedServices = cj.LogBlockedServices == aghalg.NBTrue
+	} else if prev != nil {
+		c.LogBlockedServices = prev.LogBlockedServices
+	}
+
+	err = c.setDomainRules()
+	if err != nil {
+		// The error already contains the name of the invalid list, so only
+		// find out the field.
+		field := "allowed_domains"
+		_, blockedErr := filtering.NewClientDomainRules(c.BlockedDomains, nil)
+		if blockedErr != nil {
+			field = "blocked_domains"
+		}
+
+		return nil, &fieldError{err: err, field: field}
+	}
+
 	if safeSearchConf.Enabled {
 		err = c.setSafeSearch(
 			safeSearchConf,
@@ -158,7 +482,10 @@ func (clients *clientsContainer) jsonToClient(cj clientJSON, prev *Client) (c *C
 			clients.safeSearchCacheTTL,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("creating safesearch for client %q: %w", c.Name, err)
+			return nil, &fieldError{
+				err:   fmt.Errorf("creating safesearch for client %q: %w", c.Name, err),
+				field: "safe_search",
+			}
 		}
 	}
 
@@ -174,6 +501,8 @@ func clientToJSON(c *Client) (cj *clientJSON) {
 
 	return &clientJSON{
 		Name:                c.Name,
+		Notes:               c.Notes,
+		InheritFrom:         c.InheritFrom,
 		IDs:                 c.IDs,
 		Tags:                c.Tags,
 		UseGlobalSettings:   !c.UseOwnSettings,
@@ -187,11 +516,36 @@ func clientToJSON(c *Client) (cj *clientJSON) {
 
 		BlockedServices: c.BlockedServices.IDs,
 
-		Upstreams: c.Upstreams,
+		Upstreams:        c.Upstreams,
+		UpstreamsProfile: c.UpstreamsProfile,
+		UpstreamMode:     c.UpstreamMode,
+
+		BlockedDomains: c.BlockedDomains,
+		AllowedDomains: c.AllowedDomains,
+
+		ParentalSchedule:     c.ParentalSchedule,
+		SafeBrowsingSchedule: c.SafeBrowsingSchedule,
+
+		IgnoreQueryLog:     aghalg.BoolToNullBool(c.IgnoreQueryLog),
+		IgnoreStatistics:   aghalg.BoolToNullBool(c.IgnoreStatistics),
+		LogBlockedServices: aghalg.BoolToNullBool(c.LogBlockedServices),
+
+		QueryLogRetention: float64(c.QueryLogRetention.Milliseconds()),
+
+		Ratelimit: c.Ratelimit,
+
+		CreatedAt:  timePtr(c.CreatedAt),
+		ModifiedAt: timePtr(c.ModifiedAt),
+	}
+}
 
-		IgnoreQueryLog:   aghalg.BoolToNullBool(c.IgnoreQueryLog),
-		IgnoreStatistics: aghalg.BoolToNullBool(c.IgnoreStatistics),
+// timePtr returns a pointer to t or nil, if t is zero.
+func timePtr(t time.Time) (p *time.Time) {
+	if t.IsZero() {
+		return nil
 	}
+
+	return &t
 }
 
 // handleAddClient is the handler for POST /control/clients/add HTTP API.
@@ -252,14 +606,74 @@ func (clients *clientsContainer) handleDelClient(w http.ResponseWriter, r *http.
 	onConfigModified()
 }
 
+// bulkDelJSON is the request body of the POST /control/clients/bulk_delete
+// HTTP API.
+type bulkDelJSON struct {
+	// Tag is the tag of the persistent clients to remove.
+	Tag string `json:"tag"`
+
+	// Source is the source of the runtime clients to remove.
+	Source string `json:"source"`
+}
+
+// bulkDelRespJSON is the response body of the POST
+// /control/clients/bulk_delete HTTP API.
+type bulkDelRespJSON struct {
+	// Removed is the total number of removed clients.
+	Removed int `json:"removed"`
+}
+
+// handleBulkDelClients is the handler for POST /control/clients/bulk_delete
+// HTTP API.
+func (clients *clientsContainer) handleBulkDelClients(w http.ResponseWriter, r *http.Request) {
+	req := &bulkDelJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	if req.Tag == "" && req.Source == "" {
+		aghhttp.Error(r, w, http.StatusBadRequest, "either tag or source must be non-empty")
+
+		return
+	}
+
+	if req.Tag != "" && !clients.allTags.Has(req.Tag) {
+		aghhttp.Error(r, w, http.StatusBadRequest, "unknown tag %q", req.Tag)
+
+		return
+	}
+
+	src := ClientSourceNone
+	if req.Source != "" {
+		src, err = parseRuntimeClientSource(req.Source)
+		if err != nil {
+			aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+			return
+		}
+	}
+
+	persistentNum, runtimeNum := clients.DelBulk(req.Tag, src)
+	if persistentNum > 0 {
+		onConfigModified()
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, &bulkDelRespJSON{
+		Removed: persistentNum + runtimeNum,
+	})
+}
+
 type updateJSON struct {
 	Name string     `json:"name"`
 	Data clientJSON `json:"data"`
 }
 
 // handleUpdateClient is the handler for POST /control/clients/update HTTP API.
-//
-// TODO(s.chzhen):  Accept updated parameters instead of whole structure.
+// See also [clientsContainer.handlePatchClient], which only changes the fields
+// present in the request.
 func (clients *clientsContainer) handleUpdateClient(w http.ResponseWriter, r *http.Request) {
 	dj := updateJSON{}
 	err := json.NewDecoder(r.Body).Decode(&dj)
@@ -279,8 +693,8 @@ func (clients *clientsContainer) handleUpdateClient(w http.ResponseWriter, r *ht
 	var ok bool
 
 	func() {
-		clients.lock.Lock()
-		defer clients.lock.Unlock()
+		clients.lock.RLock()
+		defer clients.lock.RUnlock()
 
 		prev, ok = clients.list[dj.Name]
 	}()
@@ -306,9 +720,163 @@ func (clients *clientsContainer) handleUpdateClient(w http.ResponseWriter, r *ht
 	onConfigModified()
 }
 
-// handleFindClient is the handler for GET /control/clients/find HTTP API.
+// fieldErrorJSON is the JSON representation of a validation error of a client
+// field.
+type fieldErrorJSON struct {
+	// Field is the name of the invalid field of clientJSON.
+	Field string `json:"field"`
+
+	// Message is the description of the error.
+	Message string `json:"message"`
+}
+
+// validateClientResp is the response for the POST /control/clients/validate
+// HTTP API.
+type validateClientResp struct {
+	// Client is the client as it would be saved, with the normalized
+	// identifiers.  It's nil if the client is invalid.
+	Client *clientJSON `json:"client,omitempty"`
+
+	// Errors are the validation errors.  It's empty if the client is valid.
+	Errors []*fieldErrorJSON `json:"errors"`
+}
+
+// handleValidateClient is the handler for the POST /control/clients/validate
+// HTTP API.  It validates the client the same way the add and update HTTP APIs
+// do, but doesn't change anything, and responds with the normalized client, if
+// it's valid.  The request has the same format as the one of the update HTTP
+// API, but the name is only set when the existing client is validated.
+func (clients *clientsContainer) handleValidateClient(w http.ResponseWriter, r *http.Request) {
+	vj := updateJSON{}
+	err := json.NewDecoder(r.Body).Decode(&vj)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	var prev *Client
+	if vj.Name != "" {
+		var ok bool
+		func() {
+			clients.lock.RLock()
+			defer clients.lock.RUnlock()
+
+			prev, ok = clients.list[vj.Name]
+		}()
+
+		if !ok {
+			aghhttp.Error(r, w, http.StatusBadRequest, "client not found")
+
+			return
+		}
+	}
+
+	resp := &validateClientResp{
+		Errors: []*fieldErrorJSON{},
+	}
+
+	c, errs := clients.validateJSON(vj.Data, prev)
+	for _, fe := range errs {
+		resp.Errors = append(resp.Errors, &fieldErrorJSON{
+			Field:   fe.field,
+			Message: fe.err.Error(),
+		})
+	}
+
+	if len(errs) == 0 {
+		resp.Client = clientToJSON(c)
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
+
+// validateJSON converts cj into a client and returns it along with the errors
+// of all the invalid fields of cj.  c is nil if cj can't be converted.  prev is
+// the client being updated, if any.
+func (clients *clientsContainer) validateJSON(
+	cj clientJSON,
+	prev *Client,
+) (c *Client, errs []*fieldError) {
+	// Don't let the normalization of the identifiers change the request data.
+	cj.IDs = slices.Clone(cj.IDs)
+
+	c, err := clients.jsonToClient(cj, prev)
+	if err != nil {
+		fe := &fieldError{}
+		if !errors.As(err, &fe) {
+			fe = &fieldError{err: err, field: "safe_search"}
+		}
+
+		return nil, []*fieldError{fe}
+	}
+
+	errs = clients.checkFields(c)
+
+	return c, append(errs, clients.checkUnique(c, prev)...)
+}
+
+// checkFindRatelimit returns false and writes the error response if the
+// request exceeds the rate limit of the client lookup HTTP APIs.
+func (clients *clientsContainer) checkFindRatelimit(w http.ResponseWriter, r *http.Request) (ok bool) {
+	limit := clients.findRatelimit
+	if limit == 0 {
+		return true
+	}
+
+	remoteIP, err := netutil.SplitHost(r.RemoteAddr)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "getting remote address: %s", err)
+
+		return false
+	}
+
+	if clients.findLimiter.isLimited(remoteIP, limit, time.Now()) {
+		w.Header().Set(httphdr.RetryAfter, "1")
+		aghhttp.Error(r, w, http.StatusTooManyRequests, "too many requests")
+
+		return false
+	}
+
+	return true
+}
+
+// handleFindClient is the handler for GET /control/clients/find HTTP API.  If
+// the resolve query parameter is true, the names of the runtime clients without
+// known hostnames are resolved using PTR requests.
 func (clients *clientsContainer) handleFindClient(w http.ResponseWriter, r *http.Request) {
+	if !clients.checkFindRatelimit(w, r) {
+		return
+	}
+
 	q := r.URL.Query()
+	if maxIDs := clients.findMaxIDs; maxIDs > 0 && q.Get(fmt.Sprintf("ip%d", maxIDs)) != "" {
+		aghhttp.Error(r, w, http.StatusBadRequest, "too many identifiers, max %d", maxIDs)
+
+		return
+	}
+
+	structured, ok := structuredShape(w, r)
+	if !ok {
+		return
+	}
+
+	resolve := false
+	if resolveStr := q.Get("resolve"); resolveStr != "" {
+		var err error
+		resolve, err = strconv.ParseBool(resolveStr)
+		if err != nil {
+			aghhttp.Error(r, w, http.StatusBadRequest, "resolve: %s", err)
+
+			return
+		}
+	}
+
+	// Limit the time spent on resolving the PTR records of all the requested
+	// clients, so that a slow resolver doesn't hang the request.
+	ctx, cancel := context.WithTimeout(r.Context(), findPTRTimeout)
+	defer cancel()
+
 	data := []map[string]*clientJSON{}
 	for i := 0; i < len(q); i++ {
 		idStr := q.Get(fmt.Sprintf("ip%d", i))
@@ -321,12 +889,16 @@ func (clients *clientsContainer) handleFindClient(w http.ResponseWriter, r *http
 		var cj *clientJSON
 		if !ok {
 			cj = clients.findRuntime(ip, idStr)
+			if resolve && cj.Name == "" {
+				cj.Name = clients.resolvePTR(ctx, ip)
+			}
 		} else {
 			cj = clientToJSON(c)
 			disallowed, rule := clients.dnsServer.IsBlockedClient(ip, idStr)
 			cj.Disallowed, cj.DisallowedRule = &disallowed, &rule
 		}
 
+		cj.structured = structured
 		data = append(data, map[string]*clientJSON{
 			idStr: cj,
 		})
@@ -335,9 +907,186 @@ func (clients *clientsContainer) handleFindClient(w http.ResponseWriter, r *http
 	_ = aghhttp.WriteJSONResponse(w, r, data)
 }
 
+// clientsCountJSON is the number of clients within a subnet along with their
+// access status.
+type clientsCountJSON struct {
+	// Total is the number of the clients.
+	Total int `json:"total"`
+
+	// Blocked is the number of the clients disallowed by the access settings.
+	Blocked int `json:"blocked"`
+
+	// Allowed is the number of the clients allowed by the access settings.
+	Allowed int `json:"allowed"`
+}
+
+// add counts a client with the given access status.
+func (c *clientsCountJSON) add(blocked bool) {
+	c.Total++
+	if blocked {
+		c.Blocked++
+	} else {
+		c.Allowed++
+	}
+}
+
+// subnetSummaryJSON is the response to the GET /control/clients/summary HTTP
+// API.
+type subnetSummaryJSON struct {
+	// Persistent are the persistent clients within the subnet.
+	Persistent *clientsCountJSON `json:"persistent"`
+
+	// Runtime are the runtime clients within the subnet, which aren't
+	// persistent clients identified by the same IP address.
+	Runtime *clientsCountJSON `json:"runtime"`
+
+	// Subnet is the requested subnet.
+	Subnet netip.Prefix `json:"subnet"`
+}
+
+// handleSubnetSummary is the handler for the GET /control/clients/summary HTTP
+// API.  It responds with the numbers of the persistent and runtime clients
+// within the subnet from the query.
+func (clients *clientsContainer) handleSubnetSummary(w http.ResponseWriter, r *http.Request) {
+	subnet, err := netip.ParsePrefix(r.URL.Query().Get("subnet"))
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "parsing subnet: %s", err)
+
+		return
+	}
+
+	subnet = subnet.Masked()
+	persistent, runtime := clients.subnetAddrs(subnet)
+
+	resp := &subnetSummaryJSON{
+		Persistent: &clientsCountJSON{},
+		Runtime:    &clientsCountJSON{},
+		Subnet:     subnet,
+	}
+
+	for _, addrs := range persistent {
+		blocked := false
+		for _, addr := range addrs {
+			if blocked = clients.isBlocked(addr); blocked {
+				break
+			}
+		}
+
+		resp.Persistent.add(blocked)
+	}
+
+	for _, ip := range runtime {
+		resp.Runtime.add(clients.isBlocked(ip))
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
+
+// subnetAddrs returns the addresses within subnet of each persistent client
+// and the addresses of the runtime clients within subnet, which aren't
+// persistent clients identified by the same IP address.  For the subnet
+// identifiers of the persistent clients, which are within subnet, their
+// network addresses are returned.
+func (clients *clientsContainer) subnetAddrs(
+	subnet netip.Prefix,
+) (persistent [][]netip.Addr, runtime []netip.Addr) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	for _, c := range clients.list {
+		var addrs []netip.Addr
+		for _, id := range c.IDs {
+			if ip, err := netip.ParseAddr(id); err == nil && subnet.Contains(ip) {
+				addrs = append(addrs, ip)
+			} else if p, err := netip.ParsePrefix(id); err == nil &&
+				p.Bits() >= subnet.Bits() &&
+				subnet.Contains(p.Addr()) {
+				addrs = append(addrs, p.Masked().Addr())
+			}
+		}
+
+		if len(addrs) > 0 {
+			persistent = append(persistent, addrs)
+		}
+	}
+
+	for ip := range clients.ipToRC {
+		if _, ok := clients.idIndex[ip.String()]; !ok && subnet.Contains(ip) {
+			runtime = append(runtime, ip)
+		}
+	}
+
+	return persistent, runtime
+}
+
+// isBlocked returns true if ip is disallowed by the access settings of the DNS
+// server, if there is one.
+func (clients *clientsContainer) isBlocked(ip netip.Addr) (blocked bool) {
+	if clients.dnsServer == nil {
+		return false
+	}
+
+	blocked, _ = clients.dnsServer.IsBlockedClient(ip, "")
+
+	return blocked
+}
+
+// handleGetRuntimeDefaults is the handler for the GET
+// /control/clients/runtime_defaults HTTP API.
+func (clients *clientsContainer) handleGetRuntimeDefaults(w http.ResponseWriter, r *http.Request) {
+	_ = aghhttp.WriteJSONResponse(w, r, clients.runtimeDefaultsConf())
+}
+
+// handleUpdateRuntimeDefaults is the handler for the PUT
+// /control/clients/runtime_defaults/update HTTP API.
+func (clients *clientsContainer) handleUpdateRuntimeDefaults(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	conf := &runtimeDefaults{}
+	err := json.NewDecoder(r.Body).Decode(conf)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	err = clients.setRuntimeDefaults(conf)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+		return
+	}
+
+	onConfigModified()
+	Context.webhook.notify(webhookChangeClients)
+
+	_ = aghhttp.WriteJSONResponse(w, r, clients.runtimeDefaultsConf())
+}
+
+// setRuntimeDefaultsJSON sets the settings of cj to the ones applied to the
+// clients, which aren't persistent.
+func (clients *clientsContainer) setRuntimeDefaultsJSON(cj *clientJSON) {
+	conf := clients.runtimeDefaultsConf()
+
+	cj.UseGlobalSettings = !conf.Enabled
+	if !conf.Enabled {
+		return
+	}
+
+	cj.FilteringEnabled = conf.FilteringEnabled
+	cj.ParentalEnabled = conf.ParentalEnabled
+	cj.SafeBrowsingEnabled = conf.SafeBrowsingEnabled
+	cj.SafeSearchEnabled = conf.SafeSearchConf.Enabled
+	cj.SafeSearchConf = &conf.SafeSearchConf
+}
+
 // findRuntime looks up the IP in runtime and temporary storages, like
 // /etc/hosts tables, DHCP leases, or blocklists.  cj is guaranteed to be
-// non-nil.
+// non-nil.  Since only persistent clients can be excluded from the query log
+// and statistics or have their blocked services logged, the corresponding
+// fields of cj are always false.  The filtering settings of cj are the runtime
+// defaults, see [runtimeDefaults].
 func (clients *clientsContainer) findRuntime(ip netip.Addr, idStr string) (cj *clientJSON) {
 	rc, ok := clients.findRuntimeClient(ip)
 	if !ok {
@@ -352,28 +1101,127 @@ func (clients *clientsContainer) findRuntime(ip netip.Addr, idStr string) (cj *c
 			Disallowed:     &disallowed,
 			DisallowedRule: &rule,
 			WHOIS:          &whois.Info{},
+
+			IgnoreQueryLog:     aghalg.NBFalse,
+			IgnoreStatistics:   aghalg.NBFalse,
+			LogBlockedServices: aghalg.NBFalse,
 		}
 
+		clients.setRuntimeDefaultsJSON(cj)
+
 		return cj
 	}
 
 	cj = &clientJSON{
-		Name:  rc.Host,
-		IDs:   []string{idStr},
-		WHOIS: rc.WHOIS,
+		Name:   rc.Host,
+		Source: rc.Source.String(),
+		IDs:    []string{idStr},
+		WHOIS:  rc.WHOIS,
+
+		IgnoreQueryLog:     aghalg.NBFalse,
+		IgnoreStatistics:   aghalg.NBFalse,
+		LogBlockedServices: aghalg.NBFalse,
 	}
 
+	clients.setRuntimeDefaultsJSON(cj)
+
 	disallowed, rule := clients.dnsServer.IsBlockedClient(ip, idStr)
 	cj.Disallowed, cj.DisallowedRule = &disallowed, &rule
 
 	return cj
 }
 
+// defaultSearchLimit is the default maximum number of clients returned by the
+// GET /control/clients/search HTTP API.
+const defaultSearchLimit = 10
+
+// clientSearchResultJSON is a single result of the GET /control/clients/search
+// HTTP API.
+type clientSearchResultJSON struct {
+	Name       string   `json:"name"`
+	IDs        []string `json:"ids"`
+	Persistent bool     `json:"persistent"`
+}
+
+// handleSearchClients is the handler for GET /control/clients/search HTTP API.
+func (clients *clientsContainer) handleSearchClients(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := defaultSearchLimit
+	if limitStr := q.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			aghhttp.Error(r, w, http.StatusBadRequest, "bad limit %q", limitStr)
+
+			return
+		}
+
+		limit = l
+	}
+
+	matches := clients.search(q.Get("q"), limit)
+	data := make([]*clientSearchResultJSON, 0, len(matches))
+	for _, m := range matches {
+		data = append(data, &clientSearchResultJSON{
+			Name:       m.name,
+			IDs:        m.ids,
+			Persistent: m.persistent,
+		})
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, data)
+}
+
 // RegisterClientsHandlers registers HTTP handlers
 func (clients *clientsContainer) registerWebHandlers() {
 	httpRegister(http.MethodGet, "/control/clients", clients.handleGetClients)
 	httpRegister(http.MethodPost, "/control/clients/add", clients.handleAddClient)
 	httpRegister(http.MethodPost, "/control/clients/delete", clients.handleDelClient)
+	httpRegister(http.MethodPost, "/control/clients/bulk_delete", clients.handleBulkDelClients)
 	httpRegister(http.MethodPost, "/control/clients/update", clients.handleUpdateClient)
+	httpRegister(http.MethodPost, "/control/clients/patch", clients.handlePatchClient)
+	httpRegister(http.MethodPost, "/control/clients/safesearch", clients.handleBulkSafeSearch)
+	httpRegister(http.MethodPost, "/control/clients/validate", clients.handleValidateClient)
 	httpRegister(http.MethodGet, "/control/clients/find", clients.handleFindClient)
+	httpRegister(http.MethodGet, "/control/clients/rules", clients.handleClientRules)
+	httpRegister(http.MethodGet, "/control/clients/search", clients.handleSearchClients)
+	httpRegister(http.MethodGet, "/control/clients/tags", clients.handleGetTags)
+	httpRegister(http.MethodGet, "/control/clients/whois", clients.handleClientsWHOIS)
+	httpRegister(http.MethodGet, "/control/clients/summary", clients.handleSubnetSummary)
+	httpRegister(http.MethodGet, "/control/clients/pending", clients.handleGetPendingClients)
+	httpRegister(http.MethodPost, "/control/clients/approve", clients.handleApproveClient)
+	httpRegister(http.MethodGet, "/control/clients/groups", clients.handleGetGroups)
+	httpRegister(http.MethodPost, "/control/clients/groups/add", clients.handleAddGroup)
+	httpRegister(http.MethodPost, "/control/clients/groups/update", clients.handleUpdateGroup)
+	httpRegister(http.MethodPost, "/control/clients/groups/delete", clients.handleDelGroup)
+	httpRegister(
+		http.MethodGet,
+		"/control/clients/upstream_profiles",
+		clients.handleGetUpstreamProfiles,
+	)
+	httpRegister(
+		http.MethodPost,
+		"/control/clients/upstream_profiles/add",
+		clients.handleAddUpstreamProfile,
+	)
+	httpRegister(
+		http.MethodPost,
+		"/control/clients/upstream_profiles/update",
+		clients.handleUpdateUpstreamProfile,
+	)
+	httpRegister(
+		http.MethodPost,
+		"/control/clients/upstream_profiles/delete",
+		clients.handleDelUpstreamProfile,
+	)
+	httpRegister(
+		http.MethodGet,
+		"/control/clients/runtime_defaults",
+		clients.handleGetRuntimeDefaults,
+	)
+	httpRegister(
+		http.MethodPut,
+		"/control/clients/runtime_defaults/update",
+		clients.handleUpdateRuntimeDefaults,
+	)
 }
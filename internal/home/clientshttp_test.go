@@ -0,0 +1,825 @@
+package home
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientsContainer_handleValidateClient(t *testing.T) {
+	filtering.InitModule()
+
+	clients := newClientsContainer(t)
+
+	ok, err := clients.Add(&Client{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+		Name: "existing",
+		IDs:  []string{"1.1.1.1"},
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	testCases := []struct {
+		req        *updateJSON
+		name       string
+		wantIDs    []string
+		wantFields []string
+		wantCode   int
+	}{{
+		req: &updateJSON{
+			Data: clientJSON{
+				Name:            "new",
+				IDs:             []string{"1.1.1.2", "client-id", "AA:BB:CC:DD:EE:FF"},
+				Tags:            []string{"user_admin"},
+				Upstreams:       []string{"1.2.3.4"},
+				BlockedServices: []string{"youtube"},
+			},
+		},
+		name:       "valid",
+		wantIDs:    []string{"1.1.1.2", "client-id", "aa:bb:cc:dd:ee:ff"},
+		wantFields: []string{},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Name: "existing",
+			Data: clientJSON{
+				Name: "existing",
+				IDs:  []string{"1.1.1.1"},
+			},
+		},
+		name:       "valid_update",
+		wantIDs:    []string{"1.1.1.1"},
+		wantFields: []string{},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Data: clientJSON{
+				Name:             "new",
+				IDs:              []string{"1.1.1.2"},
+				UpstreamsProfile: "unknown",
+			},
+		},
+		name:       "unknown_profile",
+		wantFields: []string{"upstreams_profile"},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Data: clientJSON{
+				Name:           "new",
+				IDs:            []string{"1.1.1.2"},
+				AllowedDomains: []string{"!!!"},
+			},
+		},
+		name:       "invalid_domains",
+		wantFields: []string{"allowed_domains"},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Data: clientJSON{
+				IDs: []string{},
+			},
+		},
+		name:       "empty",
+		wantFields: []string{"name", "ids"},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Data: clientJSON{
+				Name:            "new",
+				IDs:             []string{"!!!", "1.1.1.2"},
+				Tags:            []string{"bad_tag"},
+				Upstreams:       []string{"dhcp://fake.dns"},
+				BlockedServices: []string{"bad_service"},
+			},
+		},
+		name:       "invalid_fields",
+		wantFields: []string{"ids", "tags", "upstreams", "blocked_services"},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Data: clientJSON{
+				Name: "existing",
+				IDs:  []string{"1.1.1.1"},
+			},
+		},
+		name:       "duplicate",
+		wantFields: []string{"name", "ids"},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Data: clientJSON{
+				Name:  "new",
+				Notes: strings.Repeat("ы", maxClientNotesLen+1),
+				IDs:   []string{"1.1.1.2"},
+			},
+		},
+		name:       "notes_too_long",
+		wantFields: []string{"notes"},
+		wantCode:   http.StatusOK,
+	}, {
+		req: &updateJSON{
+			Name: "unknown",
+			Data: clientJSON{
+				Name: "unknown",
+				IDs:  []string{"1.1.1.3"},
+			},
+		},
+		name:       "not_found",
+		wantFields: nil,
+		wantCode:   http.StatusBadRequest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := json.Marshal(tc.req)
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodPost, "/control/clients/validate", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			clients.handleValidateClient(w, r)
+			require.Equal(t, tc.wantCode, w.Code)
+
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+
+			resp := &validateClientResp{}
+			err = json.NewDecoder(w.Body).Decode(resp)
+			require.NoError(t, err)
+
+			fields := make([]string, 0, len(resp.Errors))
+			for _, fe := range resp.Errors {
+				assert.NotEmpty(t, fe.Message)
+				fields = append(fields, fe.Field)
+			}
+
+			assert.Equal(t, tc.wantFields, fields)
+
+			if len(tc.wantFields) > 0 {
+				assert.Nil(t, resp.Client)
+
+				return
+			}
+
+			require.NotNil(t, resp.Client)
+
+			assert.Equal(t, tc.req.Data.Name, resp.Client.Name)
+			assert.Equal(t, tc.wantIDs, resp.Client.IDs)
+		})
+	}
+
+	// Make sure that nothing has changed.
+	assert.Len(t, clients.list, 1)
+	assert.Len(t, clients.idIndex, 1)
+}
+
+func TestClientsContainer_handleFindClient_limits(t *testing.T) {
+	const findURL = "/control/clients/find"
+
+	testCases := []struct {
+		name      string
+		query     string
+		wantCodes []int
+		maxIDs    uint
+		ratelimit uint32
+	}{{
+		name:      "no_limits",
+		query:     "",
+		wantCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		maxIDs:    0,
+		ratelimit: 0,
+	}, {
+		name:      "too_many_ids",
+		query:     "?ip0=1.1.1.1&ip1=1.1.1.2&ip2=1.1.1.3",
+		wantCodes: []int{http.StatusBadRequest},
+		maxIDs:    2,
+		ratelimit: 0,
+	}, {
+		name:  "ratelimit",
+		query: "",
+		wantCodes: []int{
+			http.StatusOK,
+			http.StatusOK,
+			http.StatusTooManyRequests,
+		},
+		maxIDs:    2,
+		ratelimit: 2,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clients := newClientsContainer(t)
+			clients.findMaxIDs = tc.maxIDs
+			clients.findRatelimit = tc.ratelimit
+
+			for i, wantCode := range tc.wantCodes {
+				r := httptest.NewRequest(http.MethodGet, findURL+tc.query, nil)
+				w := httptest.NewRecorder()
+
+				clients.handleFindClient(w, r)
+				assert.Equalf(t, wantCode, w.Code, "request at index %d", i)
+			}
+		})
+	}
+}
+
+func TestClientsContainer_notes(t *testing.T) {
+	const notes = "borrowed from IT, return by June"
+
+	clients := newClientsContainer(t)
+
+	c, err := clients.jsonToClient(clientJSON{
+		Name:  "laptop",
+		Notes: notes,
+		IDs:   []string{"1.1.1.1"},
+	}, nil)
+	require.NoError(t, err)
+
+	ok, err := clients.Add(c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	t.Run("json", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/control/clients", nil)
+		w := httptest.NewRecorder()
+
+		clients.handleGetClients(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &clientListJSON{}
+		err = json.NewDecoder(w.Body).Decode(resp)
+		require.NoError(t, err)
+		require.Len(t, resp.Clients, 1)
+
+		assert.Equal(t, notes, resp.Clients[0].Notes)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		objs := clients.forConfig()
+		require.Len(t, objs, 1)
+		require.Equal(t, notes, objs[0].Notes)
+
+		loaded := newClientsContainer(t)
+		err = loaded.addFromConfig(objs, &filtering.Config{})
+		require.NoError(t, err)
+
+		got, found := loaded.Find("1.1.1.1")
+		require.True(t, found)
+
+		assert.Equal(t, notes, got.Notes)
+	})
+
+	t.Run("too_long", func(t *testing.T) {
+		ok, err = clients.Add(&Client{
+			Name:  "phone",
+			Notes: strings.Repeat("a", maxClientNotesLen+1),
+			IDs:   []string{"1.1.1.2"},
+		})
+		testutil.AssertErrorMsg(t, "notes too long: got 1025 characters, max 1024", err)
+		assert.False(t, ok)
+	})
+}
+
+func TestClientsContainer_timestamps(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	// The timestamps in the requests must be ignored.
+	bogus := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	before := time.Now()
+	c, err := clients.jsonToClient(clientJSON{
+		Name:       "laptop",
+		IDs:        []string{"1.1.1.1"},
+		CreatedAt:  &bogus,
+		ModifiedAt: &bogus,
+	}, nil)
+	require.NoError(t, err)
+
+	ok, err := clients.Add(c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	created := c.CreatedAt
+	assert.False(t, created.Before(before))
+	assert.Equal(t, created, c.ModifiedAt)
+
+	phone := &Client{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+		Name: "phone",
+		IDs:  []string{"2.2.2.2"},
+	}
+
+	ok, err = clients.Add(phone)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	beforeUpdate := time.Now()
+	updated, err := clients.jsonToClient(clientJSON{
+		Name:       "laptop",
+		Notes:      "updated",
+		IDs:        []string{"1.1.1.1"},
+		CreatedAt:  &bogus,
+		ModifiedAt: &bogus,
+	}, c)
+	require.NoError(t, err)
+
+	err = clients.Update(c, updated)
+	require.NoError(t, err)
+
+	got, ok := clients.Find("1.1.1.1")
+	require.True(t, ok)
+
+	assert.Equal(t, created, got.CreatedAt)
+	assert.False(t, got.ModifiedAt.Before(beforeUpdate))
+
+	t.Run("config", func(t *testing.T) {
+		loaded := newClientsContainer(t)
+		err = loaded.addFromConfig(clients.forConfig(), &filtering.Config{})
+		require.NoError(t, err)
+
+		loadedCli, found := loaded.Find("1.1.1.1")
+		require.True(t, found)
+
+		assert.Equal(t, got.CreatedAt, loadedCli.CreatedAt)
+		assert.Equal(t, got.ModifiedAt, loadedCli.ModifiedAt)
+	})
+
+	t.Run("sort_modified", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/control/clients?sort=modified", nil)
+		w := httptest.NewRecorder()
+
+		clients.handleGetClients(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &clientListJSON{}
+		err = json.NewDecoder(w.Body).Decode(resp)
+		require.NoError(t, err)
+		require.Len(t, resp.Clients, 2)
+
+		assert.Equal(t, "laptop", resp.Clients[0].Name)
+		assert.Equal(t, "phone", resp.Clients[1].Name)
+
+		require.NotNil(t, resp.Clients[0].CreatedAt)
+		require.NotNil(t, resp.Clients[0].ModifiedAt)
+
+		assert.True(t, resp.Clients[0].CreatedAt.Equal(created))
+		assert.True(t, resp.Clients[0].ModifiedAt.Equal(got.ModifiedAt))
+	})
+
+	t.Run("bad_sort", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/control/clients?sort=name", nil)
+		w := httptest.NewRecorder()
+
+		clients.handleGetClients(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestClientsContainer_handleGetTags(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/control/clients/tags", nil)
+	w := httptest.NewRecorder()
+
+	clients.handleGetTags(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var tags []*clientTagJSON
+	err := json.NewDecoder(w.Body).Decode(&tags)
+	require.NoError(t, err)
+	require.Len(t, tags, len(clientTags))
+
+	for i, tag := range tags {
+		assert.Equal(t, clientTags[i], tag.Name)
+		assert.NotEmptyf(t, tag.Description, "tag %q", tag.Name)
+		assert.Containsf(t, []clientTagCategory{
+			clientTagCategoryDevice,
+			clientTagCategoryOS,
+			clientTagCategoryUser,
+		}, tag.Category, "tag %q", tag.Name)
+	}
+}
+
+func TestClientsContainer_domains(t *testing.T) {
+	var (
+		blocked = []string{"ads.example"}
+		allowed = []string{"cdn.example"}
+	)
+
+	clients := newClientsContainer(t)
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := clients.jsonToClient(clientJSON{
+			Name:           "laptop",
+			IDs:            []string{"1.1.1.1"},
+			BlockedDomains: []string{"bad domain"},
+		}, nil)
+		require.Error(t, err)
+
+		assert.Contains(t, err.Error(), "blocked_domains: at index 0")
+	})
+
+	c, err := clients.jsonToClient(clientJSON{
+		Name:           "laptop",
+		IDs:            []string{"1.1.1.1"},
+		BlockedDomains: blocked,
+		AllowedDomains: allowed,
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, c.domainRules, 2)
+
+	ok, err := clients.Add(c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	cj := clientToJSON(c)
+	assert.Equal(t, blocked, cj.BlockedDomains)
+	assert.Equal(t, allowed, cj.AllowedDomains)
+
+	objs := clients.forConfig()
+	require.Len(t, objs, 1)
+
+	loaded := newClientsContainer(t)
+	err = loaded.addFromConfig(objs, &filtering.Config{})
+	require.NoError(t, err)
+
+	got, ok := loaded.Find("1.1.1.1")
+	require.True(t, ok)
+
+	assert.Equal(t, blocked, got.BlockedDomains)
+	assert.Equal(t, allowed, got.AllowedDomains)
+	assert.Len(t, got.domainRules, 2)
+}
+
+func TestClientsContainer_upstreamMode(t *testing.T) {
+	testCases := []struct {
+		name       string
+		mode       dnsforward.ClientUpstreamMode
+		wantErrMsg string
+	}{{
+		name:       "default",
+		mode:       dnsforward.ClientUpstreamModeDefault,
+		wantErrMsg: "",
+	}, {
+		name:       "load_balance",
+		mode:       dnsforward.ClientUpstreamModeLoadBalance,
+		wantErrMsg: "",
+	}, {
+		name:       "parallel",
+		mode:       dnsforward.ClientUpstreamModeParallel,
+		wantErrMsg: "",
+	}, {
+		name:       "fastest",
+		mode:       dnsforward.ClientUpstreamModeFastest,
+		wantErrMsg: "",
+	}, {
+		name: "bad",
+		mode: "fastest_addr",
+		wantErrMsg: `upstream_mode: bad value "fastest_addr", ` +
+			`want "load_balance", "parallel", or "fastest"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clients := newClientsContainer(t)
+
+			c, err := clients.jsonToClient(clientJSON{
+				Name:         "laptop",
+				IDs:          []string{"1.1.1.1"},
+				Upstreams:    []string{"1.2.3.4"},
+				UpstreamMode: tc.mode,
+			}, nil)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+			if tc.wantErrMsg != "" {
+				return
+			}
+
+			assert.Equal(t, tc.mode, c.UpstreamMode)
+			assert.Equal(t, tc.mode, clientToJSON(c).UpstreamMode)
+
+			ok, err := clients.Add(c)
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			loaded := newClientsContainer(t)
+			err = loaded.addFromConfig(clients.forConfig(), &filtering.Config{})
+			require.NoError(t, err)
+
+			got, ok := loaded.Find("1.1.1.1")
+			require.True(t, ok)
+
+			assert.Equal(t, tc.mode, got.UpstreamMode)
+		})
+	}
+}
+
+func TestClientsContainer_handleSubnetSummary(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	for _, c := range []*Client{{
+		Name: "ip",
+		IDs:  []string{"192.168.1.10"},
+	}, {
+		Name: "subnet",
+		IDs:  []string{"192.168.1.128/25", "aa:aa:aa:aa:aa:aa"},
+	}, {
+		Name: "other_network",
+		IDs:  []string{"10.0.0.1"},
+	}, {
+		Name: "wider_subnet",
+		IDs:  []string{"192.168.0.0/16"},
+	}} {
+		ok, err := clients.Add(c)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	for _, ip := range []string{"192.168.1.10", "192.168.1.20", "10.0.0.2"} {
+		ok := clients.AddHost(netip.MustParseAddr(ip), "host", ClientSourceARP)
+		require.True(t, ok)
+	}
+
+	testCases := []struct {
+		want     *subnetSummaryJSON
+		name     string
+		subnet   string
+		wantCode int
+	}{{
+		want: &subnetSummaryJSON{
+			Persistent: &clientsCountJSON{Total: 2, Allowed: 2},
+			Runtime:    &clientsCountJSON{Total: 1, Allowed: 1},
+			Subnet:     netip.MustParsePrefix("192.168.1.0/24"),
+		},
+		name:     "subnet",
+		subnet:   "192.168.1.0/24",
+		wantCode: http.StatusOK,
+	}, {
+		want: &subnetSummaryJSON{
+			Persistent: &clientsCountJSON{Total: 1, Allowed: 1},
+			Runtime:    &clientsCountJSON{Total: 1, Allowed: 1},
+			Subnet:     netip.MustParsePrefix("10.0.0.0/8"),
+		},
+		name:     "not_masked",
+		subnet:   "10.1.2.3/8",
+		wantCode: http.StatusOK,
+	}, {
+		want:     nil,
+		name:     "bad_subnet",
+		subnet:   "192.168.1.0",
+		wantCode: http.StatusBadRequest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/control/clients/summary?subnet=" + url.QueryEscape(tc.subnet)
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			w := httptest.NewRecorder()
+
+			clients.handleSubnetSummary(w, r)
+			require.Equal(t, tc.wantCode, w.Code)
+
+			if tc.want == nil {
+				return
+			}
+
+			got := &subnetSummaryJSON{}
+			err := json.NewDecoder(w.Body).Decode(got)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClientsContainer_handleGetClients_shape(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	ok, err := clients.Add(&Client{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+		Name: "laptop",
+		IDs:  []string{"1.1.1.1"},
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	testCases := []struct {
+		name           string
+		query          string
+		wantDeprecated bool
+		wantCode       int
+	}{{
+		name:           "legacy",
+		query:          "",
+		wantDeprecated: true,
+		wantCode:       http.StatusOK,
+	}, {
+		name:           "structured",
+		query:          "?shape=structured",
+		wantDeprecated: false,
+		wantCode:       http.StatusOK,
+	}, {
+		name:           "bad",
+		query:          "?shape=v2",
+		wantDeprecated: false,
+		wantCode:       http.StatusBadRequest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/control/clients"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			clients.handleGetClients(w, r)
+			require.Equal(t, tc.wantCode, w.Code)
+
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+
+			resp := &struct {
+				Clients []map[string]json.RawMessage `json:"clients"`
+			}{}
+			err = json.NewDecoder(w.Body).Decode(resp)
+			require.NoError(t, err)
+			require.Len(t, resp.Clients, 1)
+
+			_, hasDeprecated := resp.Clients[0]["safesearch_enabled"]
+			assert.Equal(t, tc.wantDeprecated, hasDeprecated)
+
+			assert.NotEqual(t, "null", string(resp.Clients[0]["safe_search"]))
+		})
+	}
+}
+
+func TestClientJSON_MarshalJSON(t *testing.T) {
+	cj := &clientJSON{
+		Name:       "runtime",
+		structured: true,
+	}
+
+	b, err := json.Marshal(cj)
+	require.NoError(t, err)
+
+	got := map[string]json.RawMessage{}
+	err = json.Unmarshal(b, &got)
+	require.NoError(t, err)
+
+	assert.NotContains(t, got, "safesearch_enabled")
+
+	ssConf := &filtering.SafeSearchConfig{}
+	err = json.Unmarshal(got["safe_search"], ssConf)
+	require.NoError(t, err)
+
+	assert.False(t, ssConf.Enabled)
+
+	cj.structured = false
+	b, err = json.Marshal(cj)
+	require.NoError(t, err)
+
+	got = map[string]json.RawMessage{}
+	err = json.Unmarshal(b, &got)
+	require.NoError(t, err)
+
+	assert.Contains(t, got, "safesearch_enabled")
+	assert.Equal(t, "null", string(got["safe_search"]))
+}
+
+func TestClientsContainer_handleGetClients_source(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	var (
+		dhcpIP  = netip.MustParseAddr("1.1.1.1")
+		arpIP   = netip.MustParseAddr("1.1.1.2")
+		bothIP  = netip.MustParseAddr("1.1.1.3")
+		rdnsIP  = netip.MustParseAddr("1.1.1.4")
+		allIPs  = []netip.Addr{dhcpIP, arpIP, bothIP, rdnsIP}
+		dhcpIPs = []netip.Addr{dhcpIP, bothIP}
+	)
+
+	require.True(t, clients.AddHost(dhcpIP, "dhcp", ClientSourceDHCP))
+	require.True(t, clients.AddHost(arpIP, "arp", ClientSourceARP))
+	require.True(t, clients.AddHost(bothIP, "arp", ClientSourceARP))
+	require.True(t, clients.AddHost(bothIP, "dhcp", ClientSourceDHCP))
+	require.True(t, clients.AddHost(rdnsIP, "rdns", ClientSourceRDNS))
+
+	testCases := []struct {
+		name    string
+		query   string
+		wantIPs []netip.Addr
+	}{{
+		name:    "all",
+		query:   "",
+		wantIPs: allIPs,
+	}, {
+		name:    "dhcp",
+		query:   "?source=dhcp",
+		wantIPs: dhcpIPs,
+	}, {
+		name:    "several",
+		query:   "?source=DHCP&source=ARP",
+		wantIPs: []netip.Addr{dhcpIP, arpIP, bothIP},
+	}, {
+		name:    "comma",
+		query:   "?source=rDNS,ARP",
+		wantIPs: []netip.Addr{arpIP, bothIP, rdnsIP},
+	}, {
+		name:    "none",
+		query:   "?source=WHOIS",
+		wantIPs: nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/control/clients"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			clients.handleGetClients(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			// Don't use clientListJSON, since clientSource can't be decoded.
+			resp := &struct {
+				RuntimeClients []struct {
+					IP netip.Addr `json:"ip"`
+				} `json:"auto_clients"`
+			}{}
+			err := json.NewDecoder(w.Body).Decode(resp)
+			require.NoError(t, err)
+
+			var gotIPs []netip.Addr
+			for _, rc := range resp.RuntimeClients {
+				gotIPs = append(gotIPs, rc.IP)
+			}
+
+			assert.ElementsMatch(t, tc.wantIPs, gotIPs)
+		})
+	}
+
+	t.Run("bad", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/control/clients?source=bad", nil)
+		w := httptest.NewRecorder()
+
+		clients.handleGetClients(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestClientsContainer_queryLogRetention(t *testing.T) {
+	const retMs = float64(2 * time.Hour / time.Millisecond)
+
+	clients := newClientsContainer(t)
+
+	t.Run("invalid", func(t *testing.T) {
+		c, err := clients.jsonToClient(clientJSON{
+			Name:              "guest",
+			IDs:               []string{"1.1.1.1"},
+			QueryLogRetention: float64(time.Minute / time.Millisecond),
+		}, nil)
+		require.NoError(t, err)
+
+		_, err = clients.Add(c)
+		testutil.AssertErrorMsg(t, "less than an hour", err)
+	})
+
+	c, err := clients.jsonToClient(clientJSON{
+		Name:              "guest",
+		IDs:               []string{"1.1.1.1"},
+		QueryLogRetention: retMs,
+	}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2*time.Hour, c.QueryLogRetention)
+	assert.Equal(t, retMs, clientToJSON(c).QueryLogRetention)
+
+	ok, err := clients.Add(c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	loaded := newClientsContainer(t)
+	err = loaded.addFromConfig(clients.forConfig(), &filtering.Config{})
+	require.NoError(t, err)
+
+	got, ok := loaded.Find("1.1.1.1")
+	require.True(t, ok)
+
+	assert.Equal(t, 2*time.Hour, got.QueryLogRetention)
+}
@@ -0,0 +1,188 @@
+package home
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/golibs/errors"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// patchClientJSON is the request to the POST /control/clients/patch HTTP API.
+type patchClientJSON struct {
+	// Changes are the new values of the fields of clientJSON, which should be
+	// changed.  The other fields are left as is.
+	Changes map[string]json.RawMessage `json:"changes"`
+
+	// Name is the name of the persistent client to change.
+	Name string `json:"name"`
+}
+
+// readOnlyClientFields are the fields of clientJSON, which are only set in the
+// responses and thus can't be changed.
+var readOnlyClientFields = []string{
+	"created_at",
+	"disallowed",
+	"disallowed_rule",
+	"modified_at",
+	"source",
+	"whois_info",
+}
+
+// patch changes the fields of the persistent client named name according to
+// changes, which map the fields of clientJSON to their new values, and returns
+// the updated client.  The fields not in changes are left as is.
+func (clients *clientsContainer) patch(
+	name string,
+	changes map[string]json.RawMessage,
+) (c *Client, err error) {
+	if len(changes) == 0 {
+		return nil, errors.Error("no changes")
+	}
+
+	fields := maps.Keys(changes)
+	slices.Sort(fields)
+	for _, f := range fields {
+		err = validateClientChange(f, changes[f])
+		if err != nil {
+			return nil, fmt.Errorf("changes: field %q: %w", f, err)
+		}
+	}
+
+	var prev *Client
+	var ok bool
+	func() {
+		clients.lock.RLock()
+		defer clients.lock.RUnlock()
+
+		prev, ok = clients.list[name]
+	}()
+
+	if !ok {
+		return nil, fmt.Errorf("client %q not found", name)
+	}
+
+	cj, err := patchedClientJSON(clientToJSON(prev), changes)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	c, err = clients.jsonToClient(*cj, prev)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	err = clients.Update(prev, c)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// validateClientChange returns an error if val isn't a valid JSON value of the
+// changeable field of clientJSON.  The value itself is validated along with
+// the whole client.
+func validateClientChange(field string, val json.RawMessage) (err error) {
+	if slices.Contains(readOnlyClientFields, field) {
+		return errors.Error("field is read-only")
+	}
+
+	data, err := json.Marshal(map[string]json.RawMessage{field: val})
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	// Don't wrap the error since it's informative enough as is.
+	return dec.Decode(&clientJSON{})
+}
+
+// patchedClientJSON returns a copy of cj with changes applied.
+func patchedClientJSON(
+	cj *clientJSON,
+	changes map[string]json.RawMessage,
+) (patched *clientJSON, err error) {
+	data, err := json.Marshal(cj)
+	if err != nil {
+		return nil, fmt.Errorf("encoding client: %w", err)
+	}
+
+	merged := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &merged)
+	if err != nil {
+		return nil, fmt.Errorf("decoding client: %w", err)
+	}
+
+	for f, val := range changes {
+		merged[f] = val
+	}
+
+	// The deprecated field is only used when the new one is absent, see
+	// [clientsContainer.jsonToClient].
+	_, hasDeprecated := changes["safesearch_enabled"]
+	if _, ok := changes["safe_search"]; hasDeprecated && !ok {
+		delete(merged, "safe_search")
+	}
+
+	data, err = json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("encoding patched client: %w", err)
+	}
+
+	patched = &clientJSON{}
+	err = json.Unmarshal(data, patched)
+	if err != nil {
+		return nil, fmt.Errorf("decoding patched client: %w", err)
+	}
+
+	return patched, nil
+}
+
+// handlePatchClient is the handler for the POST /control/clients/patch HTTP
+// API.  Unlike the POST /control/clients/update one, it only changes the
+// fields present in the request and responds with the updated client.
+func (clients *clientsContainer) handlePatchClient(w http.ResponseWriter, r *http.Request) {
+	structured, ok := structuredShape(w, r)
+	if !ok {
+		return
+	}
+
+	req := &patchClientJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	if req.Name == "" {
+		aghhttp.Error(r, w, http.StatusBadRequest, "name is required")
+
+		return
+	}
+
+	c, err := clients.patch(req.Name, req.Changes)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+		return
+	}
+
+	onConfigModified()
+
+	cj := clientToJSON(c)
+	cj.structured = structured
+
+	_ = aghhttp.WriteJSONResponse(w, r, cj)
+}
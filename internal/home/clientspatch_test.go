@@ -0,0 +1,110 @@
+package home
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientsContainer_patch(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	ok, err := clients.Add(&Client{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+		Name:             "client",
+		IDs:              []string{"1.1.1.1", "aa:aa:aa:aa:aa:aa"},
+		Notes:            "note",
+		UseOwnSettings:   true,
+		FilteringEnabled: true,
+		IgnoreQueryLog:   true,
+		Ratelimit:        10,
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	prev, ok := clients.Find("1.1.1.1")
+	require.True(t, ok)
+
+	t.Run("single_field", func(t *testing.T) {
+		c, patchErr := clients.patch("client", map[string]json.RawMessage{
+			"ratelimit": json.RawMessage(`20`),
+		})
+		require.NoError(t, patchErr)
+		require.NotNil(t, c)
+
+		got, found := clients.Find("aa:aa:aa:aa:aa:aa")
+		require.True(t, found)
+
+		assert.Equal(t, 20, got.Ratelimit)
+
+		assert.Equal(t, prev.Name, got.Name)
+		assert.Equal(t, prev.IDs, got.IDs)
+		assert.Equal(t, prev.Notes, got.Notes)
+		assert.Equal(t, prev.CreatedAt, got.CreatedAt)
+		assert.True(t, got.UseOwnSettings)
+		assert.True(t, got.FilteringEnabled)
+		assert.True(t, got.IgnoreQueryLog)
+	})
+
+	testCases := []struct {
+		changes    map[string]json.RawMessage
+		name       string
+		clientName string
+		wantErrMsg string
+	}{{
+		changes:    nil,
+		name:       "no_changes",
+		clientName: "client",
+		wantErrMsg: `no changes`,
+	}, {
+		changes: map[string]json.RawMessage{
+			"notes": json.RawMessage(`"new"`),
+		},
+		name:       "not_found",
+		clientName: "unknown",
+		wantErrMsg: `client "unknown" not found`,
+	}, {
+		changes: map[string]json.RawMessage{
+			"unknown": json.RawMessage(`1`),
+		},
+		name:       "unknown_field",
+		clientName: "client",
+		wantErrMsg: `changes: field "unknown": json: unknown field "unknown"`,
+	}, {
+		changes: map[string]json.RawMessage{
+			"created_at": json.RawMessage(`"2023-01-01T00:00:00Z"`),
+		},
+		name:       "read_only",
+		clientName: "client",
+		wantErrMsg: `changes: field "created_at": field is read-only`,
+	}, {
+		changes: map[string]json.RawMessage{
+			"ratelimit": json.RawMessage(`"20"`),
+		},
+		name:       "bad_type",
+		clientName: "client",
+		wantErrMsg: `changes: field "ratelimit": json: cannot unmarshal string ` +
+			`into Go struct field clientJSON.ratelimit of type int`,
+	}, {
+		changes: map[string]json.RawMessage{
+			"ids": json.RawMessage(`[]`),
+		},
+		name:       "bad_value",
+		clientName: "client",
+		wantErrMsg: `id required`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err = clients.patch(tc.clientName, tc.changes)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package home
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// Default values of the on-demand resolving of the PTR records of clients.
+const (
+	// findPTRTimeout is the maximum time spent on resolving the PTR records
+	// within a single request to the GET /control/clients/find HTTP API.
+	findPTRTimeout = 2 * time.Second
+
+	// findPTRCacheTTL is the time, for which the results of resolving,
+	// including the empty ones, are cached.
+	findPTRCacheTTL = 5 * time.Minute
+
+	// findPTRCacheSize is the maximum number of the cached results.
+	findPTRCacheSize = 1000
+)
+
+// ptrResolver resolves the hostnames of clients using PTR requests on demand
+// and caches the results for a short time.
+type ptrResolver struct {
+	// exchanger sends the PTR requests.  It must not be nil.
+	exchanger dnsforward.RDNSExchanger
+
+	// mu protects cache and pending.
+	mu *sync.Mutex
+
+	// cache maps the IP addresses to the results of resolving them.
+	cache map[netip.Addr]ptrCacheItem
+
+	// pending maps the IP addresses to the resolvings in progress, so that
+	// an address is only resolved once at a time.
+	pending map[netip.Addr]*ptrPending
+
+	// now returns the current time.
+	now func() (now time.Time)
+}
+
+// ptrCacheItem is the cached result of resolving a PTR record.
+type ptrCacheItem struct {
+	// expiry is the time, after which the item is stale.
+	expiry time.Time
+
+	// host is the resolved hostname.  It's empty if there is no PTR record or
+	// the resolving has failed.
+	host string
+}
+
+// ptrPending is a resolving of a PTR record in progress.
+type ptrPending struct {
+	// done is closed when the resolving is finished.
+	done chan struct{}
+
+	// host is the resolved hostname.  It must only be accessed after done is
+	// closed.
+	host string
+}
+
+// newPTRResolver returns a new properly initialized *ptrResolver.
+func newPTRResolver(exchanger dnsforward.RDNSExchanger) (r *ptrResolver) {
+	return &ptrResolver{
+		exchanger: exchanger,
+		mu:        &sync.Mutex{},
+		cache:     map[netip.Addr]ptrCacheItem{},
+		pending:   map[netip.Addr]*ptrPending{},
+		now:       time.Now,
+	}
+}
+
+// resolve returns the hostname of ip from its PTR record.  host is empty if
+// there is no such record, the resolving has failed, or ctx is done before it
+// finished.  In the latter case the resolving goes on in the background, so
+// that the result is cached for the next requests.  The concurrent calls for
+// the same ip wait for the same resolving.
+func (r *ptrResolver) resolve(ctx context.Context, ip netip.Addr) (host string) {
+	if err := ctx.Err(); err != nil {
+		log.Debug("clients: resolving ptr for %s: %s", ip, err)
+
+		return ""
+	}
+
+	host, p := r.start(ip)
+	if p == nil {
+		return host
+	}
+
+	select {
+	case <-p.done:
+		return p.host
+	case <-ctx.Done():
+		log.Debug("clients: resolving ptr for %s: %s", ip, ctx.Err())
+
+		return ""
+	}
+}
+
+// start returns the cached hostname of ip, if any.  Otherwise, it returns the
+// resolving of ip in progress, starting it if there is none.
+func (r *ptrResolver) start(ip netip.Addr) (host string, p *ptrPending) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	host, ok := r.cachedLocked(ip)
+	if ok {
+		return host, nil
+	}
+
+	p, ok = r.pending[ip]
+	if !ok {
+		p = &ptrPending{
+			done: make(chan struct{}),
+		}
+		r.pending[ip] = p
+
+		go r.exchange(ip, p)
+	}
+
+	return "", p
+}
+
+// exchange resolves ip, caches the result, and finishes p.  It's intended to be
+// used as a goroutine.
+func (r *ptrResolver) exchange(ip netip.Addr, p *ptrPending) {
+	defer log.OnPanic("clients: resolving ptr")
+
+	host, err := r.exchanger.Exchange(ip.AsSlice())
+	if err != nil {
+		log.Debug("clients: resolving ptr for %s: %s", ip, err)
+
+		host = ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.setLocked(ip, host)
+	delete(r.pending, ip)
+
+	p.host = host
+	close(p.done)
+}
+
+// cachedLocked returns the cached hostname of ip.  ok is false if there is no
+// such item or it's stale.  r.mu is expected to be locked.
+func (r *ptrResolver) cachedLocked(ip netip.Addr) (host string, ok bool) {
+	item, ok := r.cache[ip]
+	if !ok || !r.now().Before(item.expiry) {
+		return "", false
+	}
+
+	return item.host, true
+}
+
+// setLocked caches host as the result of resolving ip.  The stale items are
+// removed when the cache is full, and the whole cache is cleared if there are
+// none.  r.mu is expected to be locked.
+func (r *ptrResolver) setLocked(ip netip.Addr, host string) {
+	now := r.now()
+	if len(r.cache) >= findPTRCacheSize {
+		for cachedIP, item := range r.cache {
+			if !now.Before(item.expiry) {
+				delete(r.cache, cachedIP)
+			}
+		}
+
+		if len(r.cache) >= findPTRCacheSize {
+			r.cache = map[netip.Addr]ptrCacheItem{}
+		}
+	}
+
+	r.cache[ip] = ptrCacheItem{
+		expiry: now.Add(findPTRCacheTTL),
+		host:   host,
+	}
+}
+
+// resolvePTR returns the hostname of ip from its PTR record, if resolving
+// clients on demand is available and ip is valid.  See [ptrResolver.resolve].
+func (clients *clientsContainer) resolvePTR(ctx context.Context, ip netip.Addr) (host string) {
+	if clients.ptr == nil || !ip.IsValid() {
+		return ""
+	}
+
+	return clients.ptr.resolve(ctx, ip)
+}
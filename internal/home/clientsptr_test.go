@@ -0,0 +1,186 @@
+package home
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePTRExchanger is a [dnsforward.RDNSExchanger] implementation for tests.
+type fakePTRExchanger struct {
+	onExchange func(ip net.IP) (host string, err error)
+}
+
+// type check
+var _ dnsforward.RDNSExchanger = (*fakePTRExchanger)(nil)
+
+// Exchange implements the [dnsforward.RDNSExchanger] interface for
+// *fakePTRExchanger.
+func (e *fakePTRExchanger) Exchange(ip net.IP) (host string, err error) {
+	return e.onExchange(ip)
+}
+
+// ResolvesPrivatePTR implements the [dnsforward.RDNSExchanger] interface for
+// *fakePTRExchanger.
+func (e *fakePTRExchanger) ResolvesPrivatePTR() (ok bool) {
+	return true
+}
+
+func TestClientsContainer_resolvePTR(t *testing.T) {
+	const host = "printer.lan"
+
+	var (
+		knownIP   = netip.MustParseAddr("192.168.0.2")
+		unknownIP = netip.MustParseAddr("192.168.0.3")
+	)
+
+	var num atomic.Uint32
+	ex := &fakePTRExchanger{
+		onExchange: func(ip net.IP) (h string, err error) {
+			num.Add(1)
+
+			if ip.Equal(knownIP.AsSlice()) {
+				return host, nil
+			}
+
+			return "", dnsforward.ErrRDNSNoData
+		},
+	}
+
+	clients := newClientsContainer(t)
+	clients.ptr = newPTRResolver(ex)
+
+	now := time.Now()
+	clients.ptr.now = func() (n time.Time) { return now }
+
+	ctx := context.Background()
+
+	assert.Equal(t, host, clients.resolvePTR(ctx, knownIP))
+	assert.Empty(t, clients.resolvePTR(ctx, unknownIP))
+	assert.Empty(t, clients.resolvePTR(ctx, netip.Addr{}))
+	assert.Equal(t, uint32(2), num.Load())
+
+	t.Run("cached", func(t *testing.T) {
+		assert.Equal(t, host, clients.resolvePTR(ctx, knownIP))
+		assert.Empty(t, clients.resolvePTR(ctx, unknownIP))
+		assert.Equal(t, uint32(2), num.Load())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(findPTRCacheTTL)
+
+		assert.Equal(t, host, clients.resolvePTR(ctx, knownIP))
+		assert.Equal(t, uint32(3), num.Load())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		noPTR := newClientsContainer(t)
+
+		assert.Empty(t, noPTR.resolvePTR(ctx, knownIP))
+	})
+}
+
+func TestClientsContainer_resolvePTR_timeout(t *testing.T) {
+	const host = "slow.lan"
+
+	ip := netip.MustParseAddr("192.168.0.2")
+
+	var num atomic.Uint32
+	unblock := make(chan struct{})
+	ex := &fakePTRExchanger{
+		onExchange: func(_ net.IP) (h string, err error) {
+			num.Add(1)
+			<-unblock
+
+			return host, nil
+		},
+	}
+
+	clients := newClientsContainer(t)
+	clients.ptr = newPTRResolver(ex)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Empty(t, clients.resolvePTR(ctx, ip))
+
+	// The resolving goes on in the background, so the next requests must get
+	// its result without sending another PTR request.
+	close(unblock)
+
+	ctx = context.Background()
+
+	assert.Equal(t, host, clients.resolvePTR(ctx, ip))
+	assert.Equal(t, host, clients.resolvePTR(ctx, ip))
+	assert.Equal(t, uint32(1), num.Load())
+}
+
+func TestPTRResolver_resolve_pending(t *testing.T) {
+	const (
+		host = "slow.lan"
+		n    = 10
+	)
+
+	ip := netip.MustParseAddr("192.168.0.2")
+
+	var num atomic.Uint32
+	unblock := make(chan struct{})
+	ex := &fakePTRExchanger{
+		onExchange: func(_ net.IP) (h string, err error) {
+			num.Add(1)
+			<-unblock
+
+			return host, nil
+		},
+	}
+
+	r := newPTRResolver(ex)
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.Empty(t, r.resolve(ctx, ip))
+		assert.Zero(t, num.Load())
+	})
+
+	hosts := make(chan string, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			hosts <- r.resolve(context.Background(), ip)
+		}()
+	}
+
+	require.Eventually(t, func() (ok bool) {
+		return num.Load() > 0
+	}, time.Second, time.Millisecond)
+
+	close(unblock)
+
+	for i := 0; i < n; i++ {
+		h, _ := testutil.RequireReceive(t, hosts, time.Second)
+		assert.Equal(t, host, h)
+	}
+
+	assert.Equal(t, uint32(1), num.Load())
+}
+
+func TestClientsContainer_handleFindClient_resolve(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/control/clients/find?resolve=maybe", nil)
+	w := httptest.NewRecorder()
+
+	clients.handleFindClient(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,62 @@
+package home
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+)
+
+// clientRulesJSON is the response to the GET /control/clients/rules HTTP API.
+type clientRulesJSON struct {
+	*filtering.EffectiveRules
+
+	// Name is the name of the persistent client, settings of which are applied
+	// to the requests.  It's empty if there is no such client.
+	Name string `json:"name"`
+
+	ProtectionEnabled   bool `json:"protection_enabled"`
+	FilteringEnabled    bool `json:"filtering_enabled"`
+	SafeSearchEnabled   bool `json:"safesearch_enabled"`
+	SafeBrowsingEnabled bool `json:"safebrowsing_enabled"`
+	ParentalEnabled     bool `json:"parental_enabled"`
+}
+
+// effectiveRules returns the settings and the rules, which are applied to the
+// requests from ip now.  Unlike the actual requests, it doesn't record the
+// client as pending approval.
+func (clients *clientsContainer) effectiveRules(ip netip.Addr) (resp *clientRulesJSON) {
+	setts := Context.filters.Settings()
+	setts.ProtectionEnabled = true
+	if clients.dnsServer != nil {
+		setts.ProtectionEnabled, _ = clients.dnsServer.UpdatedProtectionStatus()
+	}
+
+	applyClientFiltering(ip.AsSlice(), "", setts, false)
+
+	return &clientRulesJSON{
+		EffectiveRules:      Context.filters.EffectiveRules(setts),
+		Name:                setts.ClientName,
+		ProtectionEnabled:   setts.ProtectionEnabled,
+		FilteringEnabled:    setts.FilteringEnabled,
+		SafeSearchEnabled:   setts.SafeSearchEnabled,
+		SafeBrowsingEnabled: setts.SafeBrowsingEnabled,
+		ParentalEnabled:     setts.ParentalEnabled,
+	}
+}
+
+// handleClientRules is the handler for the GET /control/clients/rules HTTP
+// API.  It responds with the filtering rules applied to the client with the IP
+// address from the ip query parameter.
+func (clients *clientsContainer) handleClientRules(w http.ResponseWriter, r *http.Request) {
+	ipStr := r.URL.Query().Get("ip")
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "bad ip %q: %s", ipStr, err)
+
+		return
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, clients.effectiveRules(ip.Unmap()))
+}
@@ -0,0 +1,76 @@
+package home
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientsContainer_effectiveRules(t *testing.T) {
+	const (
+		globalRule = "||global.example^"
+		clientName = "kid"
+	)
+
+	var err error
+	Context.filters, err = filtering.New(&filtering.Config{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+		UserRules: []string{"! Comment", globalRule},
+	}, nil)
+	require.NoError(t, err)
+
+	Context.filters.SetEnabled(true)
+
+	domainRules, err := filtering.NewClientDomainRules(
+		[]string{"blocked.example"},
+		[]string{"allowed.example"},
+	)
+	require.NoError(t, err)
+
+	Context.clients.idIndex = map[string]*Client{
+		"1.2.3.4": {
+			Name:        clientName,
+			domainRules: domainRules,
+		},
+	}
+
+	t.Run("persistent", func(t *testing.T) {
+		resp := Context.clients.effectiveRules(netip.MustParseAddr("1.2.3.4"))
+		require.NotNil(t, resp)
+
+		assert.Equal(t, clientName, resp.Name)
+		assert.True(t, resp.ProtectionEnabled)
+		assert.True(t, resp.FilteringEnabled)
+
+		assert.Equal(t, []string{globalRule}, resp.UserRules)
+		assert.Equal(t, []string{
+			"||blocked.example^",
+			"@@||allowed.example^",
+		}, resp.ClientRules)
+	})
+
+	t.Run("runtime", func(t *testing.T) {
+		resp := Context.clients.effectiveRules(netip.MustParseAddr("5.6.7.8"))
+		require.NotNil(t, resp)
+
+		assert.Empty(t, resp.Name)
+		assert.Equal(t, []string{globalRule}, resp.UserRules)
+		assert.Empty(t, resp.ClientRules)
+	})
+
+	t.Run("bad_ip", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/control/clients/rules?ip=bad", nil)
+		w := httptest.NewRecorder()
+
+		Context.clients.handleClientRules(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,183 @@
+package home
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/golibs/log"
+	"golang.org/x/exp/slices"
+)
+
+// safeSearchProvidersJSON is the set of the safe search providers in the
+// requests to the POST /control/clients/safesearch HTTP API.
+type safeSearchProvidersJSON struct {
+	Bing       bool `json:"bing"`
+	DuckDuckGo bool `json:"duckduckgo"`
+	Google     bool `json:"google"`
+	Pixabay    bool `json:"pixabay"`
+	Yandex     bool `json:"yandex"`
+	YouTube    bool `json:"youtube"`
+}
+
+// bulkSafeSearchJSON is the request body of the POST
+// /control/clients/safesearch HTTP API.
+type bulkSafeSearchJSON struct {
+	// Providers are the safe search providers to use.  If nil, all of them are
+	// enabled.
+	Providers *safeSearchProvidersJSON `json:"providers"`
+
+	// Clients are the names of the persistent clients to update.
+	Clients []string `json:"clients"`
+
+	// Enabled tells if the safe search should be enabled.
+	Enabled bool `json:"enabled"`
+}
+
+// bulkSafeSearchRespJSON is the response body of the POST
+// /control/clients/safesearch HTTP API.
+type bulkSafeSearchRespJSON struct {
+	// Errors maps the names of the clients, which failed to update, to the
+	// descriptions of the errors.
+	Errors map[string]string `json:"errors"`
+
+	// Updated are the names of the updated clients.
+	Updated []string `json:"updated"`
+}
+
+// toConfig returns the safe search configuration for the request.
+func (req *bulkSafeSearchJSON) toConfig() (conf filtering.SafeSearchConfig) {
+	conf = filtering.SafeSearchConfig{
+		Enabled: req.Enabled,
+	}
+
+	p := req.Providers
+	if p == nil {
+		p = &safeSearchProvidersJSON{
+			Bing:       true,
+			DuckDuckGo: true,
+			Google:     true,
+			Pixabay:    true,
+			Yandex:     true,
+			YouTube:    true,
+		}
+	}
+
+	conf.Bing = p.Bing
+	conf.DuckDuckGo = p.DuckDuckGo
+	conf.Google = p.Google
+	conf.Pixabay = p.Pixabay
+	conf.Yandex = p.Yandex
+	conf.YouTube = p.YouTube
+
+	return conf
+}
+
+// setSafeSearchBulk sets the safe search configuration of each persistent
+// client from names to conf.  A failure to update a client doesn't prevent
+// updating the others.  updated are the names of the updated clients, errs
+// maps the names of the others to the errors.
+func (clients *clientsContainer) setSafeSearchBulk(
+	names []string,
+	conf filtering.SafeSearchConfig,
+) (updated []string, errs map[string]error) {
+	errs = map[string]error{}
+	for _, name := range names {
+		if _, ok := errs[name]; ok || slices.Contains(updated, name) {
+			continue
+		}
+
+		err := clients.setClientSafeSearch(name, conf)
+		if err != nil {
+			log.Debug("clients: setting safesearch for client %q: %s", name, err)
+
+			errs[name] = err
+
+			continue
+		}
+
+		updated = append(updated, name)
+	}
+
+	return updated, errs
+}
+
+// setClientSafeSearch sets the safe search configuration of the persistent
+// client named name to conf and rebuilds its safe search engine.
+func (clients *clientsContainer) setClientSafeSearch(
+	name string,
+	conf filtering.SafeSearchConfig,
+) (err error) {
+	var prev *Client
+	var ok bool
+	func() {
+		clients.lock.RLock()
+		defer clients.lock.RUnlock()
+
+		prev, ok = clients.list[name]
+	}()
+
+	if !ok {
+		return fmt.Errorf("client %q not found", name)
+	}
+
+	c := prev.ShallowClone()
+	c.safeSearchConf = conf
+	c.SafeSearch = nil
+	if conf.Enabled {
+		c.safeSearchConf.CustomResolver = safeSearchResolver{}
+
+		err = c.setSafeSearch(
+			c.safeSearchConf,
+			clients.safeSearchCacheSize,
+			clients.safeSearchCacheTTL,
+		)
+		if err != nil {
+			return fmt.Errorf("creating safesearch: %w", err)
+		}
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return clients.Update(prev, c)
+}
+
+// handleBulkSafeSearch is the handler for the POST /control/clients/safesearch
+// HTTP API.  It responds with the names of the updated clients and the errors
+// for the others.
+func (clients *clientsContainer) handleBulkSafeSearch(w http.ResponseWriter, r *http.Request) {
+	req := &bulkSafeSearchJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	if len(req.Clients) == 0 {
+		aghhttp.Error(r, w, http.StatusBadRequest, "clients are required")
+
+		return
+	}
+
+	updated, errs := clients.setSafeSearchBulk(req.Clients, req.toConfig())
+	if len(updated) > 0 {
+		onConfigModified()
+	}
+
+	resp := &bulkSafeSearchRespJSON{
+		Errors:  make(map[string]string, len(errs)),
+		Updated: updated,
+	}
+
+	if resp.Updated == nil {
+		resp.Updated = []string{}
+	}
+
+	for name, e := range errs {
+		resp.Errors[name] = e.Error()
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
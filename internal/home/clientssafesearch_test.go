@@ -0,0 +1,100 @@
+package home
+
+import (
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientsContainer_setSafeSearchBulk(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	for _, c := range []*Client{{
+		Name: "kid_tablet",
+		IDs:  []string{"1.1.1.1"},
+	}, {
+		Name: "kid_phone",
+		IDs:  []string{"1.1.1.2"},
+	}, {
+		Name: "parent",
+		IDs:  []string{"1.1.1.3"},
+	}} {
+		ok, err := clients.Add(c)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	req := &bulkSafeSearchJSON{
+		Providers: &safeSearchProvidersJSON{
+			Google:  true,
+			YouTube: true,
+		},
+		Clients: []string{"kid_tablet", "unknown", "kid_phone"},
+		Enabled: true,
+	}
+
+	updated, errs := clients.setSafeSearchBulk(req.Clients, req.toConfig())
+	assert.Equal(t, []string{"kid_tablet", "kid_phone"}, updated)
+
+	require.Len(t, errs, 1)
+	testutil.AssertErrorMsg(t, `client "unknown" not found`, errs["unknown"])
+
+	engines := map[string]filtering.SafeSearch{}
+	for _, name := range []string{"kid_tablet", "kid_phone"} {
+		c, ok := clients.list[name]
+		require.True(t, ok)
+
+		assert.True(t, c.safeSearchConf.Enabled)
+		assert.True(t, c.safeSearchConf.Google)
+		assert.True(t, c.safeSearchConf.YouTube)
+		assert.False(t, c.safeSearchConf.Bing)
+
+		require.NotNil(t, c.SafeSearch)
+		engines[name] = c.SafeSearch
+	}
+
+	parent, ok := clients.list["parent"]
+	require.True(t, ok)
+
+	assert.False(t, parent.safeSearchConf.Enabled)
+	assert.Nil(t, parent.SafeSearch)
+
+	t.Run("rebuild", func(t *testing.T) {
+		req.Providers = nil
+		req.Clients = []string{"kid_tablet"}
+
+		updated, errs = clients.setSafeSearchBulk(req.Clients, req.toConfig())
+		require.Empty(t, errs)
+
+		assert.Equal(t, []string{"kid_tablet"}, updated)
+
+		c, found := clients.list["kid_tablet"]
+		require.True(t, found)
+
+		assert.True(t, c.safeSearchConf.Bing)
+		require.NotNil(t, c.SafeSearch)
+
+		assert.NotSame(t, engines["kid_tablet"], c.SafeSearch)
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		req.Clients = []string{"kid_tablet", "kid_phone"}
+		req.Enabled = false
+
+		updated, errs = clients.setSafeSearchBulk(req.Clients, req.toConfig())
+		require.Empty(t, errs)
+
+		assert.Equal(t, []string{"kid_tablet", "kid_phone"}, updated)
+
+		for _, name := range updated {
+			c, found := clients.list[name]
+			require.True(t, found)
+
+			assert.False(t, c.safeSearchConf.Enabled)
+			assert.Nil(t, c.SafeSearch)
+		}
+	})
+}
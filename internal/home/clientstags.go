@@ -1,5 +1,12 @@
 package home
 
+import (
+	"net/http"
+	"strings"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+)
+
 var clientTags = []string{
 	"device_audio",
 	"device_camera",
@@ -25,3 +32,70 @@ var clientTags = []string{
 	"user_child",
 	"user_regular",
 }
+
+// clientTagCategory is the category of a client tag.
+type clientTagCategory string
+
+// Client tag categories.  Each category corresponds to the prefix of the tag
+// name.
+const (
+	clientTagCategoryDevice clientTagCategory = "device"
+	clientTagCategoryOS     clientTagCategory = "os"
+	clientTagCategoryUser   clientTagCategory = "user"
+)
+
+// clientTagDescriptions contains human-readable descriptions of the tags from
+// clientTags.
+var clientTagDescriptions = map[string]string{
+	"device_audio":         "Audio device, such as a smart speaker",
+	"device_camera":        "Camera, such as a security camera",
+	"device_gameconsole":   "Game console",
+	"device_laptop":        "Laptop",
+	"device_nas":           "Network-attached storage",
+	"device_other":         "Other device",
+	"device_pc":            "Personal computer",
+	"device_phone":         "Phone",
+	"device_printer":       "Printer",
+	"device_securityalarm": "Security alarm",
+	"device_tablet":        "Tablet",
+	"device_tv":            "TV",
+
+	"os_android": "Android",
+	"os_ios":     "iOS",
+	"os_linux":   "Linux",
+	"os_macos":   "macOS",
+	"os_other":   "Other operating system",
+	"os_windows": "Windows",
+
+	"user_admin":   "Administrator",
+	"user_child":   "Child",
+	"user_regular": "Regular user",
+}
+
+// clientTagJSON is the JSON representation of a supported client tag.
+type clientTagJSON struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Category    clientTagCategory `json:"category"`
+}
+
+// clientTagsJSON returns the JSON representations of all supported client
+// tags in the order of clientTags.
+func clientTagsJSON() (tags []*clientTagJSON) {
+	tags = make([]*clientTagJSON, 0, len(clientTags))
+	for _, name := range clientTags {
+		cat, _, _ := strings.Cut(name, "_")
+		tags = append(tags, &clientTagJSON{
+			Name:        name,
+			Description: clientTagDescriptions[name],
+			Category:    clientTagCategory(cat),
+		})
+	}
+
+	return tags
+}
+
+// handleGetTags is the handler for the GET /control/clients/tags HTTP API.
+func (clients *clientsContainer) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	_ = aghhttp.WriteJSONResponse(w, r, clientTagsJSON())
+}
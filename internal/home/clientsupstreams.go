@@ -0,0 +1,350 @@
+package home
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/stringutil"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// upstreamProfileObject is the representation of a named upstream profile in
+// the configuration file and the HTTP API.  The persistent clients refer to
+// the profiles by name instead of repeating the same upstreams, see
+// [Client.UpstreamsProfile].
+type upstreamProfileObject struct {
+	// Name is the unique name of the profile.
+	Name string `yaml:"name" json:"name"`
+
+	// Upstreams are the upstream servers of the profile in the same format as
+	// the ones of a persistent client.
+	Upstreams []string `yaml:"upstreams" json:"upstreams"`
+}
+
+// validate returns an error if o isn't a valid upstream profile.
+func (o *upstreamProfileObject) validate() (err error) {
+	if o.Name == "" {
+		return errors.Error("invalid name")
+	}
+
+	if len(stringutil.FilterOut(o.Upstreams, dnsforward.IsCommentOrEmpty)) == 0 {
+		return errors.Error("no upstreams")
+	}
+
+	err = dnsforward.ValidateUpstreams(o.Upstreams)
+	if err != nil {
+		return fmt.Errorf("invalid upstream servers: %w", err)
+	}
+
+	return nil
+}
+
+// setUpstreamProfiles sets the upstream profiles from the configuration file.
+// The persistent clients should already be added.  The clients referring to
+// unknown profiles use the global upstreams, since the profiles could have
+// been removed from the configuration file manually.
+func (clients *clientsContainer) setUpstreamProfiles(objs []*upstreamProfileObject) (err error) {
+	profiles := make(map[string][]string, len(objs))
+	for i, o := range objs {
+		err = o.validate()
+		if err != nil {
+			return fmt.Errorf("upstream profiles: at index %d: %w", i, err)
+		} else if _, ok := profiles[o.Name]; ok {
+			return fmt.Errorf("upstream profiles: at index %d: duplicate profile %q", i, o.Name)
+		}
+
+		profiles[o.Name] = stringutil.CloneSlice(o.Upstreams)
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	for _, c := range clients.list {
+		name := c.UpstreamsProfile
+		if _, ok := profiles[name]; name != "" && !ok {
+			log.Info("upstream profiles: warning: client %q: no profile named %q", c.Name, name)
+		}
+	}
+
+	clients.upstreamProfiles = profiles
+
+	return nil
+}
+
+// upstreamProfilesConf returns the upstream profiles sorted by name for the
+// configuration file.  objs is nil if there are no profiles.
+func (clients *clientsContainer) upstreamProfilesConf() (objs []*upstreamProfileObject) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	return clients.upstreamProfileObjectsLocked()
+}
+
+// upstreamProfileObjectsLocked returns the upstream profiles sorted by name.
+// objs is nil if there are no profiles.  clients.lock is expected to be
+// locked.
+func (clients *clientsContainer) upstreamProfileObjectsLocked() (objs []*upstreamProfileObject) {
+	names := maps.Keys(clients.upstreamProfiles)
+	slices.Sort(names)
+
+	for _, name := range names {
+		objs = append(objs, &upstreamProfileObject{
+			Name:      name,
+			Upstreams: stringutil.CloneSlice(clients.upstreamProfiles[name]),
+		})
+	}
+
+	return objs
+}
+
+// addUpstreamProfile adds a new upstream profile described by o.
+func (clients *clientsContainer) addUpstreamProfile(o *upstreamProfileObject) (err error) {
+	err = o.validate()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if _, ok := clients.upstreamProfiles[o.Name]; ok {
+		return fmt.Errorf("profile %q already exists", o.Name)
+	}
+
+	if clients.upstreamProfiles == nil {
+		clients.upstreamProfiles = map[string][]string{}
+	}
+
+	clients.upstreamProfiles[o.Name] = stringutil.CloneSlice(o.Upstreams)
+
+	return nil
+}
+
+// updateUpstreamProfile replaces the upstream profile named name with the one
+// described by o.  The clients referring to the profile are replaced with their
+// copies using the new upstreams and the new name, and the subscribers are
+// notified about the changes.
+func (clients *clientsContainer) updateUpstreamProfile(
+	name string,
+	o *upstreamProfileObject,
+) (err error) {
+	err = o.validate()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if _, ok := clients.upstreamProfiles[name]; !ok {
+		return fmt.Errorf("profile %q not found", name)
+	}
+
+	if _, ok := clients.upstreamProfiles[o.Name]; ok && o.Name != name {
+		return fmt.Errorf("profile %q already exists", o.Name)
+	}
+
+	delete(clients.upstreamProfiles, name)
+	clients.upstreamProfiles[o.Name] = stringutil.CloneSlice(o.Upstreams)
+
+	// Collect the clients first, since updating them changes clients.list.
+	var affected []*Client
+	for _, c := range clients.list {
+		if c.UpstreamsProfile == name {
+			affected = append(affected, c)
+		}
+	}
+
+	var errs []error
+	for _, prev := range affected {
+		c := prev.ShallowClone()
+		c.UpstreamsProfile = o.Name
+
+		// Make the upstream configuration recreated on the next request.
+		c.upstreamConfig = nil
+
+		err = clients.updateLocked(prev, c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("client %q: %w", prev.Name, err))
+
+			continue
+		}
+
+		err = prev.closeUpstreams()
+		if err != nil {
+			log.Error("upstream profiles: %s", err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.List("updating clients", errs...)
+	}
+
+	return nil
+}
+
+// delUpstreamProfile removes the upstream profile named name.  It returns an
+// error if there is no such profile or it's used by any client.
+func (clients *clientsContainer) delUpstreamProfile(name string) (err error) {
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if _, ok := clients.upstreamProfiles[name]; !ok {
+		return fmt.Errorf("profile %q not found", name)
+	}
+
+	for _, c := range clients.list {
+		if c.UpstreamsProfile == name {
+			return fmt.Errorf("profile %q is used by client %q", name, c.Name)
+		}
+	}
+
+	delete(clients.upstreamProfiles, name)
+
+	return nil
+}
+
+// checkUpstreamsProfile returns an error if name isn't empty and there is no
+// upstream profile with such name.
+func (clients *clientsContainer) checkUpstreamsProfile(name string) (err error) {
+	if name == "" {
+		return nil
+	}
+
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	if _, ok := clients.upstreamProfiles[name]; !ok {
+		return fmt.Errorf("upstreams_profile: profile %q not found", name)
+	}
+
+	return nil
+}
+
+// upstreamsLocked returns the upstreams of c, which are either its own ones,
+// if any, or the ones of its upstream profile, without the comments and the
+// empty lines.  clients.lock is expected to be locked.
+func (clients *clientsContainer) upstreamsLocked(c *Client) (upstreams []string) {
+	upstreams = c.Upstreams
+	if !c.hasUpstreams() {
+		upstreams = clients.upstreamProfiles[c.UpstreamsProfile]
+	}
+
+	return stringutil.FilterOut(upstreams, dnsforward.IsCommentOrEmpty)
+}
+
+// upstreamProfilesJSON is the response to the GET
+// /control/clients/upstream_profiles HTTP API.
+type upstreamProfilesJSON struct {
+	Profiles []*upstreamProfileObject `json:"profiles"`
+}
+
+// updateUpstreamProfileJSON is the request to the POST
+// /control/clients/upstream_profiles/update HTTP API.
+type updateUpstreamProfileJSON struct {
+	Data *upstreamProfileObject `json:"data"`
+	Name string                 `json:"name"`
+}
+
+// deleteUpstreamProfileJSON is the request to the POST
+// /control/clients/upstream_profiles/delete HTTP API.
+type deleteUpstreamProfileJSON struct {
+	Name string `json:"name"`
+}
+
+// handleGetUpstreamProfiles is the handler for the GET
+// /control/clients/upstream_profiles HTTP API.
+func (clients *clientsContainer) handleGetUpstreamProfiles(w http.ResponseWriter, r *http.Request) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	resp := &upstreamProfilesJSON{
+		Profiles: clients.upstreamProfileObjectsLocked(),
+	}
+
+	if resp.Profiles == nil {
+		resp.Profiles = []*upstreamProfileObject{}
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
+
+// handleAddUpstreamProfile is the handler for the POST
+// /control/clients/upstream_profiles/add HTTP API.
+func (clients *clientsContainer) handleAddUpstreamProfile(w http.ResponseWriter, r *http.Request) {
+	o := &upstreamProfileObject{}
+	err := json.NewDecoder(r.Body).Decode(o)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	err = clients.addUpstreamProfile(o)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "adding upstream profile: %s", err)
+
+		return
+	}
+
+	onConfigModified()
+}
+
+// handleUpdateUpstreamProfile is the handler for the POST
+// /control/clients/upstream_profiles/update HTTP API.
+func (clients *clientsContainer) handleUpdateUpstreamProfile(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	req := &updateUpstreamProfileJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	if req.Data == nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "data is required")
+
+		return
+	}
+
+	err = clients.updateUpstreamProfile(req.Name, req.Data)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "updating upstream profile: %s", err)
+
+		return
+	}
+
+	onConfigModified()
+}
+
+// handleDelUpstreamProfile is the handler for the POST
+// /control/clients/upstream_profiles/delete HTTP API.
+func (clients *clientsContainer) handleDelUpstreamProfile(w http.ResponseWriter, r *http.Request) {
+	req := &deleteUpstreamProfileJSON{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "failed to process request body: %s", err)
+
+		return
+	}
+
+	err = clients.delUpstreamProfile(req.Name)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "deleting upstream profile: %s", err)
+
+		return
+	}
+
+	onConfigModified()
+}
@@ -0,0 +1,169 @@
+package home
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientsContainer_upstreamProfiles(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	err := clients.addUpstreamProfile(&upstreamProfileObject{
+		Name:      "family",
+		Upstreams: []string{"1.1.1.1"},
+	})
+	require.NoError(t, err)
+
+	for _, c := range []*Client{{
+		Name:             "kid",
+		IDs:              []string{"1.1.1.1"},
+		UpstreamsProfile: "family",
+	}, {
+		Name:        "kid_tablet",
+		IDs:         []string{"1.1.1.2"},
+		InheritFrom: "kid",
+	}, {
+		Name:             "parent",
+		IDs:              []string{"1.1.1.3"},
+		Upstreams:        []string{"8.8.8.8", "[/example.org/]8.8.4.4"},
+		UpstreamsProfile: "family",
+	}} {
+		ok, addErr := clients.Add(c)
+		require.NoError(t, addErr)
+		require.True(t, ok)
+	}
+
+	kidConf, err := clients.findUpstreams("1.1.1.1")
+	require.NoError(t, err)
+	require.NotNil(t, kidConf)
+
+	assert.Len(t, kidConf.Upstreams, 1)
+	assert.Empty(t, kidConf.DomainReservedUpstreams)
+
+	t.Run("inherited", func(t *testing.T) {
+		conf, findErr := clients.findUpstreams("1.1.1.2")
+		require.NoError(t, findErr)
+
+		assert.Same(t, kidConf, conf)
+	})
+
+	t.Run("own_upstreams", func(t *testing.T) {
+		conf, findErr := clients.findUpstreams("1.1.1.3")
+		require.NoError(t, findErr)
+		require.NotNil(t, conf)
+
+		assert.Len(t, conf.DomainReservedUpstreams, 1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		events := make(chan *clientEvent, len(clients.list))
+		clients.subscribe(func(e *clientEvent) { events <- e })
+
+		prevKid := clients.list["kid"]
+
+		err = clients.updateUpstreamProfile("family", &upstreamProfileObject{
+			Name:      "kids",
+			Upstreams: []string{"9.9.9.9", "[/example.org/]9.9.9.10"},
+		})
+		require.NoError(t, err)
+
+		c, ok := clients.Find("1.1.1.1")
+		require.True(t, ok)
+
+		assert.Equal(t, "kids", c.UpstreamsProfile)
+
+		// The previous clients must be kept intact.
+		assert.Equal(t, "family", prevKid.UpstreamsProfile)
+
+		var updated []string
+		for i := 0; i < 2; i++ {
+			e, _ := testutil.RequireReceive(t, events, time.Second)
+			require.Equal(t, clientUpdated, e.change)
+			require.NotNil(t, e.prev)
+
+			assert.Equal(t, "family", e.prev.UpstreamsProfile)
+			assert.Equal(t, "kids", e.client.UpstreamsProfile)
+
+			updated = append(updated, e.client.Name)
+		}
+
+		assert.ElementsMatch(t, []string{"kid", "parent"}, updated)
+
+		conf, findErr := clients.findUpstreams("1.1.1.2")
+		require.NoError(t, findErr)
+		require.NotNil(t, conf)
+
+		assert.NotSame(t, kidConf, conf)
+		assert.Len(t, conf.DomainReservedUpstreams, 1)
+
+		assert.Equal(t, []*upstreamProfileObject{{
+			Name:      "kids",
+			Upstreams: []string{"9.9.9.9", "[/example.org/]9.9.9.10"},
+		}}, clients.upstreamProfilesConf())
+	})
+
+	t.Run("delete_used", func(t *testing.T) {
+		require.True(t, clients.Del("parent"))
+
+		err = clients.delUpstreamProfile("kids")
+		testutil.AssertErrorMsg(t, `profile "kids" is used by client "kid"`, err)
+	})
+}
+
+func TestClientsContainer_addUpstreamProfile_errors(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	err := clients.addUpstreamProfile(&upstreamProfileObject{
+		Name:      "profile",
+		Upstreams: []string{"1.1.1.1"},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		profile    *upstreamProfileObject
+		name       string
+		wantErrMsg string
+	}{{
+		profile: &upstreamProfileObject{
+			Upstreams: []string{"1.1.1.1"},
+		},
+		name:       "no_name",
+		wantErrMsg: `invalid name`,
+	}, {
+		profile: &upstreamProfileObject{
+			Name:      "profile",
+			Upstreams: []string{"1.1.1.1"},
+		},
+		name:       "duplicate",
+		wantErrMsg: `profile "profile" already exists`,
+	}, {
+		profile: &upstreamProfileObject{
+			Name:      "new",
+			Upstreams: []string{"# Comment"},
+		},
+		name:       "no_upstreams",
+		wantErrMsg: `no upstreams`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err = clients.addUpstreamProfile(tc.profile)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
+
+func TestClientsContainer_jsonToClient_upstreamsProfile(t *testing.T) {
+	clients := newClientsContainer(t)
+
+	_, err := clients.jsonToClient(clientJSON{
+		Name:             "client",
+		IDs:              []string{"1.1.1.1"},
+		UpstreamsProfile: "unknown",
+	}, nil)
+	testutil.AssertErrorMsg(t, `upstreams_profile: profile "unknown" not found`, err)
+}
@@ -0,0 +1,110 @@
+package home
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// clientFieldDiff is the change of a single field of a persistent client.
+type clientFieldDiff struct {
+	// Old is the value of the field before the change.
+	Old any `json:"old"`
+
+	// New is the value of the field after the change.
+	New any `json:"new"`
+}
+
+// webhookClientChange is a change of a persistent client reported by the
+// webhook.
+type webhookClientChange struct {
+	// Diff maps the names of the changed fields of the client, as in the HTTP
+	// API, to their old and new values.  It's only set for updates.
+	Diff map[string]*clientFieldDiff `json:"diff,omitempty"`
+
+	// Name is the name of the client.  For updates, it's the new name.
+	Name string `json:"name"`
+
+	// Action is the type of the change, either "added", "updated", or
+	// "deleted".
+	Action string `json:"action"`
+}
+
+// handleClientEvent is a [clientEventHandler], which records the change of a
+// persistent client and schedules the notification the same way as
+// [webhook.notify] does.
+func (h *webhook) handleClientEvent(e *clientEvent) {
+	if h == nil {
+		return
+	}
+
+	c := &webhookClientChange{
+		Name:   e.client.Name,
+		Action: e.change.String(),
+	}
+
+	if e.change == clientUpdated {
+		c.Diff = clientsDiff(e.prev, e.client)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clients = append(h.clients, c)
+	h.addChange(webhookChangeClients)
+}
+
+// clientsDiff returns the fields of the HTTP API representation of a persistent
+// client, which differ between prev and c.
+func clientsDiff(prev, c *Client) (diff map[string]*clientFieldDiff) {
+	prevFields, err := clientFields(prev)
+	if err != nil {
+		log.Error("webhook: computing diff: %s", err)
+
+		return nil
+	}
+
+	fields, err := clientFields(c)
+	if err != nil {
+		log.Error("webhook: computing diff: %s", err)
+
+		return nil
+	}
+
+	diff = map[string]*clientFieldDiff{}
+	for k, v := range fields {
+		if prevV := prevFields[k]; !reflect.DeepEqual(prevV, v) {
+			diff[k] = &clientFieldDiff{Old: prevV, New: v}
+		}
+	}
+
+	for k, prevV := range prevFields {
+		if _, ok := fields[k]; !ok {
+			diff[k] = &clientFieldDiff{Old: prevV, New: nil}
+		}
+	}
+
+	return diff
+}
+
+// clientFields returns the fields of the HTTP API representation of c.  The
+// timestamps are omitted, since they aren't settings and the time of the
+// change is sent in the payload anyway.
+func clientFields(c *Client) (fields map[string]any, err error) {
+	b, err := json.Marshal(clientToJSON(c))
+	if err != nil {
+		return nil, fmt.Errorf("encoding client %q: %w", c.Name, err)
+	}
+
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("decoding client %q: %w", c.Name, err)
+	}
+
+	delete(fields, "created_at")
+	delete(fields, "modified_at")
+
+	return fields, nil
+}
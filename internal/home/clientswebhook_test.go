@@ -0,0 +1,84 @@
+package home
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook_handleClientEvent(t *testing.T) {
+	const testTimeout = time.Second
+
+	u, reqCh := newTestWebhookServer(t)
+	h := newWebhook(&webhookConfig{
+		URL:         u,
+		Delay:       timeutil.Duration{Duration: 10 * time.Millisecond},
+		MaxAttempts: 1,
+	}, http.DefaultClient)
+	require.NotNil(t, h)
+
+	clients := newClientsContainer(t)
+	clients.subscribe(h.handleClientEvent)
+
+	c := &Client{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+		Name: "kid",
+		IDs:  []string{"1.1.1.1"},
+	}
+
+	ok, err := clients.Add(c)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	updated := c.ShallowClone()
+	updated.IDs = []string{"1.1.1.1", "2.2.2.2"}
+	updated.UseOwnBlockedServices = true
+
+	err = clients.Update(c, updated)
+	require.NoError(t, err)
+
+	ok = clients.Del(updated.Name)
+	require.True(t, ok)
+
+	// Receive all the changes, since they may be split between several
+	// notifications depending on the timing.
+	var got []*webhookClientChange
+	for len(got) < 3 {
+		req, _ := testutil.RequireReceive(t, reqCh, testTimeout)
+		require.NotNil(t, req)
+
+		assert.Equal(t, []webhookChange{webhookChangeClients}, req.payload.Changes)
+		got = append(got, req.payload.Clients...)
+	}
+
+	want := []*webhookClientChange{{
+		Name:   "kid",
+		Action: "added",
+	}, {
+		Diff: map[string]*clientFieldDiff{
+			"ids": {
+				Old: []any{"1.1.1.1"},
+				New: []any{"1.1.1.1", "2.2.2.2"},
+			},
+			"use_global_blocked_services": {
+				Old: true,
+				New: false,
+			},
+		},
+		Name:   "kid",
+		Action: "updated",
+	}, {
+		Name:   "kid",
+		Action: "deleted",
+	}}
+	assert.Equal(t, want, got)
+}
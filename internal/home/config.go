@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtls"
@@ -16,6 +17,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
 	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/AdGuardHome/internal/stats"
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 	"github.com/AdguardTeam/dnsproxy/fastip"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
@@ -79,11 +81,141 @@ type clientsConfig struct {
 	Sources *clientSourcesConfig `yaml:"runtime_sources"`
 	// Persistent are the configured clients.
 	Persistent []*clientObject `yaml:"persistent"`
+	// RuntimeDefaults are the settings used for the clients, which aren't
+	// persistent, instead of the global ones.
+	RuntimeDefaults *runtimeDefaults `yaml:"runtime_defaults"`
+	// WHOIS is the configuration of the WHOIS information source.  It's only
+	// used when Sources.WHOIS is true.
+	WHOIS *whoisConfig `yaml:"whois"`
+	// Find is the configuration of the GET /control/clients/find HTTP API.
+	Find *clientsFindConfig `yaml:"find"`
+	// Approval is the configuration of the approval of the new runtime
+	// clients.
+	Approval *clientsApprovalConfig `yaml:"approval,omitempty"`
+	// Groups are the groups of the persistent clients sharing their settings.
+	Groups []*clientGroupObject `yaml:"groups,omitempty"`
+	// UpstreamProfiles are the named upstreams, which the persistent clients
+	// can use instead of their own ones.
+	UpstreamProfiles []*upstreamProfileObject `yaml:"upstream_profiles,omitempty"`
+}
+
+// clientsFindConfig is the configuration of the GET /control/clients/find HTTP
+// API.
+type clientsFindConfig struct {
+	// MaxIDs is the maximum number of the client identifiers in a single
+	// request.  Zero means no limit.
+	MaxIDs uint `yaml:"max_ids"`
+	// Ratelimit is the maximum number of requests per second from a single
+	// remote address.  Zero means no limit.
+	Ratelimit uint32 `yaml:"ratelimit"`
+}
+
+// whoisConfig is the configuration of the WHOIS information source.
+type whoisConfig struct {
+	// Backend is the type of the WHOIS information processor.
+	Backend whois.Backend `yaml:"backend"`
+	// Server is the address of the initial WHOIS server or the base URL of the
+	// RDAP server.  If it's empty, the default server of the backend is used.
+	Server string `yaml:"server"`
+	// ServersFile is the optional path to the YAML file mapping networks to
+	// the initial WHOIS servers.  It's ignored by the RDAP backend.
+	ServersFile string `yaml:"servers_file"`
+	// QueryTemplates maps the hostnames of WHOIS servers to the templates of
+	// the queries sent to them, see [whois.QueryPlaceholder].  It's ignored by
+	// the RDAP backend.
+	QueryTemplates map[string]string `yaml:"query_templates"`
+	// Timeout is the timeout for WHOIS requests.
+	Timeout timeutil.Duration `yaml:"timeout"`
+	// CacheTTL is the Time to Live duration for cached IP addresses.
+	CacheTTL timeutil.Duration `yaml:"cache_ttl"`
+	// TransientTTL is the Time to Live duration for cached IP addresses, which
+	// couldn't be resolved due to a transient failure.
+	TransientTTL timeutil.Duration `yaml:"transient_ttl"`
+	// CacheTTLJitter is the maximum random deviation of the cache TTLs in
+	// percent, so that the addresses resolved together don't expire together.
+	CacheTTLJitter int `yaml:"cache_ttl_jitter"`
+	// MaxReadSize is the maximum size of a response read from a WHOIS server.
+	MaxReadSize whois.ReadSize `yaml:"max_read_size"`
+	// CacheSize is the maximum number of cached IP addresses.
+	CacheSize int `yaml:"cache_size"`
+	// QueueSize is the size of the queue of IP addresses for WHOIS processing.
+	// The addresses that don't fit into the queue are dropped.
+	QueueSize int `yaml:"queue_size"`
+	// Concurrency is the number of IP addresses processed simultaneously.
+	Concurrency int `yaml:"concurrency"`
+	// MaxConns is the maximum number of simultaneously open connections to
+	// WHOIS servers.  Zero means no limit.  It's ignored by the RDAP backend.
+	MaxConns int `yaml:"max_conns"`
+	// FollowReferrals, if false, makes the first response used as is, without
+	// querying the servers it refers to.  It's ignored by the RDAP backend.
+	FollowReferrals bool `yaml:"follow_referrals"`
+	// CountryFormat is the format of the countries in the WHOIS information,
+	// either "code" or "name".  Empty string means that the countries are left
+	// as received from the server.
+	CountryFormat whois.CountryFormat `yaml:"country_format"`
+}
+
+// validate returns an error if the WHOIS configuration is invalid, including
+// when it is nil, e.g. when the "whois" key is empty in the configuration
+// file.
+func (c *whoisConfig) validate() (err error) {
+	switch {
+	case c == nil:
+		return errors.Error("no value")
+	case c.Timeout.Duration <= 0:
+		return fmt.Errorf("timeout: must be positive, got %s", c.Timeout)
+	case c.CacheTTL.Duration < 0:
+		return fmt.Errorf("cache_ttl: must be non-negative, got %s", c.CacheTTL)
+	case c.TransientTTL.Duration < 0:
+		return fmt.Errorf("transient_ttl: must be non-negative, got %s", c.TransientTTL)
+	case c.CacheTTLJitter < 0 || c.CacheTTLJitter >= 100:
+		return fmt.Errorf("cache_ttl_jitter: must be in range [0, 100), got %d", c.CacheTTLJitter)
+	case c.CacheSize <= 0:
+		return fmt.Errorf("cache_size: must be positive, got %d", c.CacheSize)
+	case c.QueueSize <= 0:
+		return fmt.Errorf("queue_size: must be positive, got %d", c.QueueSize)
+	case c.Concurrency <= 0:
+		return fmt.Errorf("concurrency: must be positive, got %d", c.Concurrency)
+	case c.MaxConns < 0:
+		return fmt.Errorf("max_conns: must be non-negative, got %d", c.MaxConns)
+	default:
+		err = c.MaxReadSize.Validate()
+		if err != nil {
+			return fmt.Errorf("max_read_size: %w", err)
+		}
+
+		err = c.CountryFormat.Validate()
+		if err != nil {
+			return fmt.Errorf("country_format: %w", err)
+		}
+
+		return validateQueryTemplates(c.QueryTemplates)
+	}
+}
+
+// validateQueryTemplates returns an error if any of the WHOIS query templates
+// is invalid.
+func validateQueryTemplates(tmpls map[string]string) (err error) {
+	for host, tmpl := range tmpls {
+		err = whois.ValidateQueryTemplate(tmpl)
+		if err != nil {
+			return fmt.Errorf("query_templates: server %q: %w", host, err)
+		}
+	}
+
+	return nil
 }
 
 // clientSourceConfig is used to configure where the runtime clients will be
 // obtained from.
 type clientSourcesConfig struct {
+	// Priority is the list of the names of the runtime client sources ordered
+	// from the highest priority to the lowest one.  The name of a client known
+	// from several sources is taken from the source with the highest priority.
+	// The sources missing from the list have lower priorities than the listed
+	// ones.
+	Priority []string `yaml:"priority"`
+
 	WHOIS     bool `yaml:"whois"`
 	ARP       bool `yaml:"arp"`
 	RDNS      bool `yaml:"rdns"`
@@ -118,6 +250,11 @@ type configuration struct {
 	Theme Theme `yaml:"theme"`
 	// DebugPProf defines if the profiling HTTP handler will listen on :6060.
 	DebugPProf bool `yaml:"debug_pprof"`
+	// ConfigWriteDelay is the delay between the modification of the
+	// configuration and writing it to the file.  All the modifications made
+	// during the delay are written at once.  Zero means writing the file
+	// immediately.
+	ConfigWriteDelay timeutil.Duration `yaml:"config_write_delay"`
 
 	DNS      dnsConfig         `yaml:"dns"`
 	TLS      tlsConfigSettings `yaml:"tls"`
@@ -142,6 +279,10 @@ type configuration struct {
 	// Keep this field sorted to ensure consistent ordering.
 	Clients *clientsConfig `yaml:"clients"`
 
+	// Webhook is the configuration of the notifications about the changes of
+	// the filtering settings and the persistent clients.
+	Webhook *webhookConfig `yaml:"webhook"`
+
 	logSettings `yaml:",inline"`
 
 	OSConfig *osConfig `yaml:"os"`
@@ -272,8 +413,9 @@ type statsConfig struct {
 //
 // TODO(a.garipov, e.burkov): This global is awful and must be removed.
 var config = &configuration{
-	AuthAttempts: 5,
-	AuthBlockMin: 15,
+	AuthAttempts:     5,
+	AuthBlockMin:     15,
+	ConfigWriteDelay: timeutil.Duration{Duration: defaultConfigWriteDelay},
 	HTTPConfig: httpConfig{
 		Address:    netip.AddrPortFrom(netip.IPv4Unspecified(), 3000),
 		SessionTTL: timeutil.Duration{Duration: 30 * timeutil.Day},
@@ -383,12 +525,43 @@ var config = &configuration{
 	},
 	Clients: &clientsConfig{
 		Sources: &clientSourcesConfig{
+			Priority: []string{
+				ClientSourceDHCP.String(),
+				ClientSourceHostsFile.String(),
+				ClientSourceRDNS.String(),
+				ClientSourceARP.String(),
+				ClientSourceWHOIS.String(),
+			},
 			WHOIS:     true,
 			ARP:       true,
 			RDNS:      true,
 			DHCP:      true,
 			HostsFile: true,
 		},
+		RuntimeDefaults: &runtimeDefaults{
+			Enabled:          false,
+			FilteringEnabled: true,
+		},
+		WHOIS: &whoisConfig{
+			Backend:      whois.BackendDefault,
+			Timeout:      timeutil.Duration{Duration: 5 * time.Second},
+			CacheTTL:     timeutil.Duration{Duration: 1 * time.Hour},
+			TransientTTL: timeutil.Duration{Duration: 1 * time.Minute},
+			MaxReadSize:  64 * whois.ReadSizeKilobyte,
+			CacheSize:    10_000,
+			QueueSize:    255,
+			Concurrency:  1,
+
+			FollowReferrals: true,
+		},
+		Find: &clientsFindConfig{
+			MaxIDs:    1000,
+			Ratelimit: 0,
+		},
+	},
+	Webhook: &webhookConfig{
+		Delay:       timeutil.Duration{Duration: 1 * time.Second},
+		MaxAttempts: 5,
 	},
 	logSettings: logSettings{
 		Compress:   false,
@@ -473,6 +646,12 @@ func parseConfig() (err error) {
 		return err
 	}
 
+	err = config.Webhook.validate()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
 	tcpPorts := aghalg.UniqChecker[tcpPort]{}
 	addPorts(tcpPorts, tcpPort(config.HTTPConfig.Address.Port()))
 
@@ -598,6 +777,10 @@ func (c *configuration) write() (err error) {
 	}
 
 	config.Clients.Persistent = Context.clients.forConfig()
+	config.Clients.RuntimeDefaults = Context.clients.runtimeDefaultsConf()
+	config.Clients.Approval = Context.clients.approvalConf()
+	config.Clients.Groups = Context.clients.groupsConf()
+	config.Clients.UpstreamProfiles = Context.clients.upstreamProfilesConf()
 
 	configFile := config.getConfigFilename()
 	log.Debug("writing config file %q", configFile)
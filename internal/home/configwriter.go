@@ -0,0 +1,85 @@
+package home
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// defaultConfigWriteDelay is the default delay between the modification of
+// the configuration and writing it to the file.
+const defaultConfigWriteDelay = 1 * time.Second
+
+// configWriter coalesces the consequent writes of the configuration file to
+// reduce the wear of flash storages.
+type configWriter struct {
+	// write writes the configuration file.
+	write func() (err error)
+
+	// mu protects timer.
+	mu *sync.Mutex
+
+	// timer is the timer of the pending write.  It's nil if there is none.
+	timer *time.Timer
+}
+
+// confWriter is the global writer of the configuration file.
+var confWriter = newConfigWriter(func() (err error) { return config.write() })
+
+// newConfigWriter returns a new properly initialized *configWriter which uses
+// write to write the configuration file.
+func newConfigWriter(write func() (err error)) (w *configWriter) {
+	return &configWriter{
+		write: write,
+		mu:    &sync.Mutex{},
+	}
+}
+
+// schedule makes w write the configuration file after delay, unless there is
+// a pending write already.  If delay is not positive, the file is written
+// immediately.
+func (w *configWriter) schedule(delay time.Duration) {
+	if delay <= 0 {
+		w.writeNow()
+
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer == nil {
+		w.timer = time.AfterFunc(delay, w.onTimer)
+	}
+}
+
+// onTimer is called when the pending write is due.
+func (w *configWriter) onTimer() {
+	w.mu.Lock()
+	w.timer = nil
+	w.mu.Unlock()
+
+	w.writeNow()
+}
+
+// flush writes the configuration file immediately if there is a pending
+// write.  It must be called before shutting down.
+func (w *configWriter) flush() {
+	w.mu.Lock()
+	pending := w.timer != nil && w.timer.Stop()
+	w.timer = nil
+	w.mu.Unlock()
+
+	if pending {
+		w.writeNow()
+	}
+}
+
+// writeNow writes the configuration file and logs the error, if any.
+func (w *configWriter) writeNow() {
+	err := w.write()
+	if err != nil {
+		log.Error("writing config: %s", err)
+	}
+}
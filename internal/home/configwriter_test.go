@@ -0,0 +1,41 @@
+package home
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigWriter(t *testing.T) {
+	var n int
+	w := newConfigWriter(func() (err error) {
+		n++
+
+		return nil
+	})
+
+	t.Run("immediate", func(t *testing.T) {
+		n = 0
+
+		w.schedule(0)
+		w.schedule(0)
+
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("coalesced", func(t *testing.T) {
+		n = 0
+
+		w.schedule(time.Hour)
+		w.schedule(time.Hour)
+		w.schedule(time.Hour)
+		assert.Equal(t, 0, n)
+
+		w.flush()
+		assert.Equal(t, 1, n)
+
+		w.flush()
+		assert.Equal(t, 1, n)
+	})
+}
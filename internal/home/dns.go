@@ -2,6 +2,7 @@ package home
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/netip"
@@ -36,12 +37,15 @@ const (
 	defaultPortTLS   = 853
 )
 
-// Called by other modules when configuration is changed
+// onConfigModified is called by other modules when configuration is changed.
+// The configuration file is written after [configuration.ConfigWriteDelay] so
+// that the rapid modifications don't cause a write each.
 func onConfigModified() {
-	err := config.write()
-	if err != nil {
-		log.Error("writing config: %s", err)
-	}
+	config.RLock()
+	delay := config.ConfigWriteDelay.Duration
+	config.RUnlock()
+
+	confWriter.schedule(delay)
 }
 
 // initDNS updates all the fields of the [Context] needed to initialize the DNS
@@ -73,16 +77,17 @@ func initDNS() (err error) {
 	}
 
 	conf := querylog.Config{
-		Anonymizer:        anonymizer,
-		ConfigModified:    onConfigModified,
-		HTTPRegister:      httpRegister,
-		FindClient:        Context.clients.findMultiple,
-		BaseDir:           baseDir,
-		AnonymizeClientIP: config.DNS.AnonymizeClientIP,
-		RotationIvl:       config.QueryLog.Interval.Duration,
-		MemSize:           config.QueryLog.MemSize,
-		Enabled:           config.QueryLog.Enabled,
-		FileEnabled:       config.QueryLog.FileEnabled,
+		Anonymizer:         anonymizer,
+		ConfigModified:     onConfigModified,
+		HTTPRegister:       httpRegister,
+		FindClient:         Context.clients.findMultiple,
+		HasClientRetention: Context.clients.hasQueryLogRetention,
+		BaseDir:            baseDir,
+		AnonymizeClientIP:  config.DNS.AnonymizeClientIP,
+		RotationIvl:        config.QueryLog.Interval.Duration,
+		MemSize:            config.QueryLog.MemSize,
+		Enabled:            config.QueryLog.Enabled,
+		FileEnabled:        config.QueryLog.FileEnabled,
 	}
 
 	set, err = aghnet.NewDomainNameSet(config.QueryLog.Ignored)
@@ -152,6 +157,7 @@ func initDNSServer(
 	}
 
 	Context.clients.dnsServer = Context.dnsServer
+	Context.clients.ptr = newPTRResolver(Context.dnsServer)
 
 	dnsConf, err := generateServerConfig(tlsConf, httpReg)
 	if err != nil {
@@ -171,70 +177,90 @@ func initDNSServer(
 		Context.rdns = NewRDNS(Context.dnsServer, &Context.clients, config.DNS.UsePrivateRDNS)
 	}
 
-	initWHOIS()
-
-	return nil
+	return initWHOIS()
 }
 
 // initWHOIS initializes the WHOIS.
-//
-// TODO(s.chzhen):  Consider making configurable.
-func initWHOIS() {
-	const (
-		// defaultQueueSize is the size of queue of IPs for WHOIS processing.
-		defaultQueueSize = 255
+func initWHOIS() (err error) {
+	conf := config.Clients.WHOIS
+
+	// Validate the configuration even if WHOIS is disabled, since the queue
+	// and the workers are started anyway.
+	err = conf.validate()
+	if err != nil {
+		return fmt.Errorf("whois: %w", err)
+	}
+
+	var w whois.Interface
+	if config.Clients.Sources.WHOIS {
+		w, err = newWHOIS(conf)
+		if err != nil {
+			// Don't wrap the error, since it's informative enough as is.
+			return err
+		}
+	} else {
+		w = whois.Empty{}
+	}
+
+	Context.whois = w
+	Context.whoisCh = make(chan netip.Addr, conf.QueueSize)
 
-		// defaultTimeout is the timeout for WHOIS requests.
-		defaultTimeout = 5 * time.Second
+	for i := 0; i < conf.Concurrency; i++ {
+		go func() {
+			defer log.OnPanic("whois")
 
-		// defaultCacheSize is the maximum size of the cache.  If it's zero,
-		// cache size is unlimited.
-		defaultCacheSize = 10_000
+			for ip := range Context.whoisCh {
+				info, changed := w.Process(context.Background(), ip)
+				if info != nil && changed {
+					Context.clients.setWHOISInfo(ip, info)
+				}
+			}
+		}()
+	}
 
-		// defaultMaxConnReadSize is an upper limit in bytes for reading from
-		// net.Conn.
-		defaultMaxConnReadSize = 64 * 1024
+	return nil
+}
 
+// newWHOIS returns a new WHOIS information processor of the backend configured
+// in conf.  conf must not be nil and must be valid.
+func newWHOIS(conf *whoisConfig) (w whois.Interface, err error) {
+	const (
 		// defaultMaxRedirects is the maximum redirects count.
 		defaultMaxRedirects = 5
 
 		// defaultMaxInfoLen is the maximum length of whois.Info fields.
 		defaultMaxInfoLen = 250
-
-		// defaultIPTTL is the Time to Live duration for cached IP addresses.
-		defaultIPTTL = 1 * time.Hour
 	)
 
-	Context.whoisCh = make(chan netip.Addr, defaultQueueSize)
-
-	var w whois.Interface
-
-	if config.Clients.Sources.WHOIS {
-		w = whois.New(&whois.Config{
-			DialContext:     customDialContext,
-			ServerAddr:      whois.DefaultServer,
-			Port:            whois.DefaultPort,
-			Timeout:         defaultTimeout,
-			CacheSize:       defaultCacheSize,
-			MaxConnReadSize: defaultMaxConnReadSize,
-			MaxRedirects:    defaultMaxRedirects,
-			MaxInfoLen:      defaultMaxInfoLen,
-			CacheTTL:        defaultIPTTL,
-		})
-	} else {
-		w = whois.Empty{}
-	}
-
-	go func() {
-		defer log.OnPanic("whois")
-
-		for ip := range Context.whoisCh {
-			info, changed := w.Process(context.Background(), ip)
-			if info != nil && changed {
-				Context.clients.setWHOISInfo(ip, info)
-			}
-		}
-	}()
+	server := conf.Server
+	if server == "" && conf.Backend != whois.BackendRDAP {
+		server = whois.DefaultServer
+	}
+
+	return whois.NewInterface(&whois.Config{
+		DialContext: customDialContext,
+		TLSConfig: &tls.Config{
+			RootCAs:      Context.tlsRoots,
+			CipherSuites: Context.tlsCipherIDs,
+			MinVersion:   tls.VersionTLS12,
+		},
+		Backend:         conf.Backend,
+		ServerAddr:      server,
+		ServersFile:     conf.ServersFile,
+		QueryTemplates:  conf.QueryTemplates,
+		Port:            whois.DefaultPort,
+		Timeout:         conf.Timeout.Duration,
+		CacheSize:       conf.CacheSize,
+		MaxConnReadSize: int64(conf.MaxReadSize),
+		MaxRedirects:    defaultMaxRedirects,
+		MaxConns:        conf.MaxConns,
+		MaxInfoLen:      defaultMaxInfoLen,
+		CacheTTL:        conf.CacheTTL.Duration,
+		TransientTTL:    conf.TransientTTL.Duration,
+		CacheTTLJitter:  conf.CacheTTLJitter,
+		IgnoreReferrals: !conf.FollowReferrals,
+		CountryFormat:   conf.CountryFormat,
+	})
 }
 
 // parseSubnetSet parses a slice of subnets.  If the slice is empty, it returns
@@ -274,12 +300,25 @@ func onDNSRequest(pctx *proxy.DNSContext) {
 		return
 	}
 
+	Context.clients.updateLastSeen(ip, time.Now())
+
 	srcs := config.Clients.Sources
 	if srcs.RDNS && !ip.IsLoopback() {
 		Context.rdns.Begin(ip)
 	}
 
-	Context.whoisCh <- ip
+	requestWHOIS(ip)
+}
+
+// requestWHOIS adds ip to the WHOIS processing queue.  ip is dropped if the
+// queue is full, so that the caller is never blocked by the WHOIS requests.
+func requestWHOIS(ip netip.Addr) {
+	select {
+	case Context.whoisCh <- ip:
+		log.Debug("whois: %s added to queue", ip)
+	default:
+		log.Debug("whois: queue is full, dropping %s", ip)
+	}
 }
 
 func ipsToTCPAddrs(ips []netip.Addr, port int) (tcpAddrs []*net.TCPAddr) {
@@ -446,6 +485,17 @@ func getDNSEncryption() (de dnsEncryption) {
 // applyAdditionalFiltering adds additional client information and settings if
 // the client has them.
 func applyAdditionalFiltering(clientIP net.IP, clientID string, setts *filtering.Settings) {
+	applyClientFiltering(clientIP, clientID, setts, true)
+}
+
+// applyClientFiltering is like [applyAdditionalFiltering], but the client is
+// only recorded as pending approval if record is true.
+func applyClientFiltering(
+	clientIP net.IP,
+	clientID string,
+	setts *filtering.Settings,
+	record bool,
+) {
 	// pref is a prefix for logging messages around the scope.
 	const pref = "applying filters"
 
@@ -459,12 +509,16 @@ func applyAdditionalFiltering(clientIP net.IP, clientID string, setts *filtering
 
 	setts.ClientIP = clientIP
 
-	c, ok := Context.clients.Find(clientID)
+	c, ok := Context.clients.findEffective(clientID)
 	if !ok {
-		c, ok = Context.clients.Find(clientIP.String())
-		if !ok {
-			log.Debug("%s: no clients with ip %s and clientid %q", pref, clientIP, clientID)
+		c, ok = Context.clients.findEffective(clientIP.String())
+	}
 
+	if !ok {
+		log.Debug("%s: no clients with ip %s and clientid %q", pref, clientIP, clientID)
+
+		c, ok = applyPendingProfile(clientIP, setts, record)
+		if !ok {
 			return
 		}
 	}
@@ -474,24 +528,58 @@ func applyAdditionalFiltering(clientIP net.IP, clientID string, setts *filtering
 	if c.UseOwnBlockedServices {
 		// TODO(e.burkov):  Get rid of this crutch.
 		setts.ServicesRules = nil
-		svcs := c.BlockedServices.IDs
-		if !c.BlockedServices.Schedule.Contains(time.Now()) {
-			Context.filters.ApplyBlockedServicesList(setts, svcs)
-			log.Debug("%s: services for client %q set: %s", pref, c.Name, svcs)
+		if Context.filters.ApplyScheduledBlockedServices(setts, c.BlockedServices) {
+			log.Debug("%s: services for client %q set: %s", pref, c.Name, c.BlockedServices.IDs)
 		}
 	}
 
 	setts.ClientName = c.Name
 	setts.ClientTags = c.Tags
-	if !c.UseOwnSettings {
-		return
+	setts.ClientRules = c.domainRules
+	setts.Ratelimit = c.Ratelimit
+	if c.UseOwnSettings {
+		setts.FilteringEnabled = c.FilteringEnabled
+		c.applySafeSearch(setts)
+		setts.SafeBrowsingEnabled = c.SafeBrowsingEnabled
+		setts.ParentalEnabled = c.ParentalEnabled
+	}
+
+	c.applyProtectionSchedules(setts, time.Now())
+}
+
+// applyPendingProfile applies the settings of the clients pending approval to
+// setts if the client with clientIP is one of them, otherwise it applies the
+// runtime defaults.  If profile isn't nil, its settings must be applied to
+// setts.  ok is true if profile isn't nil.  See
+// [clientsContainer.pendingProfile] for record.
+func applyPendingProfile(
+	clientIP net.IP,
+	setts *filtering.Settings,
+	record bool,
+) (profile *Client, ok bool) {
+	// pref is a prefix for logging messages around the scope.
+	const pref = "applying filters"
+
+	ip, _ := netip.AddrFromSlice(clientIP)
+	profile, blockRules, pending := Context.clients.pendingProfile(ip.Unmap(), record)
+	if !pending {
+		if Context.clients.applyRuntimeDefaults(setts) {
+			log.Debug("%s: using runtime defaults for %s", pref, clientIP)
+		}
+
+		return nil, false
+	} else if profile != nil {
+		log.Debug("%s: using pending approval profile %q for %s", pref, profile.Name, clientIP)
+
+		return profile, true
 	}
 
-	setts.FilteringEnabled = c.FilteringEnabled
-	setts.SafeSearchEnabled = c.safeSearchConf.Enabled
-	setts.ClientSafeSearch = c.SafeSearch
-	setts.SafeBrowsingEnabled = c.SafeBrowsingEnabled
-	setts.ParentalEnabled = c.ParentalEnabled
+	log.Debug("%s: blocking %s pending approval", pref, clientIP)
+
+	// NOTE: The client rules aren't applied when the protection is disabled.
+	setts.ClientRules = blockRules
+
+	return nil, false
 }
 
 func startDNSServer() error {
@@ -522,7 +610,7 @@ func startDNSServer() error {
 			Context.rdns.Begin(ip)
 		}
 
-		Context.whoisCh <- ip
+		requestWHOIS(ip)
 	}
 
 	return nil
@@ -2,10 +2,15 @@ package home
 
 import (
 	"net"
+	"net/netip"
 	"testing"
+	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/timeutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -87,6 +92,158 @@ func TestApplyAdditionalFiltering(t *testing.T) {
 	}
 }
 
+func TestApplyAdditionalFiltering_runtimeDefaults(t *testing.T) {
+	var err error
+
+	Context.filters, err = filtering.New(&filtering.Config{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	const persistentID = "1.1.1.1"
+
+	runtimeIP := net.IP{2, 2, 2, 2}
+
+	Context.clients.idIndex = map[string]*Client{
+		persistentID: {
+			UseOwnSettings:   true,
+			FilteringEnabled: true,
+		},
+	}
+	Context.clients.ipToRC = map[netip.Addr]*RuntimeClient{
+		netip.MustParseAddr("2.2.2.2"): {
+			Host:   "new-device",
+			Source: ClientSourceDHCP,
+		},
+	}
+	t.Cleanup(func() { Context.clients.runtimeDefaults = nil })
+
+	strict := &Client{
+		UseOwnSettings:      true,
+		safeSearchConf:      filtering.SafeSearchConfig{Enabled: true},
+		FilteringEnabled:    true,
+		SafeBrowsingEnabled: true,
+		ParentalEnabled:     true,
+	}
+	disabled := &Client{
+		UseOwnSettings:      false,
+		safeSearchConf:      filtering.SafeSearchConfig{Enabled: true},
+		FilteringEnabled:    true,
+		SafeBrowsingEnabled: true,
+		ParentalEnabled:     true,
+	}
+
+	testCases := []struct {
+		defaults   *Client
+		name       string
+		id         string
+		ip         net.IP
+		wantStrict assert.BoolAssertionFunc
+	}{{
+		defaults:   strict,
+		name:       "runtime_client",
+		id:         "",
+		ip:         runtimeIP,
+		wantStrict: assert.True,
+	}, {
+		defaults:   strict,
+		name:       "unknown_client",
+		id:         "",
+		ip:         net.IP{3, 3, 3, 3},
+		wantStrict: assert.True,
+	}, {
+		defaults:   strict,
+		name:       "persistent_client",
+		id:         persistentID,
+		ip:         net.IP{1, 1, 1, 1},
+		wantStrict: assert.False,
+	}, {
+		defaults:   disabled,
+		name:       "disabled",
+		id:         "",
+		ip:         runtimeIP,
+		wantStrict: assert.False,
+	}, {
+		defaults:   nil,
+		name:       "not_set",
+		id:         "",
+		ip:         runtimeIP,
+		wantStrict: assert.False,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			Context.clients.runtimeDefaults = tc.defaults
+
+			setts := &filtering.Settings{}
+			applyAdditionalFiltering(tc.ip, tc.id, setts)
+
+			tc.wantStrict(t, setts.SafeSearchEnabled)
+			tc.wantStrict(t, setts.SafeBrowsingEnabled)
+			tc.wantStrict(t, setts.ParentalEnabled)
+		})
+	}
+}
+
+func TestApplyAdditionalFiltering_ratelimit(t *testing.T) {
+	var err error
+
+	Context.filters, err = filtering.New(&filtering.Config{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	Context.clients.idIndex = map[string]*Client{
+		"1.1.1.1": {
+			Name:      "limited",
+			Ratelimit: 100,
+		},
+		"2.2.2.2": {
+			Name:      "unlimited",
+			Ratelimit: -1,
+		},
+		"3.3.3.3": {
+			Name:      "global",
+			Ratelimit: 0,
+		},
+	}
+
+	testCases := []struct {
+		name string
+		ip   net.IP
+		want int
+	}{{
+		name: "override",
+		ip:   net.IP{1, 1, 1, 1},
+		want: 100,
+	}, {
+		name: "unlimited",
+		ip:   net.IP{2, 2, 2, 2},
+		want: -1,
+	}, {
+		name: "global",
+		ip:   net.IP{3, 3, 3, 3},
+		want: 0,
+	}, {
+		name: "unknown",
+		ip:   net.IP{4, 4, 4, 4},
+		want: 0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setts := &filtering.Settings{}
+			applyAdditionalFiltering(tc.ip, "", setts)
+
+			assert.Equal(t, tc.want, setts.Ratelimit)
+		})
+	}
+}
+
 func TestApplyAdditionalFiltering_blockedServices(t *testing.T) {
 	filtering.InitModule()
 
@@ -174,3 +331,59 @@ func TestApplyAdditionalFiltering_blockedServices(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestWHOIS(t *testing.T) {
+	prev := Context.whoisCh
+	t.Cleanup(func() { Context.whoisCh = prev })
+
+	Context.whoisCh = make(chan netip.Addr, 1)
+
+	ip := netip.MustParseAddr("1.2.3.4")
+	requestWHOIS(ip)
+
+	// Shouldn't block when the queue is full.
+	requestWHOIS(netip.MustParseAddr("5.6.7.8"))
+
+	require.Len(t, Context.whoisCh, 1)
+	assert.Equal(t, ip, <-Context.whoisCh)
+}
+
+func TestWhoisConfig_validate(t *testing.T) {
+	newConf := func() (c *whoisConfig) {
+		return &whoisConfig{
+			Timeout:     timeutil.Duration{Duration: time.Second},
+			MaxReadSize: whois.ReadSizeKilobyte,
+			CacheSize:   1,
+			QueueSize:   1,
+			Concurrency: 1,
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		assert.NoError(t, newConf().validate())
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var c *whoisConfig
+
+		testutil.AssertErrorMsg(t, "no value", c.validate())
+	})
+
+	t.Run("zero_queue_size", func(t *testing.T) {
+		c := newConf()
+		c.QueueSize = 0
+
+		testutil.AssertErrorMsg(t, "queue_size: must be positive, got 0", c.validate())
+	})
+
+	t.Run("bad_country_format", func(t *testing.T) {
+		c := newConf()
+		c.CountryFormat = "bad"
+
+		testutil.AssertErrorMsg(
+			t,
+			`country_format: unsupported country format "bad"`,
+			c.validate(),
+		)
+	})
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/stats"
 	"github.com/AdguardTeam/AdGuardHome/internal/updater"
 	"github.com/AdguardTeam/AdGuardHome/internal/version"
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
@@ -86,6 +87,13 @@ type homeContext struct {
 	// whoisCh is the channel for receiving IPs for WHOIS processing.
 	whoisCh chan netip.Addr
 
+	// whois is the WHOIS information processor.  It's used by the HTTP API.
+	whois whois.Interface
+
+	// webhook sends the notifications about the changes of the filtering
+	// settings and the persistent clients.  It's nil if the notifications are disabled.
+	webhook *webhook
+
 	// tlsCipherIDs are the ID of the cipher suites that AdGuard Home must use.
 	tlsCipherIDs []uint16
 
@@ -317,6 +325,8 @@ func setupOpts(opts options) (err error) {
 
 // initContextClients initializes Context clients and related fields.
 func initContextClients() (err error) {
+	Context.webhook = newWebhook(config.Webhook, Context.client)
+
 	err = setupDNSFilteringConf(config.DNS.DnsfilterConf)
 	if err != nil {
 		// Don't wrap the error, because it's informative enough as is.
@@ -367,6 +377,45 @@ func initContextClients() (err error) {
 		return err
 	}
 
+	err = Context.clients.setRuntimeDefaults(config.Clients.RuntimeDefaults)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	err = Context.clients.setSourcePriority(config.Clients.Sources.Priority)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	err = Context.clients.setApproval(config.Clients.Approval)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	err = Context.clients.setGroups(config.Clients.Groups)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	err = Context.clients.setUpstreamProfiles(config.Clients.UpstreamProfiles)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	if findConf := config.Clients.Find; findConf != nil {
+		Context.clients.findMaxIDs = findConf.MaxIDs
+		Context.clients.findRatelimit = findConf.Ratelimit
+	}
+
+	if Context.webhook != nil {
+		Context.clients.subscribe(Context.webhook.handleClientEvent)
+	}
+
 	return nil
 }
 
@@ -425,7 +474,14 @@ func setupDNSFilteringConf(conf *filtering.Config) (err error) {
 	)
 
 	conf.EtcHosts = Context.etcHosts
-	conf.ConfigModified = onConfigModified
+	conf.ConfigModified = func() {
+		onConfigModified()
+		Context.webhook.notify(webhookChangeFiltering)
+	}
+	conf.BlockedServicesModified = func() {
+		onConfigModified()
+		Context.webhook.notify(webhookChangeBlockedServices)
+	}
 	conf.HTTPRegister = httpRegister
 	conf.DataDir = Context.getDataDir()
 	conf.Filters = slices.Clone(config.Filters)
@@ -861,6 +917,15 @@ func cleanup(ctx context.Context) {
 		Context.web.close(ctx)
 		Context.web = nil
 	}
+
+	// Write the pending configuration changes before the modules are stopped,
+	// since the configuration is gathered from them.
+	confWriter.flush()
+
+	// Send the pending notifications, since the changes they report have
+	// already been made.
+	Context.webhook.flush()
+
 	if Context.auth != nil {
 		Context.auth.Close()
 		Context.auth = nil
@@ -105,7 +105,7 @@ func (r *RDNS) cache(ip netip.Addr, ttl uint64) {
 func (r *RDNS) Begin(ip netip.Addr) {
 	r.ensurePrivateCache()
 
-	if r.isCached(ip) || r.clients.clientSource(ip) > ClientSourceRDNS {
+	if r.isCached(ip) || r.clients.hasHigherSource(ip, ClientSourceRDNS) {
 		return
 	}
 
@@ -0,0 +1,39 @@
+package home
+
+import (
+	"sync"
+	"time"
+)
+
+// requestRatelimiter counts the HTTP API requests from the remote addresses
+// within the current second.  The zero requestRatelimiter is ready for use.
+type requestRatelimiter struct {
+	// mu protects counters and sec.
+	mu sync.Mutex
+
+	// counters are the numbers of requests from the remote addresses within the
+	// current second.
+	counters map[string]uint32
+
+	// sec is the current second in Unix time.
+	sec int64
+}
+
+// isLimited counts the request from addr made at now and returns true if the
+// number of requests from addr within the current second exceeds limit.
+func (r *requestRatelimiter) isLimited(addr string, limit uint32, now time.Time) (ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Reset all the counters every second so that the memory isn't wasted on
+	// the addresses, which don't send requests anymore.
+	if sec := now.Unix(); sec != r.sec || r.counters == nil {
+		r.sec = sec
+		r.counters = map[string]uint32{}
+	}
+
+	n := r.counters[addr] + 1
+	r.counters[addr] = n
+
+	return n > limit
+}
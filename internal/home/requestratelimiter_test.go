@@ -0,0 +1,31 @@
+package home
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestRatelimiter_isLimited(t *testing.T) {
+	const (
+		addr      = "1.2.3.4"
+		otherAddr = "5.6.7.8"
+		limit     = 2
+	)
+
+	r := &requestRatelimiter{}
+	now := time.Unix(1_000, 0)
+
+	assert.False(t, r.isLimited(addr, limit, now))
+	assert.False(t, r.isLimited(addr, limit, now))
+	assert.True(t, r.isLimited(addr, limit, now))
+
+	assert.False(t, r.isLimited(otherAddr, limit, now))
+
+	// Same second.
+	assert.True(t, r.isLimited(addr, limit, now.Add(999*time.Millisecond)))
+
+	// Next second.
+	assert.False(t, r.isLimited(addr, limit, now.Add(time.Second)))
+}
@@ -0,0 +1,119 @@
+package home
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+)
+
+// statusSummaryResponse is the response to the GET /control/status/summary
+// HTTP API.
+type statusSummaryResponse struct {
+	// DHCP is nil if the DHCP server isn't available.
+	DHCP *dhcpSummaryJSON `json:"dhcp"`
+
+	// WHOIS is nil if WHOIS isn't initialized yet.
+	WHOIS *whois.CacheStats `json:"whois"`
+
+	DNS       *dnsSummaryJSON       `json:"dns"`
+	Filtering *filteringSummaryJSON `json:"filtering"`
+	Clients   *clientsSummaryJSON   `json:"clients"`
+}
+
+// dnsSummaryJSON is the state of the DNS server.
+type dnsSummaryJSON struct {
+	Running           bool `json:"running"`
+	ProtectionEnabled bool `json:"protection_enabled"`
+}
+
+// dhcpSummaryJSON is the state of the DHCP server.
+type dhcpSummaryJSON struct {
+	// V4 is the utilization of the DHCPv4 address pool.  It's nil if the
+	// DHCPv4 server isn't configured.
+	V4 *dhcpd.Utilization `json:"v4"`
+
+	// V6 is the utilization of the DHCPv6 address pool.  It's nil if the
+	// DHCPv6 server isn't configured.
+	V6 *dhcpd.Utilization `json:"v6"`
+
+	// Leases is the number of the dynamic and static leases.
+	Leases int `json:"leases"`
+
+	Enabled bool `json:"enabled"`
+}
+
+// filteringSummaryJSON is the state of the filtering.
+type filteringSummaryJSON struct {
+	// BlockedServicesNextChange is the time when the blocked services get
+	// paused or resumed by their schedule.  It's nil if that never happens.
+	BlockedServicesNextChange *time.Time `json:"blocked_services_next_change"`
+
+	Enabled bool `json:"enabled"`
+
+	// BlockedServicesPaused is true if the blocked services are currently
+	// paused by their schedule.
+	BlockedServicesPaused bool `json:"blocked_services_paused"`
+}
+
+// clientsSummaryJSON is the numbers of the clients.
+type clientsSummaryJSON struct {
+	Persistent int `json:"persistent"`
+	Runtime    int `json:"runtime"`
+}
+
+// handleStatusSummary is the handler for the GET /control/status/summary HTTP
+// API.  It collects the states of the subsystems in a single response.
+func handleStatusSummary(w http.ResponseWriter, r *http.Request) {
+	resp := &statusSummaryResponse{
+		DNS: &dnsSummaryJSON{
+			Running: isRunning(),
+		},
+		Filtering: &filteringSummaryJSON{},
+		Clients:   Context.clients.summary(),
+	}
+
+	if Context.dnsServer != nil {
+		resp.DNS.ProtectionEnabled, _ = Context.dnsServer.UpdatedProtectionStatus()
+	}
+
+	if Context.filters != nil {
+		resp.Filtering.Enabled = Context.filters.Settings().FilteringEnabled
+
+		paused, next := Context.filters.BlockedServicesPaused()
+		resp.Filtering.BlockedServicesPaused = paused
+		if !next.IsZero() {
+			resp.Filtering.BlockedServicesNextChange = &next
+		}
+	}
+
+	// The DHCP server isn't available on Windows.
+	if Context.dhcpServer != nil && runtime.GOOS != "windows" {
+		resp.DHCP = &dhcpSummaryJSON{
+			Leases:  len(Context.dhcpServer.Leases(dhcpd.LeasesAll)),
+			Enabled: Context.dhcpServer.Enabled(),
+		}
+
+		resp.DHCP.V4, resp.DHCP.V6 = Context.dhcpServer.Utilization()
+	}
+
+	if Context.whois != nil {
+		resp.WHOIS = Context.whois.Stats()
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
+
+// summary returns the numbers of the persistent and runtime clients.
+func (clients *clientsContainer) summary() (s *clientsSummaryJSON) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	return &clientsSummaryJSON{
+		Persistent: len(clients.list),
+		Runtime:    len(clients.ipToRC),
+	}
+}
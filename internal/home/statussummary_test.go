@@ -0,0 +1,103 @@
+package home
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/maps"
+)
+
+func TestHandleStatusSummary(t *testing.T) {
+	prevFilters, prevWHOIS := Context.filters, Context.whois
+	prevList, prevIPToRC := Context.clients.list, Context.clients.ipToRC
+	t.Cleanup(func() {
+		Context.filters, Context.whois = prevFilters, prevWHOIS
+		Context.clients.list, Context.clients.ipToRC = prevList, prevIPToRC
+	})
+
+	var err error
+	Context.filters, err = filtering.New(&filtering.Config{
+		BlockedServices: &filtering.BlockedServices{
+			Schedule: schedule.EmptyWeekly(),
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	Context.filters.SetEnabled(true)
+
+	Context.clients.list = map[string]*Client{
+		"client1": {Name: "client1"},
+		"client2": {Name: "client2"},
+	}
+	Context.clients.ipToRC = map[netip.Addr]*RuntimeClient{
+		netip.MustParseAddr("1.2.3.4"): {Host: "host1"},
+		netip.MustParseAddr("1.2.3.5"): {Host: "host2"},
+		netip.MustParseAddr("1.2.3.6"): {Host: "host3"},
+	}
+
+	wantClients := &clientsSummaryJSON{
+		Persistent: 2,
+		Runtime:    3,
+	}
+
+	testCases := []struct {
+		whois     whois.Interface
+		wantWHOIS *whois.CacheStats
+		name      string
+	}{{
+		whois:     nil,
+		wantWHOIS: nil,
+		name:      "no_whois",
+	}, {
+		whois: &fakeWHOIS{
+			onStats: func() (s *whois.CacheStats) {
+				return &whois.CacheStats{Size: 1, Hits: 2, Misses: 3}
+			},
+		},
+		wantWHOIS: &whois.CacheStats{Size: 1, Hits: 2, Misses: 3},
+		name:      "whois",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			Context.whois = tc.whois
+
+			r := httptest.NewRequest(http.MethodGet, "/control/status/summary", nil)
+			w := httptest.NewRecorder()
+			handleStatusSummary(w, r)
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			raw := map[string]json.RawMessage{}
+			err = json.Unmarshal(w.Body.Bytes(), &raw)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, []string{
+				"dhcp",
+				"whois",
+				"dns",
+				"filtering",
+				"clients",
+			}, maps.Keys(raw))
+			assert.JSONEq(t, "null", string(raw["dhcp"]))
+
+			resp := &statusSummaryResponse{}
+			err = json.Unmarshal(w.Body.Bytes(), resp)
+			require.NoError(t, err)
+
+			assert.Nil(t, resp.DHCP)
+			assert.Equal(t, tc.wantWHOIS, resp.WHOIS)
+			assert.Equal(t, &dnsSummaryJSON{}, resp.DNS)
+			assert.Equal(t, &filteringSummaryJSON{Enabled: true}, resp.Filtering)
+			assert.Equal(t, wantClients, resp.Clients)
+		})
+	}
+}
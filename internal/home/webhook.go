@@ -0,0 +1,319 @@
+package home
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/timeutil"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// webhookChange is the type of a change of the filtering settings reported by
+// the webhook.
+type webhookChange string
+
+// webhookChange values.
+const (
+	// webhookChangeFiltering means that the global filtering settings have
+	// been changed, e.g. the filter lists, the user rules, or the safe search.
+	webhookChangeFiltering webhookChange = "filtering"
+
+	// webhookChangeBlockedServices means that the global blocked services
+	// have been changed.
+	webhookChangeBlockedServices webhookChange = "blocked_services"
+
+	// webhookChangeClients means that the persistent clients or the settings
+	// of the runtime clients have been changed.
+	webhookChangeClients webhookChange = "clients"
+)
+
+// webhookSignatureHeader is the header containing the HMAC-SHA256 signature of
+// the body of the notification, if the secret is set.
+const webhookSignatureHeader = "X-AdGuard-Home-Signature"
+
+// webhookConfig is the configuration of the notifications about the changes of
+// the filtering settings and the persistent clients.
+type webhookConfig struct {
+	// URL is the URL the notifications are POSTed to.  Empty string disables
+	// the notifications.
+	URL string `yaml:"url"`
+	// Secret is the key for the HMAC-SHA256 signature of the notifications.
+	// Empty string means that the notifications aren't signed.
+	Secret string `yaml:"secret"`
+	// Delay is the delay between the change and the notification.  All the
+	// changes made during the delay are reported at once.
+	Delay timeutil.Duration `yaml:"delay"`
+	// MaxAttempts is the maximum number of attempts to send a notification.
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// validate returns an error if the webhook configuration is invalid.
+func (c *webhookConfig) validate() (err error) {
+	if c == nil || c.URL == "" {
+		return nil
+	}
+
+	defer func() { err = errors.Annotate(err, "webhook: %w") }()
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Errorf("url: bad scheme %q", u.Scheme)
+	case c.Delay.Duration < 0:
+		return fmt.Errorf("delay: must be non-negative, got %s", c.Delay)
+	case c.MaxAttempts <= 0:
+		return fmt.Errorf("max_attempts: must be positive, got %d", c.MaxAttempts)
+	default:
+		return nil
+	}
+}
+
+// webhookPayload is the body of a notification.
+type webhookPayload struct {
+	// Time is the time of the notification.
+	Time time.Time `json:"time"`
+
+	// Changes are the types of the changes made since the previous
+	// notification, sorted.
+	Changes []webhookChange `json:"changes"`
+
+	// Clients are the changes of the persistent clients made since the
+	// previous notification, in the order they were made.
+	Clients []*webhookClientChange `json:"clients,omitempty"`
+}
+
+const (
+	// defaultWebhookRetryDelay is the delay before the first retry of a failed
+	// notification.  It doubles with each attempt.
+	defaultWebhookRetryDelay = 1 * time.Second
+
+	// maxWebhookRetryDelay is the maximum delay between the attempts to send a
+	// notification.
+	maxWebhookRetryDelay = 1 * time.Minute
+)
+
+// webhook coalesces the changes of the filtering settings and the persistent
+// clients and POSTs them to the configured URL.  A nil *webhook is a valid
+// webhook, which does nothing.
+type webhook struct {
+	// client is the HTTP client used to send the notifications.
+	client *http.Client
+
+	// mu protects timer, changes, and clients.
+	mu *sync.Mutex
+
+	// timer is the timer of the pending notification.  It's nil if there is
+	// none.
+	timer *time.Timer
+
+	// changes are the changes made since the previous notification.
+	changes map[webhookChange]struct{}
+
+	// clients are the changes of the persistent clients made since the
+	// previous notification.
+	clients []*webhookClientChange
+
+	// url is the URL the notifications are POSTed to.
+	url string
+
+	// secret is the key for the HMAC-SHA256 signature.  It's nil if the
+	// notifications aren't signed.
+	secret []byte
+
+	// delay is the delay between the change and the notification.
+	delay time.Duration
+
+	// retryDelay is the delay before the first retry.
+	retryDelay time.Duration
+
+	// maxAttempts is the maximum number of attempts to send a notification.
+	maxAttempts int
+}
+
+// newWebhook returns a new properly initialized *webhook or nil, if conf
+// doesn't have the URL.  conf must be valid.
+func newWebhook(conf *webhookConfig, client *http.Client) (h *webhook) {
+	if conf == nil || conf.URL == "" {
+		return nil
+	}
+
+	h = &webhook{
+		client:      client,
+		mu:          &sync.Mutex{},
+		changes:     map[webhookChange]struct{}{},
+		url:         conf.URL,
+		delay:       conf.Delay.Duration,
+		retryDelay:  defaultWebhookRetryDelay,
+		maxAttempts: conf.MaxAttempts,
+	}
+
+	if conf.Secret != "" {
+		h.secret = []byte(conf.Secret)
+	}
+
+	return h
+}
+
+// notify schedules the notification about change, unless there is a pending
+// one already, which then also reports change.  It never blocks.
+func (h *webhook) notify(change webhookChange) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.addChange(change)
+}
+
+// addChange records change and schedules the notification, unless there is a
+// pending one already.  h.mu is expected to be locked.
+func (h *webhook) addChange(change webhookChange) {
+	h.changes[change] = struct{}{}
+	if h.timer == nil {
+		h.timer = time.AfterFunc(h.delay, h.onTimer)
+	}
+}
+
+// onTimer is called when the pending notification is due.
+func (h *webhook) onTimer() {
+	defer log.OnPanic("webhook")
+
+	h.mu.Lock()
+	p := h.takePayload()
+	h.timer = nil
+	h.mu.Unlock()
+
+	h.send(p, h.maxAttempts)
+}
+
+// takePayload returns the payload of the notification about the changes made
+// since the previous one and resets them.  h.mu is expected to be locked.
+func (h *webhook) takePayload() (p *webhookPayload) {
+	changes := maps.Keys(h.changes)
+	slices.Sort(changes)
+
+	p = &webhookPayload{
+		Time:    time.Now().UTC(),
+		Changes: changes,
+		Clients: h.clients,
+	}
+
+	h.changes = map[webhookChange]struct{}{}
+	h.clients = nil
+
+	return p
+}
+
+// flush stops the pending notification, if any, and sends it right away making
+// a single attempt, so that the shutdown isn't delayed by the retries.  It's
+// intended to be called on shutdown.
+func (h *webhook) flush() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	if h.timer == nil || !h.timer.Stop() {
+		// There is no pending notification or it's being sent already.
+		h.mu.Unlock()
+
+		return
+	}
+
+	p := h.takePayload()
+	h.timer = nil
+	h.mu.Unlock()
+
+	h.send(p, 1)
+}
+
+// send sends the notification p making up to maxAttempts attempts with an
+// exponential backoff and logs the error, if all the attempts have failed.
+func (h *webhook) send(p *webhookPayload, maxAttempts int) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Error("webhook: encoding notification: %s", err)
+
+		return
+	}
+
+	delay := h.retryDelay
+	for attempt := 1; ; attempt++ {
+		err = h.post(body)
+		if err == nil {
+			log.Debug("webhook: sent notification about %q", p.Changes)
+
+			return
+		} else if attempt >= maxAttempts {
+			log.Error("webhook: sending notification: attempt %d: %s; giving up", attempt, err)
+
+			return
+		}
+
+		log.Debug("webhook: sending notification: attempt %d: %s; retrying in %s", attempt, err, delay)
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxWebhookRetryDelay {
+			delay = maxWebhookRetryDelay
+		}
+	}
+}
+
+// setWebhookSignature sets the HMAC-SHA256 signature of body made with secret
+// into the header of req.  It does nothing if secret is nil.
+func setWebhookSignature(req *http.Request, secret, body []byte) {
+	if secret == nil {
+		return
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// post sends body to the URL of the webhook.
+func (h *webhook) post(body []byte) (err error) {
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setWebhookSignature(req, h.secret, body)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+	defer func() { err = errors.WithDeferred(err, resp.Body.Close()) }()
+
+	// Drain the body so that the connection could be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
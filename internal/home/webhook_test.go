@@ -0,0 +1,211 @@
+package home
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReq is a request received by the test webhook server.
+type webhookReq struct {
+	payload   *webhookPayload
+	signature string
+	body      []byte
+}
+
+// newTestWebhookServer returns the URL of a new test webhook server, which
+// responds with the statuses from statuses in order and then with 200 OK, and
+// sends the received requests to the returned channel.
+func newTestWebhookServer(t *testing.T, statuses ...int) (u string, reqCh chan *webhookReq) {
+	t.Helper()
+
+	reqCh = make(chan *webhookReq, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		p := &webhookPayload{}
+		err = json.Unmarshal(body, p)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		reqCh <- &webhookReq{
+			payload:   p,
+			signature: r.Header.Get(webhookSignatureHeader),
+			body:      body,
+		}
+
+		if len(statuses) > 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL, reqCh
+}
+
+func TestWebhook_notify(t *testing.T) {
+	const (
+		testTimeout = time.Second
+		secret      = "secret"
+	)
+
+	u, reqCh := newTestWebhookServer(t)
+
+	h := newWebhook(&webhookConfig{
+		URL:         u,
+		Secret:      secret,
+		Delay:       timeutil.Duration{Duration: 10 * time.Millisecond},
+		MaxAttempts: 1,
+	}, http.DefaultClient)
+	require.NotNil(t, h)
+
+	h.notify(webhookChangeClients)
+	h.notify(webhookChangeBlockedServices)
+	h.notify(webhookChangeClients)
+
+	req, _ := testutil.RequireReceive(t, reqCh, testTimeout)
+	require.NotNil(t, req)
+
+	wantChanges := []webhookChange{webhookChangeBlockedServices, webhookChangeClients}
+	assert.Equal(t, wantChanges, req.payload.Changes)
+	assert.WithinDuration(t, time.Now(), req.payload.Time, time.Minute)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(req.body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), req.signature)
+
+	// The next change must be reported separately.
+	h.notify(webhookChangeFiltering)
+
+	req, _ = testutil.RequireReceive(t, reqCh, testTimeout)
+	require.NotNil(t, req)
+
+	assert.Equal(t, []webhookChange{webhookChangeFiltering}, req.payload.Changes)
+}
+
+func TestWebhook_send_retry(t *testing.T) {
+	const testTimeout = time.Second
+
+	u, reqCh := newTestWebhookServer(
+		t,
+		http.StatusInternalServerError,
+		http.StatusServiceUnavailable,
+	)
+
+	h := newWebhook(&webhookConfig{
+		URL:         u,
+		MaxAttempts: 3,
+	}, http.DefaultClient)
+	require.NotNil(t, h)
+
+	h.retryDelay = time.Millisecond
+
+	h.send(&webhookPayload{
+		Time:    time.Now(),
+		Changes: []webhookChange{webhookChangeFiltering},
+	}, h.maxAttempts)
+
+	for i := 0; i < 3; i++ {
+		req, _ := testutil.RequireReceive(t, reqCh, testTimeout)
+		require.NotNil(t, req)
+
+		assert.Empty(t, req.signature)
+	}
+
+	assert.Empty(t, reqCh)
+}
+
+func TestWebhook_flush(t *testing.T) {
+	const testTimeout = time.Second
+
+	u, reqCh := newTestWebhookServer(t, http.StatusInternalServerError)
+
+	h := newWebhook(&webhookConfig{
+		URL:         u,
+		Delay:       timeutil.Duration{Duration: time.Hour},
+		MaxAttempts: 3,
+	}, http.DefaultClient)
+	require.NotNil(t, h)
+
+	h.retryDelay = time.Millisecond
+
+	h.notify(webhookChangeFiltering)
+	h.flush()
+
+	req, _ := testutil.RequireReceive(t, reqCh, testTimeout)
+	require.NotNil(t, req)
+
+	assert.Equal(t, []webhookChange{webhookChangeFiltering}, req.payload.Changes)
+
+	// The pending notification must be sent only once and without retries.
+	h.flush()
+	assert.Empty(t, reqCh)
+}
+
+func TestWebhook_nil(t *testing.T) {
+	h := newWebhook(&webhookConfig{}, http.DefaultClient)
+	require.Nil(t, h)
+
+	assert.NotPanics(t, func() { h.notify(webhookChangeClients) })
+	assert.NotPanics(t, h.flush)
+}
+
+func TestWebhookConfig_validate(t *testing.T) {
+	testCases := []struct {
+		conf       *webhookConfig
+		name       string
+		wantErrMsg string
+	}{{
+		conf:       nil,
+		name:       "nil",
+		wantErrMsg: "",
+	}, {
+		conf:       &webhookConfig{},
+		name:       "disabled",
+		wantErrMsg: "",
+	}, {
+		conf: &webhookConfig{
+			URL:         "https://siem.example/hook",
+			MaxAttempts: 5,
+		},
+		name:       "valid",
+		wantErrMsg: "",
+	}, {
+		conf: &webhookConfig{
+			URL:         "ftp://siem.example/hook",
+			MaxAttempts: 5,
+		},
+		name:       "bad_scheme",
+		wantErrMsg: `webhook: url: bad scheme "ftp"`,
+	}, {
+		conf: &webhookConfig{
+			URL:         "https://siem.example/hook",
+			MaxAttempts: 0,
+		},
+		name:       "bad_attempts",
+		wantErrMsg: "webhook: max_attempts: must be positive, got 0",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.conf.validate()
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package home
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// whoisDiagnosticsResp is the response to the GET /control/whois HTTP API with
+// the diagnostics query parameter set to true.
+type whoisDiagnosticsResp struct {
+	*whois.Info
+
+	// Diagnostics are the details of the WHOIS query.
+	Diagnostics *whois.Diagnostics `json:"diagnostics"`
+}
+
+// handleWHOIS is the handler for the GET /control/whois HTTP API.  If the
+// server query parameter is set, the WHOIS request is sent to it bypassing the
+// automatic selection of the server and the cache.  If the diagnostics query
+// parameter is true, the cache is bypassed, and the response also contains the
+// details of the query.
+func handleWHOIS(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	ip, err := netip.ParseAddr(q.Get("ip"))
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "parsing ip: %s", err)
+
+		return
+	}
+
+	diagnostics := false
+	if diagStr := q.Get("diagnostics"); diagStr != "" {
+		diagnostics, err = strconv.ParseBool(diagStr)
+		if err != nil {
+			aghhttp.Error(r, w, http.StatusBadRequest, "diagnostics: %s", err)
+
+			return
+		}
+	}
+
+	server := q.Get("server")
+	if server != "" {
+		err = whois.ValidateServer(server)
+		if err != nil {
+			aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+			return
+		}
+	}
+
+	if diagnostics {
+		handleWHOISDiagnostics(w, r, ip, server)
+
+		return
+	}
+
+	var info *whois.Info
+	if server == "" {
+		info, _ = Context.whois.Process(r.Context(), ip)
+	} else {
+		info, err = Context.whois.ProcessForced(r.Context(), ip, server)
+		if err != nil {
+			aghhttp.Error(r, w, whoisErrorCode(err), "querying %q: %s", server, err)
+
+			return
+		}
+	}
+
+	if info == nil {
+		info = &whois.Info{}
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, info)
+}
+
+// handleWHOISDiagnostics handles the GET /control/whois HTTP API requests with
+// the diagnostics query parameter set to true.  server may be empty.
+func handleWHOISDiagnostics(w http.ResponseWriter, r *http.Request, ip netip.Addr, server string) {
+	d, ok := Context.whois.(whois.Diagnoser)
+	if !ok {
+		aghhttp.Error(r, w, http.StatusBadRequest, "diagnostics: not supported by whois backend")
+
+		return
+	}
+
+	info, diag, err := d.ProcessDiagnostic(r.Context(), ip, server)
+	if err != nil {
+		aghhttp.Error(r, w, whoisErrorCode(err), "querying about %s: %s", ip, err)
+
+		return
+	}
+
+	if info == nil {
+		info = &whois.Info{}
+	}
+
+	if diag == nil {
+		diag = &whois.Diagnostics{}
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, &whoisDiagnosticsResp{
+		Info:        info,
+		Diagnostics: diag,
+	})
+}
+
+// whoisErrorCode returns the HTTP status code for the error of a WHOIS query.
+func whoisErrorCode(err error) (code int) {
+	if errors.Is(err, whois.ErrTimeout) {
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusBadGateway
+}
+
+// whoisFlushReq is the request to the POST /control/whois/flush HTTP API.
+type whoisFlushReq struct {
+	// IP is the address, the cached WHOIS information about which is removed.
+	// If it's not set, all the cached WHOIS information is removed.
+	IP netip.Addr `json:"ip"`
+}
+
+// handleWHOISFlush is the handler for the POST /control/whois/flush HTTP API.
+// The information about the runtime clients is updated with the next WHOIS
+// request about their addresses.
+func handleWHOISFlush(w http.ResponseWriter, r *http.Request) {
+	req := &whoisFlushReq{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "decoding request: %s", err)
+
+		return
+	}
+
+	Context.whois.Flush(req.IP)
+
+	if req.IP == (netip.Addr{}) {
+		log.Info("whois: flushed cache")
+	} else {
+		log.Info("whois: flushed cache for %s", req.IP)
+	}
+
+	aghhttp.OK(w)
+}
+
+// clientsWHOISResp is the response to the GET /control/clients/whois HTTP
+// API.  It maps the names of the persistent clients to the WHOIS information
+// about each of their IP address identifiers.
+type clientsWHOISResp map[string]map[netip.Addr]*whois.Info
+
+// handleClientsWHOIS is the handler for the GET /control/clients/whois HTTP
+// API.  It returns the WHOIS information about the IP addresses used as the
+// identifiers of the persistent clients.  The identifiers of other types are
+// skipped.
+func (clients *clientsContainer) handleClientsWHOIS(w http.ResponseWriter, r *http.Request) {
+	if !clients.checkFindRatelimit(w, r) {
+		return
+	}
+
+	ipsByName := clients.persistentIPs()
+
+	var ips []netip.Addr
+	for _, clientIPs := range ipsByName {
+		ips = append(ips, clientIPs...)
+	}
+
+	infos := processWHOIS(r.Context(), Context.whois, ips, config.Clients.WHOIS.Concurrency)
+
+	resp := make(clientsWHOISResp, len(ipsByName))
+	for name, clientIPs := range ipsByName {
+		clientInfos := make(map[netip.Addr]*whois.Info, len(clientIPs))
+		for _, ip := range clientIPs {
+			clientInfos[ip] = infos[ip]
+		}
+
+		resp[name] = clientInfos
+	}
+
+	_ = aghhttp.WriteJSONResponse(w, r, resp)
+}
+
+// persistentIPs returns the IP address identifiers of the persistent clients
+// mapped to their names.  The clients without such identifiers are omitted.
+func (clients *clientsContainer) persistentIPs() (ipsByName map[string][]netip.Addr) {
+	clients.lock.RLock()
+	defer clients.lock.RUnlock()
+
+	ipsByName = map[string][]netip.Addr{}
+	for name, c := range clients.list {
+		for _, id := range c.IDs {
+			ip, err := netip.ParseAddr(id)
+			if err == nil {
+				ipsByName[name] = append(ipsByName[name], ip)
+			}
+		}
+	}
+
+	return ipsByName
+}
+
+// processWHOIS requests the WHOIS information about each of ips from w using
+// at most concurrency goroutines.  The addresses, about which there is no
+// information, are mapped to empty information.
+func processWHOIS(
+	ctx context.Context,
+	w whois.Interface,
+	ips []netip.Addr,
+	concurrency int,
+) (infos map[netip.Addr]*whois.Info) {
+	infos = make(map[netip.Addr]*whois.Info, len(ips))
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	ipCh := make(chan netip.Addr)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer log.OnPanic("clients whois")
+
+			for ip := range ipCh {
+				info, _ := w.Process(ctx, ip)
+				if info == nil {
+					info = &whois.Info{}
+				}
+
+				mu.Lock()
+				infos[ip] = info
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := make(map[netip.Addr]struct{}, len(ips))
+	for _, ip := range ips {
+		if _, ok := seen[ip]; !ok {
+			seen[ip] = struct{}{}
+			ipCh <- ip
+		}
+	}
+
+	close(ipCh)
+	wg.Wait()
+
+	return infos
+}
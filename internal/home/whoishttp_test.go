@@ -0,0 +1,324 @@
+package home
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/testutil/fakenet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleWHOIS(t *testing.T) {
+	const city = "Nonreal"
+
+	var dialed []string
+	w, err := whois.New(&whois.Config{
+		DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+			dialed = append(dialed, addr)
+
+			return &fakenet.Conn{
+				OnRead: func(b []byte) (n int, err error) {
+					return copy(b, "city: "+city), io.EOF
+				},
+				OnWrite: func(b []byte) (n int, err error) {
+					return len(b), nil
+				},
+				OnClose: func() (err error) {
+					return nil
+				},
+				OnSetReadDeadline: func(t time.Time) (err error) {
+					return nil
+				},
+			}, nil
+		},
+		ServerAddr:      whois.DefaultServer,
+		Port:            whois.DefaultPort,
+		MaxConnReadSize: 1024,
+		MaxRedirects:    3,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+	})
+	require.NoError(t, err)
+
+	prev := Context.whois
+	t.Cleanup(func() { Context.whois = prev })
+	Context.whois = w
+
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	testCases := []struct {
+		name       string
+		target     string
+		wantDialed string
+		wantCode   int
+	}{{
+		name:       "forced",
+		target:     "/control/whois?ip=1.2.3.4&server=whois.ripe.net",
+		wantDialed: "whois.ripe.net:43",
+		wantCode:   http.StatusOK,
+	}, {
+		name:       "forced_port",
+		target:     "/control/whois?ip=1.2.3.4&server=whois.example.net:4343",
+		wantDialed: "whois.example.net:4343",
+		wantCode:   http.StatusOK,
+	}, {
+		name:       "bad_server",
+		target:     "/control/whois?ip=1.2.3.4&server=bad%20server",
+		wantDialed: "",
+		wantCode:   http.StatusBadRequest,
+	}, {
+		name:       "bad_ip",
+		target:     "/control/whois?ip=bad&server=whois.ripe.net",
+		wantDialed: "",
+		wantCode:   http.StatusBadRequest,
+	}, {
+		name:       "diagnostics",
+		target:     "/control/whois?ip=1.2.3.4&diagnostics=true",
+		wantDialed: "whois.arin.net:43",
+		wantCode:   http.StatusOK,
+	}, {
+		name:       "bad_diagnostics",
+		target:     "/control/whois?ip=1.2.3.4&diagnostics=maybe",
+		wantDialed: "",
+		wantCode:   http.StatusBadRequest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dialed = nil
+
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			rw := httptest.NewRecorder()
+			handleWHOIS(rw, r)
+
+			require.Equal(t, tc.wantCode, rw.Code)
+
+			if tc.wantDialed == "" {
+				assert.Empty(t, dialed)
+
+				return
+			}
+
+			assert.Equal(t, []string{tc.wantDialed}, dialed)
+
+			info := &whois.Info{}
+			err = json.NewDecoder(rw.Body).Decode(info)
+			require.NoError(t, err)
+
+			assert.Equal(t, city, info.City)
+		})
+	}
+
+	t.Run("diagnostics_response", func(t *testing.T) {
+		r := httptest.NewRequest(
+			http.MethodGet,
+			"/control/whois?ip=1.2.3.4&server=whois.ripe.net&diagnostics=1",
+			nil,
+		)
+		rw := httptest.NewRecorder()
+		handleWHOIS(rw, r)
+
+		require.Equal(t, http.StatusOK, rw.Code)
+
+		resp := &struct {
+			Diagnostics *whois.Diagnostics `json:"diagnostics"`
+			City        string             `json:"city"`
+		}{}
+		err = json.NewDecoder(rw.Body).Decode(resp)
+		require.NoError(t, err)
+
+		assert.Equal(t, city, resp.City)
+		assert.Equal(t, &whois.Diagnostics{
+			Server:    "whois.ripe.net:43",
+			Redirects: 0,
+		}, resp.Diagnostics)
+	})
+
+	t.Run("not_cached", func(t *testing.T) {
+		dialed = nil
+
+		info, changed := w.Process(context.Background(), ip)
+		require.NotNil(t, info)
+
+		assert.True(t, changed)
+		assert.Equal(t, []string{"whois.arin.net:43"}, dialed)
+	})
+}
+
+// fakeWHOIS is a fake [whois.Interface] implementation for tests.
+type fakeWHOIS struct {
+	onProcess func(ctx context.Context, ip netip.Addr) (info *whois.Info, changed bool)
+	onFlush   func(ip netip.Addr)
+	onStats   func() (s *whois.CacheStats)
+}
+
+// type check
+var _ whois.Interface = (*fakeWHOIS)(nil)
+
+// Process implements the [whois.Interface] interface for *fakeWHOIS.
+func (w *fakeWHOIS) Process(ctx context.Context, ip netip.Addr) (info *whois.Info, changed bool) {
+	return w.onProcess(ctx, ip)
+}
+
+// ProcessForced implements the [whois.Interface] interface for *fakeWHOIS.
+func (w *fakeWHOIS) ProcessForced(
+	_ context.Context,
+	_ netip.Addr,
+	_ string,
+) (info *whois.Info, err error) {
+	panic("not implemented")
+}
+
+// Flush implements the [whois.Interface] interface for *fakeWHOIS.
+func (w *fakeWHOIS) Flush(ip netip.Addr) {
+	w.onFlush(ip)
+}
+
+// Stats implements the [whois.Interface] interface for *fakeWHOIS.
+func (w *fakeWHOIS) Stats() (s *whois.CacheStats) {
+	return w.onStats()
+}
+
+func TestClientsContainer_handleClientsWHOIS(t *testing.T) {
+	var (
+		ip1 = netip.MustParseAddr("1.2.3.4")
+		ip2 = netip.MustParseAddr("5.6.7.8")
+		ip3 = netip.MustParseAddr("2001:db8::1")
+	)
+
+	var (
+		mu        sync.Mutex
+		processed []netip.Addr
+	)
+
+	prev := Context.whois
+	t.Cleanup(func() { Context.whois = prev })
+	Context.whois = &fakeWHOIS{
+		onProcess: func(_ context.Context, ip netip.Addr) (info *whois.Info, changed bool) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			processed = append(processed, ip)
+			if ip == ip2 {
+				return nil, false
+			}
+
+			return &whois.Info{Orgname: "org-" + ip.String()}, true
+		},
+	}
+
+	clients := newClientsContainer(t)
+	for _, c := range []*Client{{
+		Name: "laptop",
+		IDs:  []string{ip1.String(), "aa:aa:aa:aa:aa:aa", ip3.String()},
+	}, {
+		Name: "phone",
+		IDs:  []string{ip2.String(), "phone"},
+	}, {
+		Name: "tablet",
+		IDs:  []string{"1.2.3.0/24"},
+	}} {
+		ok, err := clients.Add(c)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/control/clients/whois", nil)
+	rw := httptest.NewRecorder()
+	clients.handleClientsWHOIS(rw, r)
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	resp := clientsWHOISResp{}
+	err := json.NewDecoder(rw.Body).Decode(&resp)
+	require.NoError(t, err)
+
+	assert.Equal(t, clientsWHOISResp{
+		"laptop": {
+			ip1: {Orgname: "org-" + ip1.String()},
+			ip3: {Orgname: "org-" + ip3.String()},
+		},
+		"phone": {
+			ip2: {},
+		},
+	}, resp)
+	assert.ElementsMatch(t, []netip.Addr{ip1, ip2, ip3}, processed)
+
+	t.Run("ratelimit", func(t *testing.T) {
+		clients.findRatelimit = 1
+		t.Cleanup(func() { clients.findRatelimit = 0 })
+
+		codes := make([]int, 0, 2)
+		for i := 0; i < 2; i++ {
+			r = httptest.NewRequest(http.MethodGet, "/control/clients/whois", nil)
+			rw = httptest.NewRecorder()
+			clients.handleClientsWHOIS(rw, r)
+
+			codes = append(codes, rw.Code)
+		}
+
+		assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+	})
+}
+
+func TestHandleWHOISFlush(t *testing.T) {
+	var flushed []netip.Addr
+
+	prev := Context.whois
+	t.Cleanup(func() { Context.whois = prev })
+	Context.whois = &fakeWHOIS{
+		onFlush: func(ip netip.Addr) {
+			flushed = append(flushed, ip)
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		body     string
+		wantCode int
+		want     []netip.Addr
+	}{{
+		name:     "single",
+		body:     `{"ip":"1.2.3.4"}`,
+		wantCode: http.StatusOK,
+		want:     []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+	}, {
+		name:     "all",
+		body:     `{}`,
+		wantCode: http.StatusOK,
+		want:     []netip.Addr{{}},
+	}, {
+		name:     "bad_ip",
+		body:     `{"ip":"1.2.3"}`,
+		wantCode: http.StatusBadRequest,
+		want:     nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flushed = nil
+
+			r := httptest.NewRequest(
+				http.MethodPost,
+				"/control/whois/flush",
+				strings.NewReader(tc.body),
+			)
+			rw := httptest.NewRecorder()
+			handleWHOISFlush(rw, r)
+
+			assert.Equal(t, tc.wantCode, rw.Code)
+			assert.Equal(t, tc.want, flushed)
+		})
+	}
+}
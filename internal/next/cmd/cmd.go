@@ -26,7 +26,7 @@ func Main(embeddedFrontend fs.FS) {
 		os.Exit(exitCode)
 	}
 
-	err = setLog(opts)
+	logFile, err := setLog(opts)
 	check(err)
 
 	log.Info("starting adguard home, version %s, pid %d", version.Version(), os.Getpid())
@@ -61,6 +61,7 @@ func Main(embeddedFrontend fs.FS) {
 	sigHdlr := newSignalHandler(
 		confMgrConf,
 		opts.pidFile,
+		logFile,
 		web,
 		dns,
 	)
@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sync"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghos"
+	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 )
 
@@ -12,22 +15,29 @@ import (
 // logs to the system log.
 const syslogServiceName = "AdGuardHome"
 
-// setLog sets up the text logging.
+// setLog sets up the text logging.  lf is the log file, if the logs are written
+// into one, or nil otherwise.
 //
 // TODO(a.garipov): Add parameters from configuration file.
-func setLog(opts *options) (err error) {
-	switch opts.confFile {
-	case "stdout":
+func setLog(opts *options) (lf *logFile, err error) {
+	switch opts.logFile {
+	case "", "stdout":
 		log.SetOutput(os.Stdout)
 	case "stderr":
 		log.SetOutput(os.Stderr)
 	case "syslog":
 		err = aghos.ConfigureSyslog(syslogServiceName)
 		if err != nil {
-			return fmt.Errorf("initializing syslog: %w", err)
+			return nil, fmt.Errorf("initializing syslog: %w", err)
 		}
 	default:
-		// TODO(a.garipov): Use the path.
+		lf, err = openLogFile(opts.logFile)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return nil, err
+		}
+
+		log.SetOutput(lf)
 	}
 
 	if opts.verbose {
@@ -35,5 +45,110 @@ func setLog(opts *options) (err error) {
 		log.Debug("verbose logging enabled")
 	}
 
+	return lf, nil
+}
+
+// logFile is a log file, which can be reopened, e.g. after it has been rotated
+// by an external tool like logrotate.  A nil *logFile is a valid log file,
+// which is never reopened.
+type logFile struct {
+	// mu protects file.
+	mu *sync.Mutex
+
+	// file is the currently opened file.  It is nil after the log file has
+	// been closed.
+	file *os.File
+
+	// path is the path to the file.
+	path string
+}
+
+// type check
+var _ io.Writer = (*logFile)(nil)
+
+// openLogFile opens the log file at path for appending, creating it if
+// necessary.
+func openLogFile(path string) (lf *logFile, err error) {
+	lf = &logFile{
+		mu:   &sync.Mutex{},
+		path: path,
+	}
+
+	lf.file, err = lf.open()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	return lf, nil
+}
+
+// open opens the file at lf.path for appending.
+func (lf *logFile) open() (f *os.File, err error) {
+	f, err = os.OpenFile(lf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening log file: %w", err)
+	}
+
+	return f, nil
+}
+
+// Write implements the [io.Writer] interface for *logFile.
+func (lf *logFile) Write(b []byte) (n int, err error) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	return lf.file.Write(b)
+}
+
+// reopen closes the current file and opens the file at the same path, so that
+// the new logs are written into the new file after a rotation.  If the file
+// can't be opened, the current one is kept.
+func (lf *logFile) reopen() (err error) {
+	if lf == nil {
+		return nil
+	}
+
+	f, err := lf.open()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	prev := lf.file
+	lf.file = f
+
+	if prev == nil {
+		// The file has been closed already.
+		return nil
+	}
+
+	err = prev.Close()
+	if err != nil {
+		return fmt.Errorf("closing previous log file: %w", err)
+	}
+
 	return nil
 }
+
+// close closes the log file.  It is safe to call it several times.
+func (lf *logFile) close() (err error) {
+	if lf == nil {
+		return nil
+	}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	if lf.file == nil {
+		return nil
+	}
+
+	err = lf.file.Close()
+	lf.file = nil
+
+	return errors.Annotate(err, "closing log file: %w")
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogFile_reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "AdGuardHome.log")
+	rotated := path + ".1"
+
+	lf, err := openLogFile(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, lf.close()) })
+
+	_, err = lf.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	err = os.Rename(path, rotated)
+	require.NoError(t, err)
+
+	err = lf.reopen()
+	require.NoError(t, err)
+
+	_, err = lf.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(rotated)
+	require.NoError(t, err)
+
+	assert.Equal(t, "before\n", string(data))
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "after\n", string(data))
+}
+
+func TestLogFile_close(t *testing.T) {
+	t.Run("twice", func(t *testing.T) {
+		lf, err := openLogFile(filepath.Join(t.TempDir(), "AdGuardHome.log"))
+		require.NoError(t, err)
+
+		assert.NoError(t, lf.close())
+		assert.NoError(t, lf.close())
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var lf *logFile
+
+		assert.NoError(t, lf.close())
+		assert.NoError(t, lf.reopen())
+	})
+}
+
+func TestSignalHandler_reopenLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "AdGuardHome.log")
+
+	lf, err := openLogFile(path)
+	require.NoError(t, err)
+
+	h := &signalHandler{
+		logFile: lf,
+	}
+
+	err = os.Remove(path)
+	require.NoError(t, err)
+
+	h.reopenLog()
+
+	_, err = lf.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	h.closeLog()
+	h.closeLog()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "after\n", string(data))
+}
@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"strconv"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghos"
 	"github.com/AdguardTeam/AdGuardHome/internal/next/agh"
 	"github.com/AdguardTeam/AdGuardHome/internal/next/configmgr"
+	"github.com/AdguardTeam/AdGuardHome/internal/next/dnssvc"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/google/renameio/maybe"
 )
@@ -20,6 +22,9 @@ type signalHandler struct {
 	// signal is the channel to which OS signals are sent.
 	signal chan os.Signal
 
+	// logFile is the log file reopened on reconfiguration, if any.
+	logFile *logFile
+
 	// pidFile is the path to the file where to store the PID, if any.
 	pidFile string
 
@@ -37,6 +42,7 @@ func (h *signalHandler) handle() {
 		log.Info("sighdlr: received signal %q", sig)
 
 		if aghos.IsReconfigureSignal(sig) {
+			h.reopenLog()
 			h.reconfigure()
 		} else if aghos.IsShutdownSignal(sig) {
 			status := h.shutdown()
@@ -44,11 +50,37 @@ func (h *signalHandler) handle() {
 
 			log.Info("sighdlr: exiting with status %d", status)
 
+			h.closeLog()
 			os.Exit(status)
 		}
 	}
 }
 
+// reopenLog reopens the log file, if any, so that the logs aren't written into
+// the rotated one.  Any errors are reported to log.
+func (h *signalHandler) reopenLog() {
+	if h.logFile == nil {
+		return
+	}
+
+	err := h.logFile.reopen()
+	if err != nil {
+		log.Error("sighdlr: reopening log file: %s", err)
+
+		return
+	}
+
+	log.Info("sighdlr: reopened log file %q", h.logFile.path)
+}
+
+// closeLog closes the log file, if any.  Any errors are reported to log.
+func (h *signalHandler) closeLog() {
+	err := h.logFile.close()
+	if err != nil {
+		log.Error("sighdlr: %s", err)
+	}
+}
+
 // reconfigure rereads the configuration file and updates and restarts services.
 func (h *signalHandler) reconfigure() {
 	log.Info("sighdlr: reconfiguring adguard home")
@@ -92,14 +124,11 @@ const (
 
 // shutdown gracefully shuts down all services.
 func (h *signalHandler) shutdown() (status int) {
-	ctx, cancel := ctxWithDefaultTimeout()
-	defer cancel()
-
 	status = statusSuccess
 
 	log.Info("sighdlr: shutting down services")
 	for i, service := range h.services {
-		err := service.Shutdown(ctx)
+		err := shutdownService(service)
 		if err != nil {
 			log.Error("sighdlr: shutting down service at index %d: %s", i, err)
 			status = statusError
@@ -109,15 +138,33 @@ func (h *signalHandler) shutdown() (status int) {
 	return status
 }
 
-// newSignalHandler returns a new signalHandler that shuts down svcs.
+// shutdownService gracefully shuts down service.  Each service has its own
+// timeout so that the DNS service draining its requests in flight doesn't
+// shorten the time the other services have.
+func shutdownService(service agh.Service) (err error) {
+	timeout := defaultTimeout
+	if dns, ok := service.(agh.ServiceWithConfig[*dnssvc.Config]); ok {
+		timeout += dns.Config().ShutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return service.Shutdown(ctx)
+}
+
+// newSignalHandler returns a new signalHandler that shuts down svcs.  lf is
+// the log file reopened on reconfiguration, it may be nil.
 func newSignalHandler(
 	confMgrConf *configmgr.Config,
 	pidFile string,
+	lf *logFile,
 	svcs ...agh.Service,
 ) (h *signalHandler) {
 	h = &signalHandler{
 		confMgrConf: confMgrConf,
 		signal:      make(chan os.Signal, 1),
+		logFile:     lf,
 		pidFile:     pidFile,
 		services:    svcs,
 	}
@@ -63,6 +63,7 @@ type dnsConfig struct {
 	UpstreamDNS         []string          `yaml:"upstream_dns"`
 	DNS64Prefixes       []netip.Prefix    `yaml:"dns64_prefixes"`
 	UpstreamTimeout     timeutil.Duration `yaml:"upstream_timeout"`
+	ShutdownTimeout     timeutil.Duration `yaml:"shutdown_timeout"`
 	BootstrapPreferIPv6 bool              `yaml:"bootstrap_prefer_ipv6"`
 	UseDNS64            bool              `yaml:"use_dns64"`
 }
@@ -77,6 +78,8 @@ func (c *dnsConfig) validate() (err error) {
 		return errNoConf
 	case c.UpstreamTimeout.Duration <= 0:
 		return newMustBePositiveError("upstream_timeout", c.UpstreamTimeout)
+	case c.ShutdownTimeout.Duration < 0:
+		return newMustBeNonNegativeError("shutdown_timeout", c.ShutdownTimeout)
 	default:
 		return nil
 	}
@@ -25,3 +25,14 @@ func newMustBePositiveError[T numberOrDuration](prop string, v T) (err error) {
 
 	return fmt.Errorf("%s must be positive, got %d", prop, v)
 }
+
+// newMustBeNonNegativeError returns an error about the value that must be
+// non-negative but isn't.  prop is the name of the property to mention in the
+// error message.
+func newMustBeNonNegativeError[T numberOrDuration](prop string, v T) (err error) {
+	if s, ok := any(v).(fmt.Stringer); ok {
+		return fmt.Errorf("%s must be non-negative, got %s", prop, s)
+	}
+
+	return fmt.Errorf("%s must be non-negative, got %d", prop, v)
+}
@@ -26,6 +26,11 @@ type Config struct {
 	// UpstreamTimeout is the timeout for upstream requests.
 	UpstreamTimeout time.Duration
 
+	// ShutdownTimeout is the maximum duration to wait for the requests in
+	// flight to finish on shutdown.  If it's zero, the service waits for them
+	// until the context passed to [Service.Shutdown] is done.
+	ShutdownTimeout time.Duration
+
 	// BootstrapPreferIPv6, if true, instructs the bootstrapper to prefer IPv6
 	// addresses to IPv4 ones when bootstrapping.
 	BootstrapPreferIPv6 bool
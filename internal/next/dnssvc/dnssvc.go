@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -17,6 +18,8 @@ import (
 	// and replacement of module dnsproxy.
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
 )
 
 // Service is the AdGuard Home DNS service.  A nil *Service is a valid
@@ -26,10 +29,12 @@ import (
 // fields that are only used in [New] and [Service.Config].
 type Service struct {
 	proxy               *proxy.Proxy
+	requests            *inFlight
 	bootstraps          []string
 	upstreams           []string
 	dns64Prefixes       []netip.Prefix
 	upsTimeout          time.Duration
+	shutdownTimeout     time.Duration
 	running             atomic.Bool
 	bootstrapPreferIPv6 bool
 	useDNS64            bool
@@ -44,10 +49,12 @@ func New(c *Config) (svc *Service, err error) {
 	}
 
 	svc = &Service{
+		requests:            newInFlight(),
 		bootstraps:          c.BootstrapServers,
 		upstreams:           c.UpstreamServers,
 		dns64Prefixes:       c.DNS64Prefixes,
 		upsTimeout:          c.UpstreamTimeout,
+		shutdownTimeout:     c.ShutdownTimeout,
 		bootstrapPreferIPv6: c.BootstrapPreferIPv6,
 		useDNS64:            c.UseDNS64,
 	}
@@ -69,8 +76,9 @@ func New(c *Config) (svc *Service, err error) {
 			UpstreamConfig: &proxy.UpstreamConfig{
 				Upstreams: upstreams,
 			},
-			UseDNS64:   c.UseDNS64,
-			DNS64Prefs: c.DNS64Prefixes,
+			UseDNS64:       c.UseDNS64,
+			DNS64Prefs:     c.DNS64Prefixes,
+			RequestHandler: svc.handleRequest,
 		},
 	}
 
@@ -155,16 +163,116 @@ func (svc *Service) Start() (err error) {
 	return svc.proxy.Start()
 }
 
+// handleRequest is the [proxy.RequestHandler] of svc.  It refuses the requests
+// received after the shutdown of svc has begun.
+func (svc *Service) handleRequest(p *proxy.Proxy, dctx *proxy.DNSContext) (err error) {
+	if !svc.requests.start() {
+		// Refuse the request so that the client retries it using another
+		// server without waiting for the timeout.
+		dctx.Res = (&dns.Msg{}).SetRcode(dctx.Req, dns.RcodeRefused)
+
+		return nil
+	}
+	defer svc.requests.finish()
+
+	return p.Resolve(dctx)
+}
+
 // Shutdown implements the [agh.Service] interface for *Service.  svc may be
-// nil.
+// nil.  It stops accepting new requests and waits for the requests in flight to
+// finish for up to the shutdown timeout or until ctx is done, whichever comes
+// first, before closing the servers.
 func (svc *Service) Shutdown(ctx context.Context) (err error) {
 	if svc == nil {
 		return nil
 	}
 
+	if svc.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, svc.shutdownTimeout)
+		defer cancel()
+	}
+
+	drainErr := svc.requests.drain(ctx)
+	if drainErr != nil {
+		// Close the servers anyway, since the requests in flight would only
+		// keep them open indefinitely.
+		log.Info("dnssvc: draining requests: %s", drainErr)
+	}
+
 	return svc.proxy.Stop()
 }
 
+// inFlight tracks the DNS requests being processed.
+type inFlight struct {
+	// mu protects num, draining, and the closing of done.
+	mu *sync.Mutex
+
+	// done is closed once the requests are being drained and there are no
+	// more requests being processed.
+	done chan struct{}
+
+	// num is the number of requests being processed.
+	num int
+
+	// draining is true if no new requests should be accepted.
+	draining bool
+}
+
+// newInFlight returns a new properly initialized *inFlight.
+func newInFlight() (f *inFlight) {
+	return &inFlight{
+		mu:   &sync.Mutex{},
+		done: make(chan struct{}),
+	}
+}
+
+// start registers a new request.  ok is false if the request should not be
+// processed, since the requests are being drained.
+func (f *inFlight) start() (ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.draining {
+		return false
+	}
+
+	f.num++
+
+	return true
+}
+
+// finish unregisters a request registered by [inFlight.start].
+func (f *inFlight) finish() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.num--
+	if f.draining && f.num == 0 {
+		close(f.done)
+	}
+}
+
+// drain stops accepting new requests and waits for the registered ones to
+// finish until ctx is done.
+func (f *inFlight) drain(ctx context.Context) (err error) {
+	f.mu.Lock()
+	if !f.draining {
+		f.draining = true
+		if f.num == 0 {
+			close(f.done)
+		}
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for requests in flight: %w", ctx.Err())
+	}
+}
+
 // Config returns the current configuration of the web service.  Config must not
 // be called simultaneously with Start.  If svc was initialized with ":0"
 // addresses, addrs will not return the actual bound ports until Start is
@@ -194,6 +302,7 @@ func (svc *Service) Config() (c *Config) {
 		UpstreamServers:     svc.upstreams,
 		DNS64Prefixes:       svc.dns64Prefixes,
 		UpstreamTimeout:     svc.upsTimeout,
+		ShutdownTimeout:     svc.shutdownTimeout,
 		BootstrapPreferIPv6: svc.bootstrapPreferIPv6,
 		UseDNS64:            svc.useDNS64,
 	}
@@ -0,0 +1,58 @@
+package dnssvc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlight_drain(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		f := newInFlight()
+
+		err := f.drain(context.Background())
+		require.NoError(t, err)
+
+		assert.False(t, f.start())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		f := newInFlight()
+		require.True(t, f.start())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := f.drain(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		assert.False(t, f.start())
+
+		f.finish()
+
+		err = f.drain(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("finished", func(t *testing.T) {
+		f := newInFlight()
+		require.True(t, f.start())
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			f.finish()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		err := f.drain(ctx)
+		assert.NoError(t, err)
+	})
+}
+
+// testTimeout is the common timeout for tests.
+const testTimeout = 1 * time.Second
@@ -59,11 +59,14 @@ func (svc *Service) handlePatchSettingsDNS(w http.ResponseWriter, r *http.Reques
 	}
 
 	newConf := &dnssvc.Config{
-		Addresses:           req.Addresses,
-		BootstrapServers:    req.BootstrapServers,
-		UpstreamServers:     req.UpstreamServers,
-		DNS64Prefixes:       req.DNS64Prefixes,
-		UpstreamTimeout:     time.Duration(req.UpstreamTimeout),
+		Addresses:        req.Addresses,
+		BootstrapServers: req.BootstrapServers,
+		UpstreamServers:  req.UpstreamServers,
+		DNS64Prefixes:    req.DNS64Prefixes,
+		UpstreamTimeout:  time.Duration(req.UpstreamTimeout),
+		// Keep the current shutdown timeout, since the HTTP API doesn't have
+		// it.
+		ShutdownTimeout:     svc.confMgr.DNS().Config().ShutdownTimeout,
 		BootstrapPreferIPv6: req.BootstrapPreferIPv6,
 		UseDNS64:            req.UseDNS64,
 	}
@@ -1,6 +1,11 @@
 package querylog
 
-import "github.com/AdguardTeam/AdGuardHome/internal/whois"
+import (
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+)
 
 // Client is the information required by the query log to match against clients
 // during searches.
@@ -10,6 +15,25 @@ type Client struct {
 	DisallowedRule string      `json:"disallowed_rule"`
 	Disallowed     bool        `json:"disallowed"`
 	IgnoreQueryLog bool        `json:"-"`
+
+	// LogBlockedServices, if true, means that the requests of the client
+	// blocked by the blocked services should be logged even if IgnoreQueryLog
+	// is true.
+	LogBlockedServices bool `json:"-"`
+
+	// Retention, if positive, is the time the entries of the client are kept
+	// for instead of the global retention, see [ValidateRetention].
+	Retention time.Duration `json:"-"`
+}
+
+// isIgnored returns true if the entry of the client with the filtering result
+// res shouldn't be shown in the query log.  c may be nil.
+func (c *Client) isIgnored(res *filtering.Result) (ok bool) {
+	if c == nil || !c.IgnoreQueryLog {
+		return false
+	}
+
+	return !c.LogBlockedServices || res.Reason != filtering.FilteredBlockedService
 }
 
 // clientCacheKey is the key by which a cached client information is found.
@@ -30,6 +30,10 @@ type queryLog struct {
 
 	findClient func(ids []string) (c *Client, err error)
 
+	// hasClientRetention returns true if any client has its own retention.
+	// It's never nil.
+	hasClientRetention func() (ok bool)
+
 	// logFile is the path to the log file.
 	logFile string
 
@@ -123,6 +127,18 @@ func validateIvl(ivl time.Duration) (err error) {
 	return nil
 }
 
+// ValidateRetention returns an error if ret isn't a valid retention of the
+// entries of a client.  Zero means that the global retention is used, so it's
+// valid.
+func ValidateRetention(ret time.Duration) (err error) {
+	if ret == 0 {
+		return nil
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return validateIvl(ret)
+}
+
 func (l *queryLog) WriteDiskConfig(c *Config) {
 	l.confMu.RLock()
 	defer l.confMu.RUnlock()
@@ -278,6 +294,19 @@ func (l *queryLog) ShouldLog(host string, _, _ uint16, ids []string) bool {
 	return !l.isIgnored(host)
 }
 
+// ShouldLogBlockedService implements the [QueryLog] interface for *queryLog.
+func (l *queryLog) ShouldLogBlockedService(ids []string) (ok bool) {
+	l.confMu.RLock()
+	defer l.confMu.RUnlock()
+
+	c, err := l.findClient(ids)
+	if err != nil {
+		log.Error("querylog: finding client: %s", err)
+	}
+
+	return c != nil && c.LogBlockedServices
+}
+
 // isIgnored returns true if the host is in the ignored domains list.  It
 // assumes that l.confMu is locked for reading.
 func (l *queryLog) isIgnored(host string) bool {
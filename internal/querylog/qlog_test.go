@@ -313,6 +313,100 @@ func TestQueryLogShouldLog(t *testing.T) {
 	}
 }
 
+func TestQueryLog_ShouldLogBlockedService(t *testing.T) {
+	findClient := func(ids []string) (c *Client, err error) {
+		switch ids[0] {
+		case "log_blocked":
+			return &Client{IgnoreQueryLog: true, LogBlockedServices: true}, nil
+		case "no_log":
+			return &Client{IgnoreQueryLog: true}, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	l, err := newQueryLog(Config{
+		Enabled:     true,
+		RotationIvl: timeutil.Day,
+		MemSize:     100,
+		BaseDir:     t.TempDir(),
+		FindClient:  findClient,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		ids     []string
+		wantLog bool
+	}{{
+		name:    "log_blocked",
+		ids:     []string{"log_blocked"},
+		wantLog: true,
+	}, {
+		name:    "no_log",
+		ids:     []string{"no_log"},
+		wantLog: false,
+	}, {
+		name:    "unknown",
+		ids:     []string{"unknown"},
+		wantLog: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantLog, l.ShouldLogBlockedService(tc.ids))
+		})
+	}
+}
+
+func TestClient_isIgnored(t *testing.T) {
+	blockedSvcRes := &filtering.Result{
+		Reason:      filtering.FilteredBlockedService,
+		ServiceName: "youtube",
+	}
+	blockedListRes := &filtering.Result{
+		Reason: filtering.FilteredBlockList,
+	}
+
+	testCases := []struct {
+		cli         *Client
+		res         *filtering.Result
+		name        string
+		wantIgnored bool
+	}{{
+		cli:         nil,
+		res:         blockedListRes,
+		name:        "nil",
+		wantIgnored: false,
+	}, {
+		cli:         &Client{},
+		res:         blockedListRes,
+		name:        "not_ignored",
+		wantIgnored: false,
+	}, {
+		cli:         &Client{IgnoreQueryLog: true},
+		res:         blockedSvcRes,
+		name:        "ignored",
+		wantIgnored: true,
+	}, {
+		cli:         &Client{IgnoreQueryLog: true, LogBlockedServices: true},
+		res:         blockedSvcRes,
+		name:        "blocked_service",
+		wantIgnored: false,
+	}, {
+		cli:         &Client{IgnoreQueryLog: true, LogBlockedServices: true},
+		res:         blockedListRes,
+		name:        "other_reason",
+		wantIgnored: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantIgnored, tc.cli.isIgnored(tc.res))
+		})
+	}
+}
+
 func addEntry(l *queryLog, host string, answerStr, client net.IP) {
 	q := dns.Msg{
 		Question: []dns.Question{{
@@ -30,6 +30,11 @@ type QueryLog interface {
 
 	// ShouldLog returns true if request for the host should be logged.
 	ShouldLog(host string, qType, qClass uint16, ids []string) bool
+
+	// ShouldLogBlockedService returns true if the request of the client with
+	// ids blocked by the blocked services should be logged regardless of the
+	// result of ShouldLog.
+	ShouldLogBlockedService(ids []string) (ok bool)
 }
 
 // Config is the query log configuration structure.
@@ -52,6 +57,11 @@ type Config struct {
 	// FindClient returns client information by their IDs.
 	FindClient func(ids []string) (c *Client, err error)
 
+	// HasClientRetention returns true if any client has its own retention,
+	// see [Client.Retention].  If it's nil, no client is considered to have
+	// one.
+	HasClientRetention func() (ok bool)
+
 	// BaseDir is the base directory for log files.
 	BaseDir string
 
@@ -142,8 +152,14 @@ func newQueryLog(conf Config) (l *queryLog, err error) {
 		}
 	}
 
+	hasClientRetention := conf.HasClientRetention
+	if hasClientRetention == nil {
+		hasClientRetention = func() (ok bool) { return false }
+	}
+
 	l = &queryLog{
-		findClient: findClient,
+		findClient:         findClient,
+		hasClientRetention: hasClientRetention,
 
 		conf:    &Config{},
 		confMu:  &sync.RWMutex{},
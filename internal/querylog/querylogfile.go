@@ -1,10 +1,13 @@
 package querylog
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
@@ -80,18 +83,37 @@ func (l *queryLog) flushToFile(buffer []*logEntry) (err error) {
 	return nil
 }
 
-func (l *queryLog) rotate() error {
+// rotate replaces the old log file with the current one.  The entries of the
+// clients with their own retention, see [Client.Retention], which haven't
+// expired yet, are carried from the old log file into the new one.
+func (l *queryLog) rotate() (err error) {
 	from := l.logFile
 	to := l.logFile + ".1"
 
-	err := os.Rename(from, to)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			log.Debug("querylog: no log to rotate")
+	l.fileWriteLock.Lock()
+	defer l.fileWriteLock.Unlock()
 
-			return nil
+	if _, err = os.Stat(from); errors.Is(err, os.ErrNotExist) {
+		log.Debug("querylog: no log to rotate")
+
+		return nil
+	}
+
+	carried := false
+	if l.hasClientRetention() {
+		carried, err = l.carryRetained(from, to, time.Now())
+		if err != nil {
+			return fmt.Errorf("carrying entries of clients with own retention: %w", err)
 		}
+	}
 
+	if carried {
+		err = os.Remove(from)
+	} else {
+		err = os.Rename(from, to)
+	}
+
+	if err != nil {
 		return fmt.Errorf("failed to rename old file: %w", err)
 	}
 
@@ -100,6 +122,159 @@ func (l *queryLog) rotate() error {
 	return nil
 }
 
+// carryRetained replaces the old log file at to with the entries of the
+// clients with their own retention, which haven't expired yet at now, followed
+// by the entries from the current log file at from.  carried is false if there
+// are no such entries, in which case the old log file is left as is.
+func (l *queryLog) carryRetained(from, to string, now time.Time) (carried bool, err error) {
+	cache := clientCache{}
+	keepOld := func(line string) (ok bool) {
+		ret := l.lineRetention(line, cache)
+
+		return ret > 0 && !isExpired(line, ret, now)
+	}
+
+	err = replaceFile(to, func(w io.Writer) (ok bool, wErr error) {
+		kept, _, wErr := copyLines(w, to, keepOld)
+		if wErr != nil || kept == 0 {
+			return false, wErr
+		}
+
+		_, _, wErr = copyLines(w, from, nil)
+		carried = wErr == nil
+
+		return carried, wErr
+	})
+
+	return carried, err
+}
+
+// pruneClients removes the expired entries of the clients with their own
+// retention, see [Client.Retention], from the log files.  The entries in the
+// memory buffer aren't affected.  The files aren't read at all if no client has
+// its own retention.
+func (l *queryLog) pruneClients(now time.Time) {
+	if !l.hasClientRetention() {
+		return
+	}
+
+	l.fileWriteLock.Lock()
+	defer l.fileWriteLock.Unlock()
+
+	cache := clientCache{}
+	keep := func(line string) (ok bool) {
+		ret := l.lineRetention(line, cache)
+
+		return ret <= 0 || !isExpired(line, ret, now)
+	}
+
+	for _, path := range []string{l.logFile + ".1", l.logFile} {
+		pruned := 0
+		err := replaceFile(path, func(w io.Writer) (ok bool, wErr error) {
+			_, pruned, wErr = copyLines(w, path, keep)
+
+			return pruned > 0, wErr
+		})
+		if err != nil {
+			log.Error("querylog: pruning %s: %s", path, err)
+		} else if pruned > 0 {
+			log.Debug("querylog: pruned %d entries from %s", pruned, path)
+		}
+	}
+}
+
+// lineRetention returns the retention of the entries of the client, which has
+// sent the request from the log line.  ret is zero if the global retention is
+// used.
+func (l *queryLog) lineRetention(line string, cache clientCache) (ret time.Duration) {
+	ip := readJSONValue(line, `"IP":"`)
+	clientID := readJSONValue(line, `"CID":"`)
+
+	c, err := l.client(clientID, ip, cache)
+	if err != nil {
+		log.Error("querylog: finding client %q (clientid %q) for pruning: %s", ip, clientID, err)
+	}
+
+	if c == nil {
+		return 0
+	}
+
+	return c.Retention
+}
+
+// isExpired returns true if the entry from the log line is older than ret at
+// now.  The entries without a valid timestamp never expire.
+func isExpired(line string, ret time.Duration, now time.Time) (ok bool) {
+	ts := readQLogTimestamp(line)
+
+	return ts != 0 && now.Sub(time.Unix(0, ts)) > ret
+}
+
+// copyLines writes the lines of the file at path, for which keep returns true,
+// into w.  If keep is nil, all lines are written.  kept and skipped are the
+// numbers of the written and the skipped lines.  A missing file is considered
+// empty.
+func copyLines(
+	w io.Writer,
+	path string,
+	keep func(line string) (ok bool),
+) (kept, skipped int, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	defer func() { err = errors.WithDeferred(err, f.Close()) }()
+
+	r := bufio.NewReader(f)
+	for {
+		line, readErr := r.ReadString('\n')
+		if line != "" {
+			if keep != nil && !keep(line) {
+				skipped++
+			} else if _, err = io.WriteString(w, line); err != nil {
+				return kept, skipped, err
+			} else {
+				kept++
+			}
+		}
+
+		if readErr == io.EOF {
+			return kept, skipped, nil
+		} else if readErr != nil {
+			return kept, skipped, readErr
+		}
+	}
+}
+
+// replaceFile replaces the file at path with the data written by write.  The
+// file is left as is if write returns false or an error.
+func replaceFile(path string, write func(w io.Writer) (ok bool, err error)) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	tmpPath := tmp.Name()
+
+	ok, err := write(tmp)
+	err = errors.WithDeferred(err, tmp.Close())
+	if err == nil && ok {
+		err = os.Chmod(tmpPath, 0o644)
+	}
+
+	if err == nil && ok {
+		err = os.Rename(tmpPath, path)
+	}
+
+	if err != nil || !ok {
+		return errors.WithDeferred(err, os.Remove(tmpPath))
+	}
+
+	return nil
+}
+
 func (l *queryLog) readFileFirstTimeValue() (first time.Time, err error) {
 	var f *os.File
 	f, err = os.Open(l.logFile)
@@ -147,9 +322,12 @@ func (l *queryLog) periodicRotate() {
 	}
 }
 
-// checkAndRotate rotates log files if those are older than the specified
+// checkAndRotate removes the expired entries of the clients with their own
+// retention and rotates log files if those are older than the specified
 // rotation interval.
 func (l *queryLog) checkAndRotate() {
+	l.pruneClients(time.Now())
+
 	var rotationIvl time.Duration
 	func() {
 		l.confMu.RLock()
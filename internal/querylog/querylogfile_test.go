@@ -0,0 +1,170 @@
+package querylog
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRetentionTestLog returns a new *queryLog, which knows the guest client
+// with the IP address 1.1.1.1 and the retention of an hour and the kid client
+// with the ClientID "kid" and the retention of 30 days.  Other clients use the
+// global retention.
+func newRetentionTestLog(t *testing.T) (l *queryLog) {
+	t.Helper()
+
+	clients := map[string]*Client{
+		"1.1.1.1": {Name: "guest", Retention: time.Hour},
+		"kid":     {Name: "kid", Retention: 30 * timeutil.Day},
+	}
+
+	l, err := newQueryLog(Config{
+		FindClient: func(ids []string) (c *Client, err error) {
+			for _, id := range ids {
+				if c = clients[id]; c != nil {
+					return c, nil
+				}
+			}
+
+			return nil, nil
+		},
+		HasClientRetention: func() (ok bool) { return true },
+		BaseDir:            t.TempDir(),
+		RotationIvl:        timeutil.Day,
+	})
+	require.NoError(t, err)
+
+	return l
+}
+
+// logLine returns the log file line of the entry from the client with ip and
+// clientID made at ts.
+func logLine(t *testing.T, ts time.Time, ip, clientID string) (line string) {
+	t.Helper()
+
+	b, err := json.Marshal(&logEntry{
+		Time:     ts,
+		QHost:    "example.org",
+		QType:    "A",
+		QClass:   "IN",
+		ClientID: clientID,
+		IP:       net.ParseIP(ip),
+	})
+	require.NoError(t, err)
+
+	return string(b) + "\n"
+}
+
+// writeLines writes lines into the file at path.
+func writeLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	data := []byte{}
+	for _, line := range lines {
+		data = append(data, line...)
+	}
+
+	err := os.WriteFile(path, data, 0o644)
+	require.NoError(t, err)
+}
+
+// assertLines asserts that the file at path contains exactly lines.
+func assertLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	want := ""
+	for _, line := range lines {
+		want += line
+	}
+
+	assert.Equal(t, want, string(data))
+}
+
+func TestQueryLog_pruneClients(t *testing.T) {
+	l := newRetentionTestLog(t)
+	now := time.Now()
+
+	guestOld := logLine(t, now.Add(-2*time.Hour), "1.1.1.1", "")
+	otherOld := logLine(t, now.Add(-2*time.Hour), "1.1.1.2", "")
+	kidOld := logLine(t, now.Add(-2*time.Hour), "1.1.1.3", "kid")
+	guestNew := logLine(t, now.Add(-30*time.Minute), "1.1.1.1", "")
+
+	writeLines(t, l.logFile+".1", guestOld, kidOld)
+	writeLines(t, l.logFile, guestOld, otherOld, kidOld, guestNew)
+
+	l.pruneClients(now)
+
+	assertLines(t, l.logFile+".1", kidOld)
+	assertLines(t, l.logFile, otherOld, kidOld, guestNew)
+
+	t.Run("no_retention", func(t *testing.T) {
+		l.hasClientRetention = func() (ok bool) { return false }
+
+		// The files must not even be read.
+		l.findClient = func(_ []string) (c *Client, err error) {
+			t.Error("unexpected client lookup")
+
+			return nil, nil
+		}
+
+		writeLines(t, l.logFile, guestOld, guestNew)
+		l.pruneClients(now)
+
+		assertLines(t, l.logFile, guestOld, guestNew)
+	})
+}
+
+func TestQueryLog_rotate_retention(t *testing.T) {
+	now := time.Now()
+
+	otherCur := logLine(t, now.Add(-timeutil.Day), "1.1.1.2", "")
+	otherOld := logLine(t, now.Add(-3*timeutil.Day), "1.1.1.2", "")
+	guestOld := logLine(t, now.Add(-3*timeutil.Day), "1.1.1.1", "")
+	kidOld := logLine(t, now.Add(-3*timeutil.Day), "1.1.1.3", "kid")
+	kidExpired := logLine(t, now.Add(-40*timeutil.Day), "1.1.1.3", "kid")
+
+	testCases := []struct {
+		name    string
+		oldFile []string
+		want    []string
+	}{{
+		name:    "carried",
+		oldFile: []string{kidExpired, otherOld, kidOld, guestOld},
+		want:    []string{kidOld, otherCur},
+	}, {
+		name:    "not_carried",
+		oldFile: []string{kidExpired, otherOld, guestOld},
+		want:    []string{otherCur},
+	}, {
+		name:    "no_old_file",
+		oldFile: nil,
+		want:    []string{otherCur},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newRetentionTestLog(t)
+
+			if tc.oldFile != nil {
+				writeLines(t, l.logFile+".1", tc.oldFile...)
+			}
+
+			writeLines(t, l.logFile, otherCur)
+
+			err := l.rotate()
+			require.NoError(t, err)
+
+			assertLines(t, l.logFile+".1", tc.want...)
+			assert.NoFileExists(t, l.logFile)
+		})
+	}
+}
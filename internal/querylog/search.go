@@ -318,7 +318,7 @@ func (l *queryLog) readNextEntry(
 		// Go on and try to match anyway.
 	}
 
-	if e.client != nil && e.client.IgnoreQueryLog {
+	if e.client.isIgnored(&e.Result) {
 		return nil, ts, nil
 	}
 
@@ -0,0 +1,304 @@
+package schedule
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// loadLocation returns the location with the given name.  If tz isn't a known
+// IANA time zone name, which often happens on systems without tzdata, it's
+// parsed as a POSIX TZ string, for example "CET-1CEST,M3.5.0,M10.5.0/3".  The
+// name of the returned location is always tz.
+func loadLocation(tz string) (loc *time.Location, err error) {
+	loc, err = time.LoadLocation(tz)
+	if err == nil {
+		return loc, nil
+	}
+
+	// POSIX TZ strings always contain the offset of the standard time.
+	if !strings.ContainsAny(tz, "0123456789") {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	stdName, stdOffset, posixErr := parsePOSIXTZ(tz)
+	if posixErr != nil {
+		return nil, fmt.Errorf("bad posix time zone %q: %w", tz, posixErr)
+	}
+
+	loc, err = time.LoadLocationFromTZData(tz, posixTZData(tz, stdName, stdOffset))
+	if err != nil {
+		return nil, fmt.Errorf("loading posix time zone %q: %w", tz, err)
+	}
+
+	return loc, nil
+}
+
+// posixTZData returns the TZif version 2 data, which contains no transitions
+// and a single local time type for the standard time, so that the time
+// package uses the footer with the POSIX TZ string tz for all times.  See RFC
+// 8536.
+func posixTZData(tz, stdName string, stdOffset int) (data []byte) {
+	abbrevs := append([]byte(stdName), 0)
+
+	appendBlock := func(b []byte) (res []byte) {
+		b = append(b, "TZif2"...)
+		b = append(b, make([]byte, 15)...)
+
+		// The counts of UT/local indicators, standard/wall indicators, leap
+		// seconds, transitions, local time types, and abbreviation bytes.
+		for _, n := range []int{0, 0, 0, 0, 1, len(abbrevs)} {
+			b = binary.BigEndian.AppendUint32(b, uint32(n))
+		}
+
+		// The local time type with the UT offset, no DST, and the index of
+		// the abbreviation.
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(stdOffset)))
+		b = append(b, 0, 0)
+
+		return append(b, abbrevs...)
+	}
+
+	// Write the version 1 data block followed by the version 2 one, which
+	// are the same, since there are no transitions.
+	data = appendBlock(nil)
+	data = appendBlock(data)
+
+	return append(append(append(data, '\n'), tz...), '\n')
+}
+
+// parsePOSIXTZ validates the POSIX TZ string tz and returns the abbreviation
+// and the offset in seconds east of UTC of its standard time.  See
+// https://pubs.opengroup.org/onlinepubs/9699919799/basedefs/V1_chap08.html.
+func parsePOSIXTZ(tz string) (stdName string, stdOffset int, err error) {
+	p := &tzParser{s: tz}
+
+	stdName, err = p.name()
+	if err != nil {
+		return "", 0, fmt.Errorf("std name: %w", err)
+	}
+
+	// POSIX offsets are positive west of Greenwich.
+	west, err := p.offset(24)
+	if err != nil {
+		return "", 0, fmt.Errorf("std offset: %w", err)
+	}
+
+	if p.done() {
+		return stdName, -west, nil
+	}
+
+	_, err = p.name()
+	if err != nil {
+		return "", 0, fmt.Errorf("dst name: %w", err)
+	}
+
+	if !p.done() && p.s[0] != ',' {
+		_, err = p.offset(24)
+		if err != nil {
+			return "", 0, fmt.Errorf("dst offset: %w", err)
+		}
+	}
+
+	// The rules are optional, the time package uses the current US ones if
+	// they are omitted.
+	if !p.done() {
+		err = p.rules()
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return "", 0, err
+		}
+	}
+
+	if !p.done() {
+		return "", 0, fmt.Errorf("unexpected data %q", p.s)
+	}
+
+	return stdName, -west, nil
+}
+
+// tzParser is a parser of POSIX TZ strings.
+type tzParser struct {
+	// s is the yet unparsed part of the string.
+	s string
+}
+
+// done returns true if the whole string has been parsed.
+func (p *tzParser) done() (ok bool) {
+	return p.s == ""
+}
+
+// consume removes c from the beginning of the unparsed string if it's there.
+func (p *tzParser) consume(c byte) (ok bool) {
+	if p.done() || p.s[0] != c {
+		return false
+	}
+
+	p.s = p.s[1:]
+
+	return true
+}
+
+// name parses a time zone abbreviation, which is either a sequence of letters
+// or a sequence of letters, digits, pluses, and minuses in angle brackets.
+func (p *tzParser) name() (name string, err error) {
+	if p.consume('<') {
+		i := strings.IndexByte(p.s, '>')
+		if i < 0 {
+			return "", errors.Error("unterminated quoted name")
+		}
+
+		name, p.s = p.s[:i], p.s[i+1:]
+		for _, c := range name {
+			if !isASCIILetter(c) && !isASCIIDigit(c) && c != '+' && c != '-' {
+				return "", fmt.Errorf("bad char %q in quoted name %q", c, name)
+			}
+		}
+	} else {
+		i := strings.IndexFunc(p.s, func(c rune) (ok bool) { return !isASCIILetter(c) })
+		if i < 0 {
+			i = len(p.s)
+		}
+
+		name, p.s = p.s[:i], p.s[i:]
+	}
+
+	if len(name) < 3 {
+		return "", fmt.Errorf("name %q is shorter than 3 chars", name)
+	}
+
+	return name, nil
+}
+
+// offset parses an offset in the [+|-]hh[:mm[:ss]] format with up to maxHours
+// hours and returns it in seconds.
+func (p *tzParser) offset(maxHours int) (secs int, err error) {
+	sign := 1
+	if p.consume('-') {
+		sign = -1
+	} else {
+		p.consume('+')
+	}
+
+	h, err := p.num(0, maxHours)
+	if err != nil {
+		return 0, fmt.Errorf("hours: %w", err)
+	}
+
+	secs = h * 60 * 60
+	if p.consume(':') {
+		var m int
+		m, err = p.num(0, 59)
+		if err != nil {
+			return 0, fmt.Errorf("minutes: %w", err)
+		}
+
+		secs += m * 60
+		if p.consume(':') {
+			var s int
+			s, err = p.num(0, 59)
+			if err != nil {
+				return 0, fmt.Errorf("seconds: %w", err)
+			}
+
+			secs += s
+		}
+	}
+
+	return sign * secs, nil
+}
+
+// rules parses the rules of the start and the end of the daylight saving time
+// in the ,start[/time],end[/time] format.
+func (p *tzParser) rules() (err error) {
+	for _, what := range []string{"dst start rule", "dst end rule"} {
+		if !p.consume(',') {
+			return fmt.Errorf("%s: no leading comma", what)
+		}
+
+		err = p.date()
+		if err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+
+		if p.consume('/') {
+			// Allow the extended range of hours from RFC 8536.
+			_, err = p.offset(167)
+			if err != nil {
+				return fmt.Errorf("%s: time: %w", what, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// date parses the date of a daylight saving time rule, which is either Jn, n,
+// or Mm.w.d.
+func (p *tzParser) date() (err error) {
+	switch {
+	case p.consume('J'):
+		_, err = p.num(1, 365)
+	case p.consume('M'):
+		for i, maxVal := range []int{12, 5, 6} {
+			if i > 0 && !p.consume('.') {
+				return fmt.Errorf("month date: no dot before part %d", i+1)
+			}
+
+			minVal := 1
+			if i == 2 {
+				minVal = 0
+			}
+
+			_, err = p.num(minVal, maxVal)
+			if err != nil {
+				return fmt.Errorf("month date: part %d: %w", i+1, err)
+			}
+		}
+	default:
+		_, err = p.num(0, 365)
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return err
+}
+
+// num parses a decimal number within [minVal, maxVal].
+func (p *tzParser) num(minVal, maxVal int) (n int, err error) {
+	i := strings.IndexFunc(p.s, func(c rune) (ok bool) { return !isASCIIDigit(c) })
+	if i < 0 {
+		i = len(p.s)
+	}
+
+	if i == 0 {
+		return 0, errors.Error("no number")
+	} else if i > 3 {
+		return 0, fmt.Errorf("number %q is too long", p.s[:i])
+	}
+
+	for _, c := range p.s[:i] {
+		n = n*10 + int(c-'0')
+	}
+
+	if n < minVal || n > maxVal {
+		return 0, fmt.Errorf("number %d is out of range [%d, %d]", n, minVal, maxVal)
+	}
+
+	p.s = p.s[i:]
+
+	return n, nil
+}
+
+// isASCIILetter returns true if c is an ASCII letter.
+func isASCIILetter(c rune) (ok bool) {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isASCIIDigit returns true if c is an ASCII digit.
+func isASCIIDigit(c rune) (ok bool) {
+	return c >= '0' && c <= '9'
+}
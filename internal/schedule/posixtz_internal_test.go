@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// testPOSIXTZ is the POSIX TZ string for the Central European Time.
+const testPOSIXTZ = "CET-1CEST,M3.5.0,M10.5.0/3"
+
+func TestLoadLocation_posix(t *testing.T) {
+	loc, err := loadLocation(testPOSIXTZ)
+	require.NoError(t, err)
+
+	assert.Equal(t, testPOSIXTZ, loc.String())
+
+	testCases := []struct {
+		in         time.Time
+		name       string
+		wantZone   string
+		wantOffset int
+	}{{
+		in:         time.Date(2023, time.January, 15, 12, 0, 0, 0, time.UTC),
+		name:       "winter",
+		wantZone:   "CET",
+		wantOffset: 1 * 60 * 60,
+	}, {
+		in:         time.Date(2023, time.July, 15, 12, 0, 0, 0, time.UTC),
+		name:       "summer",
+		wantZone:   "CEST",
+		wantOffset: 2 * 60 * 60,
+	}, {
+		in:         time.Date(2023, time.March, 26, 0, 59, 59, 0, time.UTC),
+		name:       "before_dst_start",
+		wantZone:   "CET",
+		wantOffset: 1 * 60 * 60,
+	}, {
+		in:         time.Date(2023, time.March, 26, 1, 0, 0, 0, time.UTC),
+		name:       "dst_start",
+		wantZone:   "CEST",
+		wantOffset: 2 * 60 * 60,
+	}, {
+		in:         time.Date(2023, time.October, 29, 0, 59, 59, 0, time.UTC),
+		name:       "before_dst_end",
+		wantZone:   "CEST",
+		wantOffset: 2 * 60 * 60,
+	}, {
+		in:         time.Date(2023, time.October, 29, 1, 0, 0, 0, time.UTC),
+		name:       "dst_end",
+		wantZone:   "CET",
+		wantOffset: 1 * 60 * 60,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone, offset := tc.in.In(loc).Zone()
+			assert.Equal(t, tc.wantZone, zone)
+			assert.Equal(t, tc.wantOffset, offset)
+		})
+	}
+}
+
+func TestLoadLocation_errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tz         string
+		wantErrMsg string
+	}{{
+		name:       "not_posix",
+		tz:         "bad_timezone",
+		wantErrMsg: "unknown time zone bad_timezone",
+	}, {
+		name:       "short_name",
+		tz:         "AB1",
+		wantErrMsg: `bad posix time zone "AB1": std name: name "AB" is shorter than 3 chars`,
+	}, {
+		name: "bad_offset",
+		tz:   "CET25",
+		wantErrMsg: `bad posix time zone "CET25": std offset: hours: ` +
+			`number 25 is out of range [0, 24]`,
+	}, {
+		name: "bad_month",
+		tz:   "CET-1CEST,M13.5.0,M10.5.0/3",
+		wantErrMsg: `bad posix time zone "CET-1CEST,M13.5.0,M10.5.0/3": ` +
+			`dst start rule: month date: part 1: number 13 is out of range [1, 12]`,
+	}, {
+		name: "no_end_rule",
+		tz:   "CET-1CEST,M3.5.0",
+		wantErrMsg: `bad posix time zone "CET-1CEST,M3.5.0": ` +
+			`dst end rule: no leading comma`,
+	}, {
+		name: "trailing_data",
+		tz:   "CET-1CEST,M3.5.0,M10.5.0/3x",
+		wantErrMsg: `bad posix time zone "CET-1CEST,M3.5.0,M10.5.0/3x": ` +
+			`unexpected data "x"`,
+	}, {
+		name: "unterminated_quoted_name",
+		tz:   "<+03-3",
+		wantErrMsg: `bad posix time zone "<+03-3": std name: ` +
+			`unterminated quoted name`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadLocation(tc.tz)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
+
+func TestWeekly_UnmarshalYAML_posixTZ(t *testing.T) {
+	const data = `
+time_zone: ` + testPOSIXTZ + `
+sun:
+    start: 12h
+    end: 14h
+`
+
+	w := &Weekly{}
+	err := yaml.Unmarshal([]byte(data), w)
+	require.NoError(t, err)
+
+	// 2023-07-16 is a Sunday, and 10:30 UTC is 12:30 CEST.
+	assert.True(t, w.Contains(time.Date(2023, time.July, 16, 10, 30, 0, 0, time.UTC)))
+	assert.False(t, w.Contains(time.Date(2023, time.July, 16, 12, 30, 0, 0, time.UTC)))
+
+	// 2023-01-15 is a Sunday, and 12:30 UTC is 13:30 CET.
+	assert.True(t, w.Contains(time.Date(2023, time.January, 15, 12, 30, 0, 0, time.UTC)))
+	assert.False(t, w.Contains(time.Date(2023, time.January, 15, 10, 30, 0, 0, time.UTC)))
+
+	b, err := yaml.Marshal(w)
+	require.NoError(t, err)
+
+	got := &Weekly{}
+	err = yaml.Unmarshal(b, got)
+	require.NoError(t, err)
+
+	assert.Equal(t, testPOSIXTZ, got.location.String())
+	assert.Equal(t, w.days, got.days)
+}
@@ -2,23 +2,138 @@
 package schedule
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/timeutil"
+	"golang.org/x/exp/slices"
 	"gopkg.in/yaml.v3"
 )
 
-// Weekly is a schedule for one week.  Each day of the week has one range with
-// a beginning and an end.
+// Weekly is a schedule for one week.  Each day of the week has zero or more
+// non-overlapping ranges with a beginning and an end.  A range includes its
+// beginning but not its end, and the end may be 24h, which is the end of the
+// day.  So the range from 0 to 24h covers the whole day, including its last
+// minute, and the ranges ending at 24h need no adjustments.
 type Weekly struct {
 	// location is used to calculate the offsets of the day ranges.
 	location *time.Location
 
 	// days are the day ranges of this schedule.  The indexes of this array are
-	// the [time.Weekday] values.
-	days [7]dayRange
+	// the [time.Weekday] values.  The days not configured explicitly contain
+	// the ranges of defaultDay, if any.
+	days [7]dayRanges
+
+	// defaultDay are the day ranges of the days not configured explicitly.
+	// It's only used to serialize the schedule.
+	defaultDay dayRanges
+
+	// weekStart is the first day of the week.  It only affects the order of
+	// the days in the serialized and human-readable forms of the schedule, but
+	// not the semantics of [Weekly.Contains].  It's either [time.Sunday] or
+	// [time.Monday].
+	weekStart time.Weekday
+
+	// weekParity restricts the schedule to the ISO weeks of the given parity.
+	weekParity weekParity
+
+	// clockTimes is true if the day ranges are serialized into YAML with clock
+	// times, e.g. "14:30", instead of durations, e.g. "14h30m".  It's true if
+	// the YAML configuration has contained any clock times.
+	clockTimes bool
+
+	// ignoredEmpty are true for the days, the configuration of which contained
+	// ranges with both start and end set to zero.  Such ranges are omitted, so
+	// this is only used to report them in [Weekly.Warnings].
+	ignoredEmpty [7]bool
+
+	// fromDefault are true for the days, the ranges of which are taken from
+	// defaultDay.  Those days aren't serialized separately.
+	fromDefault [7]bool
+}
+
+// defaultDayKey is the YAML key of the day ranges applied to the days of the
+// week not configured explicitly.
+const defaultDayKey = "default"
+
+// dayKeys are the YAML keys of the days of the week indexed by the
+// [time.Weekday] values.
+var dayKeys = [7]string{
+	time.Sunday:    "sun",
+	time.Monday:    "mon",
+	time.Tuesday:   "tue",
+	time.Wednesday: "wed",
+	time.Thursday:  "thu",
+	time.Friday:    "fri",
+	time.Saturday:  "sat",
+}
+
+// parseWeekStart parses the first day of the week from its YAML key.  An empty
+// string means [time.Sunday].
+func parseWeekStart(s string) (wd time.Weekday, err error) {
+	switch s {
+	case "", dayKeys[time.Sunday]:
+		return time.Sunday, nil
+	case dayKeys[time.Monday]:
+		return time.Monday, nil
+	default:
+		return 0, fmt.Errorf("week start: unsupported value %q", s)
+	}
+}
+
+// weekParity is the parity of the ISO weeks, within which a schedule is active.
+type weekParity uint8
+
+// Week parities.
+const (
+	weekParityAll weekParity = iota
+	weekParityEven
+	weekParityOdd
+)
+
+// weekParityKeys are the YAML values of the week parities.
+var weekParityKeys = [...]string{
+	weekParityAll:  "all",
+	weekParityEven: "even",
+	weekParityOdd:  "odd",
+}
+
+// parseWeekParity parses the week parity from its YAML value.  An empty string
+// means [weekParityAll].
+func parseWeekParity(s string) (p weekParity, err error) {
+	switch s {
+	case "", weekParityKeys[weekParityAll]:
+		return weekParityAll, nil
+	case weekParityKeys[weekParityEven]:
+		return weekParityEven, nil
+	case weekParityKeys[weekParityOdd]:
+		return weekParityOdd, nil
+	default:
+		return 0, fmt.Errorf("week parity: unsupported value %q", s)
+	}
+}
+
+// String implements the [fmt.Stringer] interface for weekParity.
+func (p weekParity) String() (s string) {
+	return weekParityKeys[p]
+}
+
+// matches returns true if the ISO week of t has the parity p.  Note that the
+// years with 53 ISO weeks end with an odd week and the next ones start with an
+// odd week too, so around such year boundaries there are two odd weeks in a
+// row.
+func (p weekParity) matches(t time.Time) (ok bool) {
+	if p == weekParityAll {
+		return true
+	}
+
+	_, week := t.ISOWeek()
+
+	return (week%2 == 0) == (p == weekParityEven)
 }
 
 // EmptyWeekly creates empty weekly schedule with local time zone.
@@ -32,11 +147,11 @@ func EmptyWeekly() (w *Weekly) {
 //
 // TODO(s.chzhen):  Consider moving into tests.
 func FullWeekly() (w *Weekly) {
-	fullDay := dayRange{start: 0, end: maxDayRange}
+	fullDay := dayRanges{{start: 0, end: maxDayRange}}
 
 	return &Weekly{
 		location: time.Local,
-		days: [7]dayRange{
+		days: [7]dayRanges{
 			time.Sunday:    fullDay,
 			time.Monday:    fullDay,
 			time.Tuesday:   fullDay,
@@ -50,20 +165,76 @@ func FullWeekly() (w *Weekly) {
 
 // Clone returns a deep copy of a weekly.
 func (w *Weekly) Clone() (c *Weekly) {
-	// NOTE:  Do not use time.LoadLocation, because the results will be
-	// different on time zone database update.
-	return &Weekly{
-		location: w.location,
-		days:     w.days,
+	c = &Weekly{
+		// NOTE:  Do not use time.LoadLocation, because the results will be
+		// different on time zone database update.
+		location:     w.location,
+		weekStart:    w.weekStart,
+		weekParity:   w.weekParity,
+		defaultDay:   w.defaultDay.clone(),
+		clockTimes:   w.clockTimes,
+		ignoredEmpty: w.ignoredEmpty,
+		fromDefault:  w.fromDefault,
+	}
+
+	for i, drs := range w.days {
+		c.days[i] = drs.clone()
 	}
+
+	return c
+}
+
+// Union returns a new schedule containing the time points which are contained
+// in either w or other.  The first day of the week of the result is the one of
+// w.  w and other must have the same time zone and week parity.
+func (w *Weekly) Union(other *Weekly) (u *Weekly, err error) {
+	return w.combine(other, dayRanges.union)
+}
+
+// Intersection returns a new schedule containing the time points which are
+// contained in both w and other.  The first day of the week of the result is
+// the one of w.  w and other must have the same time zone and week parity.
+func (w *Weekly) Intersection(other *Weekly) (i *Weekly, err error) {
+	return w.combine(other, dayRanges.intersection)
 }
 
-// Contains returns true if t is within the corresponding day range of the
-// schedule in the schedule's time zone.
+// combine returns a new schedule with the day ranges of w and other combined
+// using f.
+func (w *Weekly) combine(other *Weekly, f func(a, b dayRanges) (c dayRanges)) (c *Weekly, err error) {
+	if w.location.String() != other.location.String() {
+		return nil, fmt.Errorf("time zones %q and %q differ", w.location, other.location)
+	}
+
+	if w.weekParity != other.weekParity {
+		return nil, fmt.Errorf("week parities %q and %q differ", w.weekParity, other.weekParity)
+	}
+
+	c = &Weekly{
+		location:   w.location,
+		weekStart:  w.weekStart,
+		weekParity: w.weekParity,
+		clockTimes: w.clockTimes,
+	}
+
+	for i := range w.days {
+		c.days[i] = f(w.days[i], other.days[i])
+	}
+
+	return c, nil
+}
+
+// Contains returns true if t is within the corresponding day ranges of the
+// schedule in the schedule's time zone.  The days not configured explicitly
+// use the default day ranges, if any.  If the schedule has a week parity, the
+// ISO week of t in the schedule's time zone must also have that parity.
 func (w *Weekly) Contains(t time.Time) (ok bool) {
 	t = t.In(w.location)
+	if !w.weekParity.matches(t) {
+		return false
+	}
+
 	wd := t.Weekday()
-	dr := w.days[wd]
+	drs := w.days[wd]
 
 	// Calculate the offset of the day range.
 	//
@@ -72,13 +243,67 @@ func (w *Weekly) Contains(t time.Time) (ok bool) {
 	day := time.Date(y, m, d, 0, 0, 0, 0, w.location)
 	offset := t.Sub(day)
 
-	return dr.contains(offset)
+	return drs.contains(offset)
+}
+
+// nextChangeDays is the number of days, within which [Weekly.NextChange] looks
+// for the change.  It's two weeks to account for the week parity plus a day to
+// account for the ranges ending at midnight.
+const nextChangeDays = 15
+
+// NextChange returns the earliest moment after t, at which the result of
+// [Weekly.Contains] changes.  ok is false if it never changes, e.g. when the
+// schedule is empty.
+func (w *Weekly) NextChange(t time.Time) (next time.Time, ok bool) {
+	cur := w.Contains(t)
+
+	t = t.In(w.location)
+	y, m, d := t.Date()
+	for i := 0; i < nextChangeDays; i++ {
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, w.location)
+
+		// The result may only change at the beginning of a day, which also
+		// covers the week parity, or at the boundaries of the day's ranges.
+		candidates := []time.Time{day}
+		for _, r := range w.days[day.Weekday()] {
+			candidates = append(candidates, day.Add(r.start), day.Add(r.end))
+		}
+
+		for _, c := range candidates {
+			if c.After(t) && w.Contains(c) != cur {
+				return c, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// RemainingActive returns the duration from t until the end of the active
+// window of the schedule containing t.  The window spans several days if their
+// ranges are contiguous, e.g. when back-to-back days are covered entirely.  ok
+// is false if t isn't within the schedule, in which case d is zero.  d is also
+// zero if the window never ends, e.g. when the schedule covers the whole week.
+func (w *Weekly) RemainingActive(t time.Time) (d time.Duration, ok bool) {
+	if !w.Contains(t) {
+		return 0, false
+	}
+
+	end, ok := w.NextChange(t)
+	if !ok {
+		return 0, true
+	}
+
+	return end.Sub(t), true
 }
 
 // type check
 var _ yaml.Unmarshaler = (*Weekly)(nil)
 
-// UnmarshalYAML implements the [yaml.Unmarshaler] interface for *Weekly.
+// UnmarshalYAML implements the [yaml.Unmarshaler] interface for *Weekly.  The
+// starts and the ends of the day ranges are either durations, e.g. "14h30m",
+// or clock times, e.g. "14:30".  The ranges under the "default" key apply to
+// the days of the week, which aren't configured explicitly.
 func (w *Weekly) UnmarshalYAML(value *yaml.Node) (err error) {
 	conf := &weeklyConfig{}
 
@@ -88,15 +313,7 @@ func (w *Weekly) UnmarshalYAML(value *yaml.Node) (err error) {
 		return err
 	}
 
-	weekly := Weekly{}
-
-	weekly.location, err = time.LoadLocation(conf.TimeZone)
-	if err != nil {
-		// Don't wrap the error since it's informative enough as is.
-		return err
-	}
-
-	days := []dayConfig{
+	days := [7]dayRangesConfig{
 		time.Sunday:    conf.Sunday,
 		time.Monday:    conf.Monday,
 		time.Tuesday:   conf.Tuesday,
@@ -105,18 +322,78 @@ func (w *Weekly) UnmarshalYAML(value *yaml.Node) (err error) {
 		time.Friday:    conf.Friday,
 		time.Saturday:  conf.Saturday,
 	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return w.fromConfig(conf.TimeZone, conf.WeekStart, conf.WeekParity, days, conf.Default)
+}
+
+// fromConfig validates the time zone, the first day of the week, the week
+// parity, and the day ranges indexed by the [time.Weekday] values and sets them
+// into w.  def, if not nil, are the day ranges of the days, configuration of
+// which is nil.  w isn't changed if there is an error.
+func (w *Weekly) fromConfig(
+	tz string,
+	weekStart string,
+	parity string,
+	days [7]dayRangesConfig,
+	def dayRangesConfig,
+) (err error) {
+	weekly := Weekly{}
+
+	weekly.location, err = loadLocation(tz)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	weekly.weekStart, err = parseWeekStart(weekStart)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	weekly.weekParity, err = parseWeekParity(parity)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	// Collect the errors for all days to report every problem at once.
+	var errs []error
+	var defIgnored bool
+	if def != nil {
+		weekly.defaultDay, defIgnored, err = w.dayRanges(def)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("default day: %w", err))
+		}
+
+		weekly.clockTimes = def.hasClockTimes()
+	}
+
 	for i, d := range days {
-		r := dayRange{
-			start: d.Start.Duration,
-			end:   d.End.Duration,
+		if d == nil && def != nil {
+			weekly.days[i] = weekly.defaultDay.clone()
+			weekly.ignoredEmpty[i] = defIgnored
+			weekly.fromDefault[i] = true
+
+			continue
 		}
 
-		err = w.validate(r)
+		weekly.days[i], weekly.ignoredEmpty[i], err = w.dayRanges(d)
 		if err != nil {
-			return fmt.Errorf("weekday %s: %w", time.Weekday(i), err)
+			errs = append(errs, fmt.Errorf("weekday %s: %w", time.Weekday(i), err))
 		}
 
-		weekly.days[i] = r
+		weekly.clockTimes = weekly.clockTimes || d.hasClockTimes()
+	}
+
+	switch len(errs) {
+	case 0:
+		// Go on.
+	case 1:
+		return errs[0]
+	default:
+		return errors.List("bad day ranges", errs...)
 	}
 
 	*w = weekly
@@ -129,21 +406,268 @@ type weeklyConfig struct {
 	// TimeZone is the local time zone.
 	TimeZone string `yaml:"time_zone"`
 
+	// WeekStart is the first day of the week, either "sun" or "mon".  Empty
+	// string means "sun".
+	WeekStart string `yaml:"week_start,omitempty"`
+
+	// WeekParity is the parity of the ISO weeks, within which the schedule is
+	// active, either "all", "even", or "odd".  Empty string means "all".
+	WeekParity string `yaml:"week_parity,omitempty"`
+
+	// Default is the configuration of the days, which aren't configured
+	// explicitly.
+	Default dayRangesConfig `yaml:"default,omitempty"`
+
 	// Days of the week.
 
-	Sunday    dayConfig `yaml:"sun,omitempty"`
-	Monday    dayConfig `yaml:"mon,omitempty"`
-	Tuesday   dayConfig `yaml:"tue,omitempty"`
-	Wednesday dayConfig `yaml:"wed,omitempty"`
-	Thursday  dayConfig `yaml:"thu,omitempty"`
-	Friday    dayConfig `yaml:"fri,omitempty"`
-	Saturday  dayConfig `yaml:"sat,omitempty"`
+	Sunday    dayRangesConfig `yaml:"sun,omitempty"`
+	Monday    dayRangesConfig `yaml:"mon,omitempty"`
+	Tuesday   dayRangesConfig `yaml:"tue,omitempty"`
+	Wednesday dayRangesConfig `yaml:"wed,omitempty"`
+	Thursday  dayRangesConfig `yaml:"thu,omitempty"`
+	Friday    dayRangesConfig `yaml:"fri,omitempty"`
+	Saturday  dayRangesConfig `yaml:"sat,omitempty"`
 }
 
-// dayConfig is the YAML configuration structure of dayRange.
+// Shortcuts for the day configuration.
+const (
+	// dayShortcutAll means that the schedule is active all day long.
+	dayShortcutAll = "all"
+
+	// dayShortcutNone means that the schedule is not active during the day.
+	dayShortcutNone = "none"
+)
+
+// dayConfig is the YAML configuration structure of dayRange.  It's either a
+// mapping with start and end or one of the day shortcuts.
 type dayConfig struct {
-	Start timeutil.Duration `yaml:"start"`
-	End   timeutil.Duration `yaml:"end"`
+	Start dayTime `yaml:"start"`
+	End   dayTime `yaml:"end"`
+
+	// isShortcut is true if the day has been configured with a shortcut.
+	isShortcut bool
+}
+
+// type check
+var _ yaml.Unmarshaler = (*dayConfig)(nil)
+
+// UnmarshalYAML implements the [yaml.Unmarshaler] interface for *dayConfig.
+func (c *dayConfig) UnmarshalYAML(value *yaml.Node) (err error) {
+	if value.Kind != yaml.ScalarNode {
+		// Decode into a type without the UnmarshalYAML method to avoid
+		// recursion.
+		type dayConfigFields dayConfig
+
+		// Don't wrap the error since it's informative enough as is.
+		return value.Decode((*dayConfigFields)(c))
+	}
+
+	switch value.Value {
+	case dayShortcutAll:
+		*c = dayConfig{
+			End:        dayTime{offset: maxDayRange},
+			isShortcut: true,
+		}
+	case dayShortcutNone:
+		*c = dayConfig{
+			isShortcut: true,
+		}
+	default:
+		return fmt.Errorf(
+			"unsupported day value %q, want %q, %q, or a day range",
+			value.Value,
+			dayShortcutAll,
+			dayShortcutNone,
+		)
+	}
+
+	return nil
+}
+
+// dayTime is the YAML configuration structure of an offset from the beginning
+// of a day.  It's either a duration, e.g. "14h30m", or a clock time in the
+// HH:MM format, e.g. "14:30".
+type dayTime struct {
+	// offset is the offset from the beginning of the day.
+	offset time.Duration
+
+	// isClock is true if the offset is a clock time.
+	isClock bool
+}
+
+// type check
+var _ yaml.Unmarshaler = (*dayTime)(nil)
+
+// UnmarshalYAML implements the [yaml.Unmarshaler] interface for *dayTime.
+func (t *dayTime) UnmarshalYAML(value *yaml.Node) (err error) {
+	if value.Kind == yaml.ScalarNode && strings.Contains(value.Value, ":") {
+		var offset time.Duration
+		offset, err = parseClockTime(value.Value)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return err
+		}
+
+		*t = dayTime{
+			offset:  offset,
+			isClock: true,
+		}
+
+		return nil
+	}
+
+	d := timeutil.Duration{}
+	err = value.Decode(&d)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	*t = dayTime{
+		offset: d.Duration,
+	}
+
+	return nil
+}
+
+// type check
+var _ yaml.Marshaler = dayTime{}
+
+// MarshalYAML implements the [yaml.Marshaler] interface for dayTime.
+func (t dayTime) MarshalYAML() (v any, err error) {
+	if t.isClock {
+		return clockTime(t.offset), nil
+	}
+
+	return timeutil.Duration{Duration: t.offset}, nil
+}
+
+// parseClockTime parses the offset from the beginning of the day from the
+// clock time in the HH:MM format.  The hours may also be a single digit.
+// "24:00" means the end of the day.
+func parseClockTime(s string) (offset time.Duration, err error) {
+	defer func() { err = errors.Annotate(err, "bad clock time %q: %w", s) }()
+
+	hStr, mStr, _ := strings.Cut(s, ":")
+	if len(hStr) < 1 || len(hStr) > 2 || len(mStr) != 2 {
+		return 0, errors.Error("want HH:MM")
+	}
+
+	h, err := parseClockNumber(hStr)
+	if err != nil {
+		return 0, fmt.Errorf("hours: %w", err)
+	}
+
+	m, err := parseClockNumber(mStr)
+	if err != nil {
+		return 0, fmt.Errorf("minutes: %w", err)
+	}
+
+	switch {
+	case m > 59:
+		return 0, fmt.Errorf("minutes: out of range: %d", m)
+	case h > 24, h == 24 && m != 0:
+		return 0, fmt.Errorf("hours: out of range: %d", h)
+	default:
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+	}
+}
+
+// parseClockNumber parses the hours or the minutes of a clock time.  s must
+// only contain decimal digits.
+func parseClockNumber(s string) (n int, err error) {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("bad digit %q", c)
+		}
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return strconv.Atoi(s)
+}
+
+// dayRangesConfig is the YAML configuration structure of dayRanges.  It's
+// either a single dayConfig or a sequence of those.  A shortcut can't be
+// combined with other ranges.
+type dayRangesConfig []dayConfig
+
+// hasClockTimes returns true if any of the day ranges in c has been configured
+// with clock times.
+func (c dayRangesConfig) hasClockTimes() (ok bool) {
+	return slices.IndexFunc(c, func(d dayConfig) (isClock bool) {
+		return d.Start.isClock || d.End.isClock
+	}) != -1
+}
+
+// type check
+var _ yaml.Unmarshaler = (*dayRangesConfig)(nil)
+
+// UnmarshalYAML implements the [yaml.Unmarshaler] interface for
+// *dayRangesConfig.
+func (c *dayRangesConfig) UnmarshalYAML(value *yaml.Node) (err error) {
+	if value.Kind == yaml.SequenceNode {
+		var confs []dayConfig
+		err = value.Decode(&confs)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return err
+		}
+
+		for _, d := range confs {
+			if d.isShortcut && len(confs) > 1 {
+				return errors.Error("day shortcut can't be mixed with day ranges")
+			}
+		}
+
+		*c = confs
+
+		return nil
+	}
+
+	d := dayConfig{}
+	err = value.Decode(&d)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	*c = dayRangesConfig{d}
+
+	return nil
+}
+
+// dayRanges validates the day ranges configuration and converts it into sorted
+// dayRanges.  Empty ranges are omitted.  hasIgnored is true if any of the
+// omitted ranges hasn't been configured with a shortcut.
+func (w *Weekly) dayRanges(c dayRangesConfig) (drs dayRanges, hasIgnored bool, err error) {
+	for _, d := range c {
+		r := dayRange{
+			start: d.Start.offset,
+			end:   d.End.offset,
+		}
+
+		err = w.validate(r)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return nil, false, err
+		}
+
+		if r != (dayRange{}) {
+			drs = append(drs, r)
+		} else if !d.isShortcut {
+			hasIgnored = true
+		}
+	}
+
+	slices.SortFunc(drs, dayRangeSortsBefore)
+	for i := 1; i < len(drs); i++ {
+		prev, r := drs[i-1], drs[i]
+		if r.start < prev.end {
+			return nil, false, fmt.Errorf("day range %s overlaps with %s", r, prev)
+		}
+	}
+
+	return drs, hasIgnored, nil
 }
 
 // maxDayRange is the maximum value for day range end.
@@ -175,39 +699,296 @@ func (w *Weekly) validate(r dayRange) (err error) {
 // type check
 var _ yaml.Marshaler = (*Weekly)(nil)
 
-// MarshalYAML implements the [yaml.Marshaler] interface for *Weekly.
+// MarshalYAML implements the [yaml.Marshaler] interface for *Weekly.  The days
+// are serialized in the order starting from the first day of the week, and the
+// times are serialized in the form they have been configured with, see
+// [Weekly.UnmarshalYAML].  The days using the default day ranges aren't
+// serialized separately.  The days without non-empty ranges are omitted, since
+// they're parsed back as empty days anyway, unless there are non-empty default
+// day ranges.
 func (w *Weekly) MarshalYAML() (v any, err error) {
-	return weeklyConfig{
-		TimeZone: w.location.String(),
-		Sunday: dayConfig{
-			Start: timeutil.Duration{Duration: w.days[time.Sunday].start},
-			End:   timeutil.Duration{Duration: w.days[time.Sunday].end},
-		},
-		Monday: dayConfig{
-			Start: timeutil.Duration{Duration: w.days[time.Monday].start},
-			End:   timeutil.Duration{Duration: w.days[time.Monday].end},
-		},
-		Tuesday: dayConfig{
-			Start: timeutil.Duration{Duration: w.days[time.Tuesday].start},
-			End:   timeutil.Duration{Duration: w.days[time.Tuesday].end},
-		},
-		Wednesday: dayConfig{
-			Start: timeutil.Duration{Duration: w.days[time.Wednesday].start},
-			End:   timeutil.Duration{Duration: w.days[time.Wednesday].end},
-		},
-		Thursday: dayConfig{
-			Start: timeutil.Duration{Duration: w.days[time.Thursday].start},
-			End:   timeutil.Duration{Duration: w.days[time.Thursday].end},
-		},
-		Friday: dayConfig{
-			Start: timeutil.Duration{Duration: w.days[time.Friday].start},
-			End:   timeutil.Duration{Duration: w.days[time.Friday].end},
-		},
-		Saturday: dayConfig{
-			Start: timeutil.Duration{Duration: w.days[time.Saturday].start},
-			End:   timeutil.Duration{Duration: w.days[time.Saturday].end},
-		},
-	}, nil
+	n := &yaml.Node{
+		Kind: yaml.MappingNode,
+	}
+
+	appendScalar := func(key, val string) {
+		n.Content = append(
+			n.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: val},
+		)
+	}
+
+	appendScalar("time_zone", w.location.String())
+	if w.weekStart != time.Sunday {
+		appendScalar("week_start", dayKeys[w.weekStart])
+	}
+
+	if w.weekParity != weekParityAll {
+		appendScalar("week_parity", w.weekParity.String())
+	}
+
+	hasDefault := slices.Contains(w.fromDefault[:], true) && len(w.defaultDay) > 0
+	if hasDefault {
+		var val *yaml.Node
+		val, err = w.dayRangesNode(w.defaultDay)
+		if err != nil {
+			return nil, fmt.Errorf("encoding default day: %w", err)
+		}
+
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: defaultDayKey}, val)
+	}
+
+	for _, wd := range w.orderedDays() {
+		if hasDefault && w.fromDefault[wd] {
+			continue
+		}
+
+		val, encErr := w.dayRangesNode(w.days[wd])
+		if encErr != nil {
+			return nil, fmt.Errorf("encoding %s: %w", wd, encErr)
+		}
+
+		if val != nil {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: dayKeys[wd]}, val)
+		} else if hasDefault {
+			// Make sure the day isn't parsed back as the default one.
+			appendScalar(dayKeys[wd], dayShortcutNone)
+		}
+	}
+
+	return n, nil
+}
+
+// dayRangesNode returns the YAML node for the day ranges drs.  val is nil if
+// drs don't contain any non-empty ranges.
+func (w *Weekly) dayRangesNode(drs dayRanges) (val *yaml.Node, err error) {
+	conf := make([]dayConfig, 0, len(drs))
+	for _, r := range drs {
+		if r == (dayRange{}) {
+			continue
+		}
+
+		conf = append(conf, dayConfig{
+			Start: dayTime{offset: r.start, isClock: w.clockTimes},
+			End:   dayTime{offset: r.end, isClock: w.clockTimes},
+		})
+	}
+
+	if len(conf) == 0 {
+		return nil, nil
+	}
+
+	// Keep the single-range days in the mapping form for compatibility.
+	var toEncode any = conf
+	if len(conf) == 1 {
+		toEncode = conf[0]
+	}
+
+	val = &yaml.Node{}
+	err = val.Encode(toEncode)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// weeklyJSON is the JSON representation of Weekly.
+type weeklyJSON struct {
+	// TimeZone is the local time zone.
+	TimeZone string `json:"time_zone"`
+
+	// WeekStart is the first day of the week, either "sun" or "mon".  Empty
+	// string means "sun".
+	WeekStart string `json:"week_start,omitempty"`
+
+	// WeekParity is the parity of the ISO weeks, within which the schedule is
+	// active, either "all", "even", or "odd".  Empty string means "all".
+	WeekParity string `json:"week_parity,omitempty"`
+
+	// Days of the week.
+
+	Sunday    []dayJSON `json:"sun,omitempty"`
+	Monday    []dayJSON `json:"mon,omitempty"`
+	Tuesday   []dayJSON `json:"tue,omitempty"`
+	Wednesday []dayJSON `json:"wed,omitempty"`
+	Thursday  []dayJSON `json:"thu,omitempty"`
+	Friday    []dayJSON `json:"fri,omitempty"`
+	Saturday  []dayJSON `json:"sat,omitempty"`
+}
+
+// dayJSON is the JSON representation of dayRange.  Start and End are the
+// offsets from the beginning of the day in milliseconds.
+type dayJSON struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// type check
+var _ json.Marshaler = (*Weekly)(nil)
+
+// MarshalJSON implements the [json.Marshaler] interface for *Weekly.
+func (w *Weekly) MarshalJSON() (b []byte, err error) {
+	days := [7][]dayJSON{}
+	for i, drs := range w.days {
+		for _, r := range drs {
+			days[i] = append(days[i], dayJSON{
+				Start: r.start.Milliseconds(),
+				End:   r.end.Milliseconds(),
+			})
+		}
+	}
+
+	wj := &weeklyJSON{
+		TimeZone:  w.location.String(),
+		Sunday:    days[time.Sunday],
+		Monday:    days[time.Monday],
+		Tuesday:   days[time.Tuesday],
+		Wednesday: days[time.Wednesday],
+		Thursday:  days[time.Thursday],
+		Friday:    days[time.Friday],
+		Saturday:  days[time.Saturday],
+	}
+
+	if w.weekStart != time.Sunday {
+		wj.WeekStart = dayKeys[w.weekStart]
+	}
+
+	if w.weekParity != weekParityAll {
+		wj.WeekParity = w.weekParity.String()
+	}
+
+	return json.Marshal(wj)
+}
+
+// type check
+var _ json.Unmarshaler = (*Weekly)(nil)
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for *Weekly.  The
+// schedule is validated the same way as in [Weekly.UnmarshalYAML].
+func (w *Weekly) UnmarshalJSON(b []byte) (err error) {
+	wj := &weeklyJSON{}
+	err = json.Unmarshal(b, wj)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	days := [7]dayRangesConfig{}
+	for i, djs := range [7][]dayJSON{
+		time.Sunday:    wj.Sunday,
+		time.Monday:    wj.Monday,
+		time.Tuesday:   wj.Tuesday,
+		time.Wednesday: wj.Wednesday,
+		time.Thursday:  wj.Thursday,
+		time.Friday:    wj.Friday,
+		time.Saturday:  wj.Saturday,
+	} {
+		for _, dj := range djs {
+			days[i] = append(days[i], dayConfig{
+				Start: dayTime{offset: time.Duration(dj.Start) * time.Millisecond},
+				End:   dayTime{offset: time.Duration(dj.End) * time.Millisecond},
+			})
+		}
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return w.fromConfig(wj.TimeZone, wj.WeekStart, wj.WeekParity, days, nil)
+}
+
+// orderedDays returns the days of the week starting from the first day of the
+// week of w.
+func (w *Weekly) orderedDays() (days [7]time.Weekday) {
+	for i := range days {
+		days[i] = (w.weekStart + time.Weekday(i)) % 7
+	}
+
+	return days
+}
+
+// Describe returns a human-readable description of the schedule.  The days are
+// listed in the order starting from the first day of the week, and the days
+// without ranges are omitted.  The week parity is mentioned if set.  For
+// example:
+//
+//	Mon 09:00-12:00, 13:00-18:00, Sun 12:00-14:00 (Europe/Brussels)
+//	Sat 00:00-24:00, odd weeks (UTC)
+func (w *Weekly) Describe() (s string) {
+	var ranges []string
+	for _, wd := range w.orderedDays() {
+		for i, r := range w.days[wd] {
+			if i == 0 {
+				ranges = append(ranges, fmt.Sprintf("%s %s", wd.String()[:3], r))
+			} else {
+				ranges = append(ranges, r.String())
+			}
+		}
+	}
+
+	if len(ranges) == 0 {
+		return fmt.Sprintf("empty (%s)", w.location)
+	}
+
+	s = strings.Join(ranges, ", ")
+	if w.weekParity != weekParityAll {
+		s = fmt.Sprintf("%s, %s weeks", s, w.weekParity)
+	}
+
+	return fmt.Sprintf("%s (%s)", s, w.location)
+}
+
+// Warnings returns the descriptions of the suspicious patterns in the schedule,
+// which are likely to be configuration mistakes.  Those are:
+//
+//   - every day is covered fully, so the schedule is always active;
+//   - a day range with both start and end set to 00:00, which is ignored;
+//   - a day range, which is only one minute long.
+//
+// The warnings are advisory, since the schedule itself is valid.
+func (w *Weekly) Warnings() (warns []string) {
+	full := true
+	for _, wd := range w.orderedDays() {
+		drs := w.days[wd]
+
+		merged := drs.union(nil)
+		if len(merged) != 1 || merged[0] != (dayRange{start: 0, end: maxDayRange}) {
+			full = false
+		}
+
+		if w.ignoredEmpty[wd] {
+			warns = append(warns, fmt.Sprintf(
+				"weekday %s: day range 00:00-00:00 is ignored",
+				wd,
+			))
+		}
+
+		for _, r := range drs {
+			if r.end-r.start == time.Minute {
+				warns = append(warns, fmt.Sprintf(
+					"weekday %s: day range %s is only one minute long",
+					wd,
+					r,
+				))
+			}
+		}
+	}
+
+	if full {
+		warns = append([]string{"schedule covers every day fully and is always active"}, warns...)
+	}
+
+	return warns
+}
+
+// clockTime formats the offset from the beginning of the day as a clock time
+// in the HH:MM format.
+func clockTime(offset time.Duration) (s string) {
+	h := offset / time.Hour
+	m := (offset % time.Hour) / time.Minute
+
+	return fmt.Sprintf("%02d:%02d", h, m)
 }
 
 // dayRange represents a single interval within a day.  The interval begins at
@@ -248,3 +1029,93 @@ func (r dayRange) validate() (err error) {
 func (r *dayRange) contains(offset time.Duration) (ok bool) {
 	return r.start <= offset && offset < r.end
 }
+
+// type check
+var _ fmt.Stringer = dayRange{}
+
+// String implements the [fmt.Stringer] interface for dayRange.  The range is
+// formatted as clock times, for example "09:00-18:30".
+func (r dayRange) String() (s string) {
+	return fmt.Sprintf("%s-%s", clockTime(r.start), clockTime(r.end))
+}
+
+// dayRangeSortsBefore returns true if a sorts before b.  It's used to sort the
+// day ranges by their start.
+func dayRangeSortsBefore(a, b dayRange) (sortsBefore bool) {
+	return a.start < b.start
+}
+
+// dayRanges are the non-empty and non-overlapping ranges within a day sorted
+// by their start.
+type dayRanges []dayRange
+
+// clone returns a deep copy of drs.
+func (drs dayRanges) clone() (c dayRanges) {
+	if drs == nil {
+		return nil
+	}
+
+	return append(dayRanges{}, drs...)
+}
+
+// contains returns true if any of the ranges contains offset, where offset is
+// the time duration from the beginning of the day.
+func (drs dayRanges) contains(offset time.Duration) (ok bool) {
+	for _, r := range drs {
+		if r.contains(offset) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// union returns the ranges covering both drs and other.  The overlapping and
+// adjacent ranges are merged.
+func (drs dayRanges) union(other dayRanges) (u dayRanges) {
+	all := append(drs.clone(), other...)
+	slices.SortFunc(all, dayRangeSortsBefore)
+
+	for _, r := range all {
+		last := len(u) - 1
+		if last >= 0 && r.start <= u[last].end {
+			if r.end > u[last].end {
+				u[last].end = r.end
+			}
+
+			continue
+		}
+
+		u = append(u, r)
+	}
+
+	return u
+}
+
+// intersection returns the ranges covered by both drs and other.
+func (drs dayRanges) intersection(other dayRanges) (is dayRanges) {
+	for i, j := 0, 0; i < len(drs) && j < len(other); {
+		a, b := drs[i], other[j]
+
+		r := a
+		if b.start > r.start {
+			r.start = b.start
+		}
+
+		if b.end < r.end {
+			r.end = b.end
+		}
+
+		if r.start < r.end {
+			is = append(is, r)
+		}
+
+		if a.end < b.end {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return is
+}
@@ -1,6 +1,7 @@
 package schedule
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -21,24 +22,24 @@ func TestWeekly_Contains(t *testing.T) {
 
 	// baseSchedule, 12:00 to 14:00.
 	baseSchedule := &Weekly{
-		days: [7]dayRange{
-			time.Friday: {start: 12 * time.Hour, end: 14 * time.Hour},
+		days: [7]dayRanges{
+			time.Friday: {{start: 12 * time.Hour, end: 14 * time.Hour}},
 		},
 		location: time.UTC,
 	}
 
 	// allDaySchedule, 00:00 to 24:00.
 	allDaySchedule := &Weekly{
-		days: [7]dayRange{
-			time.Friday: {start: 0, end: 24 * time.Hour},
+		days: [7]dayRanges{
+			time.Friday: {{start: 0, end: 24 * time.Hour}},
 		},
 		location: time.UTC,
 	}
 
 	// oneMinSchedule, 00:00 to 00:01.
 	oneMinSchedule := &Weekly{
-		days: [7]dayRange{
-			time.Friday: {start: 0, end: 1 * time.Minute},
+		days: [7]dayRanges{
+			time.Friday: {{start: 0, end: 1 * time.Minute}},
 		},
 		location: time.UTC,
 	}
@@ -147,6 +148,48 @@ time_zone: "bad_timezone"
 		badYAML = `
 yaml: "bad"
 yaml: "bad"
+`
+		badWeekStart = `
+week_start: "tue"
+`
+		multipleRanges = `
+time_zone: UTC
+mon:
+  - start: 13h
+    end: 18h
+  - start: 9h
+    end: 12h
+`
+		overlappingRanges = `
+mon:
+  - start: 9h
+    end: 13h
+  - start: 12h
+    end: 18h
+`
+		shortcuts = `
+time_zone: UTC
+mon: all
+tue: none
+wed:
+  - all
+`
+		mixedShortcut = `
+mon:
+  - all
+  - start: 9h
+    end: 12h
+`
+		badShortcut = `
+mon: some
+`
+		severalBadDays = `
+sun:
+    start: 9h
+    end: 9h
+tue:
+    start: -1h
+    end: 1h
 `
 	)
 
@@ -154,10 +197,10 @@ yaml: "bad"
 	require.NoError(t, err)
 
 	brusselsWeekly := &Weekly{
-		days: [7]dayRange{{
+		days: [7]dayRanges{{{
 			start: time.Hour * 12,
 			end:   time.Hour * 14,
-		}},
+		}}},
 		location: brusseltsTZ,
 	}
 
@@ -191,6 +234,13 @@ yaml: "bad"
 		wantErrMsg: "weekday Sunday: bad day range: start -1h0m0s is negative",
 		data:       []byte(negativeStart),
 		want:       &Weekly{},
+	}, {
+		name: "several_bad_days",
+		wantErrMsg: `bad day ranges: 2 errors: ` +
+			`"weekday Sunday: bad day range: start 9h0m0s is greater or equal to end 9h0m0s", ` +
+			`"weekday Tuesday: bad day range: start -1h0m0s is negative"`,
+		data: []byte(severalBadDays),
+		want: &Weekly{},
 	}, {
 		name:       "bad_time_zone",
 		wantErrMsg: "unknown time zone bad_timezone",
@@ -201,6 +251,48 @@ yaml: "bad"
 		wantErrMsg: "yaml: unmarshal errors:\n  line 3: mapping key \"yaml\" already defined at line 2",
 		data:       []byte(badYAML),
 		want:       &Weekly{},
+	}, {
+		name:       "bad_week_start",
+		wantErrMsg: "week start: unsupported value \"tue\"",
+		data:       []byte(badWeekStart),
+		want:       &Weekly{},
+	}, {
+		name:       "multiple_ranges",
+		wantErrMsg: "",
+		data:       []byte(multipleRanges),
+		want: &Weekly{
+			days: [7]dayRanges{time.Monday: {
+				{start: time.Hour * 9, end: time.Hour * 12},
+				{start: time.Hour * 13, end: time.Hour * 18},
+			}},
+			location: time.UTC,
+		},
+	}, {
+		name:       "overlapping_ranges",
+		wantErrMsg: "weekday Monday: day range 12:00-18:00 overlaps with 09:00-13:00",
+		data:       []byte(overlappingRanges),
+		want:       &Weekly{},
+	}, {
+		name:       "shortcuts",
+		wantErrMsg: "",
+		data:       []byte(shortcuts),
+		want: &Weekly{
+			days: [7]dayRanges{
+				time.Monday:    {{start: 0, end: maxDayRange}},
+				time.Wednesday: {{start: 0, end: maxDayRange}},
+			},
+			location: time.UTC,
+		},
+	}, {
+		name:       "mixed_shortcut",
+		wantErrMsg: "day shortcut can't be mixed with day ranges",
+		data:       []byte(mixedShortcut),
+		want:       &Weekly{},
+	}, {
+		name:       "bad_shortcut",
+		wantErrMsg: `unsupported day value "some", want "all", "none", or a day range`,
+		data:       []byte(badShortcut),
+		want:       &Weekly{},
 	}}
 
 	for _, tc := range testCases {
@@ -219,10 +311,10 @@ func TestWeekly_MarshalYAML(t *testing.T) {
 	require.NoError(t, err)
 
 	brusselsWeekly := &Weekly{
-		days: [7]dayRange{time.Sunday: {
+		days: [7]dayRanges{time.Sunday: {{
 			start: time.Hour * 12,
 			end:   time.Hour * 14,
-		}},
+		}}},
 		location: brusselsTZ,
 	}
 
@@ -259,6 +351,771 @@ func TestWeekly_MarshalYAML(t *testing.T) {
 	}
 }
 
+func TestWeekly_MarshalYAML_emptyDays(t *testing.T) {
+	testCases := []struct {
+		w    *Weekly
+		name string
+		want string
+	}{{
+		w:    &Weekly{location: time.UTC},
+		name: "empty",
+		want: "time_zone: UTC\n",
+	}, {
+		w: &Weekly{
+			days: [7]dayRanges{
+				time.Monday:  {{}},
+				time.Tuesday: {},
+			},
+			location: time.UTC,
+		},
+		name: "zero_ranges",
+		want: "time_zone: UTC\n",
+	}, {
+		w: &Weekly{
+			days: [7]dayRanges{
+				time.Monday:    {{}},
+				time.Wednesday: {{start: time.Hour * 9, end: time.Hour * 18}},
+			},
+			location: time.UTC,
+		},
+		name: "some_days",
+		want: "time_zone: UTC\n" +
+			"wed:\n" +
+			"    start: 9h\n" +
+			"    end: 18h\n",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := yaml.Marshal(tc.w)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, string(data))
+
+			got := &Weekly{}
+			err = yaml.Unmarshal(data, got)
+			require.NoError(t, err)
+
+			for wd, drs := range tc.w.days {
+				want := dayRanges(nil)
+				for _, r := range drs {
+					if r != (dayRange{}) {
+						want = append(want, r)
+					}
+				}
+
+				assert.Equalf(t, want, got.days[wd], "day %s", time.Weekday(wd))
+			}
+
+			assert.Equal(t, tc.w.location, got.location)
+		})
+	}
+}
+
+func TestWeekly_MarshalYAML_weekStart(t *testing.T) {
+	w := &Weekly{
+		days: [7]dayRanges{
+			time.Sunday: {{start: time.Hour * 12, end: time.Hour * 14}},
+			time.Monday: {{start: time.Hour * 9, end: time.Hour * 18}},
+		},
+		location:  time.UTC,
+		weekStart: time.Monday,
+	}
+
+	data, err := yaml.Marshal(w)
+	require.NoError(t, err)
+
+	const want = `time_zone: UTC
+week_start: mon
+mon:
+    start: 9h
+    end: 18h
+sun:
+    start: 12h
+    end: 14h
+`
+	assert.Equal(t, want, string(data))
+
+	got := &Weekly{}
+	err = yaml.Unmarshal(data, got)
+	require.NoError(t, err)
+
+	assert.Equal(t, w, got)
+}
+
+func TestWeekly_MarshalYAML_weekParity(t *testing.T) {
+	w := &Weekly{
+		days: [7]dayRanges{
+			time.Saturday: {{start: 0, end: maxDayRange}},
+		},
+		location:   time.UTC,
+		weekParity: weekParityEven,
+	}
+
+	data, err := yaml.Marshal(w)
+	require.NoError(t, err)
+
+	const want = `time_zone: UTC
+week_parity: even
+sat:
+    start: 0s
+    end: 24h
+`
+	assert.Equal(t, want, string(data))
+
+	got := &Weekly{}
+	err = yaml.Unmarshal(data, got)
+	require.NoError(t, err)
+
+	assert.Equal(t, w, got)
+
+	data, err = json.Marshal(w)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"time_zone": "UTC",
+		"week_parity": "even",
+		"sat": [{"start": 0, "end": 86400000}]
+	}`, string(data))
+
+	got = &Weekly{}
+	err = json.Unmarshal(data, got)
+	require.NoError(t, err)
+
+	assert.Equal(t, w, got)
+
+	err = yaml.Unmarshal([]byte("week_parity: fortnightly"), got)
+	testutil.AssertErrorMsg(t, `week parity: unsupported value "fortnightly"`, err)
+}
+
+func TestWeekly_Contains_weekParity(t *testing.T) {
+	allDay := dayRanges{{start: 0, end: maxDayRange}}
+	newWeekly := func(p weekParity) (w *Weekly) {
+		return &Weekly{
+			days:       [7]dayRanges{allDay, allDay, allDay, allDay, allDay, allDay, allDay},
+			location:   time.UTC,
+			weekParity: p,
+		}
+	}
+
+	even, odd, all := newWeekly(weekParityEven), newWeekly(weekParityOdd), newWeekly(weekParityAll)
+
+	testCases := []struct {
+		time     time.Time
+		name     string
+		wantEven bool
+	}{{
+		// 2023-W23.
+		time:     time.Date(2023, 6, 5, 12, 0, 0, 0, time.UTC),
+		name:     "odd_week",
+		wantEven: false,
+	}, {
+		// 2023-W24.
+		time:     time.Date(2023, 6, 12, 12, 0, 0, 0, time.UTC),
+		name:     "next_even_week",
+		wantEven: true,
+	}, {
+		// 2023-W24, the last moment of Sunday.
+		time:     time.Date(2023, 6, 18, 23, 59, 59, 0, time.UTC),
+		name:     "even_week_end",
+		wantEven: true,
+	}, {
+		// 2023-W25.
+		time:     time.Date(2023, 6, 19, 0, 0, 0, 0, time.UTC),
+		name:     "next_odd_week_start",
+		wantEven: false,
+	}, {
+		// 2021-W52, although it's in the next calendar year.
+		time:     time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+		name:     "iso_year_boundary_even",
+		wantEven: true,
+	}, {
+		// 2022-W01.
+		time:     time.Date(2022, 1, 3, 12, 0, 0, 0, time.UTC),
+		name:     "iso_year_start_odd",
+		wantEven: false,
+	}, {
+		// 2020-W53, since 2020 has 53 ISO weeks.
+		time:     time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC),
+		name:     "week_53",
+		wantEven: false,
+	}, {
+		// 2021-W01, the second odd week in a row.
+		time:     time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC),
+		name:     "after_week_53",
+		wantEven: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantEven, even.Contains(tc.time))
+			assert.Equal(t, !tc.wantEven, odd.Contains(tc.time))
+			assert.True(t, all.Contains(tc.time))
+		})
+	}
+
+	t.Run("time_zone", func(t *testing.T) {
+		// 2023-06-18 23:30 UTC is Sunday of 2023-W24 in UTC, but it's already
+		// Monday of 2023-W25 in UTC+03:00.
+		tm := time.Date(2023, 6, 18, 23, 30, 0, 0, time.UTC)
+
+		w := newWeekly(weekParityEven)
+		assert.True(t, w.Contains(tm))
+
+		w.location = time.FixedZone("Etc/GMT-3", 3*60*60)
+		assert.False(t, w.Contains(tm))
+	})
+}
+
+func TestWeekly_Contains_endOfDay(t *testing.T) {
+	// 2023-06-05 is a Monday.
+	lastSecond := time.Date(2023, time.June, 5, 23, 59, 59, 0, time.UTC)
+	nextDay := time.Date(2023, time.June, 6, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{{
+		name: "duration",
+		data: []byte("time_zone: UTC\nmon:\n  start: 22h\n  end: 24h\n"),
+	}, {
+		name: "clock_time",
+		data: []byte("time_zone: UTC\nmon:\n  start: '22:00'\n  end: '24:00'\n"),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &Weekly{}
+			err := yaml.Unmarshal(tc.data, w)
+			require.NoError(t, err)
+
+			assert.True(t, w.Contains(lastSecond))
+			assert.True(t, w.Contains(lastSecond.Add(time.Second-1)))
+			assert.False(t, w.Contains(nextDay))
+		})
+	}
+}
+
+func TestWeekly_MarshalYAML_multipleRanges(t *testing.T) {
+	w := &Weekly{
+		days: [7]dayRanges{
+			time.Monday: {
+				{start: time.Hour * 9, end: time.Hour * 12},
+				{start: time.Hour * 13, end: time.Hour * 18},
+			},
+			time.Tuesday: {{start: time.Hour * 9, end: time.Hour * 18}},
+		},
+		location: time.UTC,
+	}
+
+	data, err := yaml.Marshal(w)
+	require.NoError(t, err)
+
+	const want = `time_zone: UTC
+mon:
+    - start: 9h
+      end: 12h
+    - start: 13h
+      end: 18h
+tue:
+    start: 9h
+    end: 18h
+`
+	assert.Equal(t, want, string(data))
+
+	got := &Weekly{}
+	err = yaml.Unmarshal(data, got)
+	require.NoError(t, err)
+
+	assert.Equal(t, w, got)
+}
+
+func TestWeekly_UnmarshalYAML_clockTimes(t *testing.T) {
+	want := &Weekly{
+		days: [7]dayRanges{
+			time.Monday:   {{start: time.Hour * 9, end: time.Hour*17 + time.Minute*30}},
+			time.Saturday: {{start: time.Hour*7 + time.Minute*5, end: maxDayRange}},
+		},
+		location:   time.UTC,
+		clockTimes: true,
+	}
+
+	const conf = `time_zone: UTC
+mon:
+    start: "09:00"
+    end: "17:30"
+sat:
+    start: "7:05"
+    end: "24:00"
+`
+
+	got := &Weekly{}
+	err := yaml.Unmarshal([]byte(conf), got)
+	require.NoError(t, err)
+
+	assert.Equal(t, want, got)
+
+	data, err := yaml.Marshal(got)
+	require.NoError(t, err)
+
+	const wantData = `time_zone: UTC
+mon:
+    start: "09:00"
+    end: "17:30"
+sat:
+    start: "07:05"
+    end: "24:00"
+`
+	assert.Equal(t, wantData, string(data))
+
+	t.Run("mixed", func(t *testing.T) {
+		const mixedConf = `time_zone: UTC
+mon:
+    start: 9h
+    end: "17:30"
+`
+
+		w := &Weekly{}
+		err = yaml.Unmarshal([]byte(mixedConf), w)
+		require.NoError(t, err)
+
+		assert.True(t, w.clockTimes)
+		assert.Equal(t, dayRanges{{start: time.Hour * 9, end: time.Hour*17 + time.Minute*30}}, w.days[time.Monday])
+	})
+
+	t.Run("durations", func(t *testing.T) {
+		const durConf = `time_zone: UTC
+mon:
+    start: 9h
+    end: 17h30m
+`
+
+		w := &Weekly{}
+		err = yaml.Unmarshal([]byte(durConf), w)
+		require.NoError(t, err)
+
+		assert.False(t, w.clockTimes)
+	})
+}
+
+func TestWeekly_UnmarshalYAML_defaultDay(t *testing.T) {
+	workDay := dayRanges{{start: time.Hour * 9, end: time.Hour * 17}}
+
+	want := &Weekly{
+		days: [7]dayRanges{
+			time.Monday:    {{start: time.Hour * 10, end: time.Hour * 17}},
+			time.Tuesday:   workDay,
+			time.Wednesday: workDay,
+			time.Thursday:  workDay,
+			time.Friday:    {{start: time.Hour * 9, end: time.Hour * 13}},
+		},
+		defaultDay: workDay,
+		location:   time.UTC,
+		fromDefault: [7]bool{
+			time.Tuesday:   true,
+			time.Wednesday: true,
+			time.Thursday:  true,
+		},
+	}
+
+	const conf = `time_zone: UTC
+default:
+    start: 9h
+    end: 17h
+sun: none
+mon:
+    start: 10h
+    end: 17h
+fri:
+    start: 9h
+    end: 13h
+sat: none
+`
+
+	got := &Weekly{}
+	err := yaml.Unmarshal([]byte(conf), got)
+	require.NoError(t, err)
+
+	assert.Equal(t, want, got)
+
+	// 2023-06-06 is a Tuesday, 2023-06-10 is a Saturday.
+	assert.True(t, got.Contains(time.Date(2023, 6, 6, 10, 0, 0, 0, time.UTC)))
+	assert.False(t, got.Contains(time.Date(2023, 6, 6, 18, 0, 0, 0, time.UTC)))
+	assert.False(t, got.Contains(time.Date(2023, 6, 5, 9, 30, 0, 0, time.UTC)))
+	assert.False(t, got.Contains(time.Date(2023, 6, 9, 14, 0, 0, 0, time.UTC)))
+	assert.False(t, got.Contains(time.Date(2023, 6, 10, 10, 0, 0, 0, time.UTC)))
+
+	t.Run("marshal", func(t *testing.T) {
+		data, marshalErr := yaml.Marshal(got)
+		require.NoError(t, marshalErr)
+
+		const wantData = `time_zone: UTC
+default:
+    start: 9h
+    end: 17h
+sun: none
+mon:
+    start: 10h
+    end: 17h
+fri:
+    start: 9h
+    end: 13h
+sat: none
+`
+		assert.Equal(t, wantData, string(data))
+
+		w := &Weekly{}
+		err = yaml.Unmarshal(data, w)
+		require.NoError(t, err)
+
+		assert.Equal(t, got, w)
+	})
+
+	t.Run("all_days_default", func(t *testing.T) {
+		w := &Weekly{}
+		err = yaml.Unmarshal([]byte("time_zone: UTC\ndefault: all\n"), w)
+		require.NoError(t, err)
+
+		assert.Equal(t, FullWeekly().days, w.days)
+	})
+
+	t.Run("bad_default", func(t *testing.T) {
+		const badConf = `time_zone: UTC
+default:
+    start: 9h
+    end: 25h
+mon:
+    start: 10h
+    end: 9h
+`
+
+		w := &Weekly{}
+		err = yaml.Unmarshal([]byte(badConf), w)
+		testutil.AssertErrorMsg(
+			t,
+			"bad day ranges: 2 errors: "+
+				`"default day: bad day range: end 25h0m0s is greater than 24h0m0s", `+
+				`"weekday Monday: bad day range: `+
+				`start 10h0m0s is greater or equal to end 9h0m0s"`,
+			err,
+		)
+	})
+}
+
+func TestParseClockTime(t *testing.T) {
+	testCases := []struct {
+		in         string
+		name       string
+		wantErrMsg string
+		want       time.Duration
+	}{{
+		in:         "00:00",
+		name:       "midnight",
+		wantErrMsg: "",
+		want:       0,
+	}, {
+		in:         "9:15",
+		name:       "single_digit_hours",
+		wantErrMsg: "",
+		want:       time.Hour*9 + time.Minute*15,
+	}, {
+		in:         "23:59",
+		name:       "last_minute",
+		wantErrMsg: "",
+		want:       time.Hour*23 + time.Minute*59,
+	}, {
+		in:         "24:00",
+		name:       "end_of_day",
+		wantErrMsg: "",
+		want:       maxDayRange,
+	}, {
+		in:         "24:01",
+		name:       "after_end_of_day",
+		wantErrMsg: `bad clock time "24:01": hours: out of range: 24`,
+		want:       0,
+	}, {
+		in:         "25:00",
+		name:       "bad_hours",
+		wantErrMsg: `bad clock time "25:00": hours: out of range: 25`,
+		want:       0,
+	}, {
+		in:         "12:60",
+		name:       "bad_minutes",
+		wantErrMsg: `bad clock time "12:60": minutes: out of range: 60`,
+		want:       0,
+	}, {
+		in:         "12:5",
+		name:       "short_minutes",
+		wantErrMsg: `bad clock time "12:5": want HH:MM`,
+		want:       0,
+	}, {
+		in:         "12:00:00",
+		name:       "seconds",
+		wantErrMsg: `bad clock time "12:00:00": want HH:MM`,
+		want:       0,
+	}, {
+		in:         "-1:00",
+		name:       "negative",
+		wantErrMsg: `bad clock time "-1:00": hours: bad digit '-'`,
+		want:       0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseClockTime(tc.in)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWeekly_MarshalJSON(t *testing.T) {
+	brusselsTZ, err := time.LoadLocation("Europe/Brussels")
+	require.NoError(t, err)
+
+	w := &Weekly{
+		days: [7]dayRanges{
+			time.Sunday: {{
+				start: time.Hour * 12,
+				end:   time.Hour * 14,
+			}},
+			time.Monday: {{
+				start: time.Hour * 9,
+				end:   time.Hour * 12,
+			}, {
+				start: time.Hour * 13,
+				end:   time.Hour*18 + time.Minute*30,
+			}},
+		},
+		location:  brusselsTZ,
+		weekStart: time.Monday,
+	}
+
+	data, err := json.Marshal(w)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"time_zone": "Europe/Brussels",
+		"week_start": "mon",
+		"sun": [{"start": 43200000, "end": 50400000}],
+		"mon": [
+			{"start": 32400000, "end": 43200000},
+			{"start": 46800000, "end": 66600000}
+		]
+	}`, string(data))
+
+	got := &Weekly{}
+	err = json.Unmarshal(data, got)
+	require.NoError(t, err)
+
+	assert.Equal(t, w, got)
+}
+
+func TestWeekly_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       string
+		wantErrMsg string
+	}{{
+		name: "bad_json",
+		data: `{"time_zone": 1}`,
+		wantErrMsg: "json: cannot unmarshal number into Go struct field " +
+			"weeklyJSON.time_zone of type string",
+	}, {
+		name:       "bad_tz",
+		data:       `{"time_zone": "bad_timezone"}`,
+		wantErrMsg: "unknown time zone bad_timezone",
+	}, {
+		name:       "bad_week_start",
+		data:       `{"time_zone": "UTC", "week_start": "tue"}`,
+		wantErrMsg: `week start: unsupported value "tue"`,
+	}, {
+		name: "negative_start",
+		data: `{"time_zone": "UTC", "sun": [{"start": -1, "end": 1}]}`,
+		wantErrMsg: "weekday Sunday: bad day range: " +
+			"start -1ms is negative",
+	}, {
+		name: "not_rounded",
+		data: `{"time_zone": "UTC", "sun": [{"start": 1, "end": 60000}]}`,
+		wantErrMsg: "weekday Sunday: bad day range: " +
+			"start 1ms isn't rounded to minutes",
+	}, {
+		name: "too_long",
+		data: `{"time_zone": "UTC", "sun": [{"start": 0, "end": 86460000}]}`,
+		wantErrMsg: "weekday Sunday: bad day range: " +
+			"end 24h1m0s is greater than 24h0m0s",
+	}, {
+		name: "overlapping",
+		data: `{"time_zone": "UTC", "mon": [` +
+			`{"start": 32400000, "end": 46800000}, ` +
+			`{"start": 43200000, "end": 64800000}` +
+			`]}`,
+		wantErrMsg: "weekday Monday: day range 12:00-18:00 overlaps with " +
+			"09:00-13:00",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &Weekly{}
+			err := json.Unmarshal([]byte(tc.data), w)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
+
+func TestWeekly_Describe(t *testing.T) {
+	days := [7]dayRanges{
+		time.Sunday:    {{start: time.Hour * 12, end: time.Hour * 14}},
+		time.Monday:    {{start: time.Hour * 9, end: time.Hour*18 + time.Minute*30}},
+		time.Wednesday: {{start: 0, end: maxDayRange}},
+	}
+
+	testCases := []struct {
+		name       string
+		want       string
+		days       [7]dayRanges
+		weekStart  time.Weekday
+		weekParity weekParity
+	}{{
+		name:      "sunday",
+		want:      "Sun 12:00-14:00, Mon 09:00-18:30, Wed 00:00-24:00 (UTC)",
+		days:      days,
+		weekStart: time.Sunday,
+	}, {
+		name:      "monday",
+		want:      "Mon 09:00-18:30, Wed 00:00-24:00, Sun 12:00-14:00 (UTC)",
+		days:      days,
+		weekStart: time.Monday,
+	}, {
+		name:      "empty",
+		want:      "empty (UTC)",
+		days:      [7]dayRanges{},
+		weekStart: time.Monday,
+	}, {
+		name: "multiple_ranges",
+		want: "Mon 09:00-12:00, 13:00-18:00 (UTC)",
+		days: [7]dayRanges{time.Monday: {
+			{start: time.Hour * 9, end: time.Hour * 12},
+			{start: time.Hour * 13, end: time.Hour * 18},
+		}},
+		weekStart: time.Sunday,
+	}, {
+		name:       "week_parity",
+		want:       "Sat 00:00-24:00, odd weeks (UTC)",
+		days:       [7]dayRanges{time.Saturday: {{start: 0, end: maxDayRange}}},
+		weekStart:  time.Sunday,
+		weekParity: weekParityOdd,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &Weekly{
+				days:       tc.days,
+				location:   time.UTC,
+				weekStart:  tc.weekStart,
+				weekParity: tc.weekParity,
+			}
+
+			assert.Equal(t, tc.want, w.Describe())
+		})
+	}
+}
+
+func TestWeekly_Warnings(t *testing.T) {
+	const (
+		normal = `
+time_zone: UTC
+mon:
+    start: 9h
+    end: 18h
+`
+		allDays = `
+time_zone: UTC
+sun: all
+mon: all
+tue: all
+wed: all
+thu: all
+fri: all
+sat: all
+`
+		allDaysRanges = `
+time_zone: UTC
+sun: all
+mon:
+  - start: 0s
+    end: 12h
+  - start: 12h
+    end: 24h
+tue: all
+wed: all
+thu: all
+fri: all
+sat: all
+`
+		emptyRange = `
+time_zone: UTC
+mon:
+    start: 0s
+    end: 0s
+tue: none
+`
+		oneMinute = `
+time_zone: UTC
+week_start: mon
+sun:
+    start: 9h
+    end: 9h1m
+mon:
+  - start: 9h
+    end: 18h
+  - start: 23h59m
+    end: 24h
+`
+	)
+
+	testCases := []struct {
+		name string
+		data string
+		want []string
+	}{{
+		name: "normal",
+		data: normal,
+		want: nil,
+	}, {
+		name: "all_days",
+		data: allDays,
+		want: []string{"schedule covers every day fully and is always active"},
+	}, {
+		name: "all_days_ranges",
+		data: allDaysRanges,
+		want: []string{"schedule covers every day fully and is always active"},
+	}, {
+		name: "empty_range",
+		data: emptyRange,
+		want: []string{"weekday Monday: day range 00:00-00:00 is ignored"},
+	}, {
+		name: "one_minute",
+		data: oneMinute,
+		want: []string{
+			"weekday Monday: day range 23:59-24:00 is only one minute long",
+			"weekday Sunday: day range 09:00-09:01 is only one minute long",
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &Weekly{}
+			err := yaml.Unmarshal([]byte(tc.data), w)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, w.Warnings())
+			assert.Equal(t, tc.want, w.Clone().Warnings())
+		})
+	}
+}
+
 func TestWeekly_Validate(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -369,3 +1226,399 @@ func TestDayRange_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestWeekly_Union(t *testing.T) {
+	w := &Weekly{
+		days: [7]dayRanges{
+			time.Sunday: {{start: time.Hour * 9, end: time.Hour * 12}},
+			time.Monday: {{start: time.Hour * 9, end: time.Hour * 12}},
+			time.Friday: {{start: time.Hour * 9, end: time.Hour * 12}},
+		},
+		location:  time.UTC,
+		weekStart: time.Monday,
+	}
+
+	testCases := []struct {
+		other      *Weekly
+		want       *Weekly
+		name       string
+		wantErrMsg string
+	}{{
+		other: &Weekly{
+			days: [7]dayRanges{
+				time.Sunday: {{start: time.Hour * 11, end: time.Hour * 14}},
+				time.Monday: {{start: time.Hour * 12, end: time.Hour * 13}},
+			},
+			location: time.UTC,
+		},
+		want: &Weekly{
+			days: [7]dayRanges{
+				time.Sunday: {{start: time.Hour * 9, end: time.Hour * 14}},
+				time.Monday: {{start: time.Hour * 9, end: time.Hour * 13}},
+				time.Friday: {{start: time.Hour * 9, end: time.Hour * 12}},
+			},
+			location:  time.UTC,
+			weekStart: time.Monday,
+		},
+		name:       "overlapping",
+		wantErrMsg: "",
+	}, {
+		other: &Weekly{
+			days: [7]dayRanges{
+				time.Sunday:   {{start: time.Hour * 14, end: time.Hour * 18}},
+				time.Saturday: {{start: 0, end: maxDayRange}},
+			},
+			location: time.UTC,
+		},
+		want: &Weekly{
+			days: [7]dayRanges{
+				time.Sunday: {
+					{start: time.Hour * 9, end: time.Hour * 12},
+					{start: time.Hour * 14, end: time.Hour * 18},
+				},
+				time.Monday:   {{start: time.Hour * 9, end: time.Hour * 12}},
+				time.Friday:   {{start: time.Hour * 9, end: time.Hour * 12}},
+				time.Saturday: {{start: 0, end: maxDayRange}},
+			},
+			location:  time.UTC,
+			weekStart: time.Monday,
+		},
+		name:       "disjoint",
+		wantErrMsg: "",
+	}, {
+		other: &Weekly{
+			location: time.Local,
+		},
+		want:       nil,
+		name:       "different_time_zones",
+		wantErrMsg: `time zones "UTC" and "Local" differ`,
+	}, {
+		other: &Weekly{
+			location:   time.UTC,
+			weekParity: weekParityOdd,
+		},
+		want:       nil,
+		name:       "different_week_parities",
+		wantErrMsg: `week parities "all" and "odd" differ`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := w.Union(tc.other)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, u)
+		})
+	}
+}
+
+func TestWeekly_Intersection(t *testing.T) {
+	w := &Weekly{
+		days: [7]dayRanges{
+			time.Sunday: {
+				{start: time.Hour * 9, end: time.Hour * 12},
+				{start: time.Hour * 13, end: time.Hour * 18},
+			},
+			time.Monday: {{start: time.Hour * 9, end: time.Hour * 12}},
+		},
+		location: time.UTC,
+	}
+
+	testCases := []struct {
+		other      *Weekly
+		want       *Weekly
+		name       string
+		wantErrMsg string
+	}{{
+		other: &Weekly{
+			days: [7]dayRanges{
+				time.Sunday: {{start: time.Hour * 11, end: time.Hour * 14}},
+				time.Monday: {{start: time.Hour * 10, end: time.Hour * 11}},
+			},
+			location: time.UTC,
+		},
+		want: &Weekly{
+			days: [7]dayRanges{
+				time.Sunday: {
+					{start: time.Hour * 11, end: time.Hour * 12},
+					{start: time.Hour * 13, end: time.Hour * 14},
+				},
+				time.Monday: {{start: time.Hour * 10, end: time.Hour * 11}},
+			},
+			location: time.UTC,
+		},
+		name:       "overlapping",
+		wantErrMsg: "",
+	}, {
+		other: &Weekly{
+			days: [7]dayRanges{
+				time.Sunday:  {{start: time.Hour * 12, end: time.Hour * 13}},
+				time.Tuesday: {{start: 0, end: maxDayRange}},
+			},
+			location: time.UTC,
+		},
+		want: &Weekly{
+			location: time.UTC,
+		},
+		name:       "disjoint",
+		wantErrMsg: "",
+	}, {
+		other: &Weekly{
+			location: time.Local,
+		},
+		want:       nil,
+		name:       "different_time_zones",
+		wantErrMsg: `time zones "UTC" and "Local" differ`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is, err := w.Intersection(tc.other)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, is)
+		})
+	}
+}
+
+func TestWeekly_NextChange(t *testing.T) {
+	// baseTime is a Friday.
+	baseTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// baseSchedule, 12:00 to 14:00 on Fridays.
+	baseSchedule := &Weekly{
+		days: [7]dayRanges{
+			time.Friday: {{start: 12 * time.Hour, end: 14 * time.Hour}},
+		},
+		location: time.UTC,
+	}
+
+	allDay := dayRanges{{start: 0, end: maxDayRange}}
+
+	brusselsTZ, err := time.LoadLocation("Europe/Brussels")
+	require.NoError(t, err)
+
+	// dstSchedule, 01:00 to 04:00 on Sundays in Brussels, which is when the
+	// clocks are changed.
+	dstSchedule := &Weekly{
+		days: [7]dayRanges{
+			time.Sunday: {{start: 1 * time.Hour, end: 4 * time.Hour}},
+		},
+		location: brusselsTZ,
+	}
+
+	// The clocks are set forward at 02:00 on 2023-03-26 and back at 03:00 on
+	// 2023-10-29, so the days are 23 and 25 hours long.
+	springDay := time.Date(2023, 3, 26, 0, 0, 0, 0, brusselsTZ)
+	autumnDay := time.Date(2023, 10, 29, 0, 0, 0, 0, brusselsTZ)
+
+	testCases := []struct {
+		schedule *Weekly
+		t        time.Time
+		want     time.Time
+		name     string
+		wantOK   bool
+	}{{
+		schedule: EmptyWeekly(),
+		t:        baseTime,
+		want:     time.Time{},
+		name:     "empty",
+		wantOK:   false,
+	}, {
+		schedule: &Weekly{
+			days:     [7]dayRanges{allDay, allDay, allDay, allDay, allDay, allDay, allDay},
+			location: time.UTC,
+		},
+		t:      baseTime,
+		want:   time.Time{},
+		name:   "full",
+		wantOK: false,
+	}, {
+		schedule: baseSchedule,
+		t:        baseTime.Add(10 * time.Hour),
+		want:     baseTime.Add(12 * time.Hour),
+		name:     "before_range",
+		wantOK:   true,
+	}, {
+		schedule: baseSchedule,
+		t:        baseTime.Add(12*time.Hour - time.Nanosecond),
+		want:     baseTime.Add(12 * time.Hour),
+		name:     "just_before_start",
+		wantOK:   true,
+	}, {
+		schedule: baseSchedule,
+		t:        baseTime.Add(12 * time.Hour),
+		want:     baseTime.Add(14 * time.Hour),
+		name:     "at_start",
+		wantOK:   true,
+	}, {
+		schedule: baseSchedule,
+		t:        baseTime.Add(14 * time.Hour),
+		want:     baseTime.Add(7*timeutil.Day + 12*time.Hour),
+		name:     "at_end",
+		wantOK:   true,
+	}, {
+		schedule: &Weekly{
+			days: [7]dayRanges{
+				time.Friday:   {{start: 22 * time.Hour, end: maxDayRange}},
+				time.Saturday: {{start: 0, end: 2 * time.Hour}},
+			},
+			location: time.UTC,
+		},
+		t:      baseTime.Add(23 * time.Hour),
+		want:   baseTime.Add(timeutil.Day + 2*time.Hour),
+		name:   "across_midnight",
+		wantOK: true,
+	}, {
+		schedule: &Weekly{
+			days:     [7]dayRanges{allDay, allDay, allDay, allDay, allDay, allDay, allDay},
+			location: time.UTC,
+			// baseTime is within 2020-W53.
+			weekParity: weekParityEven,
+		},
+		t: baseTime,
+		// 2021-W02 starts on January 11.
+		want:   time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC),
+		name:   "week_parity",
+		wantOK: true,
+	}, {
+		schedule: dstSchedule,
+		t:        springDay,
+		want:     springDay.Add(1 * time.Hour),
+		name:     "dst_forward_start",
+		wantOK:   true,
+	}, {
+		schedule: dstSchedule,
+		t:        springDay.Add(1 * time.Hour),
+		// 05:00 CEST, since the range is measured from the midnight.
+		want:   time.Date(2023, 3, 26, 5, 0, 0, 0, brusselsTZ),
+		name:   "dst_forward_end",
+		wantOK: true,
+	}, {
+		schedule: dstSchedule,
+		t:        autumnDay,
+		want:     autumnDay.Add(1 * time.Hour),
+		name:     "dst_back_start",
+		wantOK:   true,
+	}, {
+		schedule: dstSchedule,
+		t:        autumnDay.Add(1 * time.Hour),
+		// 03:00 CET, since the range is measured from the midnight.
+		want:   time.Date(2023, 10, 29, 3, 0, 0, 0, brusselsTZ),
+		name:   "dst_back_end",
+		wantOK: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.schedule.NextChange(tc.t)
+			require.Equal(t, tc.wantOK, ok)
+
+			assert.True(t, tc.want.Equal(got), "want %s, got %s", tc.want, got)
+			if ok {
+				assert.NotEqual(t, tc.schedule.Contains(tc.t), tc.schedule.Contains(got))
+			}
+		})
+	}
+}
+
+func TestWeekly_RemainingActive(t *testing.T) {
+	// baseTime is a Friday.
+	baseTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	allDay := dayRanges{{start: 0, end: maxDayRange}}
+
+	testCases := []struct {
+		schedule *Weekly
+		t        time.Time
+		name     string
+		want     time.Duration
+		wantOK   bool
+	}{{
+		schedule: EmptyWeekly(),
+		t:        baseTime,
+		name:     "empty",
+		want:     0,
+		wantOK:   false,
+	}, {
+		schedule: &Weekly{
+			days: [7]dayRanges{
+				time.Friday: {{start: 12 * time.Hour, end: 14 * time.Hour}},
+			},
+			location: time.UTC,
+		},
+		t:      baseTime.Add(12*time.Hour + 40*time.Minute),
+		name:   "mid_window",
+		want:   1*time.Hour + 20*time.Minute,
+		wantOK: true,
+	}, {
+		schedule: &Weekly{
+			days: [7]dayRanges{
+				time.Friday: {{start: 12 * time.Hour, end: 14 * time.Hour}},
+			},
+			location: time.UTC,
+		},
+		t:      baseTime.Add(14 * time.Hour),
+		name:   "at_end",
+		want:   0,
+		wantOK: false,
+	}, {
+		schedule: &Weekly{
+			days: [7]dayRanges{
+				time.Friday:   {{start: 22 * time.Hour, end: maxDayRange}},
+				time.Saturday: {{start: 0, end: 2 * time.Hour}},
+			},
+			location: time.UTC,
+		},
+		t:      baseTime.Add(23 * time.Hour),
+		name:   "across_midnight",
+		want:   3 * time.Hour,
+		wantOK: true,
+	}, {
+		schedule: &Weekly{
+			days: [7]dayRanges{
+				time.Friday:   allDay,
+				time.Saturday: allDay,
+				time.Sunday:   {{start: 0, end: 12 * time.Hour}},
+			},
+			location: time.UTC,
+		},
+		t:      baseTime.Add(6 * time.Hour),
+		name:   "continuous_coverage",
+		want:   2*timeutil.Day + 6*time.Hour,
+		wantOK: true,
+	}, {
+		schedule: &Weekly{
+			days: [7]dayRanges{
+				time.Thursday: allDay,
+				time.Friday:   allDay,
+				time.Saturday: allDay,
+				time.Sunday:   allDay,
+				time.Monday:   allDay,
+			},
+			location: time.UTC,
+		},
+		t:      baseTime.Add(-time.Hour),
+		name:   "continuous_coverage_wrap",
+		want:   4*timeutil.Day + time.Hour,
+		wantOK: true,
+	}, {
+		schedule: &Weekly{
+			days:     [7]dayRanges{allDay, allDay, allDay, allDay, allDay, allDay, allDay},
+			location: time.UTC,
+		},
+		t:      baseTime,
+		name:   "full",
+		want:   0,
+		wantOK: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.schedule.RemainingActive(tc.t)
+			require.Equal(t, tc.wantOK, ok)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package whois
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/bluele/gcache"
+)
+
+// infoCache is the cache of WHOIS information about IP addresses, common for
+// all the WHOIS backends.
+type infoCache struct {
+	// cache is the cache containing IP addresses of clients.  An active IP
+	// address is resolved once again after it expires.  If IP address couldn't
+	// be resolved, it stays here for some time to prevent further attempts to
+	// resolve the same IP.
+	cache gcache.Cache
+
+	// ttl is the Time to Live duration for cached IP addresses.
+	ttl time.Duration
+
+	// transientTTL is the Time to Live duration for cached IP addresses, which
+	// couldn't be resolved due to a transient failure.
+	transientTTL time.Duration
+
+	// ttlJitter is the maximum deviation of the Time to Live durations of the
+	// cached IP addresses in percent.
+	ttlJitter int
+}
+
+// newInfoCache returns a new properly initialized *infoCache.  size must be
+// greater than zero, ttlJitter must be valid, see [validateTTLJitter].
+func newInfoCache(size int, ttl, transientTTL time.Duration, ttlJitter int) (c *infoCache) {
+	return &infoCache{
+		cache:        gcache.New(size).LRU().Build(),
+		ttl:          ttl,
+		transientTTL: transientTTL,
+		ttlJitter:    ttlJitter,
+	}
+}
+
+// validateTTLJitter returns an error if jitter isn't a valid percentage of the
+// Time to Live deviation.
+func validateTTLJitter(jitter int) (err error) {
+	if jitter < 0 || jitter >= 100 {
+		return fmt.Errorf("cache ttl jitter: must be in range [0, 100), got %d", jitter)
+	}
+
+	return nil
+}
+
+// queryFunc is the function requesting the WHOIS information about ip.
+type queryFunc func(ctx context.Context, ip netip.Addr) (info Info, err error)
+
+// process returns the cached WHOIS information about ip or requests it using
+// query, if there is none or it has expired.  changed indicates that Info was
+// updated since last request.
+func (c *infoCache) process(
+	ctx context.Context,
+	ip netip.Addr,
+	query queryFunc,
+) (wi *Info, changed bool) {
+	wi, expired := c.find(ip)
+	if wi != nil && !expired {
+		// Don't return an empty struct so that the frontend doesn't get
+		// confused.
+		if (*wi == Info{}) {
+			return nil, false
+		}
+
+		return wi, false
+	}
+
+	return c.request(ctx, ip, wi, query)
+}
+
+// request makes WHOIS request using query and returns WHOIS info.  changed is
+// false if received information is equal to cached.
+func (c *infoCache) request(
+	ctx context.Context,
+	ip netip.Addr,
+	cached *Info,
+	query queryFunc,
+) (wi *Info, changed bool) {
+	info, err := query(ctx, ip)
+	if err != nil {
+		log.Debug("whois: quering about %q: %s", ip, err)
+
+		if !isTransient(err) {
+			c.set(ip, Info{}, c.ttl)
+		} else if c.transientTTL > 0 {
+			c.set(ip, Info{}, c.transientTTL)
+		}
+
+		return nil, true
+	}
+
+	c.set(ip, info, c.ttl)
+
+	changed = cached == nil || info != *cached
+
+	// Don't return an empty struct so that the frontend doesn't get confused.
+	if (info == Info{}) {
+		return nil, changed
+	}
+
+	return &info, changed
+}
+
+// set caches info about ip for ttl changed by a random jitter, so that the
+// addresses resolved together don't expire and get requested again together.
+func (c *infoCache) set(ip netip.Addr, info Info, ttl time.Duration) {
+	err := c.cache.Set(ip, toCacheItem(info, c.jittered(ttl)))
+	if err != nil {
+		log.Debug("whois: cache: adding item %q: %s", ip, err)
+	}
+}
+
+// jittered returns ttl randomly increased or decreased by up to c.ttlJitter
+// percent.
+func (c *infoCache) jittered(ttl time.Duration) (res time.Duration) {
+	maxDelta := int64(ttl) / 100 * int64(c.ttlJitter)
+	if maxDelta <= 0 {
+		return ttl
+	}
+
+	return ttl + time.Duration(rand.Int63n(2*maxDelta+1)-maxDelta)
+}
+
+// flush removes the cached information about ip.  If ip is the zero value, the
+// whole cache is cleared.
+func (c *infoCache) flush(ip netip.Addr) {
+	if ip == (netip.Addr{}) {
+		c.cache.Purge()
+
+		return
+	}
+
+	c.cache.Remove(ip)
+}
+
+// stats returns the statistics of the cache.
+func (c *infoCache) stats() (s *CacheStats) {
+	return &CacheStats{
+		Size:   c.cache.Len(false),
+		Hits:   c.cache.HitCount(),
+		Misses: c.cache.MissCount(),
+	}
+}
+
+// find finds Info in the cache.  expired indicates that Info is valid.
+func (c *infoCache) find(ip netip.Addr) (wi *Info, expired bool) {
+	val, err := c.cache.Get(ip)
+	if err != nil {
+		if !errors.Is(err, gcache.KeyNotFoundError) {
+			log.Debug("whois: cache: retrieving info about %q: %s", ip, err)
+		}
+
+		return nil, false
+	}
+
+	item, ok := val.(*cacheItem)
+	if !ok {
+		log.Debug("whois: cache: %q bad type %T", ip, val)
+
+		return nil, false
+	}
+
+	return fromCacheItem(item)
+}
+
+// cacheItem represents an item that we will store in the cache.
+type cacheItem struct {
+	// expiry is the time when cacheItem will expire.
+	expiry time.Time
+
+	// info is the WHOIS data for a runtime client.
+	info *Info
+}
+
+// toCacheItem creates a cached item from a WHOIS info and Time to Live
+// duration.
+func toCacheItem(info Info, ttl time.Duration) (item *cacheItem) {
+	return &cacheItem{
+		expiry: time.Now().Add(ttl),
+		info:   &info,
+	}
+}
+
+// fromCacheItem creates a WHOIS info from the cached item.  expired indicates
+// that WHOIS info is valid.  item must not be nil.
+func fromCacheItem(item *cacheItem) (info *Info, expired bool) {
+	if time.Now().After(item.expiry) {
+		return item.info, true
+	}
+
+	return item.info, false
+}
@@ -0,0 +1,91 @@
+package whois
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// parseNetwork parses the network from the value of an inetnum, inet6num,
+// NetRange, or CIDR WHOIS field, which is either a range of addresses, like
+// "1.2.3.0 - 1.2.3.255", or a comma-separated list of CIDRs, like
+// "1.2.3.0/24, 1.2.4.0/23".  network is the comma-separated list of the
+// smallest set of CIDRs covering the range.  If s can't be parsed, it returns
+// an empty string.
+func parseNetwork(s string) (network string) {
+	var prefixes []netip.Prefix
+	if startStr, endStr, ok := strings.Cut(s, "-"); ok {
+		start, err := netip.ParseAddr(strings.TrimSpace(startStr))
+		if err != nil {
+			return ""
+		}
+
+		end, err := netip.ParseAddr(strings.TrimSpace(endStr))
+		if err != nil {
+			return ""
+		}
+
+		prefixes = rangeToPrefixes(start, end)
+	} else {
+		for _, pStr := range strings.Split(s, ",") {
+			p, err := netip.ParsePrefix(strings.TrimSpace(pStr))
+			if err != nil {
+				return ""
+			}
+
+			prefixes = append(prefixes, p.Masked())
+		}
+	}
+
+	strs := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		strs = append(strs, p.String())
+	}
+
+	return strings.Join(strs, ", ")
+}
+
+// rangeToPrefixes returns the smallest set of prefixes covering exactly the
+// addresses from start to end inclusively.  It returns nil if start and end are
+// of different families or start is greater than end.
+func rangeToPrefixes(start, end netip.Addr) (prefixes []netip.Prefix) {
+	if start.BitLen() != end.BitLen() || end.Less(start) {
+		return nil
+	}
+
+	for {
+		p := largestPrefix(start, end)
+		prefixes = append(prefixes, p)
+
+		last := lastAddr(p)
+		if last == end {
+			return prefixes
+		}
+
+		start = last.Next()
+	}
+}
+
+// largestPrefix returns the largest prefix starting at start and not containing
+// the addresses greater than end.  end must not be less than start.
+func largestPrefix(start, end netip.Addr) (p netip.Prefix) {
+	for bits := 0; bits < start.BitLen(); bits++ {
+		p = netip.PrefixFrom(start, bits).Masked()
+		if p.Addr() == start && !end.Less(lastAddr(p)) {
+			return p
+		}
+	}
+
+	return netip.PrefixFrom(start, start.BitLen())
+}
+
+// lastAddr returns the last address of p.  p must be masked.
+func lastAddr(p netip.Prefix) (last netip.Addr) {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+
+	last, _ = netip.AddrFromSlice(b)
+
+	return last
+}
@@ -0,0 +1,340 @@
+package whois
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghio"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/stringutil"
+	"golang.org/x/exp/slices"
+)
+
+// DefaultRDAPServer is the default RDAP server.  It redirects the requests to
+// the server of the appropriate regional internet registry.
+//
+// See https://about.rdap.org.
+const DefaultRDAPServer = "https://rdap.org"
+
+// RDAP is the WHOIS information processor using the Registration Data Access
+// Protocol.
+//
+// See RFC 9082 and RFC 9083.
+type RDAP struct {
+	// cache is the cache of the WHOIS information about IP addresses.
+	cache *infoCache
+
+	// client is the HTTP client for RDAP requests.
+	client *http.Client
+
+	// serverURL is the base URL of the RDAP server.
+	serverURL *url.URL
+
+	// countryFormat is the format to convert [Info.Country] into.
+	countryFormat CountryFormat
+
+	// maxConnReadSize is an upper limit in bytes for reading response bodies.
+	maxConnReadSize int64
+
+	// maxInfoLens are the maximum lengths of Info fields returned by Process.
+	maxInfoLens InfoLens
+}
+
+// NewRDAP returns a new RDAP information processor.  conf must not be nil.
+// conf.ServerAddr is the base URL of the RDAP server, [DefaultRDAPServer] is
+// used if it's empty.  conf.Port, conf.ServerPorts, conf.QueryTemplates, and
+// conf.ServersFile are ignored.
+func NewRDAP(conf *Config) (w *RDAP, err error) {
+	err = conf.CountryFormat.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("whois: %w", err)
+	}
+
+	err = validateTTLJitter(conf.CacheTTLJitter)
+	if err != nil {
+		return nil, fmt.Errorf("whois: %w", err)
+	}
+
+	serverURL, err := url.Parse(stringutil.Coalesce(conf.ServerAddr, DefaultRDAPServer))
+	if err != nil {
+		return nil, fmt.Errorf("whois: rdap server: %w", err)
+	} else if serverURL.Scheme != "http" && serverURL.Scheme != "https" {
+		return nil, fmt.Errorf("whois: rdap server: bad scheme %q", serverURL.Scheme)
+	}
+
+	maxRedirects := conf.MaxRedirects
+
+	return &RDAP{
+		cache: newInfoCache(
+			conf.CacheSize,
+			conf.CacheTTL,
+			conf.TransientTTL,
+			conf.CacheTTLJitter,
+		),
+		client: &http.Client{
+			Timeout: conf.Timeout,
+			Transport: &http.Transport{
+				DialContext:     conf.DialContext,
+				TLSClientConfig: conf.TLSConfig,
+			},
+			CheckRedirect: func(_ *http.Request, via []*http.Request) (err error) {
+				if len(via) >= maxRedirects {
+					return ErrRedirectLoop
+				}
+
+				return nil
+			},
+		},
+		serverURL:       serverURL,
+		countryFormat:   conf.CountryFormat,
+		maxConnReadSize: conf.MaxConnReadSize,
+		maxInfoLens:     conf.MaxInfoLens.withDefault(conf.MaxInfoLen),
+	}, nil
+}
+
+// type check
+var _ Interface = (*RDAP)(nil)
+
+// Process implements the [Interface] interface for *RDAP.
+func (w *RDAP) Process(ctx context.Context, ip netip.Addr) (wi *Info, changed bool) {
+	if netutil.IsSpecialPurposeAddr(ip) {
+		return nil, false
+	}
+
+	return w.cache.process(ctx, ip, func(ctx context.Context, ip netip.Addr) (info Info, err error) {
+		return w.query(ctx, w.serverURL, ip)
+	})
+}
+
+// Flush implements the [Interface] interface for *RDAP.
+func (w *RDAP) Flush(ip netip.Addr) {
+	w.cache.flush(ip)
+}
+
+// Stats implements the [Interface] interface for *RDAP.
+func (w *RDAP) Stats() (s *CacheStats) {
+	return w.cache.stats()
+}
+
+// ProcessForced implements the [Interface] interface for *RDAP.  The request
+// is sent to the HTTPS server at server.  The information isn't cached so that
+// it doesn't affect the results of [RDAP.Process].
+func (w *RDAP) ProcessForced(
+	ctx context.Context,
+	ip netip.Addr,
+	server string,
+) (wi *Info, err error) {
+	if netutil.IsSpecialPurposeAddr(ip) {
+		return nil, nil
+	}
+
+	serverURL := &url.URL{
+		Scheme: "https",
+		Host:   strings.ToLower(server),
+	}
+
+	info, err := w.query(ctx, serverURL, ip)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	} else if (info == Info{}) {
+		return nil, nil
+	}
+
+	return &info, nil
+}
+
+// query requests the information about ip from the RDAP server at serverURL.
+func (w *RDAP) query(ctx context.Context, serverURL *url.URL, ip netip.Addr) (info Info, err error) {
+	u := serverURL.JoinPath("ip", ip.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Info{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return Info{}, err
+	}
+	defer func() { err = errors.WithDeferred(err, resp.Body.Close()) }()
+
+	if resp.StatusCode != http.StatusOK {
+		return Info{}, fmt.Errorf("rdap: unexpected status code %d", resp.StatusCode)
+	}
+
+	r, err := aghio.LimitReader(resp.Body, w.maxConnReadSize)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return Info{}, err
+	}
+
+	n := &rdapNetwork{}
+	err = json.NewDecoder(r).Decode(n)
+	if err != nil {
+		return Info{}, fmt.Errorf("rdap: decoding response: %w", err)
+	}
+
+	log.Debug("whois: received rdap response from %q about %q", u.Host, ip)
+
+	return w.newInfo(n), nil
+}
+
+// newInfo returns the WHOIS information from the RDAP IP network object n.
+func (w *RDAP) newInfo(n *rdapNetwork) (info Info) {
+	orgname, city := n.registrant()
+
+	info = Info{
+		City:       trimValue(city, w.maxInfoLens.City),
+		Orgname:    trimValue(stringutil.Coalesce(orgname, n.Name), w.maxInfoLens.Orgname),
+		AbuseEmail: trimValue(n.abuseEmail(), w.maxInfoLens.AbuseEmail),
+	}
+
+	if n.StartAddress != "" && n.EndAddress != "" {
+		info.Network = trimValue(
+			parseNetwork(n.StartAddress+" - "+n.EndAddress),
+			w.maxInfoLens.Network,
+		)
+	}
+
+	if n.Country != "" {
+		info.Country = trimValue(w.countryFormat.normalize(n.Country), w.maxInfoLens.Country)
+	}
+
+	for _, e := range n.Events {
+		switch e.Action {
+		case "registration":
+			info.Created = parseDate(e.Date)
+		case "last changed":
+			info.Updated = parseDate(e.Date)
+		default:
+			// Go on.
+		}
+	}
+
+	return info
+}
+
+// rdapNetwork is the subset of the RDAP IP network object.
+//
+// See RFC 9083, section 5.4.
+type rdapNetwork struct {
+	StartAddress string       `json:"startAddress"`
+	EndAddress   string       `json:"endAddress"`
+	Name         string       `json:"name"`
+	Country      string       `json:"country"`
+	Events       []rdapEvent  `json:"events"`
+	Entities     []rdapEntity `json:"entities"`
+}
+
+// registrant returns the name and the city of the registrant of the network,
+// if any.
+func (n *rdapNetwork) registrant() (name, city string) {
+	for _, e := range n.Entities {
+		if slices.Contains(e.Roles, "registrant") {
+			name, city, _ = e.vCardValues()
+
+			return name, city
+		}
+	}
+
+	return "", ""
+}
+
+// abuseEmail returns the email of the first abuse contact of the network, if
+// any.  The abuse contacts are looked up among the entities of the network and
+// the entities nested into them, since some registries, e.g. ARIN, put the
+// abuse contact into the registrant entity.
+func (n *rdapNetwork) abuseEmail() (email string) {
+	entities := slices.Clone(n.Entities)
+	for _, e := range n.Entities {
+		entities = append(entities, e.Entities...)
+	}
+
+	for _, e := range entities {
+		if !slices.Contains(e.Roles, "abuse") {
+			continue
+		}
+
+		_, _, email = e.vCardValues()
+		if email != "" {
+			return email
+		}
+	}
+
+	return ""
+}
+
+// rdapEvent is the RDAP event object.
+//
+// See RFC 9083, section 4.5.
+type rdapEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+}
+
+// rdapEntity is the subset of the RDAP entity object.
+//
+// See RFC 9083, section 5.1.
+type rdapEntity struct {
+	Roles    []string          `json:"roles"`
+	VCard    []json.RawMessage `json:"vcardArray"`
+	Entities []rdapEntity      `json:"entities"`
+}
+
+// vCardValues returns the formatted name, the locality, and the email of the
+// entity from its jCard, if any.
+//
+// See RFC 7095.
+func (e *rdapEntity) vCardValues() (name, city, email string) {
+	if len(e.VCard) != 2 {
+		return "", "", ""
+	}
+
+	var props [][]json.RawMessage
+	err := json.Unmarshal(e.VCard[1], &props)
+	if err != nil {
+		return "", "", ""
+	}
+
+	for _, p := range props {
+		// Each property is an array of the name, the parameters, the type,
+		// and the value.
+		if len(p) < 4 {
+			continue
+		}
+
+		var propName string
+		if json.Unmarshal(p[0], &propName) != nil {
+			continue
+		}
+
+		switch propName {
+		case "fn":
+			_ = json.Unmarshal(p[3], &name)
+		case "adr":
+			// The locality is the fourth component of the address.
+			var adr []json.RawMessage
+			if json.Unmarshal(p[3], &adr) == nil && len(adr) > 3 {
+				_ = json.Unmarshal(adr[3], &city)
+			}
+		case "email":
+			if email == "" {
+				_ = json.Unmarshal(p[3], &email)
+			}
+		default:
+			// Go on.
+		}
+	}
+
+	return name, city, email
+}
@@ -0,0 +1,156 @@
+package whois_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRDAPResp is the RDAP response used in tests.
+const testRDAPResp = `{
+  "objectClassName": "ip network",
+  "startAddress": "1.2.3.0",
+  "endAddress": "1.2.4.255",
+  "name": "NET-1-2-3-0-1",
+  "country": "US",
+  "events": [{
+    "eventAction": "registration",
+    "eventDate": "2009-03-02T00:00:00Z"
+  }, {
+    "eventAction": "last changed",
+    "eventDate": "2021-12-14T12:00:00+02:00"
+  }],
+  "entities": [{
+    "roles": ["registrant"],
+    "vcardArray": ["vcard", [
+      ["version", {}, "text", "4.0"],
+      ["fn", {}, "text", "Example Org"],
+      ["adr", {}, "text", ["", "", "1 Main St", "Nonreal", "CA", "00000", ""]]
+    ]],
+    "entities": [{
+      "roles": ["abuse"],
+      "vcardArray": ["vcard", [
+        ["version", {}, "text", "4.0"],
+        ["fn", {}, "text", "Abuse Team"],
+        ["email", {}, "text", "abuse@example.org"]
+      ]]
+    }]
+  }]
+}`
+
+func TestRDAP_Process(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+
+		switch r.URL.Path {
+		case "/ip/1.2.3.4":
+			w.Header().Set("Content-Type", "application/rdap+json")
+			_, _ = io.WriteString(w, testRDAPResp)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	w, err := whois.NewInterface(&whois.Config{
+		Backend:         whois.BackendRDAP,
+		ServerAddr:      srv.URL,
+		Timeout:         5 * time.Second,
+		MaxConnReadSize: 64 * 1024,
+		MaxRedirects:    3,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+		CountryFormat:   whois.CountryFormatName,
+	})
+	require.NoError(t, err)
+
+	want := &whois.Info{
+		City:    "Nonreal",
+		Country: "United States",
+		Orgname: "Example Org",
+		Created: "2009-03-02T00:00:00Z",
+		Updated: "2021-12-14T10:00:00Z",
+		Network: "1.2.3.0/24, 1.2.4.0/24",
+
+		AbuseEmail: "abuse@example.org",
+	}
+
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	got, changed := w.Process(context.Background(), ip)
+	require.True(t, changed)
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, 1, hits)
+
+	// From cache.
+	got, changed = w.Process(context.Background(), ip)
+	require.False(t, changed)
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, 1, hits)
+
+	got, changed = w.Process(context.Background(), netip.MustParseAddr("1.2.3.5"))
+	assert.True(t, changed)
+	assert.Nil(t, got)
+	assert.Equal(t, 2, hits)
+}
+
+func TestNewInterface(t *testing.T) {
+	testCases := []struct {
+		name       string
+		conf       *whois.Config
+		wantErrMsg string
+	}{{
+		name: "default",
+		conf: &whois.Config{
+			CacheSize: 1,
+		},
+		wantErrMsg: "",
+	}, {
+		name: "rdap",
+		conf: &whois.Config{
+			Backend:   whois.BackendRDAP,
+			CacheSize: 1,
+		},
+		wantErrMsg: "",
+	}, {
+		name: "rdap_bad_scheme",
+		conf: &whois.Config{
+			Backend:    whois.BackendRDAP,
+			ServerAddr: "whois://whois.arin.net",
+			CacheSize:  1,
+		},
+		wantErrMsg: `whois: rdap server: bad scheme "whois"`,
+	}, {
+		name: "bad_backend",
+		conf: &whois.Config{
+			Backend:   "finger",
+			CacheSize: 1,
+		},
+		wantErrMsg: `whois: unsupported backend "finger"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := whois.NewInterface(tc.conf)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+			if tc.wantErrMsg == "" {
+				assert.NotNil(t, w)
+			} else {
+				assert.Nil(t, w)
+			}
+		})
+	}
+}
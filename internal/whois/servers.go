@@ -0,0 +1,156 @@
+package whois
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// rirServers maps the names of the regional internet registries to the
+// addresses of their WHOIS servers.
+var rirServers = map[string]string{
+	"afrinic": "whois.afrinic.net",
+	"apnic":   "whois.apnic.net",
+	"arin":    "whois.arin.net",
+	"lacnic":  "whois.lacnic.net",
+	"ripe":    "whois.ripe.net",
+}
+
+// rirName returns the name of the regional internet registry, WHOIS server of
+// which has the address addr, in upper case, e.g. "RIPE".  addr may contain a
+// port.  It returns an empty string if addr isn't a known RIR server.
+func rirName(addr string) (name string) {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(host)
+	for rir, rirAddr := range rirServers {
+		if rirAddr == host {
+			return strings.ToUpper(rir)
+		}
+	}
+
+	return ""
+}
+
+// serverRoute is a WHOIS server used for the addresses within a network.
+type serverRoute struct {
+	// server is the address of the WHOIS server, optionally with port.
+	server string
+
+	// prefix is the network the server is used for.
+	prefix netip.Prefix
+}
+
+// serverList is the parsed contents of the WHOIS servers file.
+type serverList struct {
+	// rirs maps the addresses of the known RIR WHOIS servers to the addresses
+	// of the servers, which should be used instead.
+	rirs map[string]string
+
+	// routes are the network routes sorted by the prefix length in descending
+	// order, so that the first matching route is the most specific one.
+	routes []serverRoute
+}
+
+// readServerList reads and parses the WHOIS servers file.  The file is a YAML
+// mapping of either CIDR networks or RIR names to WHOIS server addresses.
+func readServerList(fileName string) (l *serverList, err error) {
+	// #nosec G304 -- Trust the path explicitly given by the user.
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	m := map[string]string{}
+	err = yaml.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", fileName, err)
+	}
+
+	l = &serverList{
+		rirs: map[string]string{},
+	}
+
+	for k, server := range m {
+		if server == "" {
+			return nil, fmt.Errorf("server for %q: %w", k, errors.Error("empty address"))
+		}
+
+		server = strings.ToLower(server)
+
+		if rirAddr, ok := rirServers[strings.ToLower(k)]; ok {
+			l.rirs[rirAddr] = server
+
+			continue
+		}
+
+		var pref netip.Prefix
+		pref, err = netip.ParsePrefix(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: not a known rir or a network: %w", k, err)
+		}
+
+		l.routes = append(l.routes, serverRoute{
+			server: server,
+			prefix: pref.Masked(),
+		})
+	}
+
+	sort.Slice(l.routes, func(i, j int) (less bool) {
+		a, b := l.routes[i].prefix, l.routes[j].prefix
+		if a.Bits() != b.Bits() {
+			return a.Bits() > b.Bits()
+		}
+
+		return a.String() < b.String()
+	})
+
+	return l, nil
+}
+
+// initialServer returns the server from l which should be queried first about
+// ip.  If none of the routes match, it returns def.  l may be nil.
+func (l *serverList) initialServer(ip netip.Addr, def string) (server string) {
+	if l == nil {
+		return def
+	}
+
+	ip = ip.Unmap()
+	for _, r := range l.routes {
+		if r.prefix.Contains(ip) {
+			return r.server
+		}
+	}
+
+	return def
+}
+
+// replaceRIR returns the server configured to be used instead of the known RIR
+// server with the address addr.  If there is none, it returns addr.  l may be
+// nil.
+func (l *serverList) replaceRIR(addr string) (server string) {
+	if l == nil {
+		return addr
+	}
+
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+
+	if server = l.rirs[host]; server != "" {
+		return server
+	}
+
+	return addr
+}
@@ -0,0 +1,95 @@
+package whois
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ReadSize is the size of the data read from a WHOIS server in bytes.  It's
+// encoded as an integer followed by an optional unit, one of "B", "KB", or
+// "MB", e.g. "64KB".  The units are powers of 1024.
+type ReadSize int64
+
+// Units of [ReadSize].
+const (
+	ReadSizeByte     ReadSize = 1
+	ReadSizeKilobyte ReadSize = 1024 * ReadSizeByte
+	ReadSizeMegabyte ReadSize = 1024 * ReadSizeKilobyte
+)
+
+// Limits of [ReadSize].
+const (
+	MinReadSize = 1 * ReadSizeKilobyte
+	MaxReadSize = 10 * ReadSizeMegabyte
+)
+
+// readSizeUnits are the supported units of [ReadSize] starting from the
+// largest one.
+var readSizeUnits = []struct {
+	suffix string
+	size   ReadSize
+}{{
+	suffix: "MB",
+	size:   ReadSizeMegabyte,
+}, {
+	suffix: "KB",
+	size:   ReadSizeKilobyte,
+}, {
+	suffix: "B",
+	size:   ReadSizeByte,
+}}
+
+// Validate returns an error if s is not within [MinReadSize] and
+// [MaxReadSize].
+func (s ReadSize) Validate() (err error) {
+	if s < MinReadSize || s > MaxReadSize {
+		return fmt.Errorf("read size %s out of range [%s, %s]", s, MinReadSize, MaxReadSize)
+	}
+
+	return nil
+}
+
+// String implements the [fmt.Stringer] interface for ReadSize.  It uses the
+// largest unit dividing s.
+func (s ReadSize) String() (str string) {
+	for _, u := range readSizeUnits {
+		if s != 0 && s%u.size == 0 {
+			return strconv.FormatInt(int64(s/u.size), 10) + u.suffix
+		}
+	}
+
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface for ReadSize.
+func (s ReadSize) MarshalText() (text []byte, err error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface for
+// *ReadSize.
+func (s *ReadSize) UnmarshalText(b []byte) (err error) {
+	str := strings.TrimSpace(string(b))
+
+	unit := ReadSizeByte
+	for _, u := range readSizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str, unit = strings.TrimSpace(str[:len(str)-len(u.suffix)]), u.size
+
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad read size %q: %w", b, err)
+	} else if n < 0 || n > math.MaxInt64/int64(unit) {
+		return fmt.Errorf("bad read size %q: out of range", b)
+	}
+
+	*s = ReadSize(n) * unit
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package whois_test
+
+import (
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestReadSize_UnmarshalText(t *testing.T) {
+	testCases := []struct {
+		name       string
+		in         string
+		wantErrMsg string
+		want       whois.ReadSize
+	}{{
+		name:       "bytes",
+		in:         "2048",
+		wantErrMsg: "",
+		want:       2 * whois.ReadSizeKilobyte,
+	}, {
+		name:       "bytes_unit",
+		in:         "1500B",
+		wantErrMsg: "",
+		want:       1500,
+	}, {
+		name:       "kilobytes",
+		in:         "64KB",
+		wantErrMsg: "",
+		want:       64 * whois.ReadSizeKilobyte,
+	}, {
+		name:       "megabytes_space",
+		in:         "10 MB",
+		wantErrMsg: "",
+		want:       whois.MaxReadSize,
+	}, {
+		name: "bad_unit",
+		in:   "64GB",
+		wantErrMsg: `bad read size "64GB": strconv.ParseInt: ` +
+			`parsing "64G": invalid syntax`,
+		want: 0,
+	}, {
+		name:       "negative",
+		in:         "-1KB",
+		wantErrMsg: `bad read size "-1KB": out of range`,
+		want:       0,
+	}, {
+		name:       "overflow",
+		in:         "9223372036854775807MB",
+		wantErrMsg: `bad read size "9223372036854775807MB": out of range`,
+		want:       0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var s whois.ReadSize
+			err := s.UnmarshalText([]byte(tc.in))
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, s)
+		})
+	}
+}
+
+func TestReadSize_Validate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		wantErrMsg string
+		size       whois.ReadSize
+	}{{
+		name:       "min",
+		wantErrMsg: "",
+		size:       whois.MinReadSize,
+	}, {
+		name:       "max",
+		wantErrMsg: "",
+		size:       whois.MaxReadSize,
+	}, {
+		name:       "too_small",
+		wantErrMsg: "read size 1023B out of range [1KB, 10MB]",
+		size:       whois.MinReadSize - 1,
+	}, {
+		name:       "too_big",
+		wantErrMsg: "read size 10241KB out of range [1KB, 10MB]",
+		size:       whois.MaxReadSize + whois.ReadSizeKilobyte,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, tc.size.Validate())
+		})
+	}
+}
+
+func TestReadSize_yaml(t *testing.T) {
+	type config struct {
+		Size whois.ReadSize `yaml:"size"`
+	}
+
+	var conf config
+	err := yaml.Unmarshal([]byte("size: 64KB\n"), &conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, 64*whois.ReadSizeKilobyte, conf.Size)
+
+	b, err := yaml.Marshal(conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "size: 64KB\n", string(b))
+}
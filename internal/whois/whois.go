@@ -4,6 +4,7 @@ package whois
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -11,13 +12,13 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghio"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/stringutil"
-	"github.com/bluele/gcache"
 )
 
 const (
@@ -26,13 +27,158 @@ const (
 
 	// DefaultPort is the default port for WHOIS requests.
 	DefaultPort = 43
+
+	// QueryPlaceholder is the placeholder for the queried IP address in the
+	// query templates of WHOIS servers.
+	QueryPlaceholder = "{target}"
 )
 
+// Sentinel errors of the WHOIS queries.  The errors returned by the WHOIS
+// information processors match them with [errors.Is], while still wrapping the
+// underlying errors.
+const (
+	// ErrRedirectLoop is returned when the servers redirect the query more
+	// times than allowed.
+	ErrRedirectLoop errors.Error = "redirect loop"
+
+	// ErrTimeout is returned when a server doesn't respond in time.
+	ErrTimeout errors.Error = "timeout"
+
+	// ErrDial is returned when a server can't be connected to.
+	ErrDial errors.Error = "dial"
+
+	// ErrEmptyResponse is returned by [Default.SelfTest] when the server
+	// responds with nothing useful.
+	ErrEmptyResponse errors.Error = "empty response"
+)
+
+// selfTestIP is the well-known IP address queried by [Default.SelfTest].
+var selfTestIP = netip.AddrFrom4([4]byte{8, 8, 8, 8})
+
+// queryError is an error of a WHOIS query classified by one of the sentinel
+// errors.
+type queryError struct {
+	// err is the underlying error.
+	err error
+
+	// kind is the sentinel error classifying err.
+	kind errors.Error
+}
+
+// type check
+var _ error = (*queryError)(nil)
+
+// Error implements the error interface for *queryError.
+func (err *queryError) Error() (msg string) {
+	return fmt.Sprintf("%s: %s", err.kind, err.err)
+}
+
+// Unwrap returns the underlying error of err.
+func (err *queryError) Unwrap() (unwrapped error) {
+	return err.err
+}
+
+// Is returns true if target is the sentinel error classifying err.
+func (err *queryError) Is(target error) (ok bool) {
+	return target == err.kind
+}
+
+// classify returns err wrapped into a *queryError with the kind of the
+// failure, if it's a timeout or a dialing failure.  Otherwise, err is returned
+// as is.  isDial should be true if err is returned by the dial function.
+func classify(err error, isDial bool) (classified error) {
+	var netErr net.Error
+	switch {
+	case
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return &queryError{err: err, kind: ErrTimeout}
+	case isDial:
+		return &queryError{err: err, kind: ErrDial}
+	default:
+		return err
+	}
+}
+
+// defaultQueryTemplates are the query templates used for the servers that
+// require them unless they're overridden by [Config.QueryTemplates].
+var defaultQueryTemplates = map[string]string{
+	// Display type flags for query.  The flag is the same for both IPv4 and
+	// IPv6 addresses.
+	//
+	// See https://www.arin.net/resources/registry/whois/rws/api/#nicname-whois-queries.
+	DefaultServer: "n + " + QueryPlaceholder,
+}
+
+// ValidateQueryTemplate returns an error if tmpl isn't a valid query template
+// of a WHOIS server, which must contain [QueryPlaceholder] and must not
+// contain line breaks.
+func ValidateQueryTemplate(tmpl string) (err error) {
+	switch {
+	case !strings.Contains(tmpl, QueryPlaceholder):
+		return fmt.Errorf("bad query template %q: no placeholder %s", tmpl, QueryPlaceholder)
+	case strings.ContainsAny(tmpl, "\r\n"):
+		return fmt.Errorf("bad query template %q: contains line breaks", tmpl)
+	default:
+		return nil
+	}
+}
+
 // Interface provides WHOIS functionality.
 type Interface interface {
 	// Process makes WHOIS request and returns WHOIS information or nil.
 	// changed indicates that Info was updated since last request.
 	Process(ctx context.Context, ip netip.Addr) (info *Info, changed bool)
+
+	// ProcessForced makes WHOIS request to server, bypassing the automatic
+	// selection of the server and the cache, and returns WHOIS information or
+	// nil.  server must be valid, see [ValidateServer].
+	ProcessForced(ctx context.Context, ip netip.Addr, server string) (info *Info, err error)
+
+	// Flush removes the cached WHOIS information about ip, so that the next
+	// call to Process requests it again.  If ip is the zero value, all the
+	// cached information is removed.
+	Flush(ip netip.Addr)
+
+	// Stats returns the statistics of the cache of the WHOIS information.
+	Stats() (s *CacheStats)
+}
+
+// Diagnoser is implemented by the WHOIS information processors, which are able
+// to report the details of the queries for troubleshooting.
+type Diagnoser interface {
+	// ProcessDiagnostic makes WHOIS request like [Interface.ProcessForced] and
+	// returns WHOIS information or nil along with the details of the query.
+	// If server is empty, it's selected automatically.  diag may be non-nil
+	// even if err is not nil.
+	ProcessDiagnostic(
+		ctx context.Context,
+		ip netip.Addr,
+		server string,
+	) (info *Info, diag *Diagnostics, err error)
+}
+
+// Diagnostics are the details of a WHOIS query.  It's intended for
+// troubleshooting only, so it's not a part of [Info].
+type Diagnostics struct {
+	// Server is the address of the last queried server, including the port.
+	Server string `json:"server"`
+
+	// Redirects is the number of the referrals to other servers followed.
+	Redirects int `json:"redirects"`
+}
+
+// CacheStats are the statistics of the cache of the WHOIS information.
+type CacheStats struct {
+	// Size is the number of the cached entries, including the expired ones.
+	Size int `json:"size"`
+
+	// Hits is the number of the lookups, which found the cached entry.
+	Hits uint64 `json:"hits"`
+
+	// Misses is the number of the lookups, which didn't find the cached
+	// entry.
+	Misses uint64 `json:"misses"`
 }
 
 // Empty is an empty [Interface] implementation which does nothing.
@@ -46,30 +192,162 @@ func (Empty) Process(_ context.Context, _ netip.Addr) (info *Info, changed bool)
 	return nil, false
 }
 
-// Config is the configuration structure for Default.
+// ProcessForced implements the [Interface] interface for Empty.
+func (Empty) ProcessForced(_ context.Context, _ netip.Addr, _ string) (info *Info, err error) {
+	return nil, nil
+}
+
+// Flush implements the [Interface] interface for Empty.
+func (Empty) Flush(_ netip.Addr) {}
+
+// Stats implements the [Interface] interface for Empty.
+func (Empty) Stats() (s *CacheStats) {
+	return &CacheStats{}
+}
+
+// ValidateServer returns an error if addr isn't a valid address of a WHOIS
+// server, which is a hostname with an optional port.
+func ValidateServer(addr string) (err error) {
+	defer func() { err = errors.Annotate(err, "bad server %q: %w", addr) }()
+
+	host := addr
+	if _, _, splitErr := net.SplitHostPort(addr); splitErr == nil {
+		var port int
+		host, port, err = netutil.SplitHostPort(addr)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return err
+		} else if port == 0 {
+			return errors.Error("port must not be zero")
+		}
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return netutil.ValidateHostname(host)
+}
+
+// Backend is the type of the WHOIS information processor.
+type Backend string
+
+// Supported backends.
+const (
+	// BackendDefault is the classic WHOIS protocol over TCP port 43, see
+	// [Default].
+	BackendDefault Backend = "default"
+
+	// BackendRDAP is the Registration Data Access Protocol, see [RDAP].
+	BackendRDAP Backend = "rdap"
+)
+
+// NewInterface returns a new WHOIS information processor for conf.Backend.
+// conf must not be nil.
+func NewInterface(conf *Config) (w Interface, err error) {
+	switch conf.Backend {
+	case BackendDefault, "":
+		w, err = New(conf)
+	case BackendRDAP:
+		w, err = NewRDAP(conf)
+	default:
+		err = fmt.Errorf("whois: unsupported backend %q", conf.Backend)
+	}
+
+	if err != nil {
+		// Don't return a typed nil pointer as a non-nil interface.
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Config is the configuration structure for the WHOIS information processors.
 type Config struct {
 	// DialContext specifies the dial function for creating unencrypted TCP
 	// connections.
 	DialContext func(ctx context.Context, network, addr string) (conn net.Conn, err error)
 
-	// ServerAddr is the address of the WHOIS server.
+	// TLSConfig is the TLS configuration for RDAP requests.  If it's nil, the
+	// default configuration is used.
+	TLSConfig *tls.Config
+
+	// Backend is the type of the processor to create by [NewInterface].  The
+	// empty value means [BackendDefault].
+	Backend Backend
+
+	// ServerAddr is the address of the WHOIS server.  It is used for the
+	// addresses not matched by any network from ServersFile.  For
+	// [BackendRDAP], it's the base URL of the RDAP server.
 	ServerAddr string
 
+	// ServerPorts maps the hostnames of WHOIS servers to the ports, which
+	// should be used for them instead of Port, when the port isn't specified
+	// explicitly, e.g. by a referral.
+	ServerPorts map[string]uint16
+
+	// QueryTemplates maps the hostnames of WHOIS servers to the templates of
+	// the queries sent to them.  Each template must contain
+	// [QueryPlaceholder], which is replaced with the queried IP address.  The
+	// entries override the built-in templates, e.g. the one for
+	// [DefaultServer].  The servers without a template are sent the plain IP
+	// address.
+	QueryTemplates map[string]string
+
+	// Prelude are the lines sent to the server at ServerAddr before each query,
+	// e.g. to authenticate at a self-hosted mirror.  They aren't sent to any
+	// other server, including the ones referred to by ServerAddr.
+	Prelude []string
+
+	// ServersFile is the optional path to the YAML file mapping CIDR networks
+	// or RIR names to the addresses of WHOIS servers.  It is read once by
+	// [New].
+	ServersFile string
+
 	// Timeout is the timeout for WHOIS requests.
 	Timeout time.Duration
 
 	// CacheTTL is the Time to Live duration for cached IP addresses.
 	CacheTTL time.Duration
 
+	// TransientTTL is the Time to Live duration for cached IP addresses, which
+	// couldn't be resolved due to a transient failure, like a network outage
+	// or a timeout.  If it's zero, such addresses aren't cached.
+	TransientTTL time.Duration
+
+	// CacheTTLJitter is the maximum deviation of CacheTTL and TransientTTL in
+	// percent, so that the IP addresses resolved in a burst don't expire
+	// together.  It must be in the range [0, 100).  Zero means no jitter.
+	CacheTTLJitter int
+
 	// MaxConnReadSize is an upper limit in bytes for reading from net.Conn.
 	MaxConnReadSize int64
 
 	// MaxRedirects is the maximum redirects count.
 	MaxRedirects int
 
+	// MaxConns is the maximum number of simultaneously open connections to
+	// WHOIS servers, regardless of the number of concurrent queries and
+	// redirects.  If it's zero, the number isn't limited.  It's ignored by
+	// [BackendRDAP].
+	MaxConns int
+
+	// IgnoreReferrals, if true, makes the processor use the first response as
+	// is, without following the referrals in its whois and referralserver
+	// fields.  It's useful when the initial server is a trusted aggregator,
+	// which returns the authoritative records itself, e.g. when its query
+	// template requests the recursive lookup.  It's ignored by [BackendRDAP].
+	IgnoreReferrals bool
+
 	// MaxInfoLen is the maximum length of Info fields returned by Process.
 	MaxInfoLen int
 
+	// MaxInfoLens are the maximum lengths of the individual Info fields
+	// returned by Process.  Its zero fields mean MaxInfoLen.
+	MaxInfoLens InfoLens
+
+	// CountryFormat is the format to convert [Info.Country] into, so that the
+	// countries are consistent regardless of the server.  The countries not in
+	// the built-in table are left unchanged.
+	CountryFormat CountryFormat
+
 	// CacheSize is the maximum size of the cache.  It must be greater than
 	// zero.
 	CacheSize int
@@ -78,64 +356,222 @@ type Config struct {
 	Port uint16
 }
 
+// InfoLens are the maximum lengths of the fields of [Info].
+type InfoLens struct {
+	City       int
+	Country    int
+	Orgname    int
+	Network    int
+	AbuseEmail int
+}
+
+// withDefault returns a copy of l with the zero fields set to def.
+func (l InfoLens) withDefault(def int) (res InfoLens) {
+	res = l
+	for _, f := range []*int{
+		&res.City,
+		&res.Country,
+		&res.Orgname,
+		&res.Network,
+		&res.AbuseEmail,
+	} {
+		if *f == 0 {
+			*f = def
+		}
+	}
+
+	return res
+}
+
 // Default is the default WHOIS information processor.
 type Default struct {
-	// cache is the cache containing IP addresses of clients.  An active IP
-	// address is resolved once again after it expires.  If IP address couldn't
-	// be resolved, it stays here for some time to prevent further attempts to
-	// resolve the same IP.
-	cache gcache.Cache
+	// cache is the cache of the WHOIS information about IP addresses.
+	cache *infoCache
 
 	// dialContext connects to a remote server resolving hostname using our own
 	// DNS server and unecrypted TCP connection.
 	dialContext func(ctx context.Context, network, addr string) (conn net.Conn, err error)
 
+	// servers is the list of WHOIS servers read from the servers file.  It is
+	// nil if there is no servers file.
+	servers *serverList
+
+	// conns is the semaphore limiting the number of simultaneously open
+	// connections.  It is nil if the number isn't limited.
+	conns chan struct{}
+
+	// serverPorts maps the hostnames of WHOIS servers to the ports, which
+	// should be used for them instead of portStr.
+	serverPorts map[string]string
+
+	// queryTemplates maps the lowercased hostnames of WHOIS servers to the
+	// templates of the queries sent to them.
+	queryTemplates map[string]string
+
 	// serverAddr is the address of the WHOIS server.
 	serverAddr string
 
+	// prelude is the data written to the server at preludeHost before each
+	// query.  It's empty if there is no prelude.
+	prelude string
+
+	// preludeHost is the lowercased hostname of the server to send prelude to.
+	preludeHost string
+
+	// countryFormat is the format to convert [Info.Country] into.
+	countryFormat CountryFormat
+
 	// portStr is the port for WHOIS requests.
 	portStr string
 
 	// timeout is the timeout for WHOIS requests.
 	timeout time.Duration
 
-	// cacheTTL is the Time to Live duration for cached IP addresses.
-	cacheTTL time.Duration
-
 	// maxConnReadSize is an upper limit in bytes for reading from net.Conn.
 	maxConnReadSize int64
 
 	// maxRedirects is the maximum redirects count.
 	maxRedirects int
 
-	// maxInfoLen is the maximum length of Info fields returned by Process.
-	maxInfoLen int
+	// ignoreReferrals, if true, means that the referrals in the responses
+	// aren't followed.
+	ignoreReferrals bool
+
+	// maxInfoLens are the maximum lengths of Info fields returned by Process.
+	maxInfoLens InfoLens
 }
 
 // New returns a new default WHOIS information processor.  conf must not be
 // nil.
-func New(conf *Config) (w *Default) {
+func New(conf *Config) (w *Default, err error) {
+	err = conf.CountryFormat.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("whois: %w", err)
+	}
+
+	err = validateTTLJitter(conf.CacheTTLJitter)
+	if err != nil {
+		return nil, fmt.Errorf("whois: %w", err)
+	}
+
+	var conns chan struct{}
+	if conf.MaxConns < 0 {
+		return nil, fmt.Errorf("whois: max conns: must be non-negative, got %d", conf.MaxConns)
+	} else if conf.MaxConns > 0 {
+		conns = make(chan struct{}, conf.MaxConns)
+	}
+
+	var servers *serverList
+	if conf.ServersFile != "" {
+		servers, err = readServerList(conf.ServersFile)
+		if err != nil {
+			return nil, fmt.Errorf("whois: reading servers file: %w", err)
+		}
+	}
+
+	serverPorts := make(map[string]string, len(conf.ServerPorts))
+	for host, port := range conf.ServerPorts {
+		serverPorts[strings.ToLower(host)] = strconv.Itoa(int(port))
+	}
+
+	queryTemplates := make(map[string]string, len(defaultQueryTemplates)+len(conf.QueryTemplates))
+	for host, tmpl := range defaultQueryTemplates {
+		queryTemplates[host] = tmpl
+	}
+
+	for host, tmpl := range conf.QueryTemplates {
+		err = ValidateQueryTemplate(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("whois: server %q: %w", host, err)
+		}
+
+		queryTemplates[strings.ToLower(host)] = tmpl
+	}
+
+	var prelude string
+	for _, l := range conf.Prelude {
+		prelude += l + "\r\n"
+	}
+
+	cache := newInfoCache(conf.CacheSize, conf.CacheTTL, conf.TransientTTL, conf.CacheTTLJitter)
+
 	return &Default{
+		prelude:         prelude,
+		preludeHost:     preludeHost(conf.ServerAddr),
+		servers:         servers,
+		conns:           conns,
+		serverPorts:     serverPorts,
+		queryTemplates:  queryTemplates,
 		serverAddr:      conf.ServerAddr,
 		dialContext:     conf.DialContext,
 		timeout:         conf.Timeout,
-		cache:           gcache.New(conf.CacheSize).LRU().Build(),
+		cache:           cache,
 		maxConnReadSize: conf.MaxConnReadSize,
 		maxRedirects:    conf.MaxRedirects,
+		ignoreReferrals: conf.IgnoreReferrals,
 		portStr:         strconv.Itoa(int(conf.Port)),
-		maxInfoLen:      conf.MaxInfoLen,
-		cacheTTL:        conf.CacheTTL,
+		maxInfoLens:     conf.MaxInfoLens.withDefault(conf.MaxInfoLen),
+		countryFormat:   conf.CountryFormat,
+	}, nil
+}
+
+// preludeHost returns the lowercased hostname of addr, which may contain a
+// port.
+func preludeHost(addr string) (host string) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
 	}
+
+	return strings.ToLower(host)
 }
 
 // trimValue trims s and replaces the last 3 characters of the cut with "..."
-// to fit into max.  max must be greater than 3.
+// to fit into max characters.  The cut is made on a rune boundary, so the
+// result remains valid UTF-8.  max must be greater than 3.
 func trimValue(s string, max int) string {
-	if len(s) <= max {
+	if utf8.RuneCountInString(s) <= max {
 		return s
 	}
 
-	return s[:max-3] + "..."
+	n := 0
+	for i := range s {
+		if n == max-3 {
+			return s[:i] + "..."
+		}
+
+		n++
+	}
+
+	// Shouldn't happen, since s has more than max runes.
+	return s
+}
+
+// dateLayouts are the layouts of the dates used by the WHOIS servers of the
+// regional internet registries.
+var dateLayouts = []string{
+	// RIPE NCC, APNIC, and AFRINIC, e.g. "2002-06-25T14:19:09Z".
+	time.RFC3339,
+	// ARIN, e.g. "2009-03-02".
+	"2006-01-02",
+	// LACNIC, e.g. "19990101".
+	"20060102",
+	// Some national registries, e.g. "2009-03-02 14:19:09".
+	"2006-01-02 15:04:05",
+}
+
+// parseDate parses the date from the WHOIS response in one of the known
+// formats and returns it in the RFC 3339 format in UTC.  If s can't be parsed,
+// it returns an empty string.
+func parseDate(s string) (date string) {
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	return ""
 }
 
 // isWHOISComment returns true if the data is empty or is a WHOIS comment.
@@ -144,11 +580,14 @@ func isWHOISComment(data []byte) (ok bool) {
 }
 
 // whoisParse parses a subset of plain-text data from the WHOIS response into a
-// string map.  It trims values of the returned map to maxLen.
-func whoisParse(data []byte, maxLen int) (info map[string]string) {
+// string map.  It trims values of the returned map to the lengths from lens.
+// The organization name is taken from the orgname and org-name keys, and only
+// if there are none, from the first descr or netname key, regardless of their
+// order.
+func whoisParse(data []byte, lens InfoLens) (info map[string]string) {
 	info = map[string]string{}
 
-	var orgname string
+	var orgname, fallbackOrgname string
 	lines := bytes.Split(data, []byte("\n"))
 	for _, l := range lines {
 		if isWHOISComment(l) {
@@ -168,15 +607,35 @@ func whoisParse(data []byte, maxLen int) (info map[string]string) {
 
 		switch key {
 		case "orgname", "org-name":
-			key = "orgname"
-			val = trimValue(val, maxLen)
 			orgname = val
-		case "city", "country":
-			val = trimValue(val, maxLen)
+
+			continue
+		case "city":
+			val = trimValue(val, lens.City)
+		case "country":
+			val = trimValue(val, lens.Country)
 		case "descr", "netname":
-			key = "orgname"
-			val = stringutil.Coalesce(orgname, val)
-			orgname = val
+			fallbackOrgname = stringutil.Coalesce(fallbackOrgname, val)
+
+			continue
+		case "regdate", "created":
+			key = "created"
+			val = parseDate(val)
+		case "updated", "last-modified":
+			key = "updated"
+			val = parseDate(val)
+		case "inetnum", "inet6num", "netrange", "cidr":
+			key = "network"
+			val = trimValue(parseNetwork(val), lens.Network)
+		case "abuse-mailbox", "orgabuseemail", "abuse-c":
+			if !strings.Contains(val, "@") {
+				// Some registries, e.g. RIPE NCC, put the handle of the abuse
+				// contact object into abuse-c instead of the address.
+				continue
+			}
+
+			key = "abuse_email"
+			val = trimValue(val, lens.AbuseEmail)
 		case "whois":
 			key = "whois"
 		case "referralserver":
@@ -186,26 +645,69 @@ func whoisParse(data []byte, maxLen int) (info map[string]string) {
 			continue
 		}
 
+		switch key {
+		case "created", "updated", "network", "abuse_email":
+			// Only use the dates, the network, and the abuse contact of the
+			// first record, which describes the network itself, and skip the
+			// unparseable ones.
+			if val == "" || info[key] != "" {
+				continue
+			}
+		default:
+			// Go on.
+		}
+
 		info[key] = val
 	}
 
+	if orgname = stringutil.Coalesce(orgname, fallbackOrgname); orgname != "" {
+		info["orgname"] = trimValue(orgname, lens.Orgname)
+	}
+
 	return info
 }
 
-// query sends request to a server and returns the response or error.
+// queryTarget returns the query about ip for the WHOIS server with the given
+// address.  serverAddr must contain a port.  If expand is true, IPv6 addresses
+// are written in the expanded form, since some servers don't recognize the
+// compressed one.  The address is formatted using the query template of the
+// server, if there is one.
+func (w *Default) queryTarget(ip netip.Addr, serverAddr string, expand bool) (target string) {
+	// The servers know nothing about zones and IPv4-mapped IPv6 addresses.
+	ip = ip.Unmap().WithZone("")
+	if expand && ip.Is6() {
+		target = ip.StringExpanded()
+	} else {
+		target = ip.String()
+	}
+
+	host, _, _ := net.SplitHostPort(serverAddr)
+	tmpl, ok := w.queryTemplates[strings.ToLower(host)]
+	if !ok {
+		return target
+	}
+
+	return strings.ReplaceAll(tmpl, QueryPlaceholder, target)
+}
+
+// query sends request to a server and returns the response or error.  The
+// timeouts and the dialing failures are classified as [ErrTimeout] and
+// [ErrDial] correspondingly.  If the reading fails after some data has been
+// received, data contains it along with the error.  The reading is interrupted
+// once ctx is done.
 func (w *Default) query(ctx context.Context, target, serverAddr string) (data []byte, err error) {
-	addr, _, _ := net.SplitHostPort(serverAddr)
-	if addr == DefaultServer {
-		// Display type flags for query.
-		//
-		// See https://www.arin.net/resources/registry/whois/rws/api/#nicname-whois-queries.
-		target = "n + " + target
+	err = w.acquireConn(ctx)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
 	}
+	// Release the connection after it's closed by the deferred call below.
+	defer w.releaseConn()
 
 	conn, err := w.dialContext(ctx, "tcp", serverAddr)
 	if err != nil {
 		// Don't wrap the error since it's informative enough as is.
-		return nil, err
+		return nil, classify(err, true)
 	}
 	defer func() { err = errors.WithDeferred(err, conn.Close()) }()
 
@@ -215,56 +717,179 @@ func (w *Default) query(ctx context.Context, target, serverAddr string) (data []
 		return nil, err
 	}
 
+	req := target + "\r\n"
+	if w.prelude != "" && preludeHost(serverAddr) == w.preludeHost {
+		req = w.prelude + req
+	}
+
+	// Set the deadline before writing anything so that the timeout is counted
+	// from the start of the whole exchange, including the prelude.
 	_ = conn.SetReadDeadline(time.Now().Add(w.timeout))
-	_, err = io.WriteString(conn, target+"\r\n")
+	defer interruptOnDone(ctx, conn)()
+
+	_, err = io.WriteString(conn, req)
 	if err != nil {
 		// Don't wrap the error since it's informative enough as is.
-		return nil, err
+		return nil, classify(err, false)
 	}
 
 	// This use of ReadAll is now safe, because we limited the conn Reader.
 	data, err = io.ReadAll(r)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = fmt.Errorf("reading: %w", ctxErr)
+		}
+
+		// Return the data received so far, since the servers, which keep the
+		// connection open after the response, are stopped by the deadline.
+		//
 		// Don't wrap the error since it's informative enough as is.
-		return nil, err
+		return data, classify(err, false)
 	}
 
 	return data, nil
 }
 
-// queryAll queries WHOIS server and handles redirects.
-func (w *Default) queryAll(ctx context.Context, target string) (info map[string]string, err error) {
-	server := net.JoinHostPort(w.serverAddr, w.portStr)
+// interruptOnDone makes the pending and the following reads from conn fail once
+// ctx is done.  stop must be called when the exchange is over.
+func interruptOnDone(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	stopCh := make(chan struct{})
+	go func() {
+		defer log.OnPanic("whois: interrupting query")
+
+		select {
+		case <-ctx.Done():
+			_ = conn.SetReadDeadline(time.Now())
+		case <-stopCh:
+			// Go on.
+		}
+	}()
+
+	return func() { close(stopCh) }
+}
+
+// acquireConn waits until one more connection can be opened without exceeding
+// the configured limit.  The failure to do so before ctx is done is classified
+// as [ErrTimeout], if the deadline is exceeded.  Each successful call must be
+// followed by a call to [Default.releaseConn].
+func (w *Default) acquireConn(ctx context.Context) (err error) {
+	if w.conns == nil {
+		return nil
+	}
+
+	select {
+	case w.conns <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return classify(fmt.Errorf("waiting for connection: %w", ctx.Err()), false)
+	}
+}
+
+// releaseConn frees the connection slot taken by [Default.acquireConn].
+func (w *Default) releaseConn() {
+	if w.conns != nil {
+		<-w.conns
+	}
+}
+
+// hostPort returns addr with the WHOIS port added, if addr has none.  The port
+// configured for the host takes precedence over the default one.
+func (w *Default) hostPort(addr string) (hostPort string) {
+	_, _, err := net.SplitHostPort(addr)
+	if err == nil {
+		return addr
+	}
+
+	port, ok := w.serverPorts[addr]
+	if !ok {
+		port = w.portStr
+	}
+
+	return net.JoinHostPort(addr, port)
+}
+
+// queryAll queries WHOIS server about ip and handles redirects.  rir is the
+// name of the regional internet registry, which has answered the query, if
+// any.  The details of the query are recorded into diag, if it's not nil.
+func (w *Default) queryAll(
+	ctx context.Context,
+	ip netip.Addr,
+	diag *Diagnostics,
+) (info map[string]string, rir string, err error) {
+	return w.queryFrom(ctx, ip, w.servers.initialServer(ip, w.serverAddr), diag)
+}
+
+// queryFrom queries WHOIS server about ip starting from the server with address
+// origin and handles redirects, unless they are ignored.  The known RIR servers
+// are replaced with the ones from the servers file, if any.  If the server
+// responds with nothing useful about an IPv6 address, it's queried again with
+// the expanded form of the address.  rir is the name of the regional internet
+// registry, server of which has been the last in the redirect chain, if any.
+// The RIR servers replaced using the servers file are still reported under
+// their RIR names.  The details of the query are recorded into diag, if it's
+// not nil.  The redirect chain is abandoned as soon as ctx is done.
+func (w *Default) queryFrom(
+	ctx context.Context,
+	ip netip.Addr,
+	origin string,
+	diag *Diagnostics,
+) (info map[string]string, rir string, err error) {
+	if diag == nil {
+		diag = &Diagnostics{}
+	}
+
 	var data []byte
 
+	server := w.hostPort(w.servers.replaceRIR(origin))
+	expand := false
 	for i := 0; i < w.maxRedirects; i++ {
+		err = ctx.Err()
+		if err != nil {
+			return nil, "", classify(fmt.Errorf("querying %q: %w", server, err), false)
+		}
+
+		diag.Server = server
+
+		target := w.queryTarget(ip, server, expand)
 		data, err = w.query(ctx, target, server)
 		if err != nil {
-			// Don't wrap the error since it's informative enough as is.
-			return nil, err
+			if len(data) == 0 || !errors.Is(err, ErrTimeout) {
+				// Don't wrap the error since it's informative enough as is.
+				return nil, "", err
+			}
+
+			// Partial responses are often parseable, so use them.
+			log.Debug("whois: using partial response from %q about %q: %s", server, target, err)
 		}
 
 		log.Debug("whois: received response (%d bytes) from %q about %q", len(data), server, target)
 
-		info = whoisParse(data, w.maxInfoLen)
-		redir, ok := info["whois"]
-		if !ok {
-			return info, nil
-		}
+		info = whoisParse(data, w.maxInfoLens)
+		if len(info) == 0 && ip.Unmap().Is6() && !expand {
+			log.Debug("whois: retrying %q about %q in the expanded form", server, target)
 
-		redir = strings.ToLower(redir)
+			expand = true
 
-		_, _, err = net.SplitHostPort(redir)
-		if err != nil {
-			server = net.JoinHostPort(redir, w.portStr)
-		} else {
-			server = redir
+			continue
+		}
+
+		redir, ok := info["whois"]
+		if !ok || w.ignoreReferrals {
+			return info, rirName(origin), nil
 		}
 
-		log.Debug("whois: redirected to %q about %q", redir, target)
+		origin = strings.ToLower(redir)
+		server = w.hostPort(w.servers.replaceRIR(origin))
+		diag.Redirects++
+
+		log.Debug("whois: redirected to %q about %q", origin, target)
 	}
 
-	return nil, fmt.Errorf("whois: redirect loop")
+	return nil, "", fmt.Errorf("whois: %w", ErrRedirectLoop)
 }
 
 // type check
@@ -277,79 +902,161 @@ func (w *Default) Process(ctx context.Context, ip netip.Addr) (wi *Info, changed
 		return nil, false
 	}
 
-	wi, expired := w.findInCache(ip)
-	if wi != nil && !expired {
-		// Don't return an empty struct so that the frontend doesn't get
-		// confused.
-		if (*wi == Info{}) {
-			return nil, false
-		}
+	return w.cache.process(ctx, ip, w.queryInfo)
+}
 
-		return wi, false
+// ProcessForced implements the [Interface] interface for *Default.  The
+// information isn't cached so that it doesn't affect the results of
+// [Default.Process].
+func (w *Default) ProcessForced(
+	ctx context.Context,
+	ip netip.Addr,
+	server string,
+) (wi *Info, err error) {
+	if netutil.IsSpecialPurposeAddr(ip) {
+		return nil, nil
 	}
 
-	return w.requestInfo(ctx, ip, wi)
+	kv, rir, err := w.queryFrom(ctx, ip, strings.ToLower(server), nil)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	info := w.newInfo(kv, rir)
+	if (info == Info{}) {
+		return nil, nil
+	}
+
+	return &info, nil
 }
 
-// requestInfo makes WHOIS request and returns WHOIS info.  changed is false if
-// received information is equal to cached.
-func (w *Default) requestInfo(
+// type check
+var _ Diagnoser = (*Default)(nil)
+
+// ProcessDiagnostic implements the [Diagnoser] interface for *Default.  Like
+// with [Default.ProcessForced], the information isn't cached.
+func (w *Default) ProcessDiagnostic(
 	ctx context.Context,
 	ip netip.Addr,
-	cached *Info,
-) (wi *Info, changed bool) {
-	var info Info
+	server string,
+) (wi *Info, diag *Diagnostics, err error) {
+	if netutil.IsSpecialPurposeAddr(ip) {
+		return nil, nil, nil
+	}
 
-	defer func() {
-		item := toCacheItem(info, w.cacheTTL)
-		err := w.cache.Set(ip, item)
-		if err != nil {
-			log.Debug("whois: cache: adding item %q: %s", ip, err)
-		}
-	}()
+	diag = &Diagnostics{}
+
+	var kv map[string]string
+	var rir string
+	if server == "" {
+		kv, rir, err = w.queryAll(ctx, ip, diag)
+	} else {
+		kv, rir, err = w.queryFrom(ctx, ip, strings.ToLower(server), diag)
+	}
 
-	kv, err := w.queryAll(ctx, ip.String())
 	if err != nil {
-		log.Debug("whois: quering about %q: %s", ip, err)
+		// Don't wrap the error since it's informative enough as is.
+		return nil, diag, err
+	}
 
-		return nil, true
+	info := w.newInfo(kv, rir)
+	if (info == Info{}) {
+		return nil, diag, nil
 	}
 
+	return &info, diag, nil
+}
+
+// SelfTest queries the configured WHOIS server about a well-known IP address
+// and returns an error if the query fails or the response contains no
+// information.  Like with [Default.ProcessForced], the information isn't
+// cached.  It's intended to check the WHOIS settings.
+func (w *Default) SelfTest(ctx context.Context) (err error) {
+	defer func() { err = errors.Annotate(err, "whois: self-test about %s: %w", selfTestIP) }()
+
+	kv, _, err := w.queryAll(ctx, selfTestIP, nil)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	if len(kv) == 0 {
+		return ErrEmptyResponse
+	}
+
+	return nil
+}
+
+// Flush implements the [Interface] interface for *Default.
+func (w *Default) Flush(ip netip.Addr) {
+	w.cache.flush(ip)
+}
+
+// Stats implements the [Interface] interface for *Default.
+func (w *Default) Stats() (s *CacheStats) {
+	return w.cache.stats()
+}
+
+// queryInfo queries WHOIS servers about ip and returns the information.
+func (w *Default) queryInfo(ctx context.Context, ip netip.Addr) (info Info, err error) {
+	kv, rir, err := w.queryAll(ctx, ip, nil)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return Info{}, err
+	}
+
+	return w.newInfo(kv, rir), nil
+}
+
+// newInfo returns the WHOIS information parsed from the WHOIS response kv.
+// rir is only set if there is any other information, so that the empty
+// responses stay empty.
+func (w *Default) newInfo(kv map[string]string, rir string) (info Info) {
 	info = Info{
 		City:    kv["city"],
-		Country: kv["country"],
+		Country: w.country(kv["country"]),
 		Orgname: kv["orgname"],
-	}
+		Created: kv["created"],
+		Updated: kv["updated"],
+		Network: kv["network"],
 
-	changed = cached == nil || info != *cached
+		AbuseEmail: kv["abuse_email"],
+	}
 
-	// Don't return an empty struct so that the frontend doesn't get confused.
-	if (info == Info{}) {
-		return nil, changed
+	if (info != Info{}) {
+		info.RIR = rir
 	}
 
-	return &info, changed
+	return info
 }
 
-// findInCache finds Info in the cache.  expired indicates that Info is valid.
-func (w *Default) findInCache(ip netip.Addr) (wi *Info, expired bool) {
-	val, err := w.cache.Get(ip)
-	if err != nil {
-		if !errors.Is(err, gcache.KeyNotFoundError) {
-			log.Debug("whois: cache: retrieving info about %q: %s", ip, err)
-		}
-
-		return nil, false
+// country returns the country converted into the configured format and
+// trimmed to the maximum length.
+func (w *Default) country(raw string) (c string) {
+	if raw == "" {
+		return ""
 	}
 
-	item, ok := val.(*cacheItem)
-	if !ok {
-		log.Debug("whois: cache: %q bad type %T", ip, val)
+	return trimValue(w.countryFormat.normalize(raw), w.maxInfoLens.Country)
+}
 
-		return nil, false
+// isTransient returns true if err is caused by a failure, which is likely to
+// disappear after some time, like a network outage or a timeout.
+func isTransient(err error) (ok bool) {
+	if errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrDial) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
 	}
 
-	return fromCacheItem(item)
+	return errors.Is(err, io.ErrUnexpectedEOF)
 }
 
 // Info is the filtered WHOIS data for a runtime client.
@@ -357,32 +1064,24 @@ type Info struct {
 	City    string `json:"city,omitempty"`
 	Country string `json:"country,omitempty"`
 	Orgname string `json:"orgname,omitempty"`
-}
 
-// cacheItem represents an item that we will store in the cache.
-type cacheItem struct {
-	// expiry is the time when cacheItem will expire.
-	expiry time.Time
+	// Created is the registration date of the network in the RFC 3339 format.
+	Created string `json:"created,omitempty"`
 
-	// info is the WHOIS data for a runtime client.
-	info *Info
-}
+	// Updated is the date of the last update of the network in the RFC 3339
+	// format.
+	Updated string `json:"updated,omitempty"`
 
-// toCacheItem creates a cached item from a WHOIS info and Time to Live
-// duration.
-func toCacheItem(info Info, ttl time.Duration) (item *cacheItem) {
-	return &cacheItem{
-		expiry: time.Now().Add(ttl),
-		info:   &info,
-	}
-}
+	// Network is the comma-separated list of CIDRs of the network, e.g.
+	// "1.2.3.0/24".
+	Network string `json:"network,omitempty"`
 
-// fromCacheItem creates a WHOIS info from the cached item.  expired indicates
-// that WHOIS info is valid.  item must not be nil.
-func fromCacheItem(item *cacheItem) (info *Info, expired bool) {
-	if time.Now().After(item.expiry) {
-		return item.info, true
-	}
+	// AbuseEmail is the email address to report the abuse from the network
+	// to.
+	AbuseEmail string `json:"abuse_email,omitempty"`
 
-	return item.info, false
+	// RIR is the name of the regional internet registry, which has provided
+	// the information, in upper case, e.g. "RIPE".  It's empty if the
+	// information has been provided by some other server.
+	RIR string `json:"rir,omitempty"`
 }
@@ -0,0 +1,144 @@
+package whois
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrimValue(t *testing.T) {
+	const max = 8
+
+	testCases := []struct {
+		name string
+		in   string
+		want string
+	}{{
+		name: "empty",
+		in:   "",
+		want: "",
+	}, {
+		name: "short",
+		in:   "Moscow",
+		want: "Moscow",
+	}, {
+		name: "exact",
+		in:   "Brussels",
+		want: "Brussels",
+	}, {
+		name: "long",
+		in:   "Amsterdam",
+		want: "Amste...",
+	}, {
+		name: "multibyte_short",
+		in:   "Москва",
+		want: "Москва",
+	}, {
+		name: "multibyte_exact",
+		in:   "Тбилиси!",
+		want: "Тбилиси!",
+	}, {
+		name: "multibyte_long",
+		in:   "Санкт-Петербург",
+		want: "Санкт...",
+	}, {
+		name: "mixed",
+		in:   "Zürich, Schweiz",
+		want: "Züric...",
+	}, {
+		name: "four_bytes",
+		in:   "😀😀😀😀😀😀😀😀😀",
+		want: "😀😀😀😀😀...",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := trimValue(tc.in, max)
+			assert.Equal(t, tc.want, got)
+			assert.True(t, utf8.ValidString(got))
+			assert.LessOrEqual(t, utf8.RuneCountInString(got), max)
+		})
+	}
+}
+
+func TestInfoCache_ttlJitter(t *testing.T) {
+	const (
+		ttl    = 24 * time.Hour
+		jitter = 50
+	)
+
+	query := func(_ context.Context, _ netip.Addr) (info Info, err error) {
+		return Info{Country: "AU"}, nil
+	}
+
+	ips := []netip.Addr{
+		netip.MustParseAddr("1.2.3.4"),
+		netip.MustParseAddr("5.6.7.8"),
+	}
+
+	c := newInfoCache(10, ttl, 0, jitter)
+
+	start := time.Now()
+	for _, ip := range ips {
+		_, _ = c.process(context.Background(), ip, query)
+	}
+	end := time.Now()
+
+	expiries := make([]time.Time, 0, len(ips))
+	for _, ip := range ips {
+		val, err := c.cache.Get(ip)
+		require.NoError(t, err)
+
+		item := testutil.RequireTypeAssert[*cacheItem](t, val)
+		expiries = append(expiries, item.expiry)
+
+		maxDelta := ttl / 100 * jitter
+		assert.False(t, item.expiry.Before(start.Add(ttl-maxDelta)))
+		assert.False(t, item.expiry.After(end.Add(ttl+maxDelta)))
+	}
+
+	// The expiries of the addresses processed together differ by much more
+	// than the processing time.
+	diff := expiries[0].Sub(expiries[1])
+	if diff < 0 {
+		diff = -diff
+	}
+
+	assert.Greater(t, diff, time.Second)
+}
+
+func TestValidateTTLJitter(t *testing.T) {
+	testCases := []struct {
+		name       string
+		wantErrMsg string
+		jitter     int
+	}{{
+		name:       "zero",
+		wantErrMsg: "",
+		jitter:     0,
+	}, {
+		name:       "valid",
+		wantErrMsg: "",
+		jitter:     10,
+	}, {
+		name:       "negative",
+		wantErrMsg: "cache ttl jitter: must be in range [0, 100), got -1",
+		jitter:     -1,
+	}, {
+		name:       "too_big",
+		wantErrMsg: "cache ttl jitter: must be in range [0, 100), got 100",
+		jitter:     100,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, validateTTLJitter(tc.jitter))
+		})
+	}
+}
@@ -5,15 +5,39 @@ import (
 	"io"
 	"net"
 	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/AdguardTeam/golibs/testutil/fakenet"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// newFakeConn returns a new fake connection that responds with data to any
+// query.  The callbacks of the returned connection may be overridden.
+func newFakeConn(data string) (c *fakenet.Conn) {
+	return &fakenet.Conn{
+		OnRead: func(b []byte) (n int, err error) {
+			return copy(b, data), io.EOF
+		},
+		OnWrite: func(b []byte) (n int, err error) {
+			return len(b), nil
+		},
+		OnClose: func() (err error) {
+			return nil
+		},
+		OnSetReadDeadline: func(t time.Time) (err error) {
+			return nil
+		},
+	}
+}
+
 func TestDefault_Process(t *testing.T) {
 	const (
 		nl             = "\n"
@@ -81,6 +105,24 @@ func TestDefault_Process(t *testing.T) {
 		},
 		name: "orgname_netname",
 		data: "netname: " + orgname,
+	}, {
+		want: &whois.Info{
+			Orgname: orgname,
+		},
+		name: "orgname_before_descr",
+		data: "OrgName: " + orgname + nl + "descr: Other" + nl + "netname: OTHER-NET",
+	}, {
+		want: &whois.Info{
+			Orgname: orgname,
+		},
+		name: "orgname_after_descr",
+		data: "netname: OTHER-NET" + nl + "descr: Other" + nl + "org-name: " + orgname,
+	}, {
+		want: &whois.Info{
+			Orgname: orgname,
+		},
+		name: "orgname_first_fallback",
+		data: "netname: " + orgname + nl + "descr: Other",
 	}, {
 		want: &whois.Info{
 			City:    city,
@@ -89,6 +131,104 @@ func TestDefault_Process(t *testing.T) {
 		},
 		name: "full",
 		data: "OrgName: " + orgname + nl + "City: " + city + nl + "Country: " + country,
+	}, {
+		want: &whois.Info{
+			Orgname: orgname,
+			Created: "2009-03-02T00:00:00Z",
+			Updated: "2012-03-02T00:00:00Z",
+		},
+		name: "dates_arin",
+		data: "OrgName: " + orgname + nl +
+			"RegDate: 2009-03-02" + nl +
+			"Updated: 2012-03-02" + nl +
+			"RegDate: 1997-12-22" + nl,
+	}, {
+		want: &whois.Info{
+			Orgname: orgname,
+			Created: "2002-06-25T14:19:09Z",
+			Updated: "2019-11-12T10:39:06Z",
+		},
+		name: "dates_ripe",
+		data: "netname: " + orgname + nl +
+			"created: 2002-06-25T14:19:09Z" + nl +
+			"last-modified: 2019-11-12T11:39:06+01:00" + nl +
+			"created: 2010-01-01T00:00:00Z" + nl,
+	}, {
+		want: &whois.Info{
+			Created: "1999-01-01T00:00:00Z",
+		},
+		name: "dates_unparseable",
+		data: "created: sometime" + nl +
+			"created: 19990101" + nl +
+			"last-modified: yesterday" + nl,
+	}, {
+		want: &whois.Info{
+			Orgname: orgname,
+			Network: "1.2.3.0/24",
+		},
+		name: "network_ripe_range",
+		data: "inetnum: 1.2.3.0 - 1.2.3.255" + nl +
+			"netname: " + orgname + nl +
+			"inetnum: 1.2.0.0 - 1.2.255.255" + nl,
+	}, {
+		want: &whois.Info{
+			Network: "1.2.3.0/24, 1.2.4.0/23, 1.2.6.0/31",
+		},
+		name: "network_range_multiple",
+		data: "inetnum: 1.2.3.0 - 1.2.6.1",
+	}, {
+		want: &whois.Info{
+			Network: "2001:db8::/32",
+		},
+		name: "network_ripe_inet6num",
+		data: "inet6num: 2001:db8::/32",
+	}, {
+		want: &whois.Info{
+			Orgname: orgname,
+			Network: "1.2.3.0/24, 1.2.4.0/23",
+		},
+		name: "network_arin_cidr",
+		data: "CIDR: 1.2.3.0/24, 1.2.4.0/23" + nl +
+			"NetRange: 1.2.3.0 - 1.2.5.255" + nl +
+			"OrgName: " + orgname + nl,
+	}, {
+		want: &whois.Info{
+			Network: "1.2.3.0/24",
+		},
+		name: "network_arin_netrange",
+		data: "NetRange: 1.2.3.0 - 1.2.3.255",
+	}, {
+		want: nil,
+		name: "network_bad",
+		data: "inetnum: 1.2.3.255 - 1.2.3.0" + nl +
+			"inetnum: 1.2.3.0 - 2001:db8::1" + nl +
+			"CIDR: 1.2.3.0/33" + nl,
+	}, {
+		want: &whois.Info{
+			AbuseEmail: "abuse@example.net",
+		},
+		name: "abuse_ripe",
+		data: "abuse-c: AR1234-RIPE" + nl +
+			"abuse-mailbox: abuse@example.net" + nl +
+			"abuse-mailbox: abuse@example.org" + nl,
+	}, {
+		want: &whois.Info{
+			Orgname:    orgname,
+			AbuseEmail: "abuse@example.net",
+		},
+		name: "abuse_arin",
+		data: "OrgName: " + orgname + nl +
+			"OrgAbuseEmail: abuse@example.net" + nl,
+	}, {
+		want: &whois.Info{
+			AbuseEmail: "abuse@example.net",
+		},
+		name: "abuse_c_email",
+		data: "abuse-c: abuse@example.net",
+	}, {
+		want: nil,
+		name: "abuse_c_handle",
+		data: "abuse-c: AR1234-RIPE",
 	}, {
 		want: nil,
 		name: "whois",
@@ -107,24 +247,14 @@ func TestDefault_Process(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			hit := 0
 
-			fakeConn := &fakenet.Conn{
-				OnRead: func(b []byte) (n int, err error) {
-					hit++
+			fakeConn := newFakeConn("")
+			fakeConn.OnRead = func(b []byte) (n int, err error) {
+				hit++
 
-					return copy(b, tc.data), io.EOF
-				},
-				OnWrite: func(b []byte) (n int, err error) {
-					return len(b), nil
-				},
-				OnClose: func() (err error) {
-					return nil
-				},
-				OnSetReadDeadline: func(t time.Time) (err error) {
-					return nil
-				},
+				return copy(b, tc.data), io.EOF
 			}
 
-			w := whois.New(&whois.Config{
+			w, err := whois.New(&whois.Config{
 				Timeout: 5 * time.Second,
 				DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
 					hit = 0
@@ -137,6 +267,7 @@ func TestDefault_Process(t *testing.T) {
 				CacheSize:       100,
 				CacheTTL:        time.Hour,
 			})
+			require.NoError(t, err)
 
 			got, changed := w.Process(context.Background(), ip)
 			require.True(t, changed)
@@ -153,3 +284,1209 @@ func TestDefault_Process(t *testing.T) {
 		})
 	}
 }
+
+func TestDefault_Process_serversFile(t *testing.T) {
+	fakeConn := newFakeConn("city: Nonreal")
+
+	var dialed string
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+			dialed = addr
+
+			return fakeConn, nil
+		},
+		ServerAddr:      "whois.default.example",
+		ServersFile:     "./testdata/servers.yaml",
+		MaxConnReadSize: 1024,
+		MaxRedirects:    3,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+		Port:            whois.DefaultPort,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		ip   netip.Addr
+		name string
+		want string
+	}{{
+		ip:   netip.MustParseAddr("1.2.3.4"),
+		name: "network",
+		want: "whois.example.net:43",
+	}, {
+		ip:   netip.MustParseAddr("5.6.7.8"),
+		name: "network_port",
+		want: "whois.example.org:4343",
+	}, {
+		ip:   netip.MustParseAddr("1.2.4.5"),
+		name: "default",
+		want: "whois.default.example:43",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, changed := w.Process(context.Background(), tc.ip)
+			require.True(t, changed)
+
+			assert.Equal(t, tc.want, dialed)
+		})
+	}
+}
+
+func TestDefault_Process_serverPorts(t *testing.T) {
+	const referral = "whois.example.net"
+
+	var dialed []string
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+			dialed = append(dialed, addr)
+
+			data := "city: Nonreal"
+			if len(dialed) == 1 {
+				data = "referralserver: whois://" + referral
+			}
+
+			return newFakeConn(data), nil
+		},
+		ServerAddr: whois.DefaultServer,
+		ServerPorts: map[string]uint16{
+			referral: 4343,
+		},
+		MaxConnReadSize: 1024,
+		MaxRedirects:    3,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+		Port:            whois.DefaultPort,
+	})
+	require.NoError(t, err)
+
+	got, changed := w.Process(context.Background(), netip.MustParseAddr("1.2.3.4"))
+	require.True(t, changed)
+	require.NotNil(t, got)
+
+	assert.Equal(t, "Nonreal", got.City)
+	assert.Equal(t, []string{"whois.arin.net:43", "whois.example.net:4343"}, dialed)
+}
+
+func TestDefault_Process_ignoreReferrals(t *testing.T) {
+	const data = "whois: whois.example.net\n" +
+		"referralserver: whois://whois.example.org\n" +
+		"city: Nonreal\n"
+
+	var dialed []string
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+			dialed = append(dialed, addr)
+
+			return newFakeConn(data), nil
+		},
+		ServerAddr:      whois.DefaultServer,
+		MaxConnReadSize: 1024,
+		MaxRedirects:    3,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+		Port:            whois.DefaultPort,
+		IgnoreReferrals: true,
+	})
+	require.NoError(t, err)
+
+	got, changed := w.Process(context.Background(), netip.MustParseAddr("1.2.3.4"))
+	require.True(t, changed)
+	require.NotNil(t, got)
+
+	assert.Equal(t, "Nonreal", got.City)
+	assert.Equal(t, []string{"whois.arin.net:43"}, dialed)
+}
+
+func TestDefault_ProcessDiagnostic(t *testing.T) {
+	const (
+		city     = "Nonreal"
+		referral = "whois.example.net"
+	)
+
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+			data := "whois: " + referral
+			if addr == referral+":43" {
+				data = "city: " + city
+			}
+
+			return newFakeConn(data), nil
+		},
+		ServerAddr:      whois.DefaultServer,
+		MaxConnReadSize: 1024,
+		MaxRedirects:    3,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+		Port:            whois.DefaultPort,
+	})
+	require.NoError(t, err)
+
+	ip := netip.MustParseAddr("1.2.3.4")
+	ctx := context.Background()
+
+	testCases := []struct {
+		wantDiag *whois.Diagnostics
+		name     string
+		server   string
+	}{{
+		wantDiag: &whois.Diagnostics{
+			Server:    referral + ":43",
+			Redirects: 1,
+		},
+		name:   "automatic",
+		server: "",
+	}, {
+		wantDiag: &whois.Diagnostics{
+			Server:    referral + ":43",
+			Redirects: 0,
+		},
+		name:   "forced",
+		server: referral,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, diag, procErr := w.ProcessDiagnostic(ctx, ip, tc.server)
+			require.NoError(t, procErr)
+			require.NotNil(t, info)
+
+			assert.Equal(t, city, info.City)
+			assert.Equal(t, tc.wantDiag, diag)
+		})
+	}
+
+	t.Run("forced_referral", func(t *testing.T) {
+		_, diag, procErr := w.ProcessDiagnostic(ctx, ip, whois.DefaultServer+":4343")
+		require.NoError(t, procErr)
+
+		assert.Equal(t, &whois.Diagnostics{
+			Server:    referral + ":43",
+			Redirects: 1,
+		}, diag)
+	})
+
+	t.Run("not_cached", func(t *testing.T) {
+		assert.Zero(t, w.Stats().Size)
+	})
+}
+
+func TestDefault_Process_rir(t *testing.T) {
+	const city = "Nonreal"
+
+	testCases := []struct {
+		responses   map[string]string
+		name        string
+		serversFile string
+		wantRIR     string
+		wantDialed  []string
+	}{{
+		responses: map[string]string{
+			"whois.arin.net:43": "city: " + city,
+		},
+		name:        "arin",
+		serversFile: "",
+		wantRIR:     "ARIN",
+		wantDialed:  []string{"whois.arin.net:43"},
+	}, {
+		responses: map[string]string{
+			"whois.arin.net:43":  "referralserver: whois://whois.apnic.net",
+			"whois.apnic.net:43": "whois: whois.ripe.net",
+			"whois.ripe.net:43":  "city: " + city,
+		},
+		name:        "redirect_chain",
+		serversFile: "",
+		wantRIR:     "RIPE",
+		wantDialed: []string{
+			"whois.arin.net:43",
+			"whois.apnic.net:43",
+			"whois.ripe.net:43",
+		},
+	}, {
+		responses: map[string]string{
+			"whois.arin.net:43":     "whois: whois.ripe.net",
+			"whois.ripe.example:43": "city: " + city,
+		},
+		name:        "replaced_rir",
+		serversFile: "./testdata/servers.yaml",
+		wantRIR:     "RIPE",
+		wantDialed:  []string{"whois.arin.net:43", "whois.ripe.example:43"},
+	}, {
+		responses: map[string]string{
+			"whois.arin.net:43":    "referralserver: whois://whois.example.com",
+			"whois.example.com:43": "city: " + city,
+		},
+		name:        "not_rir",
+		serversFile: "",
+		wantRIR:     "",
+		wantDialed:  []string{"whois.arin.net:43", "whois.example.com:43"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var dialed []string
+			w, err := whois.New(&whois.Config{
+				Timeout: 5 * time.Second,
+				DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+					dialed = append(dialed, addr)
+					data := tc.responses[addr]
+
+					return newFakeConn(data), nil
+				},
+				ServerAddr:      whois.DefaultServer,
+				ServersFile:     tc.serversFile,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    5,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				Port:            whois.DefaultPort,
+			})
+			require.NoError(t, err)
+
+			got, changed := w.Process(context.Background(), netip.MustParseAddr("9.9.9.9"))
+			require.True(t, changed)
+			require.NotNil(t, got)
+
+			assert.Equal(t, city, got.City)
+			assert.Equal(t, tc.wantRIR, got.RIR)
+			assert.Equal(t, tc.wantDialed, dialed)
+		})
+	}
+}
+
+func TestDefault_Process_ipv6(t *testing.T) {
+	const (
+		compressed = "2a00:1450:4001:82b::200e"
+		expanded   = "2a00:1450:4001:082b:0000:0000:0000:200e"
+	)
+
+	testCases := []struct {
+		name        string
+		data        map[string]string
+		ip          netip.Addr
+		wantQueries []string
+		wantCity    string
+	}{{
+		name: "compressed",
+		data: map[string]string{
+			"n + " + compressed: "city: Nonreal",
+		},
+		ip:          netip.MustParseAddr(compressed),
+		wantQueries: []string{"n + " + compressed + "\r\n"},
+		wantCity:    "Nonreal",
+	}, {
+		name: "expanded",
+		data: map[string]string{
+			"n + " + expanded: "city: Nonreal",
+		},
+		ip: netip.MustParseAddr(compressed),
+		wantQueries: []string{
+			"n + " + compressed + "\r\n",
+			"n + " + expanded + "\r\n",
+		},
+		wantCity: "Nonreal",
+	}, {
+		name: "zone",
+		data: map[string]string{
+			"n + " + compressed: "city: Nonreal",
+		},
+		ip:          netip.MustParseAddr(compressed + "%eth0"),
+		wantQueries: []string{"n + " + compressed + "\r\n"},
+		wantCity:    "Nonreal",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var queries []string
+			w, err := whois.New(&whois.Config{
+				Timeout: 5 * time.Second,
+				DialContext: func(_ context.Context, _, _ string) (_ net.Conn, _ error) {
+					var data string
+
+					conn := newFakeConn("")
+					conn.OnRead = func(b []byte) (n int, err error) {
+						return copy(b, data), io.EOF
+					}
+					conn.OnWrite = func(b []byte) (n int, err error) {
+						q := string(b)
+						queries = append(queries, q)
+						data = tc.data[strings.TrimSuffix(q, "\r\n")]
+
+						return len(b), nil
+					}
+
+					return conn, nil
+				},
+				ServerAddr:      whois.DefaultServer,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    3,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				Port:            whois.DefaultPort,
+			})
+			require.NoError(t, err)
+
+			got, changed := w.Process(context.Background(), tc.ip)
+			require.True(t, changed)
+			require.NotNil(t, got)
+
+			assert.Equal(t, tc.wantCity, got.City)
+			assert.Equal(t, tc.wantQueries, queries)
+		})
+	}
+}
+
+func TestDefault_Process_queryTemplates(t *testing.T) {
+	const target = "1.2.3.4"
+
+	testCases := []struct {
+		tmpls     map[string]string
+		name      string
+		server    string
+		wantQuery string
+	}{{
+		tmpls:     nil,
+		name:      "arin_default",
+		server:    whois.DefaultServer,
+		wantQuery: "n + " + target,
+	}, {
+		tmpls:     nil,
+		name:      "no_template",
+		server:    "whois.ripe.net",
+		wantQuery: target,
+	}, {
+		tmpls: map[string]string{
+			"WHOIS.RIPE.NET": "-V Md5.2 " + whois.QueryPlaceholder,
+		},
+		name:      "custom",
+		server:    "whois.ripe.net",
+		wantQuery: "-V Md5.2 " + target,
+	}, {
+		tmpls: map[string]string{
+			whois.DefaultServer: "=" + whois.QueryPlaceholder,
+		},
+		name:      "arin_override",
+		server:    whois.DefaultServer,
+		wantQuery: "=" + target,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var queries []string
+			w, err := whois.New(&whois.Config{
+				Timeout: 5 * time.Second,
+				DialContext: func(_ context.Context, _, _ string) (_ net.Conn, _ error) {
+					conn := newFakeConn("city: Nonreal")
+					conn.OnWrite = func(b []byte) (n int, err error) {
+						queries = append(queries, string(b))
+
+						return len(b), nil
+					}
+
+					return conn, nil
+				},
+				ServerAddr:      tc.server,
+				QueryTemplates:  tc.tmpls,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    3,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				Port:            whois.DefaultPort,
+			})
+			require.NoError(t, err)
+
+			got, changed := w.Process(context.Background(), netip.MustParseAddr(target))
+			require.True(t, changed)
+			require.NotNil(t, got)
+
+			assert.Equal(t, []string{tc.wantQuery + "\r\n"}, queries)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := whois.New(&whois.Config{
+			QueryTemplates: map[string]string{
+				"whois.ripe.net": "-V Md5.2",
+			},
+		})
+		testutil.AssertErrorMsg(
+			t,
+			`whois: server "whois.ripe.net": bad query template "-V Md5.2": `+
+				`no placeholder {target}`,
+			err,
+		)
+	})
+}
+
+func TestDefault_Process_prelude(t *testing.T) {
+	const (
+		mirror    = "whois.corp.example:4343"
+		referral  = "whois.ripe.net"
+		target    = "1.2.3.4"
+		authToken = "AUTH secret"
+	)
+
+	writes := map[string][]string{}
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+			resp := "city: Nonreal"
+			if addr == mirror {
+				resp = "referralserver: whois://" + referral
+			}
+
+			conn := newFakeConn(resp)
+			conn.OnWrite = func(b []byte) (n int, err error) {
+				writes[addr] = append(writes[addr], string(b))
+
+				return len(b), nil
+			}
+
+			return conn, nil
+		},
+		ServerAddr:      mirror,
+		Prelude:         []string{authToken, "MODE plain"},
+		MaxConnReadSize: 1024,
+		MaxRedirects:    3,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+		Port:            whois.DefaultPort,
+	})
+	require.NoError(t, err)
+
+	got, _ := w.Process(context.Background(), netip.MustParseAddr(target))
+	require.NotNil(t, got)
+
+	assert.Equal(t, "Nonreal", got.City)
+	assert.Equal(t, map[string][]string{
+		mirror:           {authToken + "\r\n" + "MODE plain\r\n" + target + "\r\n"},
+		referral + ":43": {target + "\r\n"},
+	}, writes)
+}
+
+func TestDefault_Process_failures(t *testing.T) {
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	testCases := []struct {
+		dialErr      error
+		name         string
+		data         string
+		transientTTL time.Duration
+		wantDials    int
+	}{{
+		dialErr: &net.OpError{
+			Op:  "dial",
+			Net: "tcp",
+			Err: errors.Error("network is unreachable"),
+		},
+		name:         "transient_not_cached",
+		data:         "",
+		transientTTL: 0,
+		wantDials:    2,
+	}, {
+		dialErr: &net.OpError{
+			Op:  "dial",
+			Net: "tcp",
+			Err: errors.Error("network is unreachable"),
+		},
+		name:         "transient_cached",
+		data:         "",
+		transientTTL: time.Minute,
+		wantDials:    1,
+	}, {
+		dialErr:      nil,
+		name:         "permanent",
+		data:         "whois: " + whois.DefaultServer,
+		transientTTL: 0,
+		wantDials:    1,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dials := 0
+			w, err := whois.New(&whois.Config{
+				Timeout: 5 * time.Second,
+				DialContext: func(_ context.Context, _, _ string) (_ net.Conn, err error) {
+					dials++
+					if tc.dialErr != nil {
+						return nil, tc.dialErr
+					}
+
+					return newFakeConn(tc.data), nil
+				},
+				ServerAddr:      whois.DefaultServer,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    1,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				TransientTTL:    tc.transientTTL,
+			})
+			require.NoError(t, err)
+
+			got, _ := w.Process(context.Background(), ip)
+			require.Nil(t, got)
+
+			got, _ = w.Process(context.Background(), ip)
+			require.Nil(t, got)
+
+			assert.Equal(t, tc.wantDials, dials)
+		})
+	}
+}
+
+func TestDefault_Process_partial(t *testing.T) {
+	const (
+		partial = "orgname: FakeOrgLLC\ncity: Nonreal\n"
+		timeout = 50 * time.Millisecond
+	)
+
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	testCases := []struct {
+		readErr error
+		want    *whois.Info
+		name    string
+	}{{
+		readErr: os.ErrDeadlineExceeded,
+		want: &whois.Info{
+			City:    "Nonreal",
+			Orgname: "FakeOrgLLC",
+			RIR:     "ARIN",
+		},
+		name: "timeout",
+	}, {
+		readErr: errors.Error("connection reset"),
+		want:    nil,
+		name:    "other_error",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := whois.New(&whois.Config{
+				Timeout: timeout,
+				DialContext: func(_ context.Context, _, _ string) (_ net.Conn, err error) {
+					var deadline time.Time
+					sent := false
+
+					conn := newFakeConn("")
+					conn.OnRead = func(b []byte) (n int, err error) {
+						if !sent {
+							sent = true
+
+							return copy(b, partial), nil
+						}
+
+						// Hold the connection open until the deadline.
+						time.Sleep(time.Until(deadline))
+
+						return 0, tc.readErr
+					}
+					conn.OnSetReadDeadline = func(t time.Time) (err error) {
+						deadline = t
+
+						return nil
+					}
+
+					return conn, nil
+				},
+				ServerAddr:      whois.DefaultServer,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    2,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				Port:            whois.DefaultPort,
+			})
+			require.NoError(t, err)
+
+			got, _ := w.Process(context.Background(), ip)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDefault_Flush(t *testing.T) {
+	var (
+		ip1 = netip.MustParseAddr("1.2.3.4")
+		ip2 = netip.MustParseAddr("5.6.7.8")
+	)
+
+	dials := 0
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, _ string) (_ net.Conn, err error) {
+			dials++
+
+			return newFakeConn("city: Nonreal"), nil
+		},
+		ServerAddr:      whois.DefaultServer,
+		MaxConnReadSize: 1024,
+		MaxRedirects:    1,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, ip := range []netip.Addr{ip1, ip2} {
+		_, changed := w.Process(ctx, ip)
+		require.True(t, changed)
+	}
+
+	require.Equal(t, 2, dials)
+
+	t.Run("cached", func(t *testing.T) {
+		_, changed := w.Process(ctx, ip1)
+		assert.False(t, changed)
+		assert.Equal(t, 2, dials)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		assert.Equal(t, &whois.CacheStats{
+			Size:   2,
+			Hits:   1,
+			Misses: 2,
+		}, w.Stats())
+	})
+
+	t.Run("single", func(t *testing.T) {
+		w.Flush(ip1)
+
+		_, changed := w.Process(ctx, ip1)
+		assert.True(t, changed)
+		assert.Equal(t, 3, dials)
+
+		_, changed = w.Process(ctx, ip2)
+		assert.False(t, changed)
+		assert.Equal(t, 3, dials)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		w.Flush(netip.Addr{})
+
+		for _, ip := range []netip.Addr{ip1, ip2} {
+			_, changed := w.Process(ctx, ip)
+			assert.True(t, changed)
+		}
+
+		assert.Equal(t, 5, dials)
+	})
+}
+
+func TestDefault_ProcessForced_errors(t *testing.T) {
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	unreachableErr := &net.OpError{
+		Op:  "dial",
+		Net: "tcp",
+		Err: errors.Error("network is unreachable"),
+	}
+
+	testCases := []struct {
+		dialErr error
+		readErr error
+		wantErr error
+		name    string
+		data    string
+	}{{
+		dialErr: unreachableErr,
+		readErr: nil,
+		wantErr: whois.ErrDial,
+		name:    "dial",
+		data:    "",
+	}, {
+		dialErr: context.DeadlineExceeded,
+		readErr: nil,
+		wantErr: whois.ErrTimeout,
+		name:    "dial_timeout",
+		data:    "",
+	}, {
+		dialErr: nil,
+		readErr: os.ErrDeadlineExceeded,
+		wantErr: whois.ErrTimeout,
+		name:    "read_timeout",
+		data:    "",
+	}, {
+		dialErr: nil,
+		readErr: io.EOF,
+		wantErr: whois.ErrRedirectLoop,
+		name:    "redirect_loop",
+		data:    "whois: " + whois.DefaultServer,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := whois.New(&whois.Config{
+				Timeout: 5 * time.Second,
+				DialContext: func(_ context.Context, _, _ string) (_ net.Conn, err error) {
+					if tc.dialErr != nil {
+						return nil, tc.dialErr
+					}
+
+					conn := newFakeConn("")
+					conn.OnRead = func(b []byte) (n int, err error) {
+						return copy(b, tc.data), tc.readErr
+					}
+
+					return conn, nil
+				},
+				ServerAddr:      whois.DefaultServer,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    2,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				Port:            whois.DefaultPort,
+			})
+			require.NoError(t, err)
+
+			_, err = w.ProcessForced(context.Background(), ip, whois.DefaultServer)
+			assert.ErrorIs(t, err, tc.wantErr)
+
+			for _, other := range []error{
+				whois.ErrDial,
+				whois.ErrTimeout,
+				whois.ErrRedirectLoop,
+			} {
+				if other != tc.wantErr {
+					assert.NotErrorIs(t, err, other)
+				}
+			}
+
+			if tc.dialErr != nil {
+				assert.ErrorIs(t, err, tc.dialErr)
+			}
+		})
+	}
+}
+
+func TestDefault_ProcessForced_canceled(t *testing.T) {
+	const referral = "whois.example.net"
+
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	newConn := func(onRead func(b []byte) (n int, err error)) (conn *fakenet.Conn) {
+		conn = newFakeConn("")
+		conn.OnRead = onRead
+
+		return conn
+	}
+
+	t.Run("between_hops", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var dialed []string
+		w, err := whois.New(&whois.Config{
+			Timeout: 5 * time.Second,
+			DialContext: func(_ context.Context, _, addr string) (_ net.Conn, _ error) {
+				dialed = append(dialed, addr)
+
+				return newConn(func(b []byte) (n int, err error) {
+					// Cancel the context before the referral is followed.
+					cancel()
+
+					return copy(b, "whois: "+referral), io.EOF
+				}), nil
+			},
+			ServerAddr:      whois.DefaultServer,
+			MaxConnReadSize: 1024,
+			MaxRedirects:    5,
+			MaxInfoLen:      250,
+			CacheSize:       100,
+			CacheTTL:        time.Hour,
+			Port:            whois.DefaultPort,
+		})
+		require.NoError(t, err)
+
+		_, err = w.ProcessForced(ctx, ip, whois.DefaultServer)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, whois.ErrTimeout)
+
+		assert.Equal(t, []string{whois.DefaultServer + ":43"}, dialed)
+	})
+
+	t.Run("reading", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		interrupted := make(chan struct{})
+		conn := newConn(func(_ []byte) (n int, err error) {
+			cancel()
+			<-interrupted
+
+			return 0, os.ErrDeadlineExceeded
+		})
+		conn.OnSetReadDeadline = func(t time.Time) (err error) {
+			if !t.After(time.Now()) {
+				close(interrupted)
+			}
+
+			return nil
+		}
+
+		w, err := whois.New(&whois.Config{
+			Timeout: time.Hour,
+			DialContext: func(_ context.Context, _, _ string) (_ net.Conn, _ error) {
+				return conn, nil
+			},
+			ServerAddr:      whois.DefaultServer,
+			MaxConnReadSize: 1024,
+			MaxRedirects:    5,
+			MaxInfoLen:      250,
+			CacheSize:       100,
+			CacheTTL:        time.Hour,
+			Port:            whois.DefaultPort,
+		})
+		require.NoError(t, err)
+
+		_, err = w.ProcessForced(ctx, ip, whois.DefaultServer)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDefault_SelfTest(t *testing.T) {
+	testCases := []struct {
+		dialErr    error
+		wantErr    error
+		name       string
+		data       string
+		wantErrMsg string
+	}{{
+		dialErr:    nil,
+		wantErr:    nil,
+		name:       "success",
+		data:       "OrgName: Google LLC\nCountry: US",
+		wantErrMsg: "",
+	}, {
+		dialErr:    errors.Error("network is unreachable"),
+		wantErr:    whois.ErrDial,
+		name:       "dial",
+		data:       "",
+		wantErrMsg: "",
+	}, {
+		dialErr:    nil,
+		wantErr:    whois.ErrEmptyResponse,
+		name:       "empty",
+		data:       "% no entries found",
+		wantErrMsg: "whois: self-test about 8.8.8.8: empty response",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var queried string
+			w, err := whois.New(&whois.Config{
+				Timeout: 5 * time.Second,
+				DialContext: func(_ context.Context, _, _ string) (_ net.Conn, err error) {
+					if tc.dialErr != nil {
+						return nil, tc.dialErr
+					}
+
+					conn := newFakeConn(tc.data)
+					conn.OnWrite = func(b []byte) (n int, err error) {
+						queried = string(b)
+
+						return len(b), nil
+					}
+
+					return conn, nil
+				},
+				ServerAddr:      whois.DefaultServer,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    3,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				Port:            whois.DefaultPort,
+			})
+			require.NoError(t, err)
+
+			err = w.SelfTest(context.Background())
+			if tc.wantErr == nil {
+				require.NoError(t, err)
+				assert.Contains(t, queried, "8.8.8.8")
+
+				// The result of the self-test must not be cached.
+				assert.Zero(t, w.Stats().Size)
+
+				return
+			}
+
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErrMsg != "" {
+				testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestDefault_Process_countryFormat(t *testing.T) {
+	testCases := []struct {
+		name    string
+		country string
+		format  whois.CountryFormat
+		want    string
+	}{{
+		name:    "raw_code",
+		country: "us",
+		format:  whois.CountryFormatRaw,
+		want:    "us",
+	}, {
+		name:    "code_to_name",
+		country: "us",
+		format:  whois.CountryFormatName,
+		want:    "United States",
+	}, {
+		name:    "name_to_name",
+		country: "UNITED STATES",
+		format:  whois.CountryFormatName,
+		want:    "United States",
+	}, {
+		name:    "name_to_code",
+		country: "Netherlands",
+		format:  whois.CountryFormatCode,
+		want:    "NL",
+	}, {
+		name:    "code_to_code",
+		country: "nl",
+		format:  whois.CountryFormatCode,
+		want:    "NL",
+	}, {
+		name:    "unknown",
+		country: "Atlantis",
+		format:  whois.CountryFormatCode,
+		want:    "Atlantis",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := "country: " + tc.country
+			w, err := whois.New(&whois.Config{
+				Timeout: 5 * time.Second,
+				DialContext: func(_ context.Context, _, _ string) (_ net.Conn, _ error) {
+					return newFakeConn(data), nil
+				},
+				ServerAddr:      whois.DefaultServer,
+				MaxConnReadSize: 1024,
+				MaxRedirects:    1,
+				MaxInfoLen:      250,
+				CacheSize:       100,
+				CacheTTL:        time.Hour,
+				CountryFormat:   tc.format,
+			})
+			require.NoError(t, err)
+
+			got, _ := w.Process(context.Background(), netip.MustParseAddr("1.2.3.4"))
+			require.NotNil(t, got)
+
+			assert.Equal(t, tc.want, got.Country)
+		})
+	}
+
+	t.Run("bad_format", func(t *testing.T) {
+		_, err := whois.New(&whois.Config{
+			CountryFormat: "flag",
+		})
+		testutil.AssertErrorMsg(t, `whois: unsupported country format "flag"`, err)
+	})
+}
+
+func TestDefault_Process_maxInfoLens(t *testing.T) {
+	const (
+		city    = "Llanfairpwllgwyngyll"
+		orgname = "The Very Long Name of the Fake Organization LLC"
+		country = "Imagiland"
+	)
+
+	data := strings.Join([]string{
+		"city: " + city,
+		"orgname: " + orgname,
+		"country: " + country,
+	}, "\n")
+
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, _ string) (_ net.Conn, _ error) {
+			return newFakeConn(data), nil
+		},
+		ServerAddr:      whois.DefaultServer,
+		MaxConnReadSize: 1024,
+		MaxRedirects:    1,
+		MaxInfoLen:      16,
+		MaxInfoLens: whois.InfoLens{
+			City:    10,
+			Orgname: 100,
+		},
+		CacheSize: 100,
+		CacheTTL:  time.Hour,
+	})
+	require.NoError(t, err)
+
+	got, _ := w.Process(context.Background(), netip.MustParseAddr("1.2.3.4"))
+	require.NotNil(t, got)
+
+	assert.Equal(t, "Llanfai...", got.City)
+	assert.Equal(t, orgname, got.Orgname)
+	assert.Equal(t, country, got.Country)
+}
+
+func TestDefault_Process_maxConns(t *testing.T) {
+	const (
+		maxConns = 2
+		numIPs   = 10
+	)
+
+	var open, maxOpen atomic.Int32
+	unblock := make(chan struct{})
+
+	w, err := whois.New(&whois.Config{
+		Timeout: 5 * time.Second,
+		DialContext: func(_ context.Context, _, _ string) (_ net.Conn, _ error) {
+			n := open.Add(1)
+			for m := maxOpen.Load(); n > m && !maxOpen.CompareAndSwap(m, n); {
+				m = maxOpen.Load()
+			}
+
+			conn := newFakeConn("")
+			conn.OnRead = func(b []byte) (n int, err error) {
+				<-unblock
+
+				return copy(b, "city: Nonreal"), io.EOF
+			}
+			conn.OnClose = func() (err error) {
+				open.Add(-1)
+
+				return nil
+			}
+
+			return conn, nil
+		},
+		ServerAddr:      whois.DefaultServer,
+		MaxConnReadSize: 1024,
+		MaxRedirects:    1,
+		MaxConns:        maxConns,
+		MaxInfoLen:      250,
+		CacheSize:       100,
+		CacheTTL:        time.Hour,
+	})
+	require.NoError(t, err)
+
+	results := make(chan *whois.Info, numIPs)
+	for i := 1; i <= numIPs; i++ {
+		ip := netip.AddrFrom4([4]byte{1, 2, 3, byte(i)})
+		go func() {
+			info, _ := w.Process(context.Background(), ip)
+			results <- info
+		}()
+	}
+
+	require.Eventually(t, func() (ok bool) {
+		return open.Load() == maxConns
+	}, time.Second, time.Millisecond)
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		_, forcedErr := w.ProcessForced(ctx, netip.MustParseAddr("4.3.2.1"), whois.DefaultServer)
+		assert.ErrorIs(t, forcedErr, whois.ErrTimeout)
+	})
+
+	close(unblock)
+
+	for i := 0; i < numIPs; i++ {
+		info := <-results
+		require.NotNil(t, info)
+
+		assert.Equal(t, "Nonreal", info.City)
+	}
+
+	assert.Equal(t, int32(maxConns), maxOpen.Load())
+	assert.Zero(t, open.Load())
+}
+
+func TestValidateServer(t *testing.T) {
+	testCases := []struct {
+		name       string
+		addr       string
+		wantErrMsg string
+	}{{
+		name:       "hostname",
+		addr:       "whois.ripe.net",
+		wantErrMsg: "",
+	}, {
+		name:       "host_port",
+		addr:       "whois.ripe.net:43",
+		wantErrMsg: "",
+	}, {
+		name:       "empty",
+		addr:       "",
+		wantErrMsg: `bad server "": bad hostname "": hostname is empty`,
+	}, {
+		name: "bad_port",
+		addr: "whois.ripe.net:port",
+		wantErrMsg: `bad server "whois.ripe.net:port": parsing port: ` +
+			`strconv.ParseUint: parsing "port": invalid syntax`,
+	}, {
+		name:       "zero_port",
+		addr:       "whois.ripe.net:0",
+		wantErrMsg: `bad server "whois.ripe.net:0": port must not be zero`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := whois.ValidateServer(tc.addr)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
+
+func TestValidateQueryTemplate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tmpl       string
+		wantErrMsg string
+	}{{
+		name:       "plain",
+		tmpl:       whois.QueryPlaceholder,
+		wantErrMsg: "",
+	}, {
+		name:       "flags",
+		tmpl:       "-V Md5.2 " + whois.QueryPlaceholder,
+		wantErrMsg: "",
+	}, {
+		name:       "empty",
+		tmpl:       "",
+		wantErrMsg: `bad query template "": no placeholder {target}`,
+	}, {
+		name:       "no_placeholder",
+		tmpl:       "n + ",
+		wantErrMsg: `bad query template "n + ": no placeholder {target}`,
+	}, {
+		name: "line_break",
+		tmpl: whois.QueryPlaceholder + "\r\nhelp",
+		wantErrMsg: `bad query template "{target}\r\nhelp": ` +
+			`contains line breaks`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := whois.ValidateQueryTemplate(tc.tmpl)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}